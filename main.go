@@ -1,14 +1,20 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/mudler/localrecall/pkg/chunk"
 	"github.com/mudler/localrecall/rag"
 	"github.com/mudler/localrecall/rag/sources"
+	"github.com/mudler/xlog"
 	"github.com/sashabaranov/go-openai"
 )
 
@@ -22,11 +28,19 @@ var (
 	vectorEngine     = os.Getenv("VECTOR_ENGINE")
 	maxChunkingSize  = os.Getenv("MAX_CHUNKING_SIZE")
 	chunkOverlap     = os.Getenv("CHUNK_OVERLAP")
+	chunkingUnit     = os.Getenv("CHUNKING_UNIT")
+	tokenizerVocab   = os.Getenv("TOKENIZER_VOCAB_FILE")
 	apiKeys          = os.Getenv("API_KEYS")
+	jobsDir          = os.Getenv("JOBS_DIR")
+	ingestionWorkers = os.Getenv("INGESTION_WORKERS")
 	gitPrivateKey    = os.Getenv("GIT_PRIVATE_KEY")
-	sourceManager    = rag.NewSourceManager(&sources.Config{
-		GitPrivateKey: gitPrivateKey,
-	})
+	gitCacheDir      = os.Getenv("GIT_CACHE_DIR")
+	credentialsKey   = os.Getenv("SOURCE_CREDENTIALS_KEY")
+	sourceManager    *rag.SourceManager
+
+	// chunkTokenizer is loaded from TOKENIZER_VOCAB_FILE and measures chunk
+	// sizes of the collections that chunk by tokens.
+	chunkTokenizer chunk.Tokenizer
 )
 
 func init() {
@@ -40,6 +54,10 @@ func init() {
 		os.MkdirAll(fileAssets, 0755)
 	}
 
+	if jobsDir == "" {
+		jobsDir = filepath.Join(collectionDBPath, "jobs")
+	}
+
 	if listeningAddress == "" {
 		listeningAddress = ":8080"
 	}
@@ -48,10 +66,47 @@ func init() {
 		vectorEngine = "chromem"
 	}
 
+	if gitCacheDir == "" {
+		gitCacheDir = filepath.Join(collectionDBPath, "git")
+	}
+
+	key, err := loadCredentialsKey()
+	if err != nil {
+		xlog.Error("Failed to load the source credentials key, sources cannot take credentials", "error", err)
+	}
+
 	// Start the source manager
+	sourceManager = rag.NewSourceManager(&sources.Config{
+		GitPrivateKey:  gitPrivateKey,
+		GitCacheDir:    gitCacheDir,
+		CredentialsKey: key,
+	})
 	sourceManager.Start()
 }
 
+// loadCredentialsKey returns the key the credentials of external sources are
+// encrypted with: derived from SOURCE_CREDENTIALS_KEY when set, or else read
+// from a key file in the collections directory, generated on first use.
+func loadCredentialsKey() ([]byte, error) {
+	if credentialsKey != "" {
+		return sources.CredentialsKey(credentialsKey), nil
+	}
+	keyFile := filepath.Join(collectionDBPath, "source-credentials.key")
+	secret, err := os.ReadFile(keyFile)
+	if errors.Is(err, os.ErrNotExist) {
+		random := make([]byte, 32)
+		if _, err := rand.Read(random); err != nil {
+			return nil, err
+		}
+		secret = []byte(hex.EncodeToString(random))
+		err = os.WriteFile(keyFile, secret, 0600)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sources.CredentialsKey(strings.TrimSpace(string(secret))), nil
+}
+
 func startAPI(listenAddress string) {
 	e := echo.New()
 	e.Use(middleware.Logger())
@@ -87,7 +142,33 @@ func startAPI(listenAddress string) {
 		}
 	}
 
-	registerAPIRoutes(e, openAIClient, chunkingSize, overlap, keys)
+	workers := 2
+	if ingestionWorkers != "" {
+		var err error
+		workers, err = strconv.Atoi(ingestionWorkers)
+		if err != nil || workers < 1 {
+			e.Logger.Fatal("INGESTION_WORKERS must be a positive integer")
+		}
+	}
+
+	switch chunkingUnit {
+	case "", "characters", "tokens":
+	default:
+		e.Logger.Fatal("CHUNKING_UNIT must be either characters or tokens")
+	}
+	// The tokenizer is loaded whenever a vocabulary is configured, so
+	// collections can opt into token-based chunking on their own.
+	if tokenizerVocab != "" {
+		tokenizer, err := chunk.LoadWordPieceTokenizer(tokenizerVocab)
+		if err != nil {
+			e.Logger.Fatal("Failed to load tokenizer: ", err)
+		}
+		chunkTokenizer = tokenizer
+	} else if chunkingUnit == "tokens" {
+		e.Logger.Fatal("TOKENIZER_VOCAB_FILE is required when CHUNKING_UNIT is tokens")
+	}
+
+	registerAPIRoutes(e, openAIClient, chunkingSize, overlap, workers, keys)
 
 	e.Logger.Fatal(e.Start(listenAddress))
 }
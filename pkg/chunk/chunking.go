@@ -2,40 +2,118 @@ package chunk
 
 import (
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
+// Tokenizer counts the model tokens a piece of text encodes to. Counts are
+// expected to be additive across whitespace-separated words, which holds for
+// WordPiece and for BPE tokenizers that pre-split on whitespace.
+type Tokenizer interface {
+	CountTokens(s string) int
+}
+
 // Options configures paragraph chunking.
 type Options struct {
-	// MaxSize is the maximum characters per chunk (required, must be > 0).
+	// MaxSize is the maximum size per chunk (required, must be > 0), in characters
+	// (runes), or in tokens when Tokenizer is set.
 	MaxSize int
-	// Overlap is the overlap in characters between consecutive chunks, word-aligned (0 = no overlap).
+	// Overlap is the overlap between consecutive chunks, word-aligned (0 = no overlap),
+	// in the same unit as MaxSize.
 	// Must be < MaxSize; values >= MaxSize are clamped to MaxSize-1.
 	Overlap int
 	// SplitLongWords, when true, splits words longer than MaxSize into smaller chunks so no chunk exceeds MaxSize (default true).
 	SplitLongWords bool
+	// Tokenizer, when set, makes MaxSize and Overlap count tokens instead of characters,
+	// so chunks fit the token limit of the embedding model.
+	Tokenizer Tokenizer
+}
+
+// size returns the length of s in the unit MaxSize is expressed in: runes,
+// or tokens when a Tokenizer is set.
+func (o Options) size(s string) int {
+	if o.Tokenizer != nil {
+		return o.Tokenizer.CountTokens(s)
+	}
+	return utf8.RuneCountInString(s)
 }
 
-// splitLongString splits s into pieces of at most maxSize characters.
-// Returns a slice of substrings; each has length <= maxSize.
-func splitLongString(s string, maxSize int) []string {
-	if maxSize <= 0 || len(s) <= maxSize {
+// splitLongString splits s into pieces of at most maxSize characters (or
+// tokens, when opts has a Tokenizer). Cuts always fall on rune boundaries, so
+// every piece of valid UTF-8 input is valid UTF-8.
+// Returns a slice of substrings; each has size <= maxSize.
+func splitLongString(s string, maxSize int, opts Options) []string {
+	if maxSize <= 0 || opts.size(s) <= maxSize {
 		return []string{s}
 	}
+	if opts.Tokenizer != nil {
+		return splitLongStringByTokens(s, maxSize, opts)
+	}
+	runes := []rune(s)
 	var pieces []string
-	for len(s) > 0 {
+	for len(runes) > 0 {
 		n := maxSize
-		if n > len(s) {
-			n = len(s)
+		if n >= len(runes) {
+			n = len(runes)
+		} else {
+			n = graphemeCut(runes, n)
+		}
+		pieces = append(pieces, string(runes[:n]))
+		runes = runes[n:]
+	}
+	return pieces
+}
+
+// graphemeCut moves a cut before runes[n] back so it does not separate a
+// character from the combining marks, variation selectors or zero-width
+// joiners that follow it. If the whole prefix is one such cluster the cut is
+// left at n: a piece must never be empty.
+func graphemeCut(runes []rune, n int) int {
+	for cut := n; cut > 0; cut-- {
+		if !isClusterExtend(runes[cut]) && runes[cut-1] != zeroWidthJoiner {
+			return cut
+		}
+	}
+	return n
+}
+
+const zeroWidthJoiner = '\u200D'
+
+// isClusterExtend reports whether r extends the preceding character rather
+// than starting a new one.
+func isClusterExtend(r rune) bool {
+	return r == zeroWidthJoiner ||
+		unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) ||
+		unicode.Is(unicode.Variation_Selector, r) ||
+		(r >= 0x1F3FB && r <= 0x1F3FF) // emoji skin tone modifiers
+}
+
+// splitLongStringByTokens cuts s at rune boundaries into the longest prefixes
+// that stay within maxSize tokens. Prefix token counts grow (almost)
+// monotonically with length, so each cut is found by binary search.
+func splitLongStringByTokens(s string, maxSize int, opts Options) []string {
+	runes := []rune(s)
+	var pieces []string
+	for len(runes) > 0 {
+		lo, hi := 1, len(runes)
+		for lo < hi {
+			mid := (lo + hi + 1) / 2
+			if opts.size(string(runes[:mid])) <= maxSize {
+				lo = mid
+			} else {
+				hi = mid - 1
+			}
 		}
-		pieces = append(pieces, s[:n])
-		s = s[n:]
+		pieces = append(pieces, string(runes[:lo]))
+		runes = runes[lo:]
 	}
 	return pieces
 }
 
-// overlapTail returns the suffix of chunk that is at most overlap characters and word-aligned (whole words only).
+// overlapTail returns the suffix of chunk that is at most overlap characters (or
+// tokens) and word-aligned (whole words only).
 // If overlap is 0 or chunk is empty, returns "".
-func overlapTail(chunk string, overlap int) string {
+func overlapTail(chunk string, overlap int, opts Options) string {
 	if overlap <= 0 || chunk == "" {
 		return ""
 	}
@@ -43,14 +121,15 @@ func overlapTail(chunk string, overlap int) string {
 	if len(words) == 0 {
 		return ""
 	}
+	sepLen := opts.size(" ")
 	// Take words from the end until we would exceed overlap (length includes spaces between words).
 	var tail []string
 	length := 0
 	for i := len(words) - 1; i >= 0; i-- {
 		w := words[i]
-		addLen := len(w)
+		addLen := opts.size(w)
 		if len(tail) > 0 {
-			addLen++ // space before this word
+			addLen += sepLen // space before this word
 		}
 		if length+addLen > overlap {
 			break
@@ -62,7 +141,8 @@ func overlapTail(chunk string, overlap int) string {
 }
 
 // SplitParagraphIntoChunksWithOptions splits a paragraph into chunks according to opts.
-// Chunks are word-boundary aligned; consecutive chunks may overlap by opts.Overlap characters (word-aligned).
+// Chunks are word-boundary aligned; consecutive chunks may overlap by opts.Overlap characters
+// or tokens (word-aligned).
 // Words longer than opts.MaxSize are split into smaller chunks when opts.SplitLongWords is true.
 func SplitParagraphIntoChunksWithOptions(paragraph string, opts Options) []string {
 	maxSize := opts.MaxSize
@@ -77,16 +157,18 @@ func SplitParagraphIntoChunksWithOptions(paragraph string, opts Options) []strin
 		overlap = 0
 	}
 	splitLongWords := opts.SplitLongWords
+	sepLen := opts.size(" ")
+	paragraph = strings.ToValidUTF8(paragraph, string(utf8.RuneError))
 
 	// Empty or single-chunk within limit (no overlap needed)
 	if paragraph == "" {
 		return []string{""}
 	}
-	if len(paragraph) <= maxSize && overlap == 0 {
+	if overlap == 0 && opts.size(paragraph) <= maxSize {
 		words := strings.Fields(paragraph)
 		needSplit := false
 		for _, w := range words {
-			if len(w) > maxSize && splitLongWords {
+			if splitLongWords && opts.size(w) > maxSize {
 				needSplit = true
 				break
 			}
@@ -99,26 +181,34 @@ func SplitParagraphIntoChunksWithOptions(paragraph string, opts Options) []strin
 	words := strings.Fields(paragraph)
 	var chunks []string
 	var currentChunk strings.Builder
+	currentLen := 0
 	var overlapPrefix string // word-aligned prefix for next chunk (from previous chunk's tail)
 
+	flush := func() {
+		chunks = append(chunks, currentChunk.String())
+		if overlap > 0 {
+			overlapPrefix = overlapTail(currentChunk.String(), overlap, opts)
+		} else {
+			overlapPrefix = ""
+		}
+		currentChunk.Reset()
+		currentLen = 0
+	}
+
 	for _, word := range words {
+		wordLen := opts.size(word)
+
 		// Long word: split into pieces when SplitLongWords is true
-		if len(word) > maxSize && splitLongWords {
+		if wordLen > maxSize && splitLongWords {
 			// Flush current chunk first
 			if currentChunk.Len() > 0 {
-				chunks = append(chunks, currentChunk.String())
-				if overlap > 0 {
-					overlapPrefix = overlapTail(currentChunk.String(), overlap)
-				} else {
-					overlapPrefix = ""
-				}
-				currentChunk.Reset()
+				flush()
 			}
-			pieces := splitLongString(word, maxSize)
+			pieces := splitLongString(word, maxSize, opts)
 			for _, p := range pieces {
 				chunks = append(chunks, p)
 				if overlap > 0 {
-					overlapPrefix = overlapTail(p, overlap)
+					overlapPrefix = overlapTail(p, overlap, opts)
 				}
 			}
 			continue
@@ -127,34 +217,29 @@ func SplitParagraphIntoChunksWithOptions(paragraph string, opts Options) []strin
 		// Normal word: compute length if we add this word
 		var nextLen int
 		if currentChunk.Len() > 0 {
-			nextLen = currentChunk.Len() + 1 + len(word)
+			nextLen = currentLen + sepLen + wordLen
 		} else if overlapPrefix != "" {
-			nextLen = len(overlapPrefix) + 1 + len(word)
+			nextLen = opts.size(overlapPrefix) + sepLen + wordLen
 		} else {
-			nextLen = len(word)
+			nextLen = wordLen
 		}
 
 		if nextLen > maxSize {
 			// Flush current chunk
 			if currentChunk.Len() > 0 {
-				chunks = append(chunks, currentChunk.String())
-				if overlap > 0 {
-					overlapPrefix = overlapTail(currentChunk.String(), overlap)
-				} else {
-					overlapPrefix = ""
-				}
-				currentChunk.Reset()
+				flush()
 			}
 			// Start new chunk with overlap prefix only if it fits with the word
-			if overlapPrefix != "" && len(overlapPrefix)+1+len(word) <= maxSize {
+			if overlapPrefix != "" && opts.size(overlapPrefix)+sepLen+wordLen <= maxSize {
 				currentChunk.WriteString(overlapPrefix)
 				currentChunk.WriteString(" ")
 				currentChunk.WriteString(word)
-				overlapPrefix = ""
+				currentLen = opts.size(overlapPrefix) + sepLen + wordLen
 			} else {
 				currentChunk.WriteString(word)
-				overlapPrefix = ""
+				currentLen = wordLen
 			}
+			overlapPrefix = ""
 		} else {
 			if currentChunk.Len() == 0 && overlapPrefix != "" {
 				currentChunk.WriteString(overlapPrefix)
@@ -167,6 +252,7 @@ func SplitParagraphIntoChunksWithOptions(paragraph string, opts Options) []strin
 			} else {
 				currentChunk.WriteString(word)
 			}
+			currentLen = nextLen
 		}
 	}
 
@@ -0,0 +1,483 @@
+package chunk
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Chunk metadata keys of source code chunks: the language of the file, the
+// symbol (function, method or type, such as "Server.Start") a chunk was
+// taken from and the first and last line (1-based) it spans.
+const (
+	LanguageKey  = "language"
+	SymbolKey    = "symbol"
+	LineStartKey = "line_start"
+	LineEndKey   = "line_end"
+)
+
+// codeLanguages maps source file extensions to their language.
+var codeLanguages = map[string]string{
+	".go": "go", ".py": "python", ".rb": "ruby",
+	".js": "javascript", ".mjs": "javascript", ".cjs": "javascript", ".jsx": "javascript",
+	".ts": "typescript", ".tsx": "typescript",
+	".c": "c", ".h": "c", ".cc": "cpp", ".cpp": "cpp", ".hpp": "cpp",
+	".java": "java", ".cs": "csharp", ".php": "php", ".rs": "rust",
+	".swift": "swift", ".kt": "kotlin", ".scala": "scala",
+	".sh": "shell", ".bash": "shell", ".sql": "sql", ".proto": "protobuf", ".css": "css",
+}
+
+// indentLanguages delimit blocks by indentation rather than braces.
+var indentLanguages = map[string]bool{"python": true, "ruby": true}
+
+// CodeLanguage returns the language of a source file from its extension, or
+// "" if it is not a source file.
+func CodeLanguage(path string) string {
+	return codeLanguages[strings.ToLower(filepath.Ext(path))]
+}
+
+// codeUnit is a range of lines [start, end) of a source file holding a
+// top-level declaration, or the code between two declarations.
+type codeUnit struct {
+	symbol     string
+	start, end int
+	// body is the range of lines inside the declaration's block, used to
+	// split it further when it does not fit in a chunk.
+	bodyStart, bodyEnd int
+}
+
+// SplitCodeIntoChunks splits a source file along its function and type
+// boundaries: every top-level declaration, with the comments above it, is a
+// chunk of its own. Go is parsed with go/ast; other languages are split with
+// brace matching or, for Python and Ruby, indentation. A declaration larger
+// than opts.MaxSize is split along the declarations of its block (the
+// methods of a class) when it has some, and by lines otherwise. Chunk
+// metadata records the language, the symbol under SymbolKey and the lines
+// under LineStartKey and LineEndKey. Overlap is not applied.
+func SplitCodeIntoChunks(source, language string, opts Options) []Chunk {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = 1
+	}
+	lines := strings.Split(strings.ReplaceAll(source, "\r\n", "\n"), "\n")
+
+	var units []codeUnit
+	var ok bool
+	if language == "go" {
+		units, ok = goUnits(source, lines)
+	}
+	if !ok {
+		units = heuristicUnits(lines, 0, len(lines), language)
+	}
+
+	var chunks []Chunk
+	for _, unit := range units {
+		chunks = append(chunks, codeChunks(lines, unit, language, opts)...)
+	}
+	for i := range chunks {
+		if language != "" {
+			chunks[i].Metadata[LanguageKey] = language
+		}
+	}
+	return chunks
+}
+
+// codeChunks turns a unit into chunks, splitting it when it is too large.
+func codeChunks(lines []string, unit codeUnit, language string, opts Options) []Chunk {
+	start, end := trimBlankLines(lines, unit.start, unit.end)
+	if start >= end {
+		return nil
+	}
+	text := strings.Join(lines[start:end], "\n")
+	if opts.size(text) <= opts.MaxSize {
+		return []Chunk{codeChunk(text, unit.symbol, start, end-1)}
+	}
+
+	if unit.bodyEnd > unit.bodyStart {
+		inner := heuristicUnits(lines, unit.bodyStart, unit.bodyEnd, language)
+		if len(inner) > 1 {
+			// The declaration's header joins its first inner unit and its
+			// closing lines join the last one.
+			inner[0].start = unit.start
+			inner[len(inner)-1].end = unit.end
+			var chunks []Chunk
+			for _, u := range inner {
+				if u.symbol == "" {
+					u.symbol = unit.symbol
+				} else if unit.symbol != "" {
+					u.symbol = unit.symbol + "." + u.symbol
+				}
+				chunks = append(chunks, codeChunks(lines, u, language, opts)...)
+			}
+			return chunks
+		}
+	}
+
+	var chunks []Chunk
+	var piece []string
+	pieceStart := start
+	flush := func(next int) {
+		if len(piece) > 0 {
+			chunks = append(chunks, codeChunk(strings.Join(piece, "\n"), unit.symbol, pieceStart, next-1))
+		}
+		piece, pieceStart = nil, next
+	}
+	for i := start; i < end; i++ {
+		line := lines[i]
+		if opts.size(line) > opts.MaxSize {
+			flush(i)
+			for _, part := range splitLongString(line, opts.MaxSize, opts) {
+				chunks = append(chunks, codeChunk(part, unit.symbol, i, i))
+			}
+			pieceStart = i + 1
+			continue
+		}
+		if len(piece) > 0 && opts.size(strings.Join(append(piece, line), "\n")) > opts.MaxSize {
+			flush(i)
+		}
+		piece = append(piece, line)
+	}
+	flush(end)
+	return chunks
+}
+
+func codeChunk(text, symbol string, first, last int) Chunk {
+	meta := map[string]string{
+		LineStartKey: strconv.Itoa(first + 1),
+		LineEndKey:   strconv.Itoa(last + 1),
+	}
+	if symbol != "" {
+		meta[SymbolKey] = symbol
+	}
+	return Chunk{Content: text, Metadata: meta}
+}
+
+func trimBlankLines(lines []string, start, end int) (int, int) {
+	for start < end && strings.TrimSpace(lines[start]) == "" {
+		start++
+	}
+	for end > start && strings.TrimSpace(lines[end-1]) == "" {
+		end--
+	}
+	return start, end
+}
+
+// goUnits splits a Go file at its top-level declarations. Each unit runs from
+// the end of the previous declaration, so it holds the comments above its
+// own. It reports false if the file does not parse.
+func goUnits(source string, lines []string) ([]codeUnit, bool) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", source, parser.ParseComments)
+	if err != nil {
+		return nil, false
+	}
+	line := func(pos token.Pos) int { return fset.Position(pos).Line }
+
+	// The package clause and imports form the first unit.
+	prev := line(file.Name.End())
+	units := []codeUnit{{start: 0, end: prev}}
+	for _, decl := range file.Decls {
+		end := line(decl.End())
+		unit := codeUnit{start: prev, end: end}
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			unit.symbol = d.Name.Name
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				unit.symbol = receiverName(d.Recv.List[0].Type) + "." + d.Name.Name
+			}
+		case *ast.GenDecl:
+			if d.Tok == token.IMPORT {
+				break
+			}
+			var names []string
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					names = append(names, s.Name.Name)
+				case *ast.ValueSpec:
+					for _, name := range s.Names {
+						names = append(names, name.Name)
+					}
+				}
+			}
+			unit.symbol = strings.Join(names, ", ")
+		}
+		if unit.symbol == "" && units[len(units)-1].symbol == "" {
+			units[len(units)-1].end = end
+		} else {
+			units = append(units, unit)
+		}
+		prev = end
+	}
+	units[len(units)-1].end = len(lines)
+	return units, true
+}
+
+// receiverName returns the type name of a method receiver.
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(t.X)
+	case *ast.IndexExpr:
+		return receiverName(t.X)
+	case *ast.IndexListExpr:
+		return receiverName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+// heuristicUnits splits lines [from, to) into declarations by brace matching
+// or, for indentation-delimited languages, by indentation. Consecutive units
+// without a symbol, such as imports and statements, are merged.
+func heuristicUnits(lines []string, from, to int, language string) []codeUnit {
+	var units []codeUnit
+	if indentLanguages[language] {
+		units = indentUnits(lines, from, to)
+	} else {
+		units = braceUnits(lines, from, to)
+	}
+	var merged []codeUnit
+	for _, unit := range units {
+		if n := len(merged); n > 0 && unit.symbol == "" && merged[n-1].symbol == "" {
+			merged[n-1].end = unit.end
+			continue
+		}
+		merged = append(merged, unit)
+	}
+	return merged
+}
+
+// braceUnits makes a unit of every block opened and closed at the outer
+// level of lines [from, to), starting at the paragraph (lines not separated
+// by a blank line) where the block opens, so comments and annotations above
+// it are kept with it. Lines between blocks form units of their own.
+func braceUnits(lines []string, from, to int) []codeUnit {
+	var units []codeUnit
+	depth, start, opener := 0, from, -1
+	for i := from; i < to; i++ {
+		before := depth
+		depth = max(depth+braceDelta(lines[i]), 0)
+		if before == 0 && depth > 0 && opener < 0 {
+			opener = i
+		}
+		if opener < 0 || depth > 0 {
+			continue
+		}
+		head := paragraphStart(lines, start, opener)
+		if head > start {
+			units = append(units, codeUnit{start: start, end: head})
+		}
+		unit := codeUnit{symbol: codeSymbol(lines[head : opener+1]), start: head, end: i + 1}
+		if i > opener {
+			unit.bodyStart, unit.bodyEnd = opener+1, i
+		}
+		units = append(units, unit)
+		start, opener = i+1, -1
+	}
+	if start < to {
+		units = append(units, codeUnit{start: start, end: to})
+	}
+	return units
+}
+
+// indentUnits makes a unit of every statement starting at the indentation
+// of the first line of [from, to), with the lines indented below it and the
+// comments and decorators right above it.
+func indentUnits(lines []string, from, to int) []codeUnit {
+	base := -1
+	for i := from; i < to && base < 0; i++ {
+		if isIndentCode(lines[i]) {
+			base = indentation(lines[i])
+		}
+	}
+
+	var units []codeUnit
+	after := from // the line after the previous statement
+	for i := from; i < to && base >= 0; i++ {
+		line := lines[i]
+		if !isIndentCode(line) || indentation(line) != base || isContinuation(line) ||
+			strings.HasPrefix(strings.TrimSpace(line), "@") {
+			continue
+		}
+		head := i
+		for head > after && indentation(lines[head-1]) == base && isDecoration(lines[head-1]) {
+			head--
+		}
+		if n := len(units); n > 0 {
+			units[n-1].end = head
+		} else if head > from {
+			units = append(units, codeUnit{start: from, end: head})
+		}
+		unit := codeUnit{symbol: indentSymbol(strings.TrimSpace(line)), start: head}
+		if unit.symbol != "" {
+			unit.bodyStart = i + 1
+		}
+		units = append(units, unit)
+		after = i + 1
+	}
+	if len(units) == 0 {
+		return []codeUnit{{start: from, end: to}}
+	}
+	units[len(units)-1].end = to
+	for i := range units {
+		if units[i].bodyStart > 0 {
+			units[i].bodyEnd = units[i].end
+		}
+	}
+	return units
+}
+
+// isDecoration reports whether a line is a comment or a decorator.
+func isDecoration(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "@")
+}
+
+func isIndentCode(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed != "" && !strings.HasPrefix(trimmed, "#")
+}
+
+func indentation(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+// isContinuation reports whether a line continues the statement above it at
+// the same indentation: a closing bracket or a clause such as else or end.
+func isContinuation(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	if strings.ContainsAny(trimmed[:1], ")]}") {
+		return true
+	}
+	word := strings.FieldsFunc(trimmed, func(r rune) bool {
+		return !(r == '_' || r >= 'a' && r <= 'z')
+	})
+	if len(word) == 0 || !strings.HasPrefix(trimmed, word[0]) {
+		return false
+	}
+	switch word[0] {
+	case "else", "elif", "elsif", "except", "finally", "rescue", "ensure", "end", "when", "in":
+		return true
+	}
+	return false
+}
+
+// paragraphStart returns the first line of the run of non-blank lines
+// ending at line i, not going back before from.
+func paragraphStart(lines []string, from, i int) int {
+	for i > from && strings.TrimSpace(lines[i-1]) != "" {
+		i--
+	}
+	return i
+}
+
+// braceDelta returns the number of braces a line opens minus the number it
+// closes, ignoring string literals and line comments.
+func braceDelta(line string) int {
+	delta := 0
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; c {
+		case '{':
+			delta++
+		case '}':
+			delta--
+		case '/':
+			if i+1 < len(line) && line[i+1] == '/' {
+				return delta
+			}
+		case '"', '`', '\'':
+			end := closingQuote(line, i)
+			if c == '\'' && end-i > 3 {
+				// Not a character literal: a Rust lifetime or an apostrophe.
+				continue
+			}
+			if end < 0 {
+				return delta
+			}
+			i = end
+		}
+	}
+	return delta
+}
+
+// closingQuote returns the index of the quote closing the one at line[i], or
+// -1 if it is not closed on the line.
+func closingQuote(line string, i int) int {
+	for j := i + 1; j < len(line); j++ {
+		switch line[j] {
+		case '\\':
+			j++
+		case line[i]:
+			return j
+		}
+	}
+	return -1
+}
+
+var (
+	// declarationSymbol matches the name following a declaration keyword.
+	declarationSymbol = regexp.MustCompile(`\b(?:class|struct|interface|enum|trait|impl|object|record|namespace|module|message|service|type|fn|func|function|def|procedure|table|view)\s+(?:[A-Za-z_$][\w$]*\s+for\s+)?([A-Za-z_$][\w$.]*)`)
+	// callSymbol matches the name before a parameter list.
+	callSymbol = regexp.MustCompile(`([A-Za-z_$][\w$]*)\s*(?:<[^()]*>)?\s*\(`)
+	// assignedSymbol matches the name of a variable, such as an arrow function.
+	assignedSymbol = regexp.MustCompile(`\b(?:const|let|var|val)\s+([A-Za-z_$][\w$]*)`)
+	// selectorSymbol matches a CSS selector.
+	selectorSymbol = regexp.MustCompile(`^([^{]+?)\s*\{`)
+)
+
+// notSymbols are words followed by parentheses that do not name a
+// declaration.
+var notSymbols = map[string]bool{
+	"if": true, "for": true, "while": true, "switch": true, "catch": true, "return": true,
+	"function": true, "new": true, "typeof": true, "sizeof": true, "super": true, "this": true,
+}
+
+// codeSymbol guesses the name declared by the lines of a block's header,
+// skipping comments and annotations.
+func codeSymbol(header []string) string {
+	var code []string
+	for _, line := range header {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "//") || strings.HasPrefix(trimmed, "/*") ||
+			strings.HasPrefix(trimmed, "*") || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "@") {
+			continue
+		}
+		code = append(code, trimmed)
+	}
+	text := strings.Join(code, " ")
+	if m := declarationSymbol.FindStringSubmatch(text); m != nil {
+		return m[1]
+	}
+	if m := assignedSymbol.FindStringSubmatch(text); m != nil {
+		return m[1]
+	}
+	for _, m := range callSymbol.FindAllStringSubmatch(text, -1) {
+		if !notSymbols[m[1]] {
+			return m[1]
+		}
+	}
+	if m := selectorSymbol.FindStringSubmatch(text); m != nil && !strings.ContainsAny(m[1], "=();") {
+		return m[1]
+	}
+	return ""
+}
+
+// indentSymbol returns the name declared by a Python or Ruby statement.
+func indentSymbol(statement string) string {
+	statement = strings.TrimPrefix(statement, "async ")
+	for _, keyword := range []string{"def ", "class ", "module "} {
+		if rest, ok := strings.CutPrefix(statement, keyword); ok {
+			name := strings.TrimPrefix(strings.TrimSpace(rest), "self.")
+			if end := strings.IndexAny(name, "(:<; "); end >= 0 {
+				name = name[:end]
+			}
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,120 @@
+package chunk_test
+
+import (
+	"strings"
+
+	. "github.com/mudler/localrecall/pkg/chunk"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SplitCodeIntoChunks", func() {
+	// located maps every chunk's symbol to its line range.
+	located := func(chunks []Chunk) []string {
+		var out []string
+		for _, c := range chunks {
+			out = append(out, c.Metadata[SymbolKey]+" "+c.Metadata[LineStartKey]+"-"+c.Metadata[LineEndKey])
+		}
+		return out
+	}
+
+	It("splits Go files at their declarations", func() {
+		source := `package server
+
+import "net/http"
+
+// Server serves the API.
+type Server struct {
+	mux *http.ServeMux
+}
+
+// Start listens on addr.
+func (s *Server) Start(addr string) error {
+	return http.ListenAndServe(addr, s.mux)
+}
+
+const (
+	A = 1
+	B = 2
+)
+
+func helper() {}
+`
+		chunks := SplitCodeIntoChunks(source, "go", Options{MaxSize: 1000})
+		Expect(located(chunks)).To(Equal([]string{" 1-3", "Server 5-8", "Server.Start 10-13", "A, B 15-18", "helper 20-20"}))
+		Expect(chunks[2].Content).To(HavePrefix("// Start listens on addr.\nfunc (s *Server) Start"))
+		Expect(chunks[2].Metadata).To(HaveKeyWithValue(LanguageKey, "go"))
+	})
+
+	It("splits brace languages at their blocks and large classes at their methods", func() {
+		source := `import { api } from "./api";
+
+/**
+ * Client talks to the API.
+ */
+export class Client {
+  constructor(base) {
+    this.base = base; // "}"
+  }
+
+  async fetch(path) {
+    return api(this.base + path);
+  }
+}
+
+export const handler = (req) => {
+  return "{";
+};
+`
+		chunks := SplitCodeIntoChunks(source, "javascript", Options{MaxSize: 1000})
+		Expect(located(chunks)).To(Equal([]string{" 1-1", "Client 3-14", "handler 16-18"}))
+
+		chunks = SplitCodeIntoChunks(source, "javascript", Options{MaxSize: 120})
+		Expect(located(chunks)).To(Equal([]string{" 1-1", "Client.constructor 3-9", "Client.fetch 11-14", "handler 16-18"}))
+		for _, c := range chunks {
+			Expect(len(c.Content)).To(BeNumerically("<=", 120))
+		}
+	})
+
+	It("splits Python at its definitions by indentation", func() {
+		source := `import os
+
+# Loader reads files.
+@dataclass
+class Loader:
+    root: str
+
+    def load(self, name):
+        if name:
+            return open(os.path.join(self.root, name))
+        else:
+            return None
+
+def main():
+    Loader(".").load("x")
+`
+		chunks := SplitCodeIntoChunks(source, "python", Options{MaxSize: 1000})
+		Expect(located(chunks)).To(Equal([]string{" 1-1", "Loader 3-12", "main 14-15"}))
+
+		chunks = SplitCodeIntoChunks(source, "python", Options{MaxSize: 150})
+		Expect(located(chunks)).To(Equal([]string{" 1-1", "Loader 3-6", "Loader.load 8-12", "main 14-15"}))
+	})
+
+	It("splits declarations without structure by lines", func() {
+		source := "func big() {\n" + strings.Repeat("\tcall()\n", 20) + "}\n"
+		chunks := SplitCodeIntoChunks(source, "go", Options{MaxSize: 50})
+		Expect(len(chunks)).To(BeNumerically(">", 1))
+		for _, c := range chunks {
+			Expect(len(c.Content)).To(BeNumerically("<=", 50))
+			Expect(c.Metadata).To(HaveKeyWithValue(SymbolKey, "big"))
+		}
+		Expect(chunks[0].Metadata[LineStartKey]).To(Equal("1"))
+		Expect(chunks[len(chunks)-1].Metadata[LineEndKey]).To(Equal("22"))
+	})
+
+	It("detects languages from file extensions", func() {
+		Expect(CodeLanguage("cmd/main.go")).To(Equal("go"))
+		Expect(CodeLanguage("App.TSX")).To(Equal("typescript"))
+		Expect(CodeLanguage("README.md")).To(BeEmpty())
+	})
+})
@@ -0,0 +1,102 @@
+package chunk
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TimeStartKey and TimeEndKey are the chunk metadata keys holding the time
+// range (see FormatTimestamp) of the subtitle or transcript cues a chunk was
+// taken from.
+const (
+	TimeStartKey = "time_start"
+	TimeEndKey   = "time_end"
+)
+
+// Cue is a piece of timed text, such as a subtitle or a transcript line.
+type Cue struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+// FormatTimestamp formats d as HH:MM:SS. Timestamps of recordings shorter
+// than 100 hours have the same length, so they compare in time order.
+func FormatTimestamp(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	seconds := int(d / time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+}
+
+// SplitCuesIntoChunks groups consecutive cues into chunks of up to
+// opts.MaxSize, one cue per line, and records the time range of every chunk
+// under TimeStartKey and TimeEndKey. With opts.Overlap, a chunk starts with
+// the last cues of the previous one that fit in the overlap. A cue too large
+// for a chunk is split like a paragraph, every piece keeping its time range.
+func SplitCuesIntoChunks(cues []Cue, opts Options) []Chunk {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = 1
+	}
+	var chunks []Chunk
+	var window []Cue
+	flush := func() {
+		if len(window) > 0 {
+			chunks = append(chunks, cueChunk(window))
+		}
+	}
+
+	for _, cue := range cues {
+		cue.Text = strings.Join(strings.Fields(cue.Text), " ")
+		if cue.Text == "" {
+			continue
+		}
+		if opts.size(cue.Text) > opts.MaxSize {
+			flush()
+			window = nil
+			for _, piece := range SplitParagraphIntoChunksWithOptions(cue.Text, opts) {
+				chunks = append(chunks, cueChunk([]Cue{{Start: cue.Start, End: cue.End, Text: piece}}))
+			}
+			continue
+		}
+		if len(window) > 0 && opts.size(cueText(window)+"\n"+cue.Text) > opts.MaxSize {
+			flush()
+			window = overlapCues(window, opts)
+			for len(window) > 0 && opts.size(cueText(window)+"\n"+cue.Text) > opts.MaxSize {
+				window = window[1:]
+			}
+		}
+		window = append(window, cue)
+	}
+	flush()
+	return chunks
+}
+
+// overlapCues returns the last cues of window that fit in opts.Overlap.
+func overlapCues(window []Cue, opts Options) []Cue {
+	if opts.Overlap <= 0 {
+		return nil
+	}
+	start := len(window)
+	for start > 0 && opts.size(cueText(window[start-1:])) <= opts.Overlap {
+		start--
+	}
+	return append([]Cue(nil), window[start:]...)
+}
+
+func cueText(cues []Cue) string {
+	lines := make([]string, len(cues))
+	for i, cue := range cues {
+		lines[i] = cue.Text
+	}
+	return strings.Join(lines, "\n")
+}
+
+func cueChunk(cues []Cue) Chunk {
+	return Chunk{Content: cueText(cues), Metadata: map[string]string{
+		TimeStartKey: FormatTimestamp(cues[0].Start),
+		TimeEndKey:   FormatTimestamp(cues[len(cues)-1].End),
+	}}
+}
@@ -0,0 +1,53 @@
+package chunk_test
+
+import (
+	"strings"
+	"time"
+
+	. "github.com/mudler/localrecall/pkg/chunk"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SplitCuesIntoChunks", func() {
+	cues := []Cue{
+		{Start: 1 * time.Second, End: 4 * time.Second, Text: "Welcome everyone"},
+		{Start: 5 * time.Second, End: 9 * time.Second, Text: "Let's review  the\nroadmap"},
+		{Start: 12*time.Minute + 31*time.Second, End: 12*time.Minute + 40*time.Second, Text: "Search ships in March"},
+		{Start: 13 * time.Minute, End: 13*time.Minute + 2*time.Second, Text: " "},
+	}
+	timeRange := func(c Chunk) string {
+		return c.Metadata[TimeStartKey] + "-" + c.Metadata[TimeEndKey]
+	}
+
+	It("groups cues into windows with their time range", func() {
+		chunks := SplitCuesIntoChunks(cues, Options{MaxSize: 45})
+		Expect(chunks).To(HaveLen(2))
+		Expect(chunks[0].Content).To(Equal("Welcome everyone\nLet's review the roadmap"))
+		Expect(timeRange(chunks[0])).To(Equal("00:00:01-00:00:09"))
+		Expect(chunks[1].Content).To(Equal("Search ships in March"))
+		Expect(timeRange(chunks[1])).To(Equal("00:12:31-00:12:40"))
+	})
+
+	It("repeats the last cues of a window as overlap", func() {
+		chunks := SplitCuesIntoChunks(cues, Options{MaxSize: 50, Overlap: 25})
+		Expect(chunks).To(HaveLen(2))
+		Expect(chunks[1].Content).To(Equal("Let's review the roadmap\nSearch ships in March"))
+		Expect(timeRange(chunks[1])).To(Equal("00:00:05-00:12:40"))
+	})
+
+	It("splits cues larger than a chunk", func() {
+		long := []Cue{{Start: time.Hour, End: time.Hour + time.Minute, Text: strings.Repeat("word ", 20)}}
+		chunks := SplitCuesIntoChunks(long, Options{MaxSize: 30})
+		Expect(len(chunks)).To(BeNumerically(">", 1))
+		for _, c := range chunks {
+			Expect(len(c.Content)).To(BeNumerically("<=", 30))
+			Expect(timeRange(c)).To(Equal("01:00:00-01:01:00"))
+		}
+	})
+
+	It("formats timestamps so they sort in time order", func() {
+		Expect(FormatTimestamp(0)).To(Equal("00:00:00"))
+		Expect(FormatTimestamp(26*time.Hour + 3*time.Minute + 4500*time.Millisecond)).To(Equal("26:03:04"))
+	})
+})
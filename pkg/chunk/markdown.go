@@ -0,0 +1,345 @@
+package chunk
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// HeadingPathKey is the chunk metadata key holding the Markdown heading path
+// (e.g. "Install > Docker") of the section a chunk was taken from.
+const HeadingPathKey = "heading_path"
+
+// headingPathSeparator joins the headings of a heading path.
+const headingPathSeparator = " > "
+
+// Chunk is a piece of a document together with metadata describing where in
+// the document it comes from.
+type Chunk struct {
+	Content  string
+	Metadata map[string]string
+}
+
+// mdBlock is a unit of Markdown that is never split unless it is larger than
+// a chunk on its own: a paragraph, a list, a heading line or a fenced code block.
+type mdBlock struct {
+	text  string
+	fence bool
+}
+
+// mdSection is the body of a Markdown document below a heading, up to the next heading.
+type mdSection struct {
+	path   string
+	blocks []mdBlock
+}
+
+// SplitMarkdownIntoChunks splits a Markdown document into chunks that never
+// cross section (ATX heading) boundaries. Blocks (paragraphs, lists, fenced
+// code) are packed into chunks of at most opts.MaxSize, preserving line
+// breaks, and fenced code blocks are kept whole whenever they fit; a fence
+// larger than a chunk is split by lines and every piece is re-fenced. Each
+// chunk's metadata records its heading path under HeadingPathKey. Overlap is
+// applied between consecutive prose chunks of the same section.
+func SplitMarkdownIntoChunks(doc string, opts Options) []Chunk {
+	maxSize := opts.MaxSize
+	if maxSize <= 0 {
+		maxSize = 1
+	}
+	opts.MaxSize = maxSize
+	if opts.Overlap >= maxSize {
+		opts.Overlap = maxSize - 1
+	}
+	if opts.Overlap < 0 {
+		opts.Overlap = 0
+	}
+
+	var chunks []Chunk
+	doc = strings.ToValidUTF8(doc, string(utf8.RuneError))
+	for _, section := range parseMarkdownSections(doc) {
+		for _, content := range packMarkdownBlocks(section.blocks, opts) {
+			meta := map[string]string{}
+			if section.path != "" {
+				meta[HeadingPathKey] = section.path
+			}
+			chunks = append(chunks, Chunk{Content: content, Metadata: meta})
+		}
+	}
+	return chunks
+}
+
+// parseMarkdownSections splits doc into sections at ATX headings outside of
+// fenced code blocks and groups each section's lines into blocks. Sections
+// holding nothing but their heading are dropped: the heading survives in the
+// heading path of its subsections.
+func parseMarkdownSections(doc string) []mdSection {
+	var (
+		sections []mdSection
+		headings [6]string
+		current  mdSection
+		para     []string
+		fence    []string
+		fenceTag string
+	)
+
+	flushPara := func() {
+		if len(para) > 0 {
+			current.blocks = append(current.blocks, mdBlock{text: strings.Join(para, "\n")})
+			para = nil
+		}
+	}
+	flushSection := func() {
+		flushPara()
+		hasBody := false
+		for _, b := range current.blocks {
+			if !isMarkdownHeading(b.text) {
+				hasBody = true
+				break
+			}
+		}
+		if hasBody {
+			sections = append(sections, current)
+		}
+		current = mdSection{}
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(doc, "\r\n", "\n"), "\n") {
+		if fenceTag != "" {
+			fence = append(fence, line)
+			if isClosingFence(line, fenceTag) {
+				current.blocks = append(current.blocks, mdBlock{text: strings.Join(fence, "\n"), fence: true})
+				fence, fenceTag = nil, ""
+			}
+			continue
+		}
+		if tag := openingFence(line); tag != "" {
+			flushPara()
+			fence, fenceTag = []string{line}, tag
+			continue
+		}
+		if level, title := parseATXHeading(line); level > 0 {
+			flushSection()
+			headings[level-1] = title
+			for i := level; i < len(headings); i++ {
+				headings[i] = ""
+			}
+			var path []string
+			for _, h := range headings[:level] {
+				if h != "" {
+					path = append(path, h)
+				}
+			}
+			current.path = strings.Join(path, headingPathSeparator)
+			current.blocks = append(current.blocks, mdBlock{text: strings.TrimSpace(line)})
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			flushPara()
+			continue
+		}
+		para = append(para, line)
+	}
+	if fenceTag != "" {
+		// Unterminated fence: keep what we have as a (still whole) code block.
+		current.blocks = append(current.blocks, mdBlock{text: strings.Join(fence, "\n"), fence: true})
+	}
+	flushSection()
+	return sections
+}
+
+// packMarkdownBlocks greedily packs blocks, separated by blank lines, into
+// chunks of at most opts.MaxSize.
+func packMarkdownBlocks(blocks []mdBlock, opts Options) []string {
+	var (
+		chunks      []string
+		current     strings.Builder
+		currentLen  int
+		lastIsProse bool
+	)
+	blockSepLen := opts.size("\n\n")
+	spaceLen := opts.size(" ")
+
+	flush := func() {
+		if current.Len() > 0 {
+			chunks = append(chunks, current.String())
+			current.Reset()
+			currentLen = 0
+		}
+	}
+	// startWithOverlap begins a new chunk with the tail of the previous prose
+	// chunk when it fits together with the next piece of prose.
+	startWithOverlap := func(nextLen int) {
+		if opts.Overlap <= 0 || !lastIsProse || len(chunks) == 0 {
+			return
+		}
+		tail := overlapTail(chunks[len(chunks)-1], opts.Overlap, opts)
+		if tailLen := opts.size(tail); tail != "" && tailLen+spaceLen+nextLen <= opts.MaxSize {
+			current.WriteString(tail)
+			current.WriteString(" ")
+			currentLen = tailLen + spaceLen
+		}
+	}
+	add := func(text string, textLen int, fence, overlap bool) {
+		if current.Len() > 0 && currentLen+blockSepLen+textLen > opts.MaxSize {
+			flush()
+		}
+		if current.Len() > 0 {
+			current.WriteString("\n\n")
+			currentLen += blockSepLen
+		} else if !fence && overlap {
+			startWithOverlap(textLen)
+		}
+		current.WriteString(text)
+		currentLen += textLen
+		lastIsProse = !fence
+	}
+
+	for _, b := range blocks {
+		if textLen := opts.size(b.text); textLen <= opts.MaxSize {
+			add(b.text, textLen, b.fence, true)
+			continue
+		}
+		// Oversized block: emit its pieces as chunks of their own. Word-split
+		// pieces already carry their own overlap.
+		flush()
+		var pieces []string
+		if b.fence {
+			pieces = splitFence(b.text, opts)
+		} else {
+			pieces = packLines(strings.Split(b.text, "\n"), opts)
+		}
+		for i, p := range pieces {
+			add(p, opts.size(p), b.fence, i == 0)
+			flush()
+		}
+	}
+	flush()
+	return chunks
+}
+
+// packLines packs lines into pieces of at most opts.MaxSize, keeping line
+// breaks. Lines that are too long on their own are split on words.
+func packLines(lines []string, opts Options) []string {
+	var (
+		pieces     []string
+		current    strings.Builder
+		currentLen int
+	)
+	lineSepLen := opts.size("\n")
+	flush := func() {
+		if current.Len() > 0 {
+			pieces = append(pieces, current.String())
+			current.Reset()
+			currentLen = 0
+		}
+	}
+	for _, line := range lines {
+		lineLen := opts.size(line)
+		if lineLen > opts.MaxSize {
+			flush()
+			pieces = append(pieces, SplitParagraphIntoChunksWithOptions(line, opts)...)
+			continue
+		}
+		if current.Len() > 0 && currentLen+lineSepLen+lineLen > opts.MaxSize {
+			flush()
+		}
+		if current.Len() > 0 {
+			current.WriteString("\n")
+			currentLen += lineSepLen
+		}
+		current.WriteString(line)
+		currentLen += lineLen
+	}
+	flush()
+	return pieces
+}
+
+// splitFence splits an oversized fenced code block by lines and wraps each
+// piece in the original fence so every chunk is a valid code block. When the
+// fence markers themselves leave no room for code the body is split bare.
+func splitFence(block string, opts Options) []string {
+	lines := strings.Split(block, "\n")
+	open := lines[0]
+	body := lines[1:]
+	closing := ""
+	if len(body) > 0 && isClosingFence(body[len(body)-1], openingFence(open)) {
+		closing = body[len(body)-1]
+		body = body[:len(body)-1]
+	} else {
+		closing = strings.TrimSpace(openingFence(open))
+	}
+
+	inner := opts
+	inner.MaxSize = opts.MaxSize - opts.size(open+"\n") - opts.size("\n"+closing)
+	inner.Overlap = 0
+	if inner.MaxSize <= 0 {
+		return packLines(body, opts)
+	}
+	var pieces []string
+	for _, p := range packLines(body, inner) {
+		pieces = append(pieces, open+"\n"+p+"\n"+closing)
+	}
+	return pieces
+}
+
+// parseATXHeading returns the level and text of an ATX heading ("## Title"),
+// or level 0 if line is not a heading.
+func parseATXHeading(line string) (int, string) {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 {
+		return 0, ""
+	}
+	level := 0
+	for level < len(trimmed) && trimmed[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 {
+		return 0, ""
+	}
+	rest := trimmed[level:]
+	if rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+		return 0, ""
+	}
+	title := strings.TrimSpace(rest)
+	// Optional closing sequence of #s.
+	if stripped := strings.TrimRight(title, "#"); stripped != title && (stripped == "" || strings.HasSuffix(stripped, " ")) {
+		title = strings.TrimSpace(stripped)
+	}
+	return level, title
+}
+
+func isMarkdownHeading(text string) bool {
+	level, _ := parseATXHeading(text)
+	return level > 0 && !strings.Contains(text, "\n")
+}
+
+// openingFence returns the fence marker (e.g. "```" or "~~~~") opening a
+// fenced code block on line, or "" if line does not open one.
+func openingFence(line string) string {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 || len(trimmed) < 3 {
+		return ""
+	}
+	c := trimmed[0]
+	if c != '`' && c != '~' {
+		return ""
+	}
+	n := 0
+	for n < len(trimmed) && trimmed[n] == c {
+		n++
+	}
+	if n < 3 {
+		return ""
+	}
+	if c == '`' && strings.Contains(trimmed[n:], "`") {
+		return ""
+	}
+	return trimmed[:n]
+}
+
+// isClosingFence reports whether line closes a block opened with tag.
+func isClosingFence(line, tag string) bool {
+	trimmed := strings.TrimSpace(line)
+	if tag == "" || len(trimmed) < len(tag) {
+		return false
+	}
+	return strings.Trim(trimmed, tag[:1]) == ""
+}
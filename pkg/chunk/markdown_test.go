@@ -0,0 +1,95 @@
+package chunk_test
+
+import (
+	"strings"
+
+	. "github.com/mudler/localrecall/pkg/chunk"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SplitMarkdownIntoChunks", func() {
+	doc := strings.Join([]string{
+		"Intro paragraph before any heading.",
+		"",
+		"# Install",
+		"",
+		"Pick one of the methods below.",
+		"",
+		"## Docker",
+		"",
+		"Run the image:",
+		"",
+		"```sh",
+		"# not a heading",
+		"docker run -p 8080:8080 localrecall",
+		"```",
+		"",
+		"## From source",
+		"",
+		"- clone the repo",
+		"- run make build",
+		"",
+		"# Usage",
+		"",
+		"Open the web UI.",
+	}, "\n")
+
+	headingPaths := func(chunks []Chunk) []string {
+		var out []string
+		for _, c := range chunks {
+			out = append(out, c.Metadata[HeadingPathKey])
+		}
+		return out
+	}
+
+	It("never mixes sections and records heading paths", func() {
+		chunks := SplitMarkdownIntoChunks(doc, Options{MaxSize: 1000})
+		Expect(headingPaths(chunks)).To(Equal([]string{
+			"",
+			"Install",
+			"Install > Docker",
+			"Install > From source",
+			"Usage",
+		}))
+		Expect(chunks[0].Metadata).ToNot(HaveKey(HeadingPathKey))
+		Expect(chunks[2].Content).To(HavePrefix("## Docker"))
+		Expect(chunks[3].Content).To(ContainSubstring("- clone the repo\n- run make build"))
+	})
+
+	It("keeps fenced code blocks together and ignores headings inside them", func() {
+		chunks := SplitMarkdownIntoChunks(doc, Options{MaxSize: 70})
+		var fenced []string
+		for _, c := range chunks {
+			Expect(c.Metadata[HeadingPathKey]).ToNot(Equal("not a heading"))
+			if strings.Contains(c.Content, "```") {
+				fenced = append(fenced, c.Content)
+			}
+		}
+		Expect(fenced).To(HaveLen(1))
+		Expect(fenced[0]).To(Equal("```sh\n# not a heading\ndocker run -p 8080:8080 localrecall\n```"))
+	})
+
+	It("re-fences oversized code blocks and respects MaxSize", func() {
+		var lines []string
+		for i := 0; i < 20; i++ {
+			lines = append(lines, "fmt.Println(\"line\")")
+		}
+		big := "# Code\n\n```go\n" + strings.Join(lines, "\n") + "\n```\n"
+		chunks := SplitMarkdownIntoChunks(big, Options{MaxSize: 100})
+		Expect(len(chunks)).To(BeNumerically(">", 2))
+		for _, c := range chunks {
+			Expect(len(c.Content)).To(BeNumerically("<=", 100))
+			Expect(c.Metadata[HeadingPathKey]).To(Equal("Code"))
+		}
+		for _, c := range chunks[1:] {
+			Expect(c.Content).To(HavePrefix("```go\n"))
+			Expect(c.Content).To(HaveSuffix("\n```"))
+		}
+	})
+
+	It("resets deeper headings when a shallower one starts", func() {
+		chunks := SplitMarkdownIntoChunks("# A\n\n## B\n\ntext b\n\n# C\n\n### D\n\ntext d", Options{MaxSize: 100})
+		Expect(headingPaths(chunks)).To(Equal([]string{"A > B", "C > D"}))
+	})
+})
@@ -0,0 +1,69 @@
+package chunk
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PageStartKey and PageEndKey are the chunk metadata keys holding the first
+// and last page (1-based) a chunk was taken from.
+const (
+	PageStartKey = "page_start"
+	PageEndKey   = "page_end"
+)
+
+// SplitPagesIntoChunks splits the text of a paged document, such as a PDF,
+// like SplitParagraphIntoChunksWithOptions splits the pages joined together,
+// so chunks may span page breaks. Each chunk's metadata records the pages it
+// was taken from under PageStartKey and PageEndKey. Pages without text yield
+// no chunks.
+func SplitPagesIntoChunks(pages []string, opts Options) []Chunk {
+	// Chunks are words joined by single spaces, so they are located in the
+	// pages joined the same way.
+	var sb strings.Builder
+	var starts []int // offset of the first word of each page with text
+	var numbers []int
+	for i, page := range pages {
+		words := strings.Fields(page)
+		if len(words) == 0 {
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteString(" ")
+		}
+		starts = append(starts, sb.Len())
+		numbers = append(numbers, i+1)
+		sb.WriteString(strings.Join(words, " "))
+	}
+	if len(starts) == 0 {
+		return nil
+	}
+	text := sb.String()
+	pageAt := func(offset int) int {
+		return numbers[sort.Search(len(starts), func(i int) bool { return starts[i] > offset })-1]
+	}
+
+	var chunks []Chunk
+	from, lastPage := 0, numbers[0]
+	for _, content := range SplitParagraphIntoChunksWithOptions(strings.TrimSpace(strings.Join(pages, "\n\n")), opts) {
+		normalized := strings.Join(strings.Fields(content), " ")
+		if normalized == "" {
+			continue
+		}
+		start, end := lastPage, lastPage
+		// Overlap makes a chunk start before the end of the previous one,
+		// but never before its start.
+		if i := strings.Index(text[from:], normalized); i >= 0 {
+			offset := from + i
+			start, end = pageAt(offset), pageAt(offset+len(normalized)-1)
+			from = offset
+		}
+		lastPage = end
+		chunks = append(chunks, Chunk{Content: content, Metadata: map[string]string{
+			PageStartKey: strconv.Itoa(start),
+			PageEndKey:   strconv.Itoa(end),
+		}})
+	}
+	return chunks
+}
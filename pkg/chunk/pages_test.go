@@ -0,0 +1,61 @@
+package chunk_test
+
+import (
+	"strings"
+
+	. "github.com/mudler/localrecall/pkg/chunk"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SplitPagesIntoChunks", func() {
+	pageRange := func(c Chunk) string {
+		return c.Metadata[PageStartKey] + "-" + c.Metadata[PageEndKey]
+	}
+
+	It("records the pages every chunk spans", func() {
+		pages := []string{
+			"alpha beta gamma\ndelta",
+			"",
+			"epsilon zeta eta theta",
+			"iota kappa",
+		}
+		chunks := SplitPagesIntoChunks(pages, Options{MaxSize: 30})
+		var ranges, contents []string
+		for _, c := range chunks {
+			ranges = append(ranges, pageRange(c))
+			contents = append(contents, c.Content)
+		}
+		Expect(contents).To(Equal([]string{"alpha beta gamma delta epsilon", "zeta eta theta iota kappa"}))
+		Expect(ranges).To(Equal([]string{"1-3", "3-4"}))
+	})
+
+	It("follows overlapping chunks", func() {
+		pages := []string{strings.Repeat("one ", 10), strings.Repeat("two ", 10)}
+		chunks := SplitPagesIntoChunks(pages, Options{MaxSize: 20, Overlap: 8})
+		Expect(pageRange(chunks[0])).To(Equal("1-1"))
+		Expect(pageRange(chunks[len(chunks)-1])).To(Equal("2-2"))
+		spanning := 0
+		for i, c := range chunks {
+			Expect(len(c.Content)).To(BeNumerically("<=", 20))
+			if i > 0 {
+				Expect(c.Metadata[PageStartKey] >= chunks[i-1].Metadata[PageStartKey]).To(BeTrue())
+			}
+			if pageRange(c) == "1-2" {
+				spanning++
+			}
+		}
+		Expect(spanning).To(BeNumerically(">", 0))
+	})
+
+	It("keeps a short document whole", func() {
+		chunks := SplitPagesIntoChunks([]string{"", "Cover", "Body text"}, Options{MaxSize: 100})
+		Expect(chunks).To(HaveLen(1))
+		Expect(chunks[0].Content).To(Equal("Cover\n\nBody text"))
+		Expect(pageRange(chunks[0])).To(Equal("2-3"))
+	})
+
+	It("returns nothing for pages without text", func() {
+		Expect(SplitPagesIntoChunks([]string{"", " \n"}, Options{MaxSize: 100})).To(BeEmpty())
+	})
+})
@@ -0,0 +1,120 @@
+package chunk
+
+import (
+	"strconv"
+	"strings"
+)
+
+// RowStartKey and RowEndKey are the chunk metadata keys holding the first and
+// last data row (1-based, header excluded) a table chunk was taken from.
+const (
+	RowStartKey = "row_start"
+	RowEndKey   = "row_end"
+)
+
+// SplitTableIntoChunks splits a table into chunks of whole rows. Every chunk
+// is a Markdown table starting with the header, so it reads on its own, and
+// rows are packed up to opts.MaxSize. When keyColumns are given every row is
+// a chunk of its own, whose metadata holds the row's values of those columns
+// (matched case-insensitively) keyed by the names in keyColumns. A row too
+// large for a chunk is written as "column: value" lines and packed like a
+// Markdown paragraph.
+func SplitTableIntoChunks(header []string, rows [][]string, keyColumns []string, opts Options) []Chunk {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = 1
+	}
+	keyIndex := map[string]int{}
+	for _, key := range keyColumns {
+		for i, name := range header {
+			if strings.EqualFold(strings.TrimSpace(name), strings.TrimSpace(key)) {
+				keyIndex[key] = i
+				break
+			}
+		}
+	}
+	onePerRow := len(keyColumns) > 0
+
+	head := tableRow(header) + "\n" + "|" + strings.Repeat(" --- |", len(header))
+	var chunks []Chunk
+	var lines []string
+	start := 0
+	flush := func(end int) {
+		if len(lines) == 0 {
+			return
+		}
+		chunks = append(chunks, Chunk{
+			Content:  head + "\n" + strings.Join(lines, "\n"),
+			Metadata: rowMetadata(start, end, nil),
+		})
+		lines = nil
+	}
+
+	for i, row := range rows {
+		line := tableRow(row)
+		candidate := head + "\n" + line
+		if len(lines) > 0 {
+			candidate = head + "\n" + strings.Join(lines, "\n") + "\n" + line
+		}
+		if len(lines) > 0 && opts.size(candidate) > opts.MaxSize {
+			flush(i)
+		}
+		if len(lines) == 0 {
+			start = i + 1
+		}
+
+		if opts.size(head+"\n"+line) > opts.MaxSize {
+			var record []string
+			for j, cell := range row {
+				if j < len(header) && strings.TrimSpace(cell) != "" {
+					record = append(record, header[j]+": "+cell)
+				}
+			}
+			for _, piece := range packLines(record, opts) {
+				chunks = append(chunks, Chunk{Content: piece, Metadata: rowMetadata(i+1, i+1, keyValues(row, keyIndex))})
+			}
+			continue
+		}
+
+		lines = append(lines, line)
+		if onePerRow {
+			chunks = append(chunks, Chunk{
+				Content:  head + "\n" + line,
+				Metadata: rowMetadata(i+1, i+1, keyValues(row, keyIndex)),
+			})
+			lines = nil
+		}
+	}
+	flush(len(rows))
+	return chunks
+}
+
+func tableRow(cells []string) string {
+	var sb strings.Builder
+	sb.WriteString("|")
+	for _, cell := range cells {
+		cell = strings.Join(strings.Fields(cell), " ")
+		sb.WriteString(" " + strings.ReplaceAll(cell, "|", `\|`) + " |")
+	}
+	return sb.String()
+}
+
+func keyValues(row []string, keyIndex map[string]int) map[string]string {
+	values := map[string]string{}
+	for key, i := range keyIndex {
+		if i < len(row) && strings.TrimSpace(row[i]) != "" {
+			values[key] = strings.TrimSpace(row[i])
+		}
+	}
+	return values
+}
+
+func rowMetadata(start, end int, extra map[string]string) map[string]string {
+	meta := map[string]string{
+		RowStartKey: strconv.Itoa(start),
+		RowEndKey:   strconv.Itoa(end),
+	}
+	for k, v := range extra {
+		meta[k] = v
+	}
+	return meta
+}
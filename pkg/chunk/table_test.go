@@ -0,0 +1,48 @@
+package chunk_test
+
+import (
+	"strings"
+
+	. "github.com/mudler/localrecall/pkg/chunk"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SplitTableIntoChunks", func() {
+	header := []string{"SKU", "Name", "Description"}
+	rows := [][]string{
+		{"A-1", "Kettle", "Boils water"},
+		{"A-2", "Toaster", "Two | slots"},
+		{"A-3", "Mixer", "Three speeds"},
+	}
+
+	It("packs rows under a repeated header", func() {
+		chunks := SplitTableIntoChunks(header, rows, nil, Options{MaxSize: 120})
+		Expect(chunks).To(HaveLen(2))
+		for _, c := range chunks {
+			Expect(c.Content).To(HavePrefix("| SKU | Name | Description |\n| --- | --- | --- |\n"))
+			Expect(len(c.Content)).To(BeNumerically("<=", 120))
+		}
+		Expect(chunks[0].Content).To(ContainSubstring(`| A-2 | Toaster | Two \| slots |`))
+		Expect(chunks[0].Metadata).To(Equal(map[string]string{RowStartKey: "1", RowEndKey: "2"}))
+		Expect(chunks[1].Metadata).To(Equal(map[string]string{RowStartKey: "3", RowEndKey: "3"}))
+	})
+
+	It("emits one chunk per row with key columns in metadata", func() {
+		chunks := SplitTableIntoChunks(header, rows, []string{"sku", "Missing"}, Options{MaxSize: 1000})
+		Expect(chunks).To(HaveLen(3))
+		Expect(chunks[1].Content).To(Equal("| SKU | Name | Description |\n| --- | --- | --- |\n| A-2 | Toaster | Two \\| slots |"))
+		Expect(chunks[1].Metadata).To(Equal(map[string]string{"sku": "A-2", RowStartKey: "2", RowEndKey: "2"}))
+	})
+
+	It("splits rows larger than a chunk as labelled lines", func() {
+		long := [][]string{{"A-9", "Encyclopedia", strings.Repeat("volume ", 30)}}
+		chunks := SplitTableIntoChunks(header, long, []string{"SKU"}, Options{MaxSize: 60})
+		Expect(len(chunks)).To(BeNumerically(">", 1))
+		Expect(chunks[0].Content).To(HavePrefix("SKU: A-9\nName: Encyclopedia"))
+		for _, c := range chunks {
+			Expect(len(c.Content)).To(BeNumerically("<=", 60))
+			Expect(c.Metadata).To(HaveKeyWithValue("SKU", "A-9"))
+		}
+	})
+})
@@ -0,0 +1,132 @@
+package chunk_test
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	. "github.com/mudler/localrecall/pkg/chunk"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// utf8Samples are runes of every encoded width plus combining marks and an
+// emoji ZWJ sequence, the inputs byte-based splitting used to corrupt.
+var utf8Samples = []string{
+	"a", "Z", "7", "-", "/", "=", "é", "ß", "Ж", "ع", "中", "文", "語", "한",
+	"€", "→", "😀", "👍🏽", "👩‍💻", "é", "̈", "️", " ", " ", "\n",
+}
+
+// randomUTF8 builds a random string from utf8Samples, with long runs of
+// non-space runes so that long-word splitting kicks in.
+func randomUTF8(r *rand.Rand, n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		b.WriteString(utf8Samples[r.Intn(len(utf8Samples))])
+		if r.Intn(40) == 0 {
+			b.WriteString(" ")
+		}
+	}
+	return b.String()
+}
+
+// checkChunks reports the first chunk that is not valid UTF-8 or exceeds
+// maxSize runes.
+func checkChunks(chunks []string, maxSize int) (string, bool) {
+	for _, c := range chunks {
+		if !utf8.ValidString(c) || utf8.RuneCountInString(c) > maxSize {
+			return c, false
+		}
+	}
+	return "", true
+}
+
+var _ = Describe("UTF-8 safety", func() {
+	It("splits long words on rune boundaries", func() {
+		word := strings.Repeat("中文", 50)
+		chunks := SplitParagraphIntoChunks(word, 7)
+		Expect(strings.Join(chunks, "")).To(Equal(word))
+		for _, c := range chunks {
+			Expect(utf8.ValidString(c)).To(BeTrue())
+			Expect(utf8.RuneCountInString(c)).To(BeNumerically("<=", 7))
+		}
+	})
+
+	It("counts MaxSize in characters, not bytes", func() {
+		text := "日本語のテキスト です"
+		Expect(SplitParagraphIntoChunks(text, 11)).To(Equal([]string{text}))
+	})
+
+	It("keeps combining marks with their base character", func() {
+		word := strings.Repeat("é", 10)
+		for _, c := range SplitParagraphIntoChunks(word, 3) {
+			Expect(strings.HasPrefix(c, "́")).To(BeFalse())
+		}
+	})
+
+	It("replaces invalid UTF-8 in the input", func() {
+		chunks := SplitParagraphIntoChunks("abc\xff\xfedef", 4)
+		for _, c := range chunks {
+			Expect(utf8.ValidString(c)).To(BeTrue())
+		}
+	})
+
+	It("holds for random input, sizes and overlaps", func() {
+		r := rand.New(rand.NewSource(GinkgoRandomSeed()))
+		for i := 0; i < 500; i++ {
+			text := randomUTF8(r, r.Intn(400))
+			maxSize := 1 + r.Intn(40)
+			opts := Options{MaxSize: maxSize, Overlap: r.Intn(maxSize + 1), SplitLongWords: true}
+
+			bad, ok := checkChunks(SplitParagraphIntoChunksWithOptions(text, opts), maxSize)
+			Expect(ok).To(BeTrue(), "paragraph chunk %q of %q (opts %+v)", bad, text, opts)
+
+			var contents []string
+			for _, c := range SplitMarkdownIntoChunks("# Title\n\n"+text+"\n\n```\n"+text+"\n```\n", opts) {
+				contents = append(contents, c.Content)
+			}
+			bad, ok = checkChunks(contents, maxSize)
+			Expect(ok).To(BeTrue(), "markdown chunk %q of %q (opts %+v)", bad, text, opts)
+		}
+	})
+})
+
+func fuzzSeeds(f *testing.F) {
+	f.Add("hello world", 5, 1)
+	f.Add(strings.Repeat("中文", 40), 7, 2)
+	f.Add("https://example.com/"+strings.Repeat("😀", 30), 10, 3)
+	f.Add("ééé 👩‍💻👩‍💻", 2, 0)
+	f.Add("abc\xff\xfe def", 3, 1)
+}
+
+func FuzzSplitParagraphIntoChunks(f *testing.F) {
+	fuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, text string, maxSize, overlap int) {
+		maxSize = 1 + abs(maxSize)%64
+		opts := Options{MaxSize: maxSize, Overlap: abs(overlap) % (maxSize + 1), SplitLongWords: true}
+		if bad, ok := checkChunks(SplitParagraphIntoChunksWithOptions(text, opts), maxSize); !ok {
+			t.Fatalf("invalid chunk %q (opts %+v)", bad, opts)
+		}
+	})
+}
+
+func FuzzSplitMarkdownIntoChunks(f *testing.F) {
+	fuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, text string, maxSize, overlap int) {
+		maxSize = 1 + abs(maxSize)%64
+		opts := Options{MaxSize: maxSize, Overlap: abs(overlap) % (maxSize + 1), SplitLongWords: true}
+		for _, c := range SplitMarkdownIntoChunks(text, opts) {
+			if bad, ok := checkChunks([]string{c.Content}, maxSize); !ok {
+				t.Fatalf("invalid chunk %q (opts %+v)", bad, opts)
+			}
+		}
+	})
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
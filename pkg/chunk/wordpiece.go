@@ -0,0 +1,180 @@
+package chunk
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	wordPieceUnknown         = "[UNK]"
+	wordPieceContinuation    = "##"
+	wordPieceMaxCharsPerWord = 100
+)
+
+// WordPieceTokenizer is an offline BERT-style WordPiece tokenizer. It needs
+// nothing but the model's vocab.txt, so chunk sizes can be measured in the
+// same tokens the embedding model sees without calling out to LocalAI.
+type WordPieceTokenizer struct {
+	vocab     map[string]struct{}
+	lowercase bool
+}
+
+// LoadWordPieceTokenizer loads a WordPiece vocabulary file (one token per
+// line, as shipped with BERT-family models as vocab.txt).
+func LoadWordPieceTokenizer(path string) (*WordPieceTokenizer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening vocab file: %w", err)
+	}
+	defer f.Close()
+
+	var vocab []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if token := strings.TrimRight(scanner.Text(), "\r"); token != "" {
+			vocab = append(vocab, token)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading vocab file: %w", err)
+	}
+	if len(vocab) == 0 {
+		return nil, fmt.Errorf("vocab file %s is empty", path)
+	}
+	return NewWordPieceTokenizer(vocab), nil
+}
+
+// NewWordPieceTokenizer builds a tokenizer from vocabulary tokens. Input is
+// lowercased and stripped of accents when the vocabulary has no uppercase
+// entries, mirroring the "uncased" model variants.
+func NewWordPieceTokenizer(vocab []string) *WordPieceTokenizer {
+	t := &WordPieceTokenizer{vocab: make(map[string]struct{}, len(vocab)), lowercase: true}
+	for _, token := range vocab {
+		t.vocab[token] = struct{}{}
+		if !strings.HasPrefix(token, "[") && strings.ToLower(token) != token {
+			t.lowercase = false
+		}
+	}
+	return t
+}
+
+// CountTokens returns the number of WordPiece tokens s encodes to, excluding
+// special tokens such as [CLS] and [SEP].
+func (t *WordPieceTokenizer) CountTokens(s string) int {
+	n := 0
+	for _, word := range t.basicTokenize(s) {
+		n += len(t.wordPiece(word))
+	}
+	return n
+}
+
+// Tokenize splits s into WordPiece tokens.
+func (t *WordPieceTokenizer) Tokenize(s string) []string {
+	var tokens []string
+	for _, word := range t.basicTokenize(s) {
+		tokens = append(tokens, t.wordPiece(word)...)
+	}
+	return tokens
+}
+
+// basicTokenize cleans s and splits it on whitespace, punctuation and CJK
+// characters, which each become a word of their own.
+func (t *WordPieceTokenizer) basicTokenize(s string) []string {
+	if t.lowercase {
+		s = stripAccents(strings.ToLower(s))
+	}
+	var (
+		words   []string
+		current strings.Builder
+	)
+	flush := func() {
+		if current.Len() > 0 {
+			words = append(words, current.String())
+			current.Reset()
+		}
+	}
+	for _, r := range s {
+		switch {
+		case r == 0 || r == unicode.ReplacementChar:
+			continue
+		case unicode.IsSpace(r):
+			flush()
+		case unicode.IsControl(r) || unicode.Is(unicode.Cf, r):
+			continue
+		case isCJK(r) || isPunctuation(r):
+			flush()
+			words = append(words, string(r))
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+	return words
+}
+
+// wordPiece splits a single word greedily into the longest vocabulary
+// entries, falling back to [UNK] for the whole word when that fails.
+func (t *WordPieceTokenizer) wordPiece(word string) []string {
+	runes := []rune(word)
+	if len(runes) > wordPieceMaxCharsPerWord {
+		return []string{wordPieceUnknown}
+	}
+	var pieces []string
+	for start := 0; start < len(runes); {
+		end := len(runes)
+		found := ""
+		for ; end > start; end-- {
+			candidate := string(runes[start:end])
+			if start > 0 {
+				candidate = wordPieceContinuation + candidate
+			}
+			if _, ok := t.vocab[candidate]; ok {
+				found = candidate
+				break
+			}
+		}
+		if found == "" {
+			return []string{wordPieceUnknown}
+		}
+		pieces = append(pieces, found)
+		start = end
+	}
+	return pieces
+}
+
+func stripAccents(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		if !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// isPunctuation follows BERT: every non-alphanumeric printable ASCII
+// character counts as punctuation, plus the Unicode P* categories.
+func isPunctuation(r rune) bool {
+	if (r >= 33 && r <= 47) || (r >= 58 && r <= 64) || (r >= 91 && r <= 96) || (r >= 123 && r <= 126) {
+		return true
+	}
+	return unicode.IsPunct(r)
+}
+
+// isCJK reports whether r is in the CJK Unified Ideographs blocks, whose
+// characters are tokenized individually.
+func isCJK(r rune) bool {
+	return (r >= 0x4E00 && r <= 0x9FFF) ||
+		(r >= 0x3400 && r <= 0x4DBF) ||
+		(r >= 0x20000 && r <= 0x2A6DF) ||
+		(r >= 0x2A700 && r <= 0x2B73F) ||
+		(r >= 0x2B740 && r <= 0x2B81F) ||
+		(r >= 0x2B820 && r <= 0x2CEAF) ||
+		(r >= 0xF900 && r <= 0xFAFF) ||
+		(r >= 0x2F800 && r <= 0x2FA1F)
+}
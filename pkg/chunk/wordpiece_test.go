@@ -0,0 +1,90 @@
+package chunk_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/mudler/localrecall/pkg/chunk"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WordPieceTokenizer", func() {
+	vocab := []string{
+		"[PAD]", "[UNK]", "[CLS]", "[SEP]",
+		"the", "quick", "brown", "fox", "jump", "##s", "##ed", "over", "lazy", "dog",
+		"un", "##un", "##aff", "##able", "cafe", ".", ",", "!", "中", "文",
+	}
+
+	It("splits words into the longest vocabulary pieces", func() {
+		t := NewWordPieceTokenizer(vocab)
+		Expect(t.Tokenize("The quick brown fox jumps over the lazy dog.")).To(Equal([]string{
+			"the", "quick", "brown", "fox", "jump", "##s", "over", "the", "lazy", "dog", ".",
+		}))
+		Expect(t.Tokenize("unaffable")).To(Equal([]string{"un", "##aff", "##able"}))
+	})
+
+	It("lowercases and strips accents for uncased vocabularies", func() {
+		t := NewWordPieceTokenizer(vocab)
+		Expect(t.Tokenize("Café!")).To(Equal([]string{"cafe", "!"}))
+	})
+
+	It("keeps case for cased vocabularies", func() {
+		t := NewWordPieceTokenizer(append([]string{"Fox"}, vocab...))
+		Expect(t.Tokenize("Fox fox FOX")).To(Equal([]string{"Fox", "fox", "[UNK]"}))
+	})
+
+	It("maps unknown words to a single [UNK] and splits CJK characters", func() {
+		t := NewWordPieceTokenizer(vocab)
+		Expect(t.Tokenize("zzz 中文")).To(Equal([]string{"[UNK]", "中", "文"}))
+		Expect(t.CountTokens("zzz 中文")).To(Equal(3))
+		Expect(t.CountTokens("  \n\t")).To(Equal(0))
+	})
+
+	It("loads a vocab.txt file", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "vocab.txt")
+		Expect(os.WriteFile(path, []byte(strings.Join(vocab, "\n")+"\n"), 0644)).To(Succeed())
+
+		t, err := LoadWordPieceTokenizer(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(t.CountTokens("the dog jumped")).To(Equal(4))
+
+		_, err = LoadWordPieceTokenizer(filepath.Join(GinkgoT().TempDir(), "missing.txt"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	Describe("as a chunking tokenizer", func() {
+		t := NewWordPieceTokenizer(vocab)
+		text := strings.Repeat("the quick brown fox jumps over the lazy dog. ", 20)
+
+		It("bounds paragraph chunks by token count", func() {
+			chunks := SplitParagraphIntoChunksWithOptions(text, Options{MaxSize: 16, Overlap: 4, Tokenizer: t})
+			Expect(len(chunks)).To(BeNumerically(">", 1))
+			for _, c := range chunks {
+				Expect(t.CountTokens(c)).To(BeNumerically("<=", 16))
+			}
+			// Measured in characters the same limit yields far more chunks.
+			Expect(len(SplitParagraphIntoChunksWithOptions(text, Options{MaxSize: 16}))).To(BeNumerically(">", len(chunks)))
+		})
+
+		It("splits words longer than the token budget", func() {
+			long := strings.Repeat("un", 10) + "affable"
+			chunks := SplitParagraphIntoChunksWithOptions(long, Options{MaxSize: 3, SplitLongWords: true, Tokenizer: t})
+			Expect(len(chunks)).To(BeNumerically(">", 1))
+			Expect(strings.Join(chunks, "")).To(Equal(long))
+			for _, c := range chunks {
+				Expect(t.CountTokens(c)).To(BeNumerically("<=", 3))
+			}
+		})
+
+		It("bounds Markdown chunks by token count", func() {
+			doc := "# Title\n\n" + text + "\n\n## Code\n\n```\n" + strings.Repeat("the dog\n", 30) + "```\n"
+			chunks := SplitMarkdownIntoChunks(doc, Options{MaxSize: 20, Tokenizer: t})
+			Expect(len(chunks)).To(BeNumerically(">", 2))
+			for _, c := range chunks {
+				Expect(t.CountTokens(c.Content)).To(BeNumerically("<=", 20))
+			}
+		})
+	})
+})
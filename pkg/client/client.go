@@ -2,6 +2,7 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,6 +11,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"time"
 
 	"github.com/mudler/localrecall/rag/types"
 )
@@ -17,8 +19,15 @@ import (
 // Client is a client for the RAG API
 type Client struct {
 	BaseURL string
+	// JobTimeout bounds how long Store, StoreWithMetadata and StoreRecords
+	// wait for the ingestion of a file. Zero means DefaultJobTimeout.
+	JobTimeout time.Duration
 }
 
+// DefaultJobTimeout is how long a client waits for the ingestion of a file
+// unless its JobTimeout is set.
+const DefaultJobTimeout = 10 * time.Minute
+
 // NewClient creates a new RAG API client
 func NewClient(baseURL string) *Client {
 	return &Client{
@@ -28,13 +37,24 @@ func NewClient(baseURL string) *Client {
 
 // CreateCollection creates a new collection
 func (c *Client) CreateCollection(name string) error {
+	return c.CreateCollectionWithConfig(name, nil)
+}
+
+// CreateCollectionWithConfig creates a new collection with its own chunking,
+// embedding model and engine settings. Unset fields use the server defaults.
+func (c *Client) CreateCollectionWithConfig(name string, config *types.CollectionConfig) error {
 	url := fmt.Sprintf("%s/api/collections", c.BaseURL)
 
 	type request struct {
 		Name string `json:"name"`
+		types.CollectionConfig
 	}
 
-	payload, err := json.Marshal(request{Name: name})
+	r := request{Name: name}
+	if config != nil {
+		r.CollectionConfig = *config
+	}
+	payload, err := json.Marshal(r)
 	if err != nil {
 		return err
 	}
@@ -52,6 +72,95 @@ func (c *Client) CreateCollection(name string) error {
 	return nil
 }
 
+// GetCollectionConfig returns the settings stored with a collection and the
+// effective settings after applying the server defaults.
+func (c *Client) GetCollectionConfig(collection string) (stored *types.CollectionConfig, effective types.CollectionConfig, err error) {
+	url := fmt.Sprintf("%s/api/collections/%s/config", c.BaseURL, collection)
+
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, effective, err
+	}
+	defer resp.Body.Close()
+
+	return decodeCollectionConfig(resp)
+}
+
+// UpdateCollectionConfig changes the settings set in config and returns the
+// new stored and effective settings. Existing entries are re-indexed when the
+// chunking, embedding model or engine change.
+func (c *Client) UpdateCollectionConfig(collection string, config types.CollectionConfig) (stored *types.CollectionConfig, effective types.CollectionConfig, err error) {
+	url := fmt.Sprintf("%s/api/collections/%s/config", c.BaseURL, collection)
+
+	payload, err := json.Marshal(config)
+	if err != nil {
+		return nil, effective, err
+	}
+
+	req, err := http.NewRequest(http.MethodPatch, url, bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, effective, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, effective, err
+	}
+	defer resp.Body.Close()
+
+	return decodeCollectionConfig(resp)
+}
+
+func decodeCollectionConfig(resp *http.Response) (*types.CollectionConfig, types.CollectionConfig, error) {
+	var apiResp struct {
+		Data struct {
+			Config    *types.CollectionConfig `json:"config"`
+			Effective types.CollectionConfig  `json:"effective"`
+		} `json:"data"`
+		Error *struct {
+			Message string `json:"message"`
+			Details string `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return nil, types.CollectionConfig{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		if apiResp.Error != nil {
+			return nil, types.CollectionConfig{}, fmt.Errorf("collection config request failed: %s: %s", apiResp.Error.Message, apiResp.Error.Details)
+		}
+		return nil, types.CollectionConfig{}, fmt.Errorf("collection config request failed: status %d", resp.StatusCode)
+	}
+	return apiResp.Data.Config, apiResp.Data.Effective, nil
+}
+
+// DeleteCollection deletes a collection together with all its entries and sources
+func (c *Client) DeleteCollection(name string) error {
+	url := fmt.Sprintf("%s/api/collections/%s", c.BaseURL, name)
+	req, err := http.NewRequest(http.MethodDelete, url, nil)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		b := new(bytes.Buffer)
+		b.ReadFrom(resp.Body)
+
+		return errors.New("failed to delete collection: " + b.String())
+	}
+
+	return nil
+}
+
 // ListCollections lists all collections
 func (c *Client) ListCollections() ([]string, error) {
 	url := fmt.Sprintf("%s/api/collections", c.BaseURL)
@@ -159,6 +268,75 @@ func (c *Client) GetEntryContent(collection, entry string) ([]EntryChunk, error)
 	return []EntryChunk{{Content: result.Data.Content}}, nil
 }
 
+// GetEntryMetadata returns the metadata recorded for an entry.
+func (c *Client) GetEntryMetadata(collection, entry string) (map[string]string, error) {
+	apiURL := fmt.Sprintf("%s/api/collections/%s/entries/%s/metadata", c.BaseURL, collection, url.PathEscape(entry))
+
+	resp, err := http.Get(apiURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return decodeEntryMetadata(resp)
+}
+
+// UpdateEntryMetadata sets the given metadata keys on an entry and all its
+// chunks, and removes the keys listed in remove. It returns the resulting
+// metadata.
+func (c *Client) UpdateEntryMetadata(collection, entry string, set map[string]string, remove ...string) (map[string]string, error) {
+	apiURL := fmt.Sprintf("%s/api/collections/%s/entries/%s/metadata", c.BaseURL, collection, url.PathEscape(entry))
+
+	patch := make(map[string]*string, len(set)+len(remove))
+	for _, k := range remove {
+		patch[k] = nil
+	}
+	for k, v := range set {
+		patch[k] = &v
+	}
+	payload, err := json.Marshal(map[string]interface{}{"metadata": patch})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPatch, apiURL, bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return decodeEntryMetadata(resp)
+}
+
+func decodeEntryMetadata(resp *http.Response) (map[string]string, error) {
+	var apiResp struct {
+		Data struct {
+			Metadata map[string]string `json:"metadata"`
+		} `json:"data"`
+		Error *struct {
+			Message string `json:"message"`
+			Details string `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		if apiResp.Error != nil {
+			return nil, fmt.Errorf("entry metadata request failed: %s: %s", apiResp.Error.Message, apiResp.Error.Details)
+		}
+		return nil, fmt.Errorf("entry metadata request failed: status %d", resp.StatusCode)
+	}
+	return apiResp.Data.Metadata, nil
+}
+
 // GetEntryRawFile returns the original uploaded binary file as a ReadCloser.
 // The caller is responsible for closing the returned ReadCloser.
 func (c *Client) GetEntryRawFile(collection, entry string) (io.ReadCloser, error) {
@@ -229,14 +407,20 @@ func (c *Client) DeleteEntry(collection, entry string) ([]string, error) {
 
 // Search searches a collection
 func (c *Client) Search(collection, query string, maxResults int) ([]types.Result, error) {
+	return c.SearchWithFilter(collection, query, maxResults, nil)
+}
+
+// SearchWithFilter searches a collection, returning only results whose metadata matches filter
+func (c *Client) SearchWithFilter(collection, query string, maxResults int, filter *types.Filter) ([]types.Result, error) {
 	url := fmt.Sprintf("%s/api/collections/%s/search", c.BaseURL, collection)
 
 	type request struct {
-		Query      string `json:"query"`
-		MaxResults int    `json:"max_results"`
+		Query      string        `json:"query"`
+		MaxResults int           `json:"max_results"`
+		Filter     *types.Filter `json:"filter,omitempty"`
 	}
 
-	payload, err := json.Marshal(request{Query: query, MaxResults: maxResults})
+	payload, err := json.Marshal(request{Query: query, MaxResults: maxResults, Filter: filter})
 	if err != nil {
 		return nil, err
 	}
@@ -290,72 +474,306 @@ func (c *Client) Reset(collection string) error {
 	return nil
 }
 
-// Store uploads a file to a collection
+// StoreDocuments stores raw text documents as entries of their own and
+// returns their entry keys, in order
+func (c *Client) StoreDocuments(collection string, docs ...types.Document) ([]string, error) {
+	url := fmt.Sprintf("%s/api/collections/%s/documents", c.BaseURL, collection)
+
+	payload, err := json.Marshal(docs)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.Post(url, "application/json", bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var apiResp struct {
+		Data struct {
+			Documents []struct {
+				ID  string `json:"id"`
+				Key string `json:"key"`
+			} `json:"documents"`
+		} `json:"data"`
+		Error *struct {
+			Message string `json:"message"`
+			Details string `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return nil, fmt.Errorf("failed to store documents: status %d", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if apiResp.Error != nil {
+			return nil, fmt.Errorf("failed to store documents: %s: %s", apiResp.Error.Message, apiResp.Error.Details)
+		}
+		return nil, fmt.Errorf("failed to store documents: status %d", resp.StatusCode)
+	}
+
+	keys := make([]string, 0, len(apiResp.Data.Documents))
+	for _, d := range apiResp.Data.Documents {
+		keys = append(keys, d.Key)
+	}
+	return keys, nil
+}
+
+// Store uploads a file to a collection and waits until it is ingested
 func (c *Client) Store(collection, filePath string) (string, error) {
-	url := fmt.Sprintf("%s/api/collections/%s/upload", c.BaseURL, collection)
+	return c.StoreWithMetadata(collection, filePath, nil)
+}
 
-	file, err := os.Open(filePath)
+// StoreWithMetadata uploads a file like Store, attaching metadata that is
+// merged into every chunk of the entry.
+func (c *Client) StoreWithMetadata(collection, filePath string, metadata map[string]string) (string, error) {
+	job, err := c.SubmitFile(collection, filePath, metadata)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.jobTimeout())
+	defer cancel()
+	job, err = c.WaitForJob(ctx, job.ID)
 	if err != nil {
 		return "", err
 	}
-	defer file.Close()
+	return job.Key, nil
+}
 
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
+func (c *Client) jobTimeout() time.Duration {
+	if c.JobTimeout > 0 {
+		return c.JobTimeout
+	}
+	return DefaultJobTimeout
+}
+
+// jobPollInterval is how often WaitForJob checks the state of a job.
+const jobPollInterval = 500 * time.Millisecond
+
+// SubmitFile uploads a file to a collection and returns the ingestion job
+// without waiting for it.
+func (c *Client) SubmitFile(collection, filePath string, metadata map[string]string) (types.Job, error) {
+	return c.submitFile(collection, filePath, metadata, nil)
+}
 
-	part, err := writer.CreateFormFile("file", file.Name())
+// StoreRecords uploads a JSON or JSON Lines file whose records are stored as
+// documents of their own following mapping, and waits until they are all
+// stored. The returned job counts the stored documents.
+func (c *Client) StoreRecords(collection, filePath string, mapping types.JSONMapping, metadata map[string]string) (types.Job, error) {
+	job, err := c.submitFile(collection, filePath, metadata, &mapping)
 	if err != nil {
-		return "", err
+		return types.Job{}, err
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.jobTimeout())
+	defer cancel()
+	return c.WaitForJob(ctx, job.ID)
+}
 
-	_, err = io.Copy(part, file)
+func (c *Client) submitFile(collection, filePath string, metadata map[string]string, mapping *types.JSONMapping) (types.Job, error) {
+	resp, err := c.upload(collection, metadata, mapping, filePath)
 	if err != nil {
-		return "", err
+		return types.Job{}, err
 	}
+	defer resp.Body.Close()
 
-	err = writer.Close()
+	var successResp struct {
+		Success bool `json:"success"`
+		Data    struct {
+			Job *types.Job `json:"job"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&successResp); err != nil {
+		return types.Job{}, err
+	}
+	if successResp.Data.Job == nil {
+		return types.Job{}, errors.New("no job returned: archives expand into several jobs, use SubmitFiles")
+	}
+	return *successResp.Data.Job, nil
+}
+
+// SubmitFiles uploads several files to a collection in one request, without
+// waiting for their ingestion. Archives (.zip, .tar.gz) are expanded by the
+// server. It returns the outcome of each file; files that could not be
+// queued have their Error set.
+func (c *Client) SubmitFiles(collection string, metadata map[string]string, filePaths ...string) ([]types.UploadResult, error) {
+	resp, err := c.upload(collection, metadata, nil, filePaths...)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var successResp struct {
+		Data struct {
+			Files []types.UploadResult `json:"files"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&successResp); err != nil {
+		return nil, err
+	}
+	return successResp.Data.Files, nil
+}
+
+func (c *Client) upload(collection string, metadata map[string]string, mapping *types.JSONMapping, filePaths ...string) (*http.Response, error) {
+	url := fmt.Sprintf("%s/api/collections/%s/upload", c.BaseURL, collection)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	for _, filePath := range filePaths {
+		file, err := os.Open(filePath)
+		if err != nil {
+			return nil, err
+		}
+		part, err := writer.CreateFormFile("file", file.Name())
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		_, err = io.Copy(part, file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(metadata) > 0 {
+		md, err := json.Marshal(metadata)
+		if err != nil {
+			return nil, err
+		}
+		if err := writer.WriteField("metadata", string(md)); err != nil {
+			return nil, err
+		}
+	}
+	if mapping != nil {
+		m, err := json.Marshal(mapping)
+		if err != nil {
+			return nil, err
+		}
+		if err := writer.WriteField("mapping", string(m)); err != nil {
+			return nil, err
+		}
+	}
+
+	err := writer.Close()
+	if err != nil {
+		return nil, err
 	}
 
 	req, err := http.NewRequest(http.MethodPost, url, body)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		var r struct {
+			Error *struct {
+				Message string `json:"message"`
+				Details string `json:"details"`
+			} `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&r); err == nil && r.Error != nil {
+			return nil, fmt.Errorf("failed to upload file: %s: %s", r.Error.Message, r.Error.Details)
+		}
+		return nil, errors.New("failed to upload file")
+	}
+	return resp, nil
+}
+
+// GetJob returns the state of an ingestion job
+func (c *Client) GetJob(id string) (types.Job, error) {
+	return c.getJob(context.Background(), id)
+}
+
+func (c *Client) getJob(ctx context.Context, id string) (types.Job, error) {
+	apiURL := fmt.Sprintf("%s/api/jobs/%s", c.BaseURL, url.PathEscape(id))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return types.Job{}, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return types.Job{}, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		b := new(bytes.Buffer)
-		b.ReadFrom(resp.Body)
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return types.Job{}, errors.New("job not found")
+	default:
+		return types.Job{}, fmt.Errorf("failed to get job: status %d", resp.StatusCode)
+	}
 
-		type response struct {
-			Error string `json:"error"`
-		}
+	var result struct {
+		Data types.Job `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return types.Job{}, err
+	}
+	return result.Data, nil
+}
 
-		var r response
-		err = json.Unmarshal(b.Bytes(), &r)
-		if err == nil {
-			return "", errors.New("failed to upload file: " + r.Error)
-		}
+// RetryJob queues a failed ingestion job again from its staged file.
+func (c *Client) RetryJob(id string) (types.Job, error) {
+	apiURL := fmt.Sprintf("%s/api/jobs/%s/retry", c.BaseURL, url.PathEscape(id))
 
-		return "", errors.New("failed to upload file")
+	resp, err := http.Post(apiURL, "application/json", nil)
+	if err != nil {
+		return types.Job{}, err
 	}
+	defer resp.Body.Close()
 
-	var successResp struct {
-		Success bool `json:"success"`
-		Data    struct {
-			Key string `json:"key"`
-		} `json:"data"`
+	switch resp.StatusCode {
+	case http.StatusAccepted:
+	case http.StatusNotFound:
+		return types.Job{}, errors.New("job not found")
+	default:
+		return types.Job{}, fmt.Errorf("failed to retry job: status %d", resp.StatusCode)
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&successResp); err != nil {
-		return "", nil // upload succeeded, just can't parse key
+
+	var result struct {
+		Data types.Job `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return types.Job{}, err
+	}
+	return result.Data, nil
+}
+
+// WaitForJob polls an ingestion job until it finishes or ctx is done. It
+// returns an error if the job failed, and the last state seen with the
+// context's error if the job did not finish in time.
+func (c *Client) WaitForJob(ctx context.Context, id string) (types.Job, error) {
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+	for {
+		job, err := c.getJob(ctx, id)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return job, fmt.Errorf("waiting for job %s: %w", id, ctxErr)
+			}
+			return job, err
+		}
+		switch job.State {
+		case types.JobSucceeded:
+			return job, nil
+		case types.JobFailed:
+			return job, errors.New("failed to ingest file: " + job.Error)
+		}
+		select {
+		case <-ctx.Done():
+			return job, fmt.Errorf("waiting for job %s, still %s: %w", id, job.State, ctx.Err())
+		case <-ticker.C:
+		}
 	}
-	return successResp.Data.Key, nil
 }
@@ -0,0 +1,355 @@
+package extract
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	wordNS = "http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+	mcNS   = "http://schemas.openxmlformats.org/markup-compatibility/2006"
+)
+
+// DOCX returns the text of a Word document as Markdown. Headings (from
+// outline levels or heading styles), list items and tables are kept.
+func DOCX(path string) (string, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open docx: %w", err)
+	}
+	defer zr.Close()
+
+	styles := map[string]wordStyle{}
+	if f := findZipFile(&zr.Reader, "word/styles.xml"); f != nil {
+		if styles, err = readZipXML(f, parseWordStyles); err != nil {
+			return "", fmt.Errorf("failed to read docx styles: %w", err)
+		}
+	}
+	f := findZipFile(&zr.Reader, "word/document.xml")
+	if f == nil {
+		return "", errors.New("not a docx file: word/document.xml is missing")
+	}
+	text, err := readZipXML(f, func(d *xml.Decoder) (string, error) {
+		return parseWordDocument(d, styles)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to read docx: %w", err)
+	}
+	return text, nil
+}
+
+// wordStyle is the part of a paragraph style that decides headings.
+type wordStyle struct {
+	name         string
+	basedOn      string
+	outlineLevel int // -1 when unset
+}
+
+// headingLevel resolves the Markdown heading level of a style, following
+// basedOn links. It returns 0 for body text.
+func headingLevel(styles map[string]wordStyle, id string) int {
+	for range 10 {
+		s, ok := styles[id]
+		if !ok {
+			return 0
+		}
+		if s.outlineLevel >= 0 {
+			return outlineToHeading(s.outlineLevel)
+		}
+		if level := styleHeadingLevel(s.name); level > 0 {
+			return level
+		}
+		id = s.basedOn
+	}
+	return 0
+}
+
+// outlineToHeading maps a zero-based outline level to a heading level; level
+// 9 means body text.
+func outlineToHeading(level int) int {
+	if level < 0 || level > 8 {
+		return 0
+	}
+	return level + 1
+}
+
+func parseWordStyles(d *xml.Decoder) (map[string]wordStyle, error) {
+	styles := map[string]wordStyle{}
+	var current *wordStyle
+	var currentID string
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return styles, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space != wordNS {
+				continue
+			}
+			switch t.Name.Local {
+			case "style":
+				currentID = wordAttr(t, "styleId")
+				current = &wordStyle{outlineLevel: -1}
+			case "name":
+				if current != nil {
+					current.name = wordAttr(t, "val")
+				}
+			case "basedOn":
+				if current != nil {
+					current.basedOn = wordAttr(t, "val")
+				}
+			case "outlineLvl":
+				if current != nil {
+					current.outlineLevel = atoiDefault(wordAttr(t, "val"), -1)
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Space == wordNS && t.Name.Local == "style" && current != nil {
+				styles[currentID] = *current
+				current = nil
+			}
+		}
+	}
+}
+
+func parseWordDocument(d *xml.Decoder, styles map[string]wordStyle) (string, error) {
+	w := &markdownWriter{}
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return w.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch {
+		case start.Name.Space == mcNS && start.Name.Local == "Fallback":
+			if err := d.Skip(); err != nil {
+				return "", err
+			}
+		case start.Name.Space != wordNS:
+		case start.Name.Local == "p":
+			p, err := readWordParagraph(d)
+			if err != nil {
+				return "", err
+			}
+			level := outlineToHeading(p.outlineLevel)
+			if p.outlineLevel < 0 {
+				level = headingLevel(styles, p.style)
+			}
+			switch {
+			case level > 0:
+				w.heading(level, p.text)
+			case p.listLevel >= 0:
+				w.listItem(p.listLevel, p.text)
+			default:
+				w.paragraph(p.text)
+			}
+		case start.Name.Local == "tbl":
+			rows, err := readWordTable(d)
+			if err != nil {
+				return "", err
+			}
+			w.table(rows)
+		}
+	}
+}
+
+type wordParagraph struct {
+	text         string
+	style        string
+	outlineLevel int // -1 when unset
+	listLevel    int // -1 when not a list item
+}
+
+// readWordParagraph reads a w:p element after its start tag. Paragraphs
+// nested in text boxes are folded into the text.
+func readWordParagraph(d *xml.Decoder) (wordParagraph, error) {
+	p := wordParagraph{outlineLevel: -1, listLevel: -1}
+	var sb strings.Builder
+	depth := 0
+	inText := false
+	inProps := false
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return p, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if t.Name.Space == mcNS && t.Name.Local == "Fallback" {
+				if err := d.Skip(); err != nil {
+					return p, err
+				}
+				depth--
+				continue
+			}
+			if t.Name.Space != wordNS {
+				continue
+			}
+			switch t.Name.Local {
+			case "pPr":
+				inProps = depth == 1
+			case "pStyle":
+				if inProps {
+					p.style = wordAttr(t, "val")
+				}
+			case "outlineLvl":
+				if inProps {
+					p.outlineLevel = atoiDefault(wordAttr(t, "val"), -1)
+				}
+			case "numPr":
+				if inProps && p.listLevel < 0 {
+					p.listLevel = 0
+				}
+			case "ilvl":
+				if inProps {
+					p.listLevel = atoiDefault(wordAttr(t, "val"), 0)
+				}
+			case "t":
+				inText = true
+			case "tab":
+				if !inProps {
+					sb.WriteString("\t")
+				}
+			case "br", "cr":
+				sb.WriteString("\n")
+			case "noBreakHyphen":
+				sb.WriteString("-")
+			}
+		case xml.EndElement:
+			depth--
+			if depth < 0 {
+				p.text = sb.String()
+				return p, nil
+			}
+			if t.Name.Space != wordNS {
+				continue
+			}
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "pPr":
+				if depth == 0 {
+					inProps = false
+				}
+			case "p":
+				sb.WriteString(" ")
+			}
+		case xml.CharData:
+			if inText {
+				sb.Write(t)
+			}
+		}
+	}
+}
+
+// readWordTable reads a w:tbl element after its start tag. Tables nested in
+// cells are flattened into the cell text.
+func readWordTable(d *xml.Decoder) ([][]string, error) {
+	var rows [][]string
+	var cell []string
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space == mcNS && t.Name.Local == "Fallback" {
+				if err := d.Skip(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if t.Name.Space != wordNS {
+				continue
+			}
+			switch t.Name.Local {
+			case "tr":
+				rows = append(rows, nil)
+			case "tc":
+				cell = nil
+			case "p":
+				p, err := readWordParagraph(d)
+				if err != nil {
+					return nil, err
+				}
+				cell = append(cell, p.text)
+			case "tbl":
+				nested, err := readWordTable(d)
+				if err != nil {
+					return nil, err
+				}
+				for _, row := range nested {
+					cell = append(cell, strings.Join(row, " "))
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Space != wordNS {
+				continue
+			}
+			switch t.Name.Local {
+			case "tc":
+				if len(rows) > 0 {
+					rows[len(rows)-1] = append(rows[len(rows)-1], strings.Join(cell, " "))
+				}
+			case "tbl":
+				return rows, nil
+			}
+		}
+	}
+}
+
+func wordAttr(start xml.StartElement, local string) string {
+	for _, a := range start.Attr {
+		if a.Name.Local == local && (a.Name.Space == wordNS || a.Name.Space == "") {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func atoiDefault(s string, def int) int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+func findZipFile(zr *zip.Reader, name string) *zip.File {
+	for _, f := range zr.File {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// readZipXML runs parse over an XML member of a zip archive.
+func readZipXML[T any](f *zip.File, parse func(*xml.Decoder) (T, error)) (T, error) {
+	rc, err := f.Open()
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	defer rc.Close()
+	d := xml.NewDecoder(rc)
+	d.Strict = false
+	return parse(d)
+}
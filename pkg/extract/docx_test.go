@@ -0,0 +1,74 @@
+package extract_test
+
+import (
+	. "github.com/mudler/localrecall/pkg/extract"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const docxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+  <w:style w:type="paragraph" w:styleId="Title"><w:name w:val="Title"/></w:style>
+  <w:style w:type="paragraph" w:styleId="Heading1"><w:name w:val="heading 1"/><w:pPr><w:outlineLvl w:val="0"/></w:pPr></w:style>
+  <w:style w:type="paragraph" w:styleId="Heading2"><w:name w:val="heading 2"/></w:style>
+  <w:style w:type="paragraph" w:styleId="Custom"><w:name w:val="Custom"/><w:basedOn w:val="Heading2"/></w:style>
+</w:styles>`
+
+const docxDocument = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+  xmlns:mc="http://schemas.openxmlformats.org/markup-compatibility/2006">
+  <w:body>
+    <w:p><w:pPr><w:pStyle w:val="Title"/></w:pPr><w:r><w:t>Handbook</w:t></w:r></w:p>
+    <w:p><w:r><w:t xml:space="preserve">Welcome to the </w:t></w:r><w:r><w:t>team.</w:t></w:r></w:p>
+    <w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>Onboarding</w:t></w:r></w:p>
+    <w:p><w:pPr><w:pStyle w:val="Custom"/></w:pPr><w:r><w:t>First week</w:t></w:r></w:p>
+    <w:p><w:pPr><w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr></w:pPr><w:r><w:t>Get a laptop</w:t></w:r></w:p>
+    <w:p><w:pPr><w:numPr><w:ilvl w:val="1"/><w:numId w:val="1"/></w:numPr></w:pPr><w:r><w:t>Install tools</w:t></w:r></w:p>
+    <w:p><w:r><w:t>Name</w:t></w:r><w:r><w:tab/><w:t>Role</w:t></w:r><w:r><w:br/><w:t>Second line</w:t></w:r></w:p>
+    <w:p><w:r><mc:AlternateContent><mc:Choice Requires="wps"><w:t>shape text</w:t></mc:Choice><mc:Fallback><w:t>fallback text</w:t></mc:Fallback></mc:AlternateContent></w:r></w:p>
+    <w:tbl>
+      <w:tr><w:tc><w:p><w:r><w:t>Day</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>Task</w:t></w:r></w:p></w:tc></w:tr>
+      <w:tr><w:tc><w:p><w:r><w:t>Monday</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>Meet | greet</w:t></w:r></w:p></w:tc></w:tr>
+    </w:tbl>
+    <w:p><w:pPr><w:outlineLvl w:val="2"/></w:pPr><w:r><w:t>Appendix</w:t></w:r></w:p>
+    <w:sectPr/>
+  </w:body>
+</w:document>`
+
+var _ = Describe("DOCX", func() {
+	It("renders headings, lists and tables as Markdown", func() {
+		path := writeZip("handbook.docx", map[string]string{
+			"word/document.xml": docxDocument,
+			"word/styles.xml":   docxStyles,
+		})
+		text, err := DOCX(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(text).To(Equal(`# Handbook
+
+Welcome to the team.
+
+# Onboarding
+
+## First week
+
+- Get a laptop
+  - Install tools
+
+Name Role
+Second line
+
+shape text
+
+| Day | Task |
+| --- | --- |
+| Monday | Meet \| greet |
+
+### Appendix
+`))
+	})
+
+	It("fails on files that are not Word documents", func() {
+		_, err := DOCX(writeZip("empty.docx", map[string]string{"readme.txt": "hi"}))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,387 @@
+package extract
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+// Email is a parsed email message.
+type Email struct {
+	From    string
+	To      string
+	Cc      string
+	Subject string
+	// Date is the sending date, or the zero time if the message has none.
+	Date time.Time
+	// MessageID, InReplyTo and References hold message IDs without their
+	// angle brackets.
+	MessageID  string
+	InReplyTo  string
+	References []string
+	// Text is the message body. text/plain is preferred over text/html,
+	// which is converted to Markdown.
+	Text string
+	// Attachments are the attached files, including forwarded messages.
+	Attachments []Attachment
+}
+
+// Attachment is a file attached to an email.
+type Attachment struct {
+	// Name is the file name of the attachment, made safe to use as the
+	// base name of a file.
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// ThreadID returns the ID of the first message of the thread the message
+// belongs to: the first of its references, else the message it replies to,
+// else its own ID.
+func (e Email) ThreadID() string {
+	if len(e.References) > 0 {
+		return e.References[0]
+	}
+	if e.InReplyTo != "" {
+		return e.InReplyTo
+	}
+	return e.MessageID
+}
+
+// maxEmailDepth bounds the nesting of multipart bodies.
+const maxEmailDepth = 16
+
+// EML reads the email message stored at path.
+func EML(path string) (Email, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Email{}, err
+	}
+	defer f.Close()
+	return ParseEmail(f)
+}
+
+// ParseEmail parses an RFC 5322 message with MIME bodies read from r.
+func ParseEmail(r io.Reader) (Email, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return Email{}, fmt.Errorf("failed to read email: %w", err)
+	}
+	decoder := &mime.WordDecoder{CharsetReader: charset.NewReaderLabel}
+	header := func(name string) string {
+		value := msg.Header.Get(name)
+		if decoded, err := decoder.DecodeHeader(value); err == nil {
+			value = decoded
+		}
+		return singleLine(value)
+	}
+
+	email := Email{
+		From:       header("From"),
+		To:         header("To"),
+		Cc:         header("Cc"),
+		Subject:    header("Subject"),
+		MessageID:  firstMessageID(msg.Header.Get("Message-ID")),
+		InReplyTo:  firstMessageID(msg.Header.Get("In-Reply-To")),
+		References: messageIDs(msg.Header.Get("References")),
+	}
+	if date, err := msg.Header.Date(); err == nil {
+		email.Date = date
+	}
+
+	p := &emailParser{decoder: decoder}
+	if err := p.part(textproto.MIMEHeader(msg.Header), msg.Body, 0); err != nil {
+		return Email{}, err
+	}
+	email.Text = p.text()
+	email.Attachments = p.attachments
+	return email, nil
+}
+
+// MBOX calls fn with the raw content of every message of the mbox file at
+// path, in order. Lines escaped as ">From " are unescaped. An error returned
+// by fn stops the walk and is returned.
+func MBOX(path string, fn func(message []byte) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var message bytes.Buffer
+	started, blank := false, true
+	flush := func() error {
+		if !started {
+			return nil
+		}
+		// The blank line before the next separator is not part of the message.
+		content := bytes.TrimSuffix(message.Bytes(), []byte("\n"))
+		content = bytes.TrimSuffix(content, []byte("\r"))
+		message.Reset()
+		if len(bytes.TrimSpace(content)) == 0 {
+			return nil
+		}
+		return fn(content)
+	}
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			switch {
+			case blank && bytes.HasPrefix(line, []byte("From ")):
+				if ferr := flush(); ferr != nil {
+					return ferr
+				}
+				started = true
+			case !started:
+				if len(bytes.TrimSpace(line)) > 0 {
+					return errors.New("not an mbox file: missing From separator")
+				}
+			default:
+				if unquoted := bytes.TrimLeft(line, ">"); len(unquoted) < len(line) && bytes.HasPrefix(unquoted, []byte("From ")) {
+					line = line[1:]
+				}
+				message.Write(line)
+			}
+			blank = len(bytes.TrimRight(line, "\r\n")) == 0
+		}
+		if errors.Is(err, io.EOF) {
+			return flush()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// emailParser collects the bodies and attachments of the parts of a message.
+type emailParser struct {
+	decoder     *mime.WordDecoder
+	plain       []string
+	html        []string
+	attachments []Attachment
+}
+
+// text returns the plain text bodies, or the HTML ones if there are none.
+func (p *emailParser) text() string {
+	bodies := p.plain
+	if len(bodies) == 0 {
+		bodies = p.html
+	}
+	return strings.TrimSpace(strings.Join(bodies, "\n\n"))
+}
+
+// part collects the body or attachment of a MIME part. Named parts, and parts
+// that are neither plain text nor HTML, are attachments.
+func (p *emailParser) part(header textproto.MIMEHeader, body io.Reader, depth int) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+	body = transferDecoder(header.Get("Content-Transfer-Encoding"), body)
+
+	disposition, dispositionParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	name := dispositionParams["filename"]
+	if name == "" {
+		name = params["name"]
+	}
+	if decoded, err := p.decoder.DecodeHeader(name); err == nil {
+		name = decoded
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/") && depth < maxEmailDepth:
+		return p.multipart(mediaType, params["boundary"], body, depth)
+	case disposition == "attachment" || name != "" || (mediaType != "text/plain" && mediaType != "text/html"):
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return fmt.Errorf("failed to read attachment: %w", err)
+		}
+		p.attachments = append(p.attachments, Attachment{Name: attachmentName(name, mediaType, len(p.attachments)), ContentType: mediaType, Data: data})
+		return nil
+	case mediaType == "text/html":
+		text, err := emailHTML(body, params["charset"])
+		if err != nil {
+			return err
+		}
+		p.html = append(p.html, text)
+		return nil
+	default:
+		text, err := decodeCharset(body, params["charset"])
+		if err != nil {
+			return err
+		}
+		p.plain = append(p.plain, strings.TrimSpace(text))
+		return nil
+	}
+}
+
+// multipart parses the parts of a multipart body. Of the alternatives of a
+// multipart/alternative body only the plain text one is kept, or else the
+// HTML one.
+func (p *emailParser) multipart(mediaType, boundary string, body io.Reader, depth int) error {
+	if boundary == "" {
+		return errors.New("multipart body without boundary")
+	}
+	target := p
+	if mediaType == "multipart/alternative" {
+		target = &emailParser{decoder: p.decoder}
+	}
+	mr := multipart.NewReader(body, boundary)
+	for {
+		part, err := mr.NextRawPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read multipart body: %w", err)
+		}
+		if err := target.part(part.Header, part, depth+1); err != nil {
+			return err
+		}
+	}
+	if target != p {
+		if text := target.text(); text != "" {
+			if len(target.plain) > 0 {
+				p.plain = append(p.plain, text)
+			} else {
+				p.html = append(p.html, text)
+			}
+		}
+		p.attachments = append(p.attachments, target.attachments...)
+	}
+	return nil
+}
+
+func transferDecoder(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, &base64Cleaner{r: r})
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
+	return r
+}
+
+// base64Cleaner drops the line breaks and spaces of a base64 body.
+type base64Cleaner struct {
+	r io.Reader
+}
+
+func (c *base64Cleaner) Read(p []byte) (int, error) {
+	for {
+		n, err := c.r.Read(p)
+		kept := 0
+		for _, b := range p[:n] {
+			if b != '\r' && b != '\n' && b != ' ' && b != '\t' {
+				p[kept] = b
+				kept++
+			}
+		}
+		if kept > 0 || err != nil {
+			return kept, err
+		}
+	}
+}
+
+func decodeCharset(r io.Reader, label string) (string, error) {
+	if label != "" {
+		if decoded, err := charset.NewReaderLabel(label, r); err == nil {
+			r = decoded
+		}
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to read email body: %w", err)
+	}
+	return strings.ReplaceAll(string(data), "\r\n", "\n"), nil
+}
+
+// emailHTML renders an HTML body as Markdown. Unlike a web page, all of it is
+// content.
+func emailHTML(r io.Reader, label string) (string, error) {
+	contentType := "text/html"
+	if label != "" {
+		contentType += "; charset=" + label
+	}
+	r, err := charset.NewReader(r, contentType)
+	if err != nil {
+		return "", fmt.Errorf("failed to detect html charset: %w", err)
+	}
+	doc, err := html.Parse(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse html: %w", err)
+	}
+	body := findElement(doc, atom.Body)
+	if body == nil {
+		body = doc
+	}
+	return renderHTML(body), nil
+}
+
+// attachmentName returns a safe file name for an attachment, naming unnamed
+// ones after their position and media type.
+func attachmentName(name, mediaType string, index int) string {
+	name = path.Base(strings.ReplaceAll(strings.TrimSpace(name), `\`, "/"))
+	if name != "." && name != "/" && name != ".." {
+		return name
+	}
+	ext := ".bin"
+	switch mediaType {
+	case "message/rfc822":
+		ext = ".eml"
+	default:
+		if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+			ext = exts[0]
+		}
+	}
+	return fmt.Sprintf("attachment-%d%s", index+1, ext)
+}
+
+// messageIDs returns the message IDs of a Message-ID, In-Reply-To or
+// References header, without their angle brackets.
+func messageIDs(value string) []string {
+	var ids []string
+	for {
+		start := strings.IndexByte(value, '<')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(value[start:], '>')
+		if end < 0 {
+			break
+		}
+		if id := strings.TrimSpace(value[start+1 : start+end]); id != "" {
+			ids = append(ids, id)
+		}
+		value = value[start+end+1:]
+	}
+	if len(ids) == 0 {
+		// Some mailers omit the brackets.
+		ids = strings.Fields(value)
+	}
+	return ids
+}
+
+func firstMessageID(value string) string {
+	if ids := messageIDs(value); len(ids) > 0 {
+		return ids[0]
+	}
+	return ""
+}
@@ -0,0 +1,95 @@
+package extract_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/mudler/localrecall/pkg/extract"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const multipartEmail = "From: =?UTF-8?Q?Ren=C3=A9e?= <renee@example.com>\r\n" +
+	"To: dev@lists.example.com\r\n" +
+	"Subject: Re: Release plan\r\n" +
+	"Date: Mon, 02 Jan 2006 15:04:05 -0700\r\n" +
+	"Message-ID: <reply-1@example.com>\r\n" +
+	"In-Reply-To: <root@example.com>\r\n" +
+	"References: <root@example.com> <other@example.com>\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=\"outer\"\r\n" +
+	"\r\n" +
+	"--outer\r\n" +
+	"Content-Type: multipart/alternative; boundary=\"inner\"\r\n" +
+	"\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"\r\n" +
+	"Ship it on Fri=\r\n" +
+	"day.\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/html; charset=utf-8\r\n" +
+	"\r\n" +
+	"<p>Ship it on <b>Friday</b>.</p>\r\n" +
+	"--inner--\r\n" +
+	"--outer\r\n" +
+	"Content-Type: text/markdown; name=\"notes.md\"\r\n" +
+	"Content-Disposition: attachment; filename=\"../notes.md\"\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"IyBOb3Rlcwp0ZXN0ZWQ=\r\n" +
+	"--outer--\r\n"
+
+var _ = Describe("Email", func() {
+	It("parses headers, prefers the plain text body and collects attachments", func() {
+		email, err := ParseEmail(strings.NewReader(multipartEmail))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(email.From).To(Equal("Renée <renee@example.com>"))
+		Expect(email.To).To(Equal("dev@lists.example.com"))
+		Expect(email.Subject).To(Equal("Re: Release plan"))
+		Expect(email.Date.UTC()).To(Equal(time.Date(2006, 1, 2, 22, 4, 5, 0, time.UTC)))
+		Expect(email.MessageID).To(Equal("reply-1@example.com"))
+		Expect(email.InReplyTo).To(Equal("root@example.com"))
+		Expect(email.References).To(Equal([]string{"root@example.com", "other@example.com"}))
+		Expect(email.ThreadID()).To(Equal("root@example.com"))
+		Expect(email.Text).To(Equal("Ship it on Friday."))
+		Expect(email.Attachments).To(HaveLen(1))
+		Expect(email.Attachments[0].Name).To(Equal("notes.md"))
+		Expect(string(email.Attachments[0].Data)).To(Equal("# Notes\ntested"))
+	})
+
+	It("falls back to the HTML body", func() {
+		email, err := ParseEmail(strings.NewReader("Subject: Hi\r\nContent-Type: text/html; charset=iso-8859-1\r\n\r\n<html><body><h2>Caf\xe9</h2><style>p{}</style><p>Open</p></body></html>"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(email.Text).To(Equal("## Café\n\nOpen"))
+		Expect(email.ThreadID()).To(BeEmpty())
+	})
+
+	It("splits mbox files into messages", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "list.mbox")
+		Expect(os.WriteFile(path, []byte("From a@example.com Mon Jan  2 15:04:05 2006\n"+
+			"Subject: one\nMessage-ID: <1@example.com>\n\nFirst\n>From the start\n\n"+
+			"From b@example.com Mon Jan  2 16:04:05 2006\n"+
+			"Subject: two\n\nSecond\n"), 0644)).To(Succeed())
+
+		var subjects, bodies []string
+		Expect(MBOX(path, func(message []byte) error {
+			email, err := ParseEmail(strings.NewReader(string(message)))
+			Expect(err).ToNot(HaveOccurred())
+			subjects = append(subjects, email.Subject)
+			bodies = append(bodies, email.Text)
+			return nil
+		})).To(Succeed())
+		Expect(subjects).To(Equal([]string{"one", "two"}))
+		Expect(bodies).To(Equal([]string{"First\nFrom the start", "Second"}))
+	})
+
+	It("rejects files that are not mbox", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "notes.mbox")
+		Expect(os.WriteFile(path, []byte("just text\n"), 0644)).To(Succeed())
+		Expect(MBOX(path, func([]byte) error { return nil })).ToNot(Succeed())
+	})
+})
@@ -0,0 +1,294 @@
+package extract
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Book is the text of an e-book, chapter by chapter in reading order.
+type Book struct {
+	Title    string
+	Chapters []Chapter
+}
+
+// Chapter is one document of a book's reading order.
+type Chapter struct {
+	// Title is the chapter's table of contents entry, or else its first
+	// heading.
+	Title string
+	// Text is the chapter content as Markdown.
+	Text string
+}
+
+// Text returns the chapters of the book as one Markdown document.
+func (b Book) Text() string {
+	parts := make([]string, 0, len(b.Chapters))
+	for _, c := range b.Chapters {
+		parts = append(parts, c.Text)
+	}
+	return strings.Join(parts, "\n")
+}
+
+// EPUB reads an EPUB 2 or 3 book. The documents of the OPF spine are read in
+// reading order, skipping non-linear ones, and titled after the EPUB 3
+// navigation document or the EPUB 2 NCX table of contents.
+func EPUB(fpath string) (Book, error) {
+	zr, err := zip.OpenReader(fpath)
+	if err != nil {
+		return Book{}, fmt.Errorf("failed to open epub: %w", err)
+	}
+	defer zr.Close()
+
+	container := findZipFile(&zr.Reader, "META-INF/container.xml")
+	if container == nil {
+		return Book{}, errors.New("not an epub file: META-INF/container.xml is missing")
+	}
+	opfPath, err := readZipXML(container, parseEPUBContainer)
+	if err != nil {
+		return Book{}, fmt.Errorf("failed to read epub container: %w", err)
+	}
+	opfFile := findZipFile(&zr.Reader, opfPath)
+	if opfFile == nil {
+		return Book{}, fmt.Errorf("epub package %s is missing", opfPath)
+	}
+	pkg, err := readZipXML(opfFile, func(d *xml.Decoder) (opfPackage, error) {
+		var pkg opfPackage
+		return pkg, d.Decode(&pkg)
+	})
+	if err != nil {
+		return Book{}, fmt.Errorf("failed to read epub package: %w", err)
+	}
+
+	// Manifest hrefs are relative to the package document.
+	items := map[string]opfItem{}
+	for _, item := range pkg.Manifest {
+		item.Href = resolveHref(opfPath, item.Href)
+		items[item.ID] = item
+	}
+
+	toc := map[string]string{}
+	for _, item := range items {
+		if strings.Contains(" "+item.Properties+" ", " nav ") {
+			if f := findZipFile(&zr.Reader, item.Href); f != nil {
+				if toc, err = readNavTOC(f, item.Href); err != nil {
+					return Book{}, fmt.Errorf("failed to read epub navigation: %w", err)
+				}
+			}
+			break
+		}
+	}
+	if ncx, ok := items[pkg.Spine.TOC]; ok && len(toc) == 0 {
+		if f := findZipFile(&zr.Reader, ncx.Href); f != nil {
+			toc, err = readZipXML(f, func(d *xml.Decoder) (map[string]string, error) {
+				return parseNCX(d, ncx.Href)
+			})
+			if err != nil {
+				return Book{}, fmt.Errorf("failed to read epub table of contents: %w", err)
+			}
+		}
+	}
+
+	var book Book
+	if len(pkg.Titles) > 0 {
+		book.Title = singleLine(pkg.Titles[0])
+	}
+	previous := ""
+	for _, ref := range pkg.Spine.ItemRefs {
+		item, ok := items[ref.IDRef]
+		if !ok || ref.Linear == "no" || (item.MediaType != "application/xhtml+xml" && item.MediaType != "text/html") {
+			continue
+		}
+		f := findZipFile(&zr.Reader, item.Href)
+		if f == nil {
+			continue
+		}
+		chapter, docTitle, err := readEPUBChapter(f)
+		if err != nil {
+			return Book{}, fmt.Errorf("failed to read %s: %w", item.Href, err)
+		}
+		switch title, ok := toc[item.Href]; {
+		case ok:
+			chapter.Title = title
+		case chapter.Title != "":
+		case previous != "":
+			// Long chapters are often split across files of which only
+			// the first is in the table of contents.
+			chapter.Title = previous
+		default:
+			chapter.Title = docTitle
+		}
+		previous = chapter.Title
+		if strings.TrimSpace(chapter.Text) != "" {
+			book.Chapters = append(book.Chapters, chapter)
+		}
+	}
+	return book, nil
+}
+
+type opfPackage struct {
+	Titles   []string  `xml:"metadata>title"`
+	Manifest []opfItem `xml:"manifest>item"`
+	Spine    struct {
+		TOC      string `xml:"toc,attr"`
+		ItemRefs []struct {
+			IDRef  string `xml:"idref,attr"`
+			Linear string `xml:"linear,attr"`
+		} `xml:"itemref"`
+	} `xml:"spine"`
+}
+
+type opfItem struct {
+	ID         string `xml:"id,attr"`
+	Href       string `xml:"href,attr"`
+	MediaType  string `xml:"media-type,attr"`
+	Properties string `xml:"properties,attr"`
+}
+
+func parseEPUBContainer(d *xml.Decoder) (string, error) {
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return "", errors.New("no rootfile found")
+		}
+		if err != nil {
+			return "", err
+		}
+		if t, ok := tok.(xml.StartElement); ok && t.Name.Local == "rootfile" {
+			if p := xmlAttr(t, "", "full-path"); p != "" {
+				return p, nil
+			}
+		}
+	}
+}
+
+// resolveHref resolves a link found in the archive member base to the name
+// of the member it points to, without its fragment.
+func resolveHref(base, href string) string {
+	href, _, _ = strings.Cut(href, "#")
+	if unescaped, err := url.PathUnescape(href); err == nil {
+		href = unescaped
+	}
+	if strings.HasPrefix(href, "/") {
+		return strings.TrimPrefix(path.Clean(href), "/")
+	}
+	return path.Join(path.Dir(base), href)
+}
+
+// readNavTOC maps the documents linked from the toc nav of an EPUB 3
+// navigation document to the title of their first entry.
+func readNavTOC(f *zip.File, name string) (map[string]string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	doc, err := html.Parse(rc)
+	if err != nil {
+		return nil, err
+	}
+
+	navs := findAll(doc, func(n *html.Node) bool { return n.DataAtom == atom.Nav })
+	var tocNav *html.Node
+	for _, nav := range navs {
+		if t, _ := attr(nav, "epub:type"); strings.Contains(" "+t+" ", " toc ") {
+			tocNav = nav
+			break
+		}
+	}
+	if tocNav == nil && len(navs) > 0 {
+		tocNav = navs[0]
+	}
+	toc := map[string]string{}
+	if tocNav == nil {
+		return toc, nil
+	}
+	for _, a := range findAll(tocNav, func(n *html.Node) bool { return n.DataAtom == atom.A }) {
+		href, _ := attr(a, "href")
+		title := singleLine(textContent(a))
+		if href == "" || title == "" {
+			continue
+		}
+		if target := resolveHref(name, href); toc[target] == "" {
+			toc[target] = title
+		}
+	}
+	return toc, nil
+}
+
+// parseNCX maps the documents of an EPUB 2 NCX table of contents to the
+// label of their first navigation point.
+func parseNCX(d *xml.Decoder, name string) (map[string]string, error) {
+	toc := map[string]string{}
+	var label strings.Builder
+	inText := false
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return toc, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "navLabel":
+				label.Reset()
+			case "text":
+				inText = true
+			case "content":
+				src := xmlAttr(t, "", "src")
+				title := singleLine(label.String())
+				if target := resolveHref(name, src); src != "" && title != "" && toc[target] == "" {
+					toc[target] = title
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Local == "text" {
+				inText = false
+			}
+		case xml.CharData:
+			if inText {
+				label.Write(t)
+			}
+		}
+	}
+}
+
+// readEPUBChapter renders an XHTML content document as Markdown, titled after
+// its first h1. It also returns the <title> of the document.
+func readEPUBChapter(f *zip.File) (Chapter, string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return Chapter{}, "", err
+	}
+	defer rc.Close()
+	doc, err := html.Parse(rc)
+	if err != nil {
+		return Chapter{}, "", err
+	}
+
+	var chapter Chapter
+	if h1 := findElement(doc, atom.H1); h1 != nil {
+		chapter.Title = singleLine(textContent(h1))
+	}
+	docTitle := ""
+	if title := findElement(doc, atom.Title); title != nil {
+		docTitle = singleLine(textContent(title))
+	}
+	body := findElement(doc, atom.Body)
+	if body == nil {
+		body = doc
+	}
+	chapter.Text = renderHTML(body)
+	return chapter, docTitle, nil
+}
@@ -0,0 +1,92 @@
+package extract_test
+
+import (
+	. "github.com/mudler/localrecall/pkg/extract"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const epubContainer = `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`
+
+var _ = Describe("EPUB", func() {
+	It("reads the spine in reading order, titled after the navigation document", func() {
+		path := writeZip("book.epub", map[string]string{
+			"mimetype":               "application/epub+zip",
+			"META-INF/container.xml": epubContainer,
+			"OEBPS/content.opf": `<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" xmlns:dc="http://purl.org/dc/elements/1.1/" version="3.0">
+  <metadata><dc:title>Go in Practice</dc:title></metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="cover" href="text/cover.xhtml" media-type="application/xhtml+xml"/>
+    <item id="ch2" href="text/ch2.xhtml" media-type="application/xhtml+xml"/>
+    <item id="ch1" href="text/ch1.xhtml" media-type="application/xhtml+xml"/>
+    <item id="ch1b" href="text/ch1b.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine>
+    <itemref idref="cover" linear="no"/>
+    <itemref idref="ch1"/>
+    <itemref idref="ch1b"/>
+    <itemref idref="ch2"/>
+  </spine>
+</package>`,
+			"OEBPS/nav.xhtml": `<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops"><body>
+  <nav epub:type="landmarks"><ol><li><a href="text/cover.xhtml">Cover</a></li></ol></nav>
+  <nav epub:type="toc"><ol>
+    <li><a href="text/ch1.xhtml">1. Getting started</a></li>
+    <li><a href="text/ch2.xhtml#top">2. Concurrency</a></li>
+  </ol></nav>
+</body></html>`,
+			"OEBPS/text/cover.xhtml": `<html><body><p>Cover art</p></body></html>`,
+			"OEBPS/text/ch1.xhtml":   `<html><head><title>ch1</title><style>p{}</style></head><body><h1>Getting started</h1><p>Install the toolchain.</p></body></html>`,
+			"OEBPS/text/ch1b.xhtml":  `<html><body><p>Write a first program.</p></body></html>`,
+			"OEBPS/text/ch2.xhtml":   `<html><body><h1>Concurrency</h1><h2>Goroutines</h2><p>Start with <code>go</code>.</p></body></html>`,
+		})
+		book, err := EPUB(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(book.Title).To(Equal("Go in Practice"))
+		Expect(book.Chapters).To(HaveLen(3))
+		Expect(book.Chapters[0].Title).To(Equal("1. Getting started"))
+		Expect(book.Chapters[0].Text).To(ContainSubstring("Install the toolchain."))
+		Expect(book.Chapters[0].Text).ToNot(ContainSubstring("p{}"))
+		Expect(book.Chapters[1].Title).To(Equal("1. Getting started"))
+		Expect(book.Chapters[1].Text).To(ContainSubstring("Write a first program."))
+		Expect(book.Chapters[2].Title).To(Equal("2. Concurrency"))
+		Expect(book.Chapters[2].Text).To(ContainSubstring("## Goroutines"))
+		Expect(book.Text()).ToNot(ContainSubstring("Cover art"))
+	})
+
+	It("falls back to the NCX table of contents of EPUB 2 books", func() {
+		path := writeZip("old.epub", map[string]string{
+			"META-INF/container.xml": epubContainer,
+			"OEBPS/content.opf": `<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" xmlns:dc="http://purl.org/dc/elements/1.1/" version="2.0">
+  <metadata><dc:title>Old Book</dc:title></metadata>
+  <manifest>
+    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+    <item id="c1" href="chapter%201.html" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine toc="ncx"><itemref idref="c1"/></spine>
+</package>`,
+			"OEBPS/toc.ncx": `<?xml version="1.0"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/"><navMap>
+  <navPoint id="p1"><navLabel><text>Prologue</text></navLabel><content src="chapter%201.html"/></navPoint>
+</navMap></ncx>`,
+			"OEBPS/chapter 1.html": `<html><body><p>It was a dark night.</p></body></html>`,
+		})
+		book, err := EPUB(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(book.Title).To(Equal("Old Book"))
+		Expect(book.Chapters).To(HaveLen(1))
+		Expect(book.Chapters[0].Title).To(Equal("Prologue"))
+		Expect(book.Chapters[0].Text).To(ContainSubstring("It was a dark night."))
+	})
+
+	It("fails on archives without a container", func() {
+		_, err := EPUB(writeZip("bad.epub", map[string]string{"mimetype": "application/epub+zip"}))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,545 @@
+package extract
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+// HTMLPage is the main content of an HTML page.
+type HTMLPage struct {
+	// Title is the page title: og:title, <title> or the first <h1>.
+	Title string
+	// Text is the main content as Markdown, without navigation, footers,
+	// scripts, cookie banners and other boilerplate.
+	Text string
+	// Links holds the href of every link of the page, navigation included,
+	// in document order and as written, except links marked rel="nofollow".
+	Links []string
+	// Canonical is the href of the page's <link rel="canonical">, as
+	// written.
+	Canonical string
+}
+
+// HTML extracts the main content of the HTML file at path.
+func HTML(path string) (HTMLPage, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return HTMLPage{}, err
+	}
+	defer f.Close()
+	return ParseHTML(f, "")
+}
+
+// ParseHTML extracts the main content of the HTML page read from r. The
+// optional contentType (e.g. a Content-Type header) helps decode pages that
+// are not UTF-8; without it the charset is sniffed from the page.
+//
+// The content is found readability-style: boilerplate elements are dropped,
+// then a lone <main> or <article> is taken as is, and otherwise the element
+// whose paragraphs carry the most non-link text wins, together with the
+// siblings that score close to it.
+func ParseHTML(r io.Reader, contentType string) (HTMLPage, error) {
+	r, err := charset.NewReader(r, contentType)
+	if err != nil {
+		return HTMLPage{}, fmt.Errorf("failed to detect html charset: %w", err)
+	}
+	doc, err := html.Parse(r)
+	if err != nil {
+		return HTMLPage{}, fmt.Errorf("failed to parse html: %w", err)
+	}
+
+	page := HTMLPage{Title: htmlTitle(doc)}
+	walk(doc, func(n *html.Node) {
+		href, ok := attr(n, "href")
+		if !ok || n.Type != html.ElementNode {
+			return
+		}
+		rel, _ := attr(n, "rel")
+		rels := strings.Fields(strings.ToLower(rel))
+		switch {
+		case n.DataAtom == atom.A && !slices.Contains(rels, "nofollow"):
+			page.Links = append(page.Links, strings.TrimSpace(href))
+		case n.DataAtom == atom.Link && slices.Contains(rels, "canonical") && page.Canonical == "":
+			page.Canonical = strings.TrimSpace(href)
+		}
+	})
+	body := findElement(doc, atom.Body)
+	if body == nil {
+		body = doc
+	}
+	removeBoilerplate(body)
+
+	w := &markdownWriter{}
+	renderer := &htmlRenderer{w: w, listDepth: -1}
+	for _, n := range mainContent(body) {
+		renderer.render(n)
+	}
+	renderer.flush()
+	page.Text = w.String()
+	return page, nil
+}
+
+// renderHTML renders all of n as Markdown, such as a book chapter whose
+// every part is content, dropping only elements that hold no text.
+func renderHTML(n *html.Node) string {
+	removeElements(n, func(c *html.Node) bool { return nonTextTags[c.DataAtom] })
+	w := &markdownWriter{}
+	renderer := &htmlRenderer{w: w, listDepth: -1}
+	renderer.render(n)
+	renderer.flush()
+	return w.String()
+}
+
+var (
+	// unlikelyCandidate matches class and id values of page furniture.
+	unlikelyCandidate = regexp.MustCompile(`(?i)-ad-|ad-break|agegate|banner|breadcrumb|combx|comment|community|disqus|extra|footer|header|legends|menu|navbar|nav-|pager|pagination|related|remark|replies|rss|shoutbox|sidebar|skyscraper|sponsor|supplemental|toolbar`)
+	// maybeCandidate rescues unlikely matches that may hold the content.
+	maybeCandidate = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow`)
+	// alwaysBoilerplate is dropped even when it also looks like content, as
+	// in "cookie-consent-content".
+	alwaysBoilerplate = regexp.MustCompile(`(?i)cookie|consent|gdpr|newsletter|subscribe|popup|modal|share|social|advert`)
+
+	positiveWeight = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|post|text|blog|story`)
+	negativeWeight = regexp.MustCompile(`(?i)-ad-|hidden|^hid$| hid$| hid |^hid |banner|combx|comment|com-|contact|foot|footer|footnote|masthead|media|meta|outbrain|promo|related|scroll|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget`)
+)
+
+// boilerplateTags never hold main content.
+var boilerplateTags = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Svg: true, atom.Math: true, atom.Iframe: true, atom.Object: true, atom.Embed: true,
+	atom.Canvas: true, atom.Nav: true, atom.Footer: true, atom.Aside: true, atom.Form: true,
+	atom.Button: true, atom.Select: true, atom.Input: true, atom.Textarea: true, atom.Dialog: true,
+	atom.Link: true, atom.Meta: true,
+}
+
+// nonTextTags hold scripts, styles, graphics or embedded content.
+var nonTextTags = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Svg: true, atom.Math: true, atom.Iframe: true, atom.Object: true, atom.Embed: true,
+	atom.Canvas: true, atom.Link: true, atom.Meta: true,
+}
+
+var boilerplateRoles = map[string]bool{
+	"navigation": true, "banner": true, "contentinfo": true, "complementary": true,
+	"dialog": true, "alertdialog": true, "search": true, "menu": true, "menubar": true,
+}
+
+// removeBoilerplate detaches elements that are not part of the page content.
+func removeBoilerplate(n *html.Node) {
+	removeElements(n, isBoilerplate)
+}
+
+// removeElements detaches the comments and the elements matching remove from
+// the subtree of n.
+func removeElements(n *html.Node, remove func(*html.Node) bool) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.CommentNode || (c.Type == html.ElementNode && remove(c)) {
+			n.RemoveChild(c)
+		} else {
+			removeElements(c, remove)
+		}
+		c = next
+	}
+}
+
+func isBoilerplate(n *html.Node) bool {
+	if boilerplateTags[n.DataAtom] {
+		return true
+	}
+	if _, hidden := attr(n, "hidden"); hidden {
+		return true
+	}
+	if v, _ := attr(n, "aria-hidden"); v == "true" {
+		return true
+	}
+	if v, _ := attr(n, "role"); boilerplateRoles[v] {
+		return true
+	}
+	// A page header is furniture; an article header holds its title.
+	if n.DataAtom == atom.Header && !hasAncestor(n, atom.Article, atom.Main) {
+		return true
+	}
+	if n.DataAtom == atom.Body || n.DataAtom == atom.A || hasAncestor(n, atom.Table, atom.Pre, atom.Code) {
+		return false
+	}
+	match := classAndID(n)
+	if match == "" {
+		return false
+	}
+	return alwaysBoilerplate.MatchString(match) || (unlikelyCandidate.MatchString(match) && !maybeCandidate.MatchString(match))
+}
+
+// mainContent returns the nodes holding the main content of body.
+func mainContent(body *html.Node) []*html.Node {
+	for _, semantic := range []func(*html.Node) bool{
+		func(n *html.Node) bool {
+			role, _ := attr(n, "role")
+			return n.DataAtom == atom.Main || role == "main"
+		},
+		func(n *html.Node) bool { return n.DataAtom == atom.Article },
+	} {
+		if found := findAll(body, semantic); len(found) == 1 {
+			return found
+		}
+	}
+
+	scores := scoreCandidates(body)
+	var top *html.Node
+	walk(body, func(n *html.Node) {
+		if s, ok := scores[n]; ok && (top == nil || s > scores[top]) {
+			top = n
+		}
+	})
+	if top == nil || top == body || top.Parent == nil {
+		return []*html.Node{body}
+	}
+
+	// Keep siblings that score close to the top candidate or read like
+	// paragraphs of the same article.
+	threshold := max(10, scores[top]*0.2)
+	var nodes []*html.Node
+	for s := top.Parent.FirstChild; s != nil; s = s.NextSibling {
+		switch {
+		case s == top:
+			nodes = append(nodes, s)
+		case s.Type != html.ElementNode:
+		case scores[s] >= threshold:
+			nodes = append(nodes, s)
+		case s.DataAtom == atom.P:
+			length := textLength(s)
+			if length > 80 && linkDensity(s) < 0.25 {
+				nodes = append(nodes, s)
+			}
+		}
+	}
+	return nodes
+}
+
+// scoreCandidates scores the ancestors of every paragraph by the amount of
+// text it holds, discounted by the share of that text inside links.
+func scoreCandidates(body *html.Node) map[*html.Node]float64 {
+	raw := map[*html.Node]float64{}
+	walk(body, func(n *html.Node) {
+		if !isParagraphLike(n) {
+			return
+		}
+		text := textContent(n)
+		length := len([]rune(strings.TrimSpace(text)))
+		if length < 25 {
+			return
+		}
+		score := 1 + float64(strings.Count(text, ",")) + min(float64(length)/100, 3)
+		ancestor := n.Parent
+		for level := 0; ancestor != nil && ancestor.Type == html.ElementNode && level < 3; level++ {
+			if _, ok := raw[ancestor]; !ok {
+				raw[ancestor] = initialScore(ancestor)
+			}
+			divider := 1.0
+			if level == 1 {
+				divider = 2
+			} else if level > 1 {
+				divider = float64(level) * 3
+			}
+			raw[ancestor] += score / divider
+			ancestor = ancestor.Parent
+		}
+	})
+	scores := make(map[*html.Node]float64, len(raw))
+	for n, s := range raw {
+		scores[n] = s * (1 - linkDensity(n))
+	}
+	return scores
+}
+
+func initialScore(n *html.Node) float64 {
+	var score float64
+	switch n.DataAtom {
+	case atom.Div:
+		score = 5
+	case atom.Pre, atom.Td, atom.Blockquote:
+		score = 3
+	case atom.Address, atom.Ol, atom.Ul, atom.Dl, atom.Dd, atom.Dt, atom.Li, atom.Form:
+		score = -3
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Th:
+		score = -5
+	}
+	if match := classAndID(n); match != "" {
+		if negativeWeight.MatchString(match) {
+			score -= 25
+		}
+		if positiveWeight.MatchString(match) {
+			score += 25
+		}
+	}
+	return score
+}
+
+// isParagraphLike reports whether n is a paragraph, or a div holding only
+// inline content, whose text counts towards its ancestors' scores.
+func isParagraphLike(n *html.Node) bool {
+	switch n.DataAtom {
+	case atom.P, atom.Pre, atom.Td:
+		return true
+	case atom.Div, atom.Section:
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && blockTags[c.DataAtom] {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func linkDensity(n *html.Node) float64 {
+	total := textLength(n)
+	if total == 0 {
+		return 0
+	}
+	links := 0
+	walk(n, func(c *html.Node) {
+		if c.DataAtom == atom.A {
+			links += textLength(c)
+		}
+	})
+	return min(float64(links)/float64(total), 1)
+}
+
+// blockTags start a new block when rendered.
+var blockTags = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Blockquote: true, atom.Details: true,
+	atom.Div: true, atom.Dl: true, atom.Dd: true, atom.Dt: true, atom.Figcaption: true,
+	atom.Figure: true, atom.Header: true, atom.Hr: true, atom.Li: true, atom.Main: true,
+	atom.Ol: true, atom.P: true, atom.Pre: true, atom.Section: true, atom.Summary: true,
+	atom.Table: true, atom.Ul: true, atom.H1: true, atom.H2: true, atom.H3: true,
+	atom.H4: true, atom.H5: true, atom.H6: true,
+}
+
+var headingLevels = map[atom.Atom]int{
+	atom.H1: 1, atom.H2: 2, atom.H3: 3, atom.H4: 4, atom.H5: 5, atom.H6: 6,
+}
+
+// htmlRenderer writes the content of a DOM subtree as Markdown, collecting
+// inline text until the next block boundary.
+type htmlRenderer struct {
+	w         *markdownWriter
+	inline    strings.Builder
+	listDepth int
+}
+
+func (r *htmlRenderer) render(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		r.inline.WriteString(n.Data)
+		return
+	case html.ElementNode, html.DocumentNode:
+	default:
+		return
+	}
+
+	if level, ok := headingLevels[n.DataAtom]; ok {
+		r.flush()
+		r.w.heading(level, textContent(n))
+		return
+	}
+	switch n.DataAtom {
+	case atom.Br:
+		r.inline.WriteString("\n")
+		return
+	case atom.Img:
+		return
+	case atom.Pre:
+		r.flush()
+		r.w.code(rawText(n))
+		return
+	case atom.Table:
+		r.flush()
+		r.w.table(tableRows(n))
+		return
+	case atom.Ul, atom.Ol:
+		r.flush()
+		r.listDepth++
+		r.renderChildren(n)
+		r.flush()
+		r.listDepth--
+		return
+	}
+	if blockTags[n.DataAtom] {
+		r.flush()
+		r.renderChildren(n)
+		r.flush()
+		return
+	}
+	r.renderChildren(n)
+}
+
+func (r *htmlRenderer) renderChildren(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.render(c)
+	}
+}
+
+// flush writes the pending inline text as a paragraph, or as a list item
+// inside a list.
+func (r *htmlRenderer) flush() {
+	text := collapseHTMLSpace(r.inline.String())
+	r.inline.Reset()
+	if r.listDepth >= 0 {
+		r.w.listItem(r.listDepth, text)
+		return
+	}
+	r.w.paragraph(text)
+}
+
+// tableRows returns the cell text of the rows of a table, skipping nested
+// tables' rows (their text stays in the enclosing cell).
+func tableRows(table *html.Node) [][]string {
+	var rows [][]string
+	var visit func(*html.Node)
+	visit = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			switch c.DataAtom {
+			case atom.Tr:
+				var row []string
+				for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.DataAtom == atom.Td || cell.DataAtom == atom.Th {
+						row = append(row, textContent(cell))
+					}
+				}
+				rows = append(rows, row)
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				visit(c)
+			}
+		}
+	}
+	visit(table)
+	return rows
+}
+
+// collapseHTMLSpace collapses whitespace the way a browser renders it, while
+// keeping the line breaks inserted for <br>.
+func collapseHTMLSpace(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(strings.NewReplacer("\r", " ", "\t", " ").Replace(line)), " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func htmlTitle(doc *html.Node) string {
+	var title, ogTitle string
+	walk(doc, func(n *html.Node) {
+		switch n.DataAtom {
+		case atom.Title:
+			if title == "" && !hasAncestor(n, atom.Svg) {
+				title = singleLine(textContent(n))
+			}
+		case atom.Meta:
+			if p, _ := attr(n, "property"); p == "og:title" && ogTitle == "" {
+				v, _ := attr(n, "content")
+				ogTitle = singleLine(v)
+			}
+		}
+	})
+	switch {
+	case ogTitle != "":
+		return ogTitle
+	case title != "":
+		return title
+	}
+	if h1 := findElement(doc, atom.H1); h1 != nil {
+		return singleLine(textContent(h1))
+	}
+	return ""
+}
+
+// textContent returns the text of n with block boundaries as spaces.
+func textContent(n *html.Node) string {
+	var sb strings.Builder
+	walk(n, func(c *html.Node) {
+		switch {
+		case c.Type == html.TextNode:
+			sb.WriteString(c.Data)
+		case c.Type == html.ElementNode && (blockTags[c.DataAtom] || c.DataAtom == atom.Br || c.DataAtom == atom.Td || c.DataAtom == atom.Th):
+			sb.WriteString(" ")
+		}
+	})
+	return sb.String()
+}
+
+func rawText(n *html.Node) string {
+	var sb strings.Builder
+	walk(n, func(c *html.Node) {
+		if c.Type == html.TextNode {
+			sb.WriteString(c.Data)
+		} else if c.DataAtom == atom.Br {
+			sb.WriteString("\n")
+		}
+	})
+	return sb.String()
+}
+
+func textLength(n *html.Node) int {
+	return len([]rune(singleLine(textContent(n))))
+}
+
+// walk calls fn for n and its descendants in document order.
+func walk(n *html.Node, fn func(*html.Node)) {
+	fn(n)
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walk(c, fn)
+	}
+}
+
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	found := findAll(n, func(c *html.Node) bool { return c.DataAtom == a })
+	if len(found) == 0 {
+		return nil
+	}
+	return found[0]
+}
+
+func findAll(n *html.Node, match func(*html.Node) bool) []*html.Node {
+	var found []*html.Node
+	walk(n, func(c *html.Node) {
+		if c.Type == html.ElementNode && match(c) {
+			found = append(found, c)
+		}
+	})
+	return found
+}
+
+func hasAncestor(n *html.Node, atoms ...atom.Atom) bool {
+	for p := n.Parent; p != nil; p = p.Parent {
+		for _, a := range atoms {
+			if p.DataAtom == a {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func attr(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key && a.Namespace == "" {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+func classAndID(n *html.Node) string {
+	class, _ := attr(n, "class")
+	id, _ := attr(n, "id")
+	return strings.TrimSpace(class + " " + id)
+}
@@ -0,0 +1,110 @@
+package extract_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/mudler/localrecall/pkg/extract"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const blogPage = `<!DOCTYPE html>
+<html>
+<head>
+  <title>Tuning chunk sizes | Example Blog</title>
+  <meta property="og:title" content="Tuning chunk sizes">
+  <style>body { color: red }</style>
+  <script>var tracking = "should not appear";</script>
+</head>
+<body>
+  <header class="site-header"><a href="/">Example Blog</a></header>
+  <nav><ul><li><a href="/">Home</a></li><li><a href="/about">About</a></li></ul></nav>
+  <div id="cookie-banner" class="cookie-consent-content">We use cookies to improve your experience, accept them all.</div>
+  <div class="layout">
+    <div class="sidebar"><p>Popular posts you might enjoy reading, chosen by our editors.</p></div>
+    <div class="post-body">
+      <h1>Tuning chunk sizes</h1>
+      <p>Chunk size decides how much context, and how much noise, every search result carries.</p>
+      <h2>Rules of thumb</h2>
+      <p>Start with paragraphs of a few hundred characters, then measure recall on real queries.</p>
+      <ul>
+        <li>Small chunks are precise</li>
+        <li>Large chunks keep context
+          <ul><li>but dilute embeddings</li></ul>
+        </li>
+      </ul>
+      <pre><code>LOCALRECALL_CHUNK_SIZE=512
+# tune me</code></pre>
+      <table>
+        <thead><tr><th>Size</th><th>Recall</th></tr></thead>
+        <tbody><tr><td>256</td><td>0.81</td></tr></tbody>
+      </table>
+    </div>
+    <div class="share-buttons"><p>Share this article on your favourite social network today.</p></div>
+  </div>
+  <footer><p>Copyright Example Blog, all rights reserved, since forever.</p></footer>
+</body>
+</html>`
+
+var _ = Describe("HTML", func() {
+	It("keeps the main content as Markdown and drops boilerplate", func() {
+		page, err := ParseHTML(strings.NewReader(blogPage), "text/html")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(page.Title).To(Equal("Tuning chunk sizes"))
+		Expect(page.Text).To(Equal(`# Tuning chunk sizes
+
+Chunk size decides how much context, and how much noise, every search result carries.
+
+## Rules of thumb
+
+Start with paragraphs of a few hundred characters, then measure recall on real queries.
+
+- Small chunks are precise
+- Large chunks keep context
+  - but dilute embeddings
+
+` + "```" + `
+LOCALRECALL_CHUNK_SIZE=512
+# tune me
+` + "```" + `
+
+| Size | Recall |
+| --- | --- |
+| 256 | 0.81 |
+`))
+	})
+
+	It("prefers a lone main element", func() {
+		page, err := ParseHTML(strings.NewReader(`<html><head><title>Docs</title></head><body>
+			<div class="menu">Menu entries</div>
+			<main><p>Short but <em>important</em>.<br>Second line.</p></main>
+			<div class="related"><p>Something long enough to look like a real paragraph of text, with commas, many commas.</p></div>
+		</body></html>`), "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(page.Title).To(Equal("Docs"))
+		Expect(page.Text).To(Equal("Short but important.\nSecond line.\n"))
+	})
+
+	It("lists the links of the whole page and its canonical URL", func() {
+		page, err := ParseHTML(strings.NewReader(`<html><head><link rel="canonical" href=" https://example.com/guide ">
+			<link rel="stylesheet" href="/style.css"></head><body>
+			<nav><a href="/">Home</a></nav>
+			<main><p>See <a href="install#linux">install</a> and <a rel="nofollow" href="/login">log in</a>.</p></main>
+		</body></html>`), "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(page.Links).To(Equal([]string{"/", "install#linux"}))
+		Expect(page.Canonical).To(Equal("https://example.com/guide"))
+	})
+
+	It("decodes pages in legacy charsets", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "legacy.html")
+		content := []byte(`<html><head><meta charset="iso-8859-1"><title>Caf` + "\xe9" + `</title></head><body><p>Cr` + "\xe8" + `me br` + "\xfb" + `l` + "\xe9" + `e</p></body></html>`)
+		Expect(os.WriteFile(path, content, 0644)).To(Succeed())
+		page, err := HTML(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(page.Title).To(Equal("Café"))
+		Expect(page.Text).To(Equal("Crème brûlée\n"))
+	})
+})
@@ -0,0 +1,128 @@
+package extract
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// JSONRecords reads the records of a JSON or JSON Lines file. A .jsonl or
+// .ndjson file holds one record per non-empty line; a JSON file holding an
+// array yields its elements, and any other JSON value is a single record.
+// Numbers are kept as json.Number so that IDs do not lose precision.
+func JSONRecords(path string) ([]any, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	if bom, _ := br.Peek(3); bytes.Equal(bom, []byte("\xef\xbb\xbf")) {
+		br.Discard(3)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".ndjson":
+		return readJSONLines(br)
+	}
+	d := json.NewDecoder(br)
+	d.UseNumber()
+	var value any
+	if err := d.Decode(&value); err != nil {
+		return nil, fmt.Errorf("failed to parse json: %w", err)
+	}
+	if _, err := d.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("failed to parse json: unexpected data after the top-level value")
+	}
+	if records, ok := value.([]any); ok {
+		return records, nil
+	}
+	return []any{value}, nil
+}
+
+func readJSONLines(br *bufio.Reader) ([]any, error) {
+	var records []any
+	for line := 1; ; line++ {
+		data, err := br.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 {
+			d := json.NewDecoder(bytes.NewReader(trimmed))
+			d.UseNumber()
+			var record any
+			if derr := d.Decode(&record); derr != nil {
+				return nil, fmt.Errorf("failed to parse line %d: %w", line, derr)
+			}
+			if d.More() {
+				return nil, fmt.Errorf("failed to parse line %d: more than one value", line)
+			}
+			records = append(records, record)
+		}
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+	}
+}
+
+// JSONField returns the value at a dot-separated path of object keys, such
+// as "fields.summary", in record.
+func JSONField(record any, path string) (any, bool) {
+	value := record
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// JSONText renders a JSON value as text. Strings, numbers and booleans are
+// written as they are and null as nothing; arrays of those are joined with
+// sep, and objects and other arrays are written as indented JSON.
+func JSONText(value any, sep string) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case []any:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			switch item.(type) {
+			case map[string]any, []any:
+				return indentJSON(v)
+			}
+			if text := JSONText(item, sep); text != "" {
+				parts = append(parts, text)
+			}
+		}
+		return strings.Join(parts, sep)
+	}
+	return indentJSON(value)
+}
+
+func indentJSON(value any) string {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
@@ -0,0 +1,65 @@
+package extract_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	. "github.com/mudler/localrecall/pkg/extract"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("JSON records", func() {
+	write := func(name, content string) string {
+		path := filepath.Join(GinkgoT().TempDir(), name)
+		Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+		return path
+	}
+
+	It("reads the elements of an array, or a single value", func() {
+		records, err := JSONRecords(write("tickets.json", "\xef\xbb\xbf[{\"id\": 12345678901234567890}, {\"id\": 2}]"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(records).To(HaveLen(2))
+		id, ok := JSONField(records[0], "id")
+		Expect(ok).To(BeTrue())
+		Expect(JSONText(id, "")).To(Equal("12345678901234567890"))
+
+		records, err = JSONRecords(write("ticket.json", `{"id": "a"}`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(records).To(HaveLen(1))
+
+		_, err = JSONRecords(write("broken.json", `{"id": "a"} {"id": "b"}`))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("reads one record per line of a JSON Lines file", func() {
+		records, err := JSONRecords(write("chat.jsonl", "{\"text\": \"hi\"}\n\n  {\"text\": \"bye\"}  \r\n[1, 2]"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(records).To(HaveLen(3))
+
+		_, err = JSONRecords(write("broken.jsonl", "{\"text\": \"hi\"}\n{\"text\": \n"))
+		Expect(err).To(MatchError(ContainSubstring("line 2")))
+	})
+
+	It("looks up nested fields and renders values as text", func() {
+		var record any
+		Expect(json.Unmarshal([]byte(`{"fields": {"summary": "Printer jam", "labels": ["hw", "urgent"], "done": false, "owner": null}}`), &record)).To(Succeed())
+
+		value, ok := JSONField(record, "fields.summary")
+		Expect(ok).To(BeTrue())
+		Expect(JSONText(value, "")).To(Equal("Printer jam"))
+		value, _ = JSONField(record, "fields.labels")
+		Expect(JSONText(value, ", ")).To(Equal("hw, urgent"))
+		value, _ = JSONField(record, "fields.done")
+		Expect(JSONText(value, "")).To(Equal("false"))
+		value, ok = JSONField(record, "fields.owner")
+		Expect(ok).To(BeTrue())
+		Expect(JSONText(value, "")).To(BeEmpty())
+		_, ok = JSONField(record, "fields.summary.text")
+		Expect(ok).To(BeFalse())
+
+		value, _ = JSONField(record, "fields")
+		Expect(JSONText(value, "")).To(ContainSubstring(`"summary": "Printer jam"`))
+	})
+})
@@ -0,0 +1,142 @@
+// Package extract turns document formats into text for chunking. Structured
+// formats are rendered as Markdown, so headings, lists and tables survive and
+// chunks can be split along sections.
+package extract
+
+import (
+	"strings"
+)
+
+// markdownWriter assembles the structure read from a document as Markdown.
+type markdownWriter struct {
+	sb       strings.Builder
+	lastList bool
+}
+
+// heading writes an ATX heading. Levels are clamped to 1-6.
+func (w *markdownWriter) heading(level int, text string) {
+	text = singleLine(text)
+	if text == "" {
+		return
+	}
+	level = max(1, min(level, 6))
+	w.block(strings.Repeat("#", level) + " " + text)
+}
+
+// paragraph writes a paragraph. Line breaks inside it are kept.
+func (w *markdownWriter) paragraph(text string) {
+	text = strings.TrimSpace(normalizeSpaces(text))
+	if text == "" {
+		return
+	}
+	w.block(escapeBlockStart(text))
+}
+
+// listItem writes a bullet list item nested level deep. Consecutive items
+// form one list.
+func (w *markdownWriter) listItem(level int, text string) {
+	text = singleLine(text)
+	if text == "" {
+		return
+	}
+	if w.sb.Len() > 0 && !w.lastList {
+		w.sb.WriteString("\n")
+	}
+	w.sb.WriteString(strings.Repeat("  ", max(level, 0)) + "- " + text + "\n")
+	w.lastList = true
+}
+
+// table writes rows as a Markdown table whose first row is the header.
+// Empty rows are dropped and short rows padded.
+func (w *markdownWriter) table(rows [][]string) {
+	var kept [][]string
+	cols := 0
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		empty := true
+		for i, c := range row {
+			cells[i] = strings.ReplaceAll(singleLine(c), "|", `\|`)
+			if cells[i] != "" {
+				empty = false
+			}
+		}
+		for len(cells) > 0 && cells[len(cells)-1] == "" {
+			cells = cells[:len(cells)-1]
+		}
+		if empty {
+			continue
+		}
+		kept = append(kept, cells)
+		cols = max(cols, len(cells))
+	}
+	if len(kept) == 0 {
+		return
+	}
+
+	var sb strings.Builder
+	writeRow := func(cells []string) {
+		sb.WriteString("|")
+		for i := 0; i < cols; i++ {
+			cell := ""
+			if i < len(cells) {
+				cell = cells[i]
+			}
+			sb.WriteString(" " + cell + " |")
+		}
+		sb.WriteString("\n")
+	}
+	writeRow(kept[0])
+	sb.WriteString("|" + strings.Repeat(" --- |", cols) + "\n")
+	for _, row := range kept[1:] {
+		writeRow(row)
+	}
+	w.block(strings.TrimSuffix(sb.String(), "\n"))
+}
+
+// code writes text verbatim as a fenced code block.
+func (w *markdownWriter) code(text string) {
+	text = strings.Trim(text, "\n")
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	w.block(fence + "\n" + text + "\n" + fence)
+}
+
+func (w *markdownWriter) block(text string) {
+	if w.sb.Len() > 0 {
+		w.sb.WriteString("\n")
+	}
+	w.sb.WriteString(text + "\n")
+	w.lastList = false
+}
+
+func (w *markdownWriter) String() string {
+	return w.sb.String()
+}
+
+// normalizeSpaces turns tabs and other horizontal whitespace runs into single
+// spaces and trims every line, keeping line breaks.
+func normalizeSpaces(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func singleLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// escapeBlockStart keeps paragraph text from being read as a heading or a
+// code fence.
+func escapeBlockStart(text string) string {
+	if strings.HasPrefix(text, "#") || strings.HasPrefix(text, "```") || strings.HasPrefix(text, "~~~") {
+		return `\` + text
+	}
+	return text
+}
@@ -0,0 +1,270 @@
+package extract
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	odfTextNS   = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+	odfTableNS  = "urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+	odfOfficeNS = "urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+)
+
+// maxRepeatedCells bounds table:number-columns-repeated, which spreadsheets
+// use to pad rows to thousands of empty columns.
+const maxRepeatedCells = 64
+
+// ODT returns the text of an OpenDocument text file as Markdown. Headings,
+// list items and tables are kept; notes and annotations are dropped.
+func ODT(path string) (string, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open odt: %w", err)
+	}
+	defer zr.Close()
+
+	f := findZipFile(&zr.Reader, "content.xml")
+	if f == nil {
+		return "", errors.New("not an odt file: content.xml is missing")
+	}
+	text, err := readZipXML(f, parseODFContent)
+	if err != nil {
+		return "", fmt.Errorf("failed to read odt: %w", err)
+	}
+	return text, nil
+}
+
+func parseODFContent(d *xml.Decoder) (string, error) {
+	w := &markdownWriter{}
+	listDepth := -1
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return w.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if err := readODFBlock(d, t, w, &listDepth); err != nil {
+				return "", err
+			}
+		case xml.EndElement:
+			if t.Name.Space == odfTextNS && t.Name.Local == "list" {
+				listDepth--
+			}
+		}
+	}
+}
+
+// readODFBlock handles a block-level start element. Elements it does not
+// consume are descended into by the caller's token loop.
+func readODFBlock(d *xml.Decoder, start xml.StartElement, w *markdownWriter, listDepth *int) error {
+	switch {
+	case isODFSkipped(start):
+		return d.Skip()
+	case start.Name.Space == odfTextNS && start.Name.Local == "list":
+		*listDepth++
+	case start.Name.Space == odfTextNS && start.Name.Local == "h":
+		text, err := readODFInline(d)
+		if err != nil {
+			return err
+		}
+		if *listDepth >= 0 {
+			w.listItem(*listDepth, text)
+			return nil
+		}
+		w.heading(atoiDefault(xmlAttr(start, odfTextNS, "outline-level"), 1), text)
+	case start.Name.Space == odfTextNS && start.Name.Local == "p":
+		text, err := readODFInline(d)
+		if err != nil {
+			return err
+		}
+		if *listDepth >= 0 {
+			w.listItem(*listDepth, text)
+			return nil
+		}
+		w.paragraph(text)
+	case start.Name.Space == odfTableNS && start.Name.Local == "table":
+		rows, err := readODFTable(d)
+		if err != nil {
+			return err
+		}
+		w.table(rows)
+	}
+	return nil
+}
+
+// isODFSkipped reports whether an element holds text that is not part of the
+// document flow.
+func isODFSkipped(start xml.StartElement) bool {
+	switch start.Name.Space {
+	case odfOfficeNS:
+		return start.Name.Local == "annotation" || start.Name.Local == "forms"
+	case odfTextNS:
+		switch start.Name.Local {
+		case "note", "tracked-changes", "sequence-decls", "variable-decls":
+			return true
+		}
+	}
+	return false
+}
+
+// readODFInline reads the text of a paragraph or heading after its start tag.
+func readODFInline(d *xml.Decoder) (string, error) {
+	var sb strings.Builder
+	depth := 0
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if isODFSkipped(t) {
+				if err := d.Skip(); err != nil {
+					return "", err
+				}
+				continue
+			}
+			depth++
+			if t.Name.Space != odfTextNS {
+				continue
+			}
+			switch t.Name.Local {
+			case "s":
+				sb.WriteString(strings.Repeat(" ", max(1, min(atoiDefault(xmlAttr(t, odfTextNS, "c"), 1), 64))))
+			case "tab":
+				sb.WriteString("\t")
+			case "line-break":
+				sb.WriteString("\n")
+			}
+		case xml.EndElement:
+			depth--
+			if depth < 0 {
+				return sb.String(), nil
+			}
+		case xml.CharData:
+			sb.Write(t)
+		}
+	}
+}
+
+// readODFTable reads a table:table element after its start tag. Nested
+// tables are flattened into the cell text.
+func readODFTable(d *xml.Decoder) ([][]string, error) {
+	var rows [][]string
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space != odfTableNS {
+				if err := d.Skip(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			switch t.Name.Local {
+			case "table-row":
+				row, err := readODFRow(d)
+				if err != nil {
+					return nil, err
+				}
+				rows = append(rows, row)
+			case "table-header-rows", "table-rows", "table-row-group":
+			default:
+				if err := d.Skip(); err != nil {
+					return nil, err
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Space == odfTableNS && t.Name.Local == "table" {
+				return rows, nil
+			}
+		}
+	}
+}
+
+func readODFRow(d *xml.Decoder) ([]string, error) {
+	var row []string
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space != odfTableNS || (t.Name.Local != "table-cell" && t.Name.Local != "covered-table-cell") {
+				if err := d.Skip(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			text, err := readODFCell(d)
+			if err != nil {
+				return nil, err
+			}
+			repeat := max(1, min(atoiDefault(xmlAttr(t, odfTableNS, "number-columns-repeated"), 1), maxRepeatedCells))
+			for range repeat {
+				row = append(row, text)
+			}
+		case xml.EndElement:
+			return row, nil
+		}
+	}
+}
+
+func readODFCell(d *xml.Decoder) (string, error) {
+	var parts []string
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case isODFSkipped(t):
+				if err := d.Skip(); err != nil {
+					return "", err
+				}
+			case t.Name.Space == odfTextNS && (t.Name.Local == "p" || t.Name.Local == "h"):
+				text, err := readODFInline(d)
+				if err != nil {
+					return "", err
+				}
+				parts = append(parts, text)
+			case t.Name.Space == odfTableNS && t.Name.Local == "table":
+				nested, err := readODFTable(d)
+				if err != nil {
+					return "", err
+				}
+				for _, row := range nested {
+					parts = append(parts, strings.Join(row, " "))
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Space == odfTableNS {
+				return strings.Join(parts, " "), nil
+			}
+		}
+	}
+}
+
+func xmlAttr(start xml.StartElement, space, local string) string {
+	for _, a := range start.Attr {
+		if a.Name.Space == space && a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
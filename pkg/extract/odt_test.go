@@ -0,0 +1,70 @@
+package extract_test
+
+import (
+	. "github.com/mudler/localrecall/pkg/extract"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const odtContent = `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content
+  xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+  xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+  xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0">
+  <office:body>
+    <office:text>
+      <text:sequence-decls><text:sequence-decl text:name="Table"/></text:sequence-decls>
+      <text:h text:outline-level="1">Release notes</text:h>
+      <text:p>Version<text:s text:c="2"/>2.0<text:tab/>is out.<text:note><text:note-body><text:p>A footnote</text:p></text:note-body></text:note></text:p>
+      <text:h text:outline-level="2">Changes</text:h>
+      <text:list>
+        <text:list-item><text:p>Faster search</text:p></text:list-item>
+        <text:list-item>
+          <text:p>New formats</text:p>
+          <text:list><text:list-item><text:p>DOCX</text:p></text:list-item></text:list>
+        </text:list-item>
+      </text:list>
+      <text:p>Thanks<office:annotation><text:p>reviewer comment</text:p></office:annotation> to all<text:line-break/>contributors.</text:p>
+      <table:table table:name="Table1">
+        <table:table-column table:number-columns-repeated="2"/>
+        <table:table-header-rows>
+          <table:table-row><table:table-cell><text:p>Format</text:p></table:table-cell><table:table-cell><text:p>Status</text:p></table:table-cell></table:table-row>
+        </table:table-header-rows>
+        <table:table-row><table:table-cell><text:p>ODT</text:p></table:table-cell><table:table-cell><text:p>done</text:p></table:table-cell><table:table-cell table:number-columns-repeated="1000"/></table:table-row>
+      </table:table>
+    </office:text>
+  </office:body>
+</office:document-content>`
+
+var _ = Describe("ODT", func() {
+	It("renders headings, lists and tables as Markdown", func() {
+		path := writeZip("notes.odt", map[string]string{
+			"mimetype":    "application/vnd.oasis.opendocument.text",
+			"content.xml": odtContent,
+		})
+		text, err := ODT(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(text).To(Equal(`# Release notes
+
+Version 2.0 is out.
+
+## Changes
+
+- Faster search
+- New formats
+  - DOCX
+
+Thanks to all
+contributors.
+
+| Format | Status |
+| --- | --- |
+| ODT | done |
+`))
+	})
+
+	It("fails on files that are not OpenDocument text", func() {
+		_, err := ODT(writeZip("empty.odt", map[string]string{"mimetype": "text/plain"}))
+		Expect(err).To(HaveOccurred())
+	})
+})
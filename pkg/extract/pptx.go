@@ -0,0 +1,443 @@
+package extract
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+const (
+	presentationNS = "http://schemas.openxmlformats.org/presentationml/2006/main"
+	drawingNS      = "http://schemas.openxmlformats.org/drawingml/2006/main"
+
+	notesSlideRel = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/notesSlide"
+)
+
+// Deck is the text of a presentation, slide by slide.
+type Deck struct {
+	// Title is the title in the document properties.
+	Title  string
+	Slides []Slide
+}
+
+// Slide is one slide of a presentation.
+type Slide struct {
+	// Number is the 1-based position of the slide in the deck.
+	Number int
+	// Title is the text of the title placeholder.
+	Title string
+	// Text is the content of the slide other than its title, as Markdown.
+	// Bulleted placeholders become lists.
+	Text string
+	// Notes are the speaker notes.
+	Notes string
+}
+
+// Markdown returns the slide as a section headed with its number and title,
+// followed by its speaker notes.
+func (s Slide) Markdown() string {
+	w := &markdownWriter{}
+	heading := fmt.Sprintf("Slide %d", s.Number)
+	if s.Title != "" {
+		heading += ": " + s.Title
+	}
+	w.heading(2, heading)
+	if text := strings.TrimSpace(s.Text); text != "" {
+		w.block(text)
+	}
+	if s.Notes != "" {
+		w.paragraph("Notes:")
+		for _, p := range strings.Split(s.Notes, "\n\n") {
+			w.paragraph(p)
+		}
+	}
+	return w.String()
+}
+
+// Text returns the slides of the deck as one Markdown document.
+func (d Deck) Text() string {
+	parts := make([]string, 0, len(d.Slides))
+	for _, s := range d.Slides {
+		parts = append(parts, s.Markdown())
+	}
+	return strings.Join(parts, "\n")
+}
+
+// PPTX reads the slides of a PowerPoint presentation in order, with their
+// speaker notes. Text boxes, placeholders, grouped shapes and tables are
+// read; pictures and charts are skipped.
+func PPTX(fpath string) (Deck, error) {
+	zr, err := zip.OpenReader(fpath)
+	if err != nil {
+		return Deck{}, fmt.Errorf("failed to open pptx: %w", err)
+	}
+	defer zr.Close()
+
+	pres := findZipFile(&zr.Reader, "ppt/presentation.xml")
+	if pres == nil {
+		return Deck{}, errors.New("not a pptx file: ppt/presentation.xml is missing")
+	}
+	slideIDs, err := readZipXML(pres, parsePresentationSlides)
+	if err != nil {
+		return Deck{}, fmt.Errorf("failed to read pptx presentation: %w", err)
+	}
+	targets, err := readPartRelationships(&zr.Reader, "ppt/presentation.xml")
+	if err != nil {
+		return Deck{}, fmt.Errorf("failed to read pptx relationships: %w", err)
+	}
+
+	var deck Deck
+	if f := findZipFile(&zr.Reader, "docProps/core.xml"); f != nil {
+		if deck.Title, err = readZipXML(f, parseCoreTitle); err != nil {
+			return Deck{}, fmt.Errorf("failed to read pptx properties: %w", err)
+		}
+	}
+	for i, relID := range slideIDs {
+		target, ok := targets[relID]
+		if !ok {
+			continue
+		}
+		f := findZipFile(&zr.Reader, target.path)
+		if f == nil {
+			continue
+		}
+		shapes, err := readZipXML(f, parseSlideShapes)
+		if err != nil {
+			return Deck{}, fmt.Errorf("failed to read slide %d: %w", i+1, err)
+		}
+		slide := Slide{Number: i + 1}
+		slide.Title, slide.Text = renderSlide(shapes)
+
+		slideRels, err := readPartRelationships(&zr.Reader, target.path)
+		if err != nil {
+			return Deck{}, fmt.Errorf("failed to read slide %d relationships: %w", i+1, err)
+		}
+		for _, rel := range slideRels {
+			if rel.relType != notesSlideRel {
+				continue
+			}
+			if nf := findZipFile(&zr.Reader, rel.path); nf != nil {
+				notes, err := readZipXML(nf, parseSlideShapes)
+				if err != nil {
+					return Deck{}, fmt.Errorf("failed to read slide %d notes: %w", i+1, err)
+				}
+				slide.Notes = renderNotes(notes)
+			}
+			break
+		}
+		deck.Slides = append(deck.Slides, slide)
+	}
+	return deck, nil
+}
+
+// partRelationship is a relationship of an OOXML part, with its target
+// resolved to the name of an archive member.
+type partRelationship struct {
+	relType string
+	path    string
+}
+
+// readPartRelationships reads the relationships of the part named part, if
+// it has any.
+func readPartRelationships(zr *zip.Reader, part string) (map[string]partRelationship, error) {
+	relsName := path.Join(path.Dir(part), "_rels", path.Base(part)+".rels")
+	f := findZipFile(zr, relsName)
+	if f == nil {
+		return map[string]partRelationship{}, nil
+	}
+	return readZipXML(f, func(d *xml.Decoder) (map[string]partRelationship, error) {
+		rels := map[string]partRelationship{}
+		for {
+			tok, err := d.Token()
+			if errors.Is(err, io.EOF) {
+				return rels, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			t, ok := tok.(xml.StartElement)
+			if !ok || t.Name.Local != "Relationship" || xmlAttr(t, "", "TargetMode") == "External" {
+				continue
+			}
+			target := xmlAttr(t, "", "Target")
+			if strings.HasPrefix(target, "/") {
+				target = strings.TrimPrefix(path.Clean(target), "/")
+			} else {
+				target = path.Join(path.Dir(part), target)
+			}
+			rels[xmlAttr(t, "", "Id")] = partRelationship{relType: xmlAttr(t, "", "Type"), path: target}
+		}
+	})
+}
+
+func parsePresentationSlides(d *xml.Decoder) ([]string, error) {
+	var ids []string
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return ids, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if t, ok := tok.(xml.StartElement); ok && t.Name.Space == presentationNS && t.Name.Local == "sldId" {
+			ids = append(ids, xmlAttr(t, relationshipsNS, "id"))
+		}
+	}
+}
+
+// parseCoreTitle reads dc:title from the core document properties.
+func parseCoreTitle(d *xml.Decoder) (string, error) {
+	var title strings.Builder
+	inTitle := false
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return singleLine(title.String()), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			inTitle = t.Name.Local == "title"
+		case xml.EndElement:
+			inTitle = false
+		case xml.CharData:
+			if inTitle {
+				title.Write(t)
+			}
+		}
+	}
+}
+
+// slideShape is a text shape or a table of a slide, in reading order.
+type slideShape struct {
+	// placeholder is the placeholder type, "" for text boxes. Placeholders
+	// without a type are body placeholders.
+	placeholder string
+	paragraphs  []slideParagraph
+	table       [][]string
+}
+
+type slideParagraph struct {
+	level  int
+	bullet bool // explicit bullet
+	plain  bool // bullets turned off
+	text   string
+}
+
+// parseSlideShapes reads the text shapes and tables of a slide or notes
+// slide, descending into groups.
+func parseSlideShapes(d *xml.Decoder) ([]slideShape, error) {
+	var shapes []slideShape
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return shapes, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		t, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch {
+		case t.Name.Space == mcNS && t.Name.Local == "Fallback":
+			if err := d.Skip(); err != nil {
+				return nil, err
+			}
+		case t.Name.Space == presentationNS && t.Name.Local == "sp":
+			shape, err := readSlideShape(d)
+			if err != nil {
+				return nil, err
+			}
+			shapes = append(shapes, shape)
+		case t.Name.Space == drawingNS && t.Name.Local == "tbl":
+			rows, err := readDrawingTable(d)
+			if err != nil {
+				return nil, err
+			}
+			shapes = append(shapes, slideShape{table: rows})
+		}
+	}
+}
+
+func readSlideShape(d *xml.Decoder) (slideShape, error) {
+	var shape slideShape
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return shape, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case t.Name.Space == presentationNS && t.Name.Local == "ph":
+				shape.placeholder = xmlAttr(t, "", "type")
+				if shape.placeholder == "" {
+					shape.placeholder = "body"
+				}
+			case t.Name.Space == drawingNS && t.Name.Local == "p":
+				p, err := readDrawingParagraph(d)
+				if err != nil {
+					return shape, err
+				}
+				shape.paragraphs = append(shape.paragraphs, p)
+			}
+		case xml.EndElement:
+			if t.Name.Space == presentationNS && t.Name.Local == "sp" {
+				return shape, nil
+			}
+		}
+	}
+}
+
+// readDrawingParagraph reads an a:p element after its start tag.
+func readDrawingParagraph(d *xml.Decoder) (slideParagraph, error) {
+	var p slideParagraph
+	var sb strings.Builder
+	inText := false
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return p, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space != drawingNS {
+				continue
+			}
+			switch t.Name.Local {
+			case "pPr":
+				p.level = atoiDefault(xmlAttr(t, "", "lvl"), 0)
+			case "buNone":
+				p.plain = true
+			case "buChar", "buAutoNum", "buBlip":
+				p.bullet = true
+			case "t":
+				inText = true
+			case "br":
+				sb.WriteString("\n")
+			}
+		case xml.EndElement:
+			if t.Name.Space != drawingNS {
+				continue
+			}
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				p.text = sb.String()
+				return p, nil
+			}
+		case xml.CharData:
+			if inText {
+				sb.Write(t)
+			}
+		}
+	}
+}
+
+// readDrawingTable reads the rows of an a:tbl element after its start tag.
+func readDrawingTable(d *xml.Decoder) ([][]string, error) {
+	var rows [][]string
+	var cell []string
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space != drawingNS {
+				continue
+			}
+			switch t.Name.Local {
+			case "tr":
+				rows = append(rows, nil)
+			case "tc":
+				cell = nil
+			case "p":
+				p, err := readDrawingParagraph(d)
+				if err != nil {
+					return nil, err
+				}
+				cell = append(cell, p.text)
+			}
+		case xml.EndElement:
+			if t.Name.Space != drawingNS {
+				continue
+			}
+			switch t.Name.Local {
+			case "tc":
+				if n := len(rows); n > 0 {
+					rows[n-1] = append(rows[n-1], strings.Join(cell, " "))
+				}
+			case "tbl":
+				return rows, nil
+			}
+		}
+	}
+}
+
+// renderSlide returns the title of a slide and the rest of its content as
+// Markdown. Dates, footers and slide numbers are left out.
+func renderSlide(shapes []slideShape) (string, string) {
+	title := ""
+	w := &markdownWriter{}
+	for _, shape := range shapes {
+		switch shape.placeholder {
+		case "title", "ctrTitle":
+			if title == "" {
+				title = shapeText(shape)
+				continue
+			}
+		case "dt", "ftr", "hdr", "sldNum", "sldImg":
+			continue
+		}
+		if shape.table != nil {
+			w.table(shape.table)
+			continue
+		}
+		bulleted := shape.placeholder == "body" || shape.placeholder == "obj"
+		for _, p := range shape.paragraphs {
+			if (bulleted || p.bullet) && !p.plain {
+				w.listItem(p.level, p.text)
+			} else {
+				w.paragraph(p.text)
+			}
+		}
+	}
+	return title, w.String()
+}
+
+// renderNotes returns the text of the body placeholders of a notes slide,
+// one paragraph per non-empty line.
+func renderNotes(shapes []slideShape) string {
+	var paragraphs []string
+	for _, shape := range shapes {
+		if shape.placeholder != "body" {
+			continue
+		}
+		for _, p := range shape.paragraphs {
+			if text := strings.TrimSpace(normalizeSpaces(p.text)); text != "" {
+				paragraphs = append(paragraphs, text)
+			}
+		}
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+func shapeText(shape slideShape) string {
+	parts := make([]string, 0, len(shape.paragraphs))
+	for _, p := range shape.paragraphs {
+		parts = append(parts, p.text)
+	}
+	return singleLine(strings.Join(parts, " "))
+}
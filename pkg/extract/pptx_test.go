@@ -0,0 +1,109 @@
+package extract_test
+
+import (
+	. "github.com/mudler/localrecall/pkg/extract"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const pptxPresentation = `<?xml version="1.0" encoding="UTF-8"?>
+<p:presentation xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main"
+  xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+  <p:sldIdLst><p:sldId id="256" r:id="rId3"/><p:sldId id="257" r:id="rId2"/></p:sldIdLst>
+</p:presentation>`
+
+const pptxPresentationRels = `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+  <Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/slide" Target="slides/slide2.xml"/>
+  <Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/slide" Target="slides/slide1.xml"/>
+</Relationships>`
+
+const pptxSlide1 = `<?xml version="1.0" encoding="UTF-8"?>
+<p:sld xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main" xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main">
+  <p:cSld><p:spTree>
+    <p:sp><p:nvSpPr><p:nvPr><p:ph type="title"/></p:nvPr></p:nvSpPr>
+      <p:txBody><a:p><a:r><a:t>Service </a:t></a:r><a:r><a:t>architecture</a:t></a:r></a:p></p:txBody></p:sp>
+    <p:sp><p:nvSpPr><p:nvPr><p:ph idx="1"/></p:nvPr></p:nvSpPr>
+      <p:txBody>
+        <a:p><a:r><a:t>Gateway</a:t></a:r></a:p>
+        <a:p><a:pPr lvl="1"/><a:r><a:t>Rate limiting</a:t></a:r></a:p>
+      </p:txBody></p:sp>
+    <p:grpSp>
+      <p:sp><p:txBody><a:p><a:r><a:t>Grouped note</a:t></a:r></a:p></p:txBody></p:sp>
+    </p:grpSp>
+    <p:graphicFrame><a:graphic><a:graphicData><a:tbl>
+      <a:tr><a:tc><a:txBody><a:p><a:r><a:t>Service</a:t></a:r></a:p></a:txBody></a:tc><a:tc><a:txBody><a:p><a:r><a:t>Owner</a:t></a:r></a:p></a:txBody></a:tc></a:tr>
+      <a:tr><a:tc><a:txBody><a:p><a:r><a:t>search</a:t></a:r></a:p></a:txBody></a:tc><a:tc><a:txBody><a:p><a:r><a:t>core</a:t></a:r></a:p></a:txBody></a:tc></a:tr>
+    </a:tbl></a:graphicData></a:graphic></p:graphicFrame>
+    <p:sp><p:nvSpPr><p:nvPr><p:ph type="sldNum"/></p:nvPr></p:nvSpPr>
+      <p:txBody><a:p><a:r><a:t>1</a:t></a:r></a:p></p:txBody></p:sp>
+  </p:spTree></p:cSld>
+</p:sld>`
+
+const pptxSlide1Rels = `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+  <Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/slideLayout" Target="../slideLayouts/slideLayout1.xml"/>
+  <Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/notesSlide" Target="../notesSlides/notesSlide1.xml"/>
+</Relationships>`
+
+const pptxNotes1 = `<?xml version="1.0" encoding="UTF-8"?>
+<p:notes xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main" xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main">
+  <p:cSld><p:spTree>
+    <p:sp><p:nvSpPr><p:nvPr><p:ph type="sldImg"/></p:nvPr></p:nvSpPr></p:sp>
+    <p:sp><p:nvSpPr><p:nvPr><p:ph type="body" idx="1"/></p:nvPr></p:nvSpPr>
+      <p:txBody><a:p><a:r><a:t>Mention the   outage.</a:t></a:r></a:p><a:p/></p:txBody></p:sp>
+  </p:spTree></p:cSld>
+</p:notes>`
+
+const pptxSlide2 = `<?xml version="1.0" encoding="UTF-8"?>
+<p:sld xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main" xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main">
+  <p:cSld><p:spTree>
+    <p:sp><p:txBody><a:p><a:r><a:t>Questions?</a:t></a:r></a:p></p:txBody></p:sp>
+  </p:spTree></p:cSld>
+</p:sld>`
+
+var _ = Describe("PPTX", func() {
+	It("reads slides in order with their titles, content and notes", func() {
+		path := writeZip("deck.pptx", map[string]string{
+			"ppt/presentation.xml":             pptxPresentation,
+			"ppt/_rels/presentation.xml.rels":  pptxPresentationRels,
+			"ppt/slides/slide1.xml":            pptxSlide1,
+			"ppt/slides/_rels/slide1.xml.rels": pptxSlide1Rels,
+			"ppt/notesSlides/notesSlide1.xml":  pptxNotes1,
+			"ppt/slides/slide2.xml":            pptxSlide2,
+			"docProps/core.xml":                `<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>Q3 review</dc:title></cp:coreProperties>`,
+		})
+		deck, err := PPTX(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(deck.Title).To(Equal("Q3 review"))
+		Expect(deck.Slides).To(HaveLen(2))
+
+		first := deck.Slides[0]
+		Expect(first.Number).To(Equal(1))
+		Expect(first.Title).To(Equal("Service architecture"))
+		Expect(first.Notes).To(Equal("Mention the outage."))
+		Expect(first.Markdown()).To(Equal(`## Slide 1: Service architecture
+
+- Gateway
+  - Rate limiting
+
+Grouped note
+
+| Service | Owner |
+| --- | --- |
+| search | core |
+
+Notes:
+
+Mention the outage.
+`))
+
+		Expect(deck.Slides[1].Title).To(BeEmpty())
+		Expect(deck.Slides[1].Markdown()).To(Equal("## Slide 2\n\nQuestions?\n"))
+	})
+
+	It("fails on files that are not presentations", func() {
+		_, err := PPTX(writeZip("empty.pptx", map[string]string{"[Content_Types].xml": "<Types/>"}))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,318 @@
+package extract
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// RTF returns the text of a Rich Text Format file as Markdown. Headings (from
+// outline levels or "heading N" styles) and tables are kept; fonts, pictures,
+// headers, footers and other non-body destinations are dropped.
+func RTF(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(string(data[:min(len(data), 5)]), `{\rtf`) {
+		return "", fmt.Errorf("not an rtf file: %s", path)
+	}
+	return parseRTF(data), nil
+}
+
+// rtfSkippedDestinations hold no body text.
+var rtfSkippedDestinations = map[string]bool{
+	"fonttbl": true, "colortbl": true, "info": true, "pict": true, "object": true,
+	"header": true, "headerl": true, "headerr": true, "headerf": true,
+	"footer": true, "footerl": true, "footerr": true, "footerf": true,
+	"footnote": true, "annotation": true, "fldinst": true, "themedata": true,
+	"colorschememapping": true, "datastore": true, "latentstyles": true,
+	"listtable": true, "listoverridetable": true, "rsidtbl": true,
+	"generator": true, "xmlnstbl": true, "mmathPr": true, "filetbl": true,
+	"revtbl": true, "pntext": true, "pntxta": true, "pntxtb": true,
+	"bkmkstart": true, "bkmkend": true, "nonshppict": true, "shpinst": true,
+}
+
+var rtfCodepages = map[int]*charmap.Charmap{
+	437: charmap.CodePage437, 850: charmap.CodePage850,
+	1250: charmap.Windows1250, 1251: charmap.Windows1251, 1252: charmap.Windows1252,
+	1253: charmap.Windows1253, 1254: charmap.Windows1254, 1255: charmap.Windows1255,
+	1256: charmap.Windows1256, 1257: charmap.Windows1257, 1258: charmap.Windows1258,
+	10000: charmap.Macintosh,
+}
+
+var rtfSymbols = map[string]string{
+	"emdash": "—", "endash": "–", "bullet": "•", "lquote": "‘", "rquote": "’",
+	"ldblquote": "“", "rdblquote": "”", "emspace": " ", "enspace": " ", "qmspace": " ",
+	"tab": "\t", "line": "\n",
+}
+
+// rtfGroup is the state scoped to a {...} group.
+type rtfGroup struct {
+	skip       bool
+	stylesheet bool
+	hidden     bool
+	ucSkip     int
+}
+
+type rtfParser struct {
+	data []byte
+	pos  int
+
+	groups  []rtfGroup
+	charmap *charmap.Charmap
+	// pendingSkip counts fallback characters still to drop after \uN.
+	pendingSkip int
+
+	styles    map[int]string
+	styleNum  int
+	styleName strings.Builder
+	para      strings.Builder
+	outline   int
+	paraStyle int
+	inTable   bool
+	cell      []string
+	row       []string
+	tableRows [][]string
+	out       markdownWriter
+}
+
+func parseRTF(data []byte) string {
+	p := &rtfParser{
+		data:    data,
+		groups:  []rtfGroup{{ucSkip: 1}},
+		charmap: charmap.Windows1252,
+		styles:  map[int]string{},
+		outline: -1,
+	}
+	p.run()
+	p.endParagraph()
+	p.flushTable()
+	return p.out.String()
+}
+
+func (p *rtfParser) group() *rtfGroup {
+	return &p.groups[len(p.groups)-1]
+}
+
+func (p *rtfParser) run() {
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+		switch c {
+		case '{':
+			p.groups = append(p.groups, *p.group())
+		case '}':
+			if len(p.groups) > 1 {
+				p.groups = p.groups[:len(p.groups)-1]
+			}
+		case '\\':
+			p.control()
+		case '\r', '\n':
+		default:
+			if c >= 0x80 {
+				p.char(string(p.charmap.DecodeByte(c)))
+			} else {
+				p.char(string(rune(c)))
+			}
+		}
+	}
+}
+
+func (p *rtfParser) control() {
+	if p.pos >= len(p.data) {
+		return
+	}
+	c := p.data[p.pos]
+	p.pos++
+	switch {
+	case c == '\'':
+		if p.pos+2 <= len(p.data) {
+			if b, err := strconv.ParseUint(string(p.data[p.pos:p.pos+2]), 16, 8); err == nil {
+				p.char(string(p.charmap.DecodeByte(byte(b))))
+			}
+			p.pos += 2
+		}
+	case c == '*':
+		p.group().skip = true
+	case c == '\\' || c == '{' || c == '}':
+		p.char(string(c))
+	case c == '~':
+		p.char(" ")
+	case c == '_':
+		p.char("-")
+	case c == '\r' || c == '\n':
+		p.word("par", 0, false)
+	case isASCIILetter(c):
+		start := p.pos - 1
+		for p.pos < len(p.data) && isASCIILetter(p.data[p.pos]) {
+			p.pos++
+		}
+		name := string(p.data[start:p.pos])
+		numStart := p.pos
+		if p.pos < len(p.data) && p.data[p.pos] == '-' {
+			p.pos++
+		}
+		for p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '9' {
+			p.pos++
+		}
+		param, err := strconv.Atoi(string(p.data[numStart:p.pos]))
+		hasParam := err == nil
+		if p.pos < len(p.data) && p.data[p.pos] == ' ' {
+			p.pos++
+		}
+		p.word(name, param, hasParam)
+	}
+}
+
+func (p *rtfParser) word(name string, param int, hasParam bool) {
+	g := p.group()
+	if rtfSkippedDestinations[name] {
+		g.skip = true
+		return
+	}
+	switch name {
+	case "bin":
+		p.pos = min(len(p.data), p.pos+max(param, 0))
+		return
+	case "ansicpg":
+		if cm, ok := rtfCodepages[param]; ok {
+			p.charmap = cm
+		}
+		return
+	case "uc":
+		g.ucSkip = max(param, 0)
+		return
+	case "stylesheet":
+		g.stylesheet = true
+		return
+	case "v":
+		g.hidden = !hasParam || param != 0
+		return
+	}
+	if g.skip {
+		return
+	}
+	if g.stylesheet {
+		if name == "s" {
+			p.styleNum = param
+		}
+		return
+	}
+	switch name {
+	case "u":
+		if param < 0 {
+			param += 65536
+		}
+		p.char(string(rune(param)))
+		p.pendingSkip = g.ucSkip
+	case "par", "sect", "page":
+		p.endParagraph()
+	case "pard":
+		p.outline = -1
+		p.paraStyle = 0
+		p.inTable = false
+	case "intbl":
+		p.inTable = true
+	case "outlinelevel":
+		p.outline = param
+	case "s":
+		p.paraStyle = param
+	case "cell", "nestcell":
+		p.endCell()
+	case "row":
+		p.endCell()
+		if len(p.row) > 0 {
+			p.tableRows = append(p.tableRows, p.row)
+		}
+		p.row = nil
+	default:
+		if s, ok := rtfSymbols[name]; ok {
+			p.char(s)
+		}
+	}
+}
+
+func (p *rtfParser) char(s string) {
+	if p.pendingSkip > 0 {
+		p.pendingSkip--
+		return
+	}
+	g := p.group()
+	if g.skip || g.hidden {
+		return
+	}
+	if g.stylesheet {
+		if s == ";" {
+			p.endStyle()
+			return
+		}
+		p.styleName.WriteString(s)
+		return
+	}
+	p.para.WriteString(s)
+}
+
+func (p *rtfParser) endStyle() {
+	if name := strings.TrimSpace(p.styleName.String()); name != "" {
+		p.styles[p.styleNum] = name
+	}
+	p.styleName.Reset()
+	p.styleNum = 0
+}
+
+func (p *rtfParser) endParagraph() {
+	text := p.para.String()
+	p.para.Reset()
+	if p.inTable {
+		p.cell = append(p.cell, text)
+		return
+	}
+	p.flushTable()
+	level := outlineToHeading(p.outline)
+	if p.outline < 0 {
+		level = styleHeadingLevel(p.styles[p.paraStyle])
+	}
+	if level > 0 {
+		p.out.heading(level, text)
+		return
+	}
+	p.out.paragraph(text)
+}
+
+func (p *rtfParser) endCell() {
+	p.cell = append(p.cell, p.para.String())
+	p.para.Reset()
+	p.row = append(p.row, strings.Join(p.cell, " "))
+	p.cell = nil
+}
+
+func (p *rtfParser) flushTable() {
+	if len(p.row) > 0 {
+		p.tableRows = append(p.tableRows, p.row)
+		p.row = nil
+	}
+	if len(p.tableRows) > 0 {
+		p.out.table(p.tableRows)
+		p.tableRows = nil
+	}
+}
+
+// styleHeadingLevel returns the heading level of a named style, or 0.
+func styleHeadingLevel(name string) int {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "title" {
+		return 1
+	}
+	if n, ok := strings.CutPrefix(name, "heading "); ok {
+		return atoiDefault(n, 0)
+	}
+	return 0
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,59 @@
+package extract_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/mudler/localrecall/pkg/extract"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const rtfDocument = `{\rtf1\ansi\ansicpg1252\deff0
+{\fonttbl{\f0\froman Times New Roman;}}
+{\colortbl;\red0\green0\blue0;}
+{\stylesheet{\s0 Normal;}{\s1\b\fs32 heading 1;}{\s2\b\fs28 heading 2;}}
+{\info{\title Not body text}{\author Someone}}
+{\header\pard Page header\par}
+\pard\s1 Getting started\par
+\pard Caf\'e9 opens at 9\emdash bring your badge.\line Second line\par
+\pard\outlinelevel1 Menu\par
+\pard The \b word\b0  {\*\bkmkstart x}plat du jour is \u8364?12.\par
+{\pict\pngblip 89504e47}
+\trowd\cellx2000\cellx4000
+\pard\intbl Dish\cell Price\cell\row
+\trowd\cellx2000\cellx4000
+\pard\intbl Soup\cell 4\cell\row
+\pard Enjoy{\v hidden}!\par
+}`
+
+var _ = Describe("RTF", func() {
+	It("renders headings, paragraphs and tables as Markdown", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "menu.rtf")
+		Expect(os.WriteFile(path, []byte(rtfDocument), 0644)).To(Succeed())
+		text, err := RTF(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(text).To(Equal(`# Getting started
+
+Café opens at 9—bring your badge.
+Second line
+
+## Menu
+
+The word plat du jour is €12.
+
+| Dish | Price |
+| --- | --- |
+| Soup | 4 |
+
+Enjoy!
+`))
+	})
+
+	It("fails on files that are not RTF", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "plain.rtf")
+		Expect(os.WriteFile(path, []byte("plain text"), 0644)).To(Succeed())
+		_, err := RTF(path)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,160 @@
+package extract
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Cue is a subtitle or transcript cue: its text, on a single line, and the
+// time range it is shown for.
+type Cue struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+// SRT returns the cues of a SubRip subtitle file in file order. Formatting
+// tags are removed. Files that are not valid UTF-8 are read as Windows-1252.
+func SRT(path string) ([]Cue, error) {
+	text, err := readSubtitles(path)
+	if err != nil {
+		return nil, err
+	}
+	cues := parseCues(text)
+	if len(cues) == 0 && strings.TrimSpace(text) != "" {
+		return nil, fmt.Errorf("no subtitle cues found in %s", path)
+	}
+	return cues, nil
+}
+
+// WebVTT returns the cues of a WebVTT file in file order. Formatting tags are
+// removed and the speaker of a voice span (<v Speaker>) prefixes its text;
+// NOTE, STYLE and REGION blocks are skipped.
+func WebVTT(path string) ([]Cue, error) {
+	text, err := readSubtitles(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(text, "WEBVTT") {
+		return nil, fmt.Errorf("not a WebVTT file: %s", path)
+	}
+	return parseCues(text), nil
+}
+
+func readSubtitles(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(data) {
+		if data, err = charmap.Windows1252.NewDecoder().Bytes(data); err != nil {
+			return "", err
+		}
+	}
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	return strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "\n"), nil
+}
+
+// parseCues reads the blank-line separated blocks of an SRT or WebVTT file.
+// A block is a cue when it has a timing line ("start --> end"); lines before
+// it (a sequence number or cue identifier) are ignored and the lines after it
+// are the cue text. Blocks without a timing line, such as the WebVTT header
+// and NOTE, STYLE and REGION blocks, are skipped.
+func parseCues(text string) []Cue {
+	var cues []Cue
+	for _, block := range strings.Split(text, "\n\n") {
+		lines := strings.Split(strings.Trim(block, "\n"), "\n")
+		if first := strings.TrimSpace(lines[0]); first == "NOTE" || strings.HasPrefix(first, "NOTE ") {
+			continue
+		}
+		for i, line := range lines {
+			start, end, ok := parseTiming(line)
+			if !ok {
+				continue
+			}
+			cue := Cue{Start: start, End: end, Text: cueText(lines[i+1:])}
+			if cue.Text != "" {
+				cues = append(cues, cue)
+			}
+			break
+		}
+	}
+	return cues
+}
+
+// parseTiming parses a cue timing line. WebVTT cue settings after the end
+// time are ignored.
+func parseTiming(line string) (time.Duration, time.Duration, bool) {
+	from, to, ok := strings.Cut(line, "-->")
+	if !ok {
+		return 0, 0, false
+	}
+	fields := strings.Fields(to)
+	if len(fields) == 0 {
+		return 0, 0, false
+	}
+	start, ok := parseCueTimestamp(strings.TrimSpace(from))
+	if !ok {
+		return 0, 0, false
+	}
+	end, ok := parseCueTimestamp(fields[0])
+	if !ok {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// parseCueTimestamp parses "hh:mm:ss,mmm" (SRT) and "hh:mm:ss.mmm" or
+// "mm:ss.mmm" (WebVTT) timestamps.
+func parseCueTimestamp(s string) (time.Duration, bool) {
+	clock, fraction, _ := strings.Cut(strings.Replace(s, ",", ".", 1), ".")
+	parts := strings.Split(clock, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, false
+	}
+	var d time.Duration
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0, false
+		}
+		d = d*60 + time.Duration(n)
+	}
+	d *= time.Second
+	if fraction != "" {
+		ms, err := strconv.Atoi((fraction + "00")[:3])
+		if err != nil {
+			return 0, false
+		}
+		d += time.Duration(ms) * time.Millisecond
+	}
+	return d, true
+}
+
+var (
+	// cueVoice matches a WebVTT voice span start tag, capturing the speaker.
+	cueVoice = regexp.MustCompile(`<v(?:\.[^\s>]*)?\s+([^>]+)>`)
+	// cueTag matches markup tags and SSA/ASS override codes ({\an8}).
+	cueTag = regexp.MustCompile(`<[^>]*>|\{\\[^}]*\}`)
+)
+
+// cueText joins the text lines of a cue into a single line without markup.
+func cueText(lines []string) string {
+	var parts []string
+	for _, line := range lines {
+		line = cueVoice.ReplaceAllString(line, "$1: ")
+		line = html.UnescapeString(cueTag.ReplaceAllString(line, ""))
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			parts = append(parts, line)
+		}
+	}
+	return strings.Join(parts, " ")
+}
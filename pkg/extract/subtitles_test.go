@@ -0,0 +1,55 @@
+package extract_test
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/mudler/localrecall/pkg/extract"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Subtitles", func() {
+	write := func(name, content string) string {
+		path := filepath.Join(GinkgoT().TempDir(), name)
+		Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+		return path
+	}
+
+	It("reads SubRip cues without formatting", func() {
+		cues, err := SRT(write("talk.srt", "\ufeff1\r\n00:00:01,500 --> 00:00:04,000\r\n<i>Welcome</i> to the\r\n{\\an8}show &amp; tell\r\n\r\n"+
+			"2\r\n00:12:31,000 --> 00:12:35,250\r\nSearch ships in March\r\n"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cues).To(Equal([]Cue{
+			{Start: 1500 * time.Millisecond, End: 4 * time.Second, Text: "Welcome to the show & tell"},
+			{Start: 12*time.Minute + 31*time.Second, End: 12*time.Minute + 35*time.Second + 250*time.Millisecond, Text: "Search ships in March"},
+		}))
+	})
+
+	It("reads Windows-1252 SubRip files", func() {
+		cues, err := SRT(write("old.srt", "1\n00:00:01,000 --> 00:00:02,000\nCaf\xe9\n"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cues[0].Text).To(Equal("Café"))
+	})
+
+	It("reads WebVTT cues with their speakers", func() {
+		cues, err := WebVTT(write("meeting.vtt", "WEBVTT - Weekly sync\n\n"+
+			"NOTE recorded by the bot\n00:00:00.000 --> 00:00:01.000\n\n"+
+			"STYLE\n::cue { color: white }\n\n"+
+			"intro\n00:05.000 --> 00:09.100 align:start position:10%\n<v.lead Alice>Let's <b>start</b></v>\n\n"+
+			"01:02:03.000 --> 01:02:04.000\n<v Bob>Thanks</v>\n"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cues).To(Equal([]Cue{
+			{Start: 5 * time.Second, End: 9*time.Second + 100*time.Millisecond, Text: "Alice: Let's start"},
+			{Start: time.Hour + 2*time.Minute + 3*time.Second, End: time.Hour + 2*time.Minute + 4*time.Second, Text: "Bob: Thanks"},
+		}))
+	})
+
+	It("rejects files that are not subtitles", func() {
+		_, err := WebVTT(write("notes.vtt", "00:00:01.000 --> 00:00:02.000\nHi\n"))
+		Expect(err).To(HaveOccurred())
+		_, err = SRT(write("notes.srt", "just some text\n"))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,34 @@
+package extract_test
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestExtract(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Extract Suite")
+}
+
+// writeZip writes a zip archive with the given members to a temporary file
+// and returns its path.
+func writeZip(name string, members map[string]string) string {
+	path := filepath.Join(GinkgoT().TempDir(), name)
+	f, err := os.Create(path)
+	Expect(err).ToNot(HaveOccurred())
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for member, content := range members {
+		w, err := zw.Create(member)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = w.Write([]byte(content))
+		Expect(err).ToNot(HaveOccurred())
+	}
+	Expect(zw.Close()).To(Succeed())
+	return path
+}
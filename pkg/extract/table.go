@@ -0,0 +1,408 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Table is a sheet of tabular data whose first non-empty row is the header.
+type Table struct {
+	// Name is the sheet name for spreadsheets, empty for CSV and TSV.
+	Name   string
+	Header []string
+	Rows   [][]string
+}
+
+// Markdown renders the table as a Markdown table.
+func (t Table) Markdown() string {
+	w := &markdownWriter{}
+	if t.Name != "" {
+		w.heading(2, t.Name)
+	}
+	w.table(append([][]string{t.Header}, t.Rows...))
+	return w.String()
+}
+
+// CSV reads a comma-separated (or, with comma set to '\t', tab-separated)
+// file. Quoting is handled leniently and rows may have any number of fields.
+func CSV(path string, comma rune) (Table, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Table{}, err
+	}
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	r.Comma = comma
+	r.LazyQuotes = true
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return Table{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return newTable("", records), nil
+}
+
+// newTable takes the first non-empty record as the header, names unnamed
+// columns, and drops empty rows and trailing empty cells.
+func newTable(name string, records [][]string) Table {
+	t := Table{Name: name}
+	for _, record := range records {
+		for len(record) > 0 && strings.TrimSpace(record[len(record)-1]) == "" {
+			record = record[:len(record)-1]
+		}
+		if len(record) == 0 {
+			continue
+		}
+		for i := range record {
+			record[i] = strings.TrimSpace(record[i])
+		}
+		if t.Header == nil {
+			t.Header = record
+			continue
+		}
+		t.Rows = append(t.Rows, record)
+	}
+	width := len(t.Header)
+	for _, row := range t.Rows {
+		width = max(width, len(row))
+	}
+	for i := range width {
+		if i >= len(t.Header) {
+			t.Header = append(t.Header, "")
+		}
+		if t.Header[i] == "" {
+			t.Header[i] = fmt.Sprintf("Column %d", i+1)
+		}
+	}
+	return t
+}
+
+const (
+	spreadsheetNS   = "http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+	relationshipsNS = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+)
+
+// XLSX reads every worksheet of an Excel workbook. Shared and inline strings,
+// booleans and dates are resolved to text; formulas yield their cached value.
+func XLSX(fpath string) ([]Table, error) {
+	zr, err := zip.OpenReader(fpath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open xlsx: %w", err)
+	}
+	defer zr.Close()
+
+	wb := findZipFile(&zr.Reader, "xl/workbook.xml")
+	if wb == nil {
+		return nil, errors.New("not an xlsx file: xl/workbook.xml is missing")
+	}
+	sheets, err := readZipXML(wb, parseWorkbookSheets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read xlsx workbook: %w", err)
+	}
+	targets := map[string]string{}
+	if f := findZipFile(&zr.Reader, "xl/_rels/workbook.xml.rels"); f != nil {
+		if targets, err = readZipXML(f, parseRelationships); err != nil {
+			return nil, fmt.Errorf("failed to read xlsx relationships: %w", err)
+		}
+	}
+	var shared []string
+	if f := findZipFile(&zr.Reader, "xl/sharedStrings.xml"); f != nil {
+		if shared, err = readZipXML(f, parseSharedStrings); err != nil {
+			return nil, fmt.Errorf("failed to read xlsx shared strings: %w", err)
+		}
+	}
+	var dateStyles []bool
+	if f := findZipFile(&zr.Reader, "xl/styles.xml"); f != nil {
+		if dateStyles, err = readZipXML(f, parseDateStyles); err != nil {
+			return nil, fmt.Errorf("failed to read xlsx styles: %w", err)
+		}
+	}
+
+	var tables []Table
+	for _, sheet := range sheets {
+		target, ok := targets[sheet.relID]
+		if !ok {
+			continue
+		}
+		if strings.HasPrefix(target, "/") {
+			target = strings.TrimPrefix(target, "/")
+		} else {
+			target = path.Join("xl", target)
+		}
+		f := findZipFile(&zr.Reader, target)
+		if f == nil {
+			continue
+		}
+		records, err := readZipXML(f, func(d *xml.Decoder) ([][]string, error) {
+			return parseWorksheet(d, shared, dateStyles)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read sheet %q: %w", sheet.name, err)
+		}
+		if table := newTable(sheet.name, records); table.Header != nil {
+			tables = append(tables, table)
+		}
+	}
+	return tables, nil
+}
+
+type workbookSheet struct {
+	name  string
+	relID string
+}
+
+func parseWorkbookSheets(d *xml.Decoder) ([]workbookSheet, error) {
+	var sheets []workbookSheet
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return sheets, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if t, ok := tok.(xml.StartElement); ok && t.Name.Space == spreadsheetNS && t.Name.Local == "sheet" {
+			sheets = append(sheets, workbookSheet{name: xmlAttr(t, "", "name"), relID: xmlAttr(t, relationshipsNS, "id")})
+		}
+	}
+}
+
+func parseRelationships(d *xml.Decoder) (map[string]string, error) {
+	targets := map[string]string{}
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return targets, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if t, ok := tok.(xml.StartElement); ok && t.Name.Local == "Relationship" {
+			targets[xmlAttr(t, "", "Id")] = xmlAttr(t, "", "Target")
+		}
+	}
+}
+
+func parseSharedStrings(d *xml.Decoder) ([]string, error) {
+	var shared []string
+	var sb strings.Builder
+	inText, inPhonetic := false, false
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return shared, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "si":
+				sb.Reset()
+			case "t":
+				inText = true
+			case "rPh":
+				inPhonetic = true
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "si":
+				shared = append(shared, sb.String())
+			case "t":
+				inText = false
+			case "rPh":
+				inPhonetic = false
+			}
+		case xml.CharData:
+			if inText && !inPhonetic {
+				sb.Write(t)
+			}
+		}
+	}
+}
+
+// parseDateStyles reports, for every cell style index, whether its number
+// format displays a date.
+func parseDateStyles(d *xml.Decoder) ([]bool, error) {
+	customDates := map[int]bool{}
+	var styles []bool
+	inCellXfs := false
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return styles, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "numFmt":
+				id := atoiDefault(xmlAttr(t, "", "numFmtId"), -1)
+				customDates[id] = isDateFormat(xmlAttr(t, "", "formatCode"))
+			case "cellXfs":
+				inCellXfs = true
+			case "xf":
+				if inCellXfs {
+					id := atoiDefault(xmlAttr(t, "", "numFmtId"), 0)
+					styles = append(styles, (id >= 14 && id <= 22) || (id >= 45 && id <= 47) || customDates[id])
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Local == "cellXfs" {
+				inCellXfs = false
+			}
+		}
+	}
+}
+
+// isDateFormat reports whether a custom number format code shows a date or
+// time, ignoring quoted literals and bracketed colours.
+func isDateFormat(code string) bool {
+	inQuote, inBracket := false, false
+	for _, r := range strings.ToLower(code) {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+		case inQuote:
+		case r == '[':
+			inBracket = true
+		case r == ']':
+			inBracket = false
+		case inBracket:
+		case r == 'y' || r == 'd' || r == 'h' || r == 's' || r == 'm':
+			return true
+		}
+	}
+	return false
+}
+
+func parseWorksheet(d *xml.Decoder, shared []string, dateStyles []bool) ([][]string, error) {
+	var records [][]string
+	var cellType, cellRef, value string
+	cellStyle := 0
+	var sb strings.Builder
+	inValue := false
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space != spreadsheetNS {
+				continue
+			}
+			switch t.Name.Local {
+			case "row":
+				records = append(records, nil)
+			case "c":
+				cellType = xmlAttr(t, "", "t")
+				cellRef = xmlAttr(t, "", "r")
+				cellStyle = atoiDefault(xmlAttr(t, "", "s"), 0)
+				value = ""
+			case "v", "t":
+				inValue = true
+				sb.Reset()
+			}
+		case xml.EndElement:
+			if t.Name.Space != spreadsheetNS {
+				continue
+			}
+			switch t.Name.Local {
+			case "v", "t":
+				inValue = false
+				value += sb.String()
+			case "c":
+				if len(records) == 0 {
+					records = append(records, nil)
+				}
+				row := &records[len(records)-1]
+				col := columnIndex(cellRef, len(*row))
+				if col > 16384 {
+					continue
+				}
+				for len(*row) <= col {
+					*row = append(*row, "")
+				}
+				isDate := cellStyle < len(dateStyles) && dateStyles[cellStyle]
+				(*row)[col] = cellText(cellType, value, shared, isDate)
+			}
+		case xml.CharData:
+			if inValue {
+				sb.Write(t)
+			}
+		}
+	}
+}
+
+// columnIndex returns the zero-based column of a cell reference such as
+// "C7", or next when the reference is missing.
+func columnIndex(ref string, next int) int {
+	col := 0
+	n := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A'+1)
+		n++
+	}
+	if n == 0 {
+		return next
+	}
+	return col - 1
+}
+
+func cellText(cellType, value string, shared []string, isDate bool) string {
+	switch cellType {
+	case "s":
+		i, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || i < 0 || i >= len(shared) {
+			return ""
+		}
+		return shared[i]
+	case "b":
+		if value == "1" {
+			return "TRUE"
+		}
+		return "FALSE"
+	case "str", "inlineStr", "e":
+		return value
+	}
+	if isDate {
+		if serial, err := strconv.ParseFloat(value, 64); err == nil {
+			return excelDate(serial)
+		}
+	}
+	return value
+}
+
+// excelDate converts a serial date of the 1900 date system.
+func excelDate(serial float64) string {
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	days, frac := math.Modf(serial)
+	t := epoch.AddDate(0, 0, int(days)).Add(time.Duration(math.Round(frac*86400)) * time.Second)
+	if frac == 0 {
+		return t.Format("2006-01-02")
+	}
+	if days == 0 {
+		return t.Format("15:04:05")
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
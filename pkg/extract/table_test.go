@@ -0,0 +1,98 @@
+package extract_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/mudler/localrecall/pkg/extract"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+  <sheets>
+    <sheet name="Products" sheetId="1" r:id="rId1"/>
+    <sheet name="Empty" sheetId="2" r:id="rId2"/>
+  </sheets>
+</workbook>`
+	xlsxRels = `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+  <Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+  <Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="/xl/worksheets/sheet2.xml"/>
+</Relationships>`
+	xlsxShared = `<?xml version="1.0" encoding="UTF-8"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+  <si><t>SKU</t></si>
+  <si><t>Name</t></si>
+  <si><r><t>Electric </t></r><r><t>kettle</t></r><rPh><t>phonetic</t></rPh></si>
+</sst>`
+	xlsxStyles = `<?xml version="1.0" encoding="UTF-8"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+  <numFmts><numFmt numFmtId="164" formatCode="yyyy\-mm\-dd"/><numFmt numFmtId="165" formatCode="&quot;day&quot;0"/></numFmts>
+  <cellXfs>
+    <xf numFmtId="0"/>
+    <xf numFmtId="164"/>
+    <xf numFmtId="165"/>
+  </cellXfs>
+</styleSheet>`
+	xlsxSheet1 = `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+  <sheetData>
+    <row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="inlineStr"><is><t>Released</t></is></c><c r="E1" t="str"><v>In stock</v></c></row>
+    <row r="3"><c r="A3"><v>1001</v></c><c r="B3" t="s"><v>2</v></c><c r="C3" s="1"><v>45292</v></c><c r="D3" s="2"><v>7</v></c><c r="E3" t="b"><f>TRUE()</f><v>1</v></c></row>
+  </sheetData>
+</worksheet>`
+	xlsxSheet2 = `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData/></worksheet>`
+)
+
+var _ = Describe("Tables", func() {
+	writeFile := func(name, content string) string {
+		path := filepath.Join(GinkgoT().TempDir(), name)
+		Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+		return path
+	}
+
+	It("reads CSV files leniently", func() {
+		path := writeFile("faq.csv", "\xef\xbb\xbfQuestion,Answer,\n\"How do I reset, my password?\",\"Use the \"\"Forgot\"\" link\"\n,,\nWhere is my invoice?,In billing,extra\n")
+		table, err := CSV(path, ',')
+		Expect(err).ToNot(HaveOccurred())
+		Expect(table.Header).To(Equal([]string{"Question", "Answer", "Column 3"}))
+		Expect(table.Rows).To(Equal([][]string{
+			{"How do I reset, my password?", `Use the "Forgot" link`},
+			{"Where is my invoice?", "In billing", "extra"},
+		}))
+		Expect(table.Markdown()).To(Equal("| Question | Answer | Column 3 |\n| --- | --- | --- |\n| How do I reset, my password? | Use the \"Forgot\" link |  |\n| Where is my invoice? | In billing | extra |\n"))
+	})
+
+	It("reads TSV files", func() {
+		table, err := CSV(writeFile("data.tsv", "a\tb\n1\t2\n"), '\t')
+		Expect(err).ToNot(HaveOccurred())
+		Expect(table.Header).To(Equal([]string{"a", "b"}))
+		Expect(table.Rows).To(Equal([][]string{{"1", "2"}}))
+	})
+
+	It("reads the sheets of XLSX workbooks", func() {
+		path := writeZip("catalog.xlsx", map[string]string{
+			"xl/workbook.xml":            xlsxWorkbook,
+			"xl/_rels/workbook.xml.rels": xlsxRels,
+			"xl/sharedStrings.xml":       xlsxShared,
+			"xl/styles.xml":              xlsxStyles,
+			"xl/worksheets/sheet1.xml":   xlsxSheet1,
+			"xl/worksheets/sheet2.xml":   xlsxSheet2,
+		})
+		tables, err := XLSX(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tables).To(HaveLen(1))
+		Expect(tables[0].Name).To(Equal("Products"))
+		Expect(tables[0].Header).To(Equal([]string{"SKU", "Name", "Released", "Column 4", "In stock"}))
+		Expect(tables[0].Rows).To(Equal([][]string{{"1001", "Electric kettle", "2024-01-01", "7", "TRUE"}}))
+	})
+
+	It("fails on files that are not workbooks", func() {
+		_, err := XLSX(writeZip("empty.xlsx", map[string]string{"readme.txt": "hi"}))
+		Expect(err).To(HaveOccurred())
+	})
+})
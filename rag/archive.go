@@ -0,0 +1,149 @@
+package rag
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+const (
+	// ArchiveKey is the metadata key holding the name of the archive an
+	// entry was expanded from.
+	ArchiveKey = "archive"
+	// ArchivePathKey is the metadata key holding the path of an entry
+	// relative to the root of its archive.
+	ArchivePathKey = "archive_path"
+
+	// maxArchiveFiles bounds the number of files expanded from one archive.
+	maxArchiveFiles = 10000
+	// maxArchiveSize bounds the total uncompressed size of one archive.
+	maxArchiveSize = 4 << 30
+)
+
+// IsArchive reports whether name is an archive that uploads expand into
+// one entry per contained file: .zip, .tar.gz or .tgz.
+func IsArchive(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasSuffix(lower, ".zip") || strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz")
+}
+
+// ArchiveEntryName names the entry of a file expanded from an archive after
+// its path in the archive, encoded like the paths of source files, so files
+// of the same name in different folders stay apart.
+func ArchiveEntryName(relPath string) string {
+	return encodeEntryPath(relPath, 255)
+}
+
+// WalkArchive calls fn for every regular file in the archive read from r,
+// with its slash-separated path relative to the archive root. Directories,
+// links and macOS metadata are skipped. An error returned by fn stops the
+// walk and is returned.
+func WalkArchive(name string, r io.ReaderAt, size int64, fn func(relPath string, content io.Reader) error) error {
+	if strings.HasSuffix(strings.ToLower(name), ".zip") {
+		return walkZip(r, size, fn)
+	}
+	if IsArchive(name) {
+		return walkTarGz(io.NewSectionReader(r, 0, size), fn)
+	}
+	return fmt.Errorf("unsupported archive: %s", name)
+}
+
+func walkZip(r io.ReaderAt, size int64, fn func(string, io.Reader) error) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("failed to read zip archive: %w", err)
+	}
+	limits := &archiveLimits{}
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		relPath, ok := archiveRelPath(f.Name)
+		if !ok {
+			continue
+		}
+		if err := limits.add(int64(f.UncompressedSize64)); err != nil {
+			return err
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", f.Name, err)
+		}
+		err = fn(relPath, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func walkTarGz(r io.Reader, fn func(string, io.Reader) error) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("failed to read gzip stream: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	limits := &archiveLimits{}
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		relPath, ok := archiveRelPath(hdr.Name)
+		if !ok {
+			continue
+		}
+		if err := limits.add(hdr.Size); err != nil {
+			return err
+		}
+		if err := fn(relPath, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// archiveRelPath cleans the path of an archive member. It reports false for
+// members that are not worth ingesting (macOS metadata) or that escape the
+// archive root.
+func archiveRelPath(name string) (string, bool) {
+	name = strings.ReplaceAll(name, `\`, "/")
+	cleaned := path.Clean("/" + name)[1:]
+	if cleaned == "" || strings.HasPrefix(name, "../") || strings.Contains(name, "/../") {
+		return "", false
+	}
+	if strings.HasPrefix(cleaned, "__MACOSX/") || path.Base(cleaned) == ".DS_Store" {
+		return "", false
+	}
+	return cleaned, true
+}
+
+type archiveLimits struct {
+	files int
+	size  int64
+}
+
+func (l *archiveLimits) add(size int64) error {
+	l.files++
+	l.size += size
+	if l.files > maxArchiveFiles {
+		return fmt.Errorf("archive has more than %d files", maxArchiveFiles)
+	}
+	if l.size > maxArchiveSize {
+		return fmt.Errorf("archive expands to more than %d bytes", int64(maxArchiveSize))
+	}
+	return nil
+}
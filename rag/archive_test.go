@@ -0,0 +1,107 @@
+package rag_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+
+	. "github.com/mudler/localrecall/rag"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func walkAll(name string, data []byte) (map[string]string, error) {
+	files := map[string]string{}
+	err := WalkArchive(name, bytes.NewReader(data), int64(len(data)), func(relPath string, content io.Reader) error {
+		b, err := io.ReadAll(content)
+		files[relPath] = string(b)
+		return err
+	})
+	return files, err
+}
+
+var _ = Describe("Archives", func() {
+	members := map[string]string{
+		"README.md":              "# Docs",
+		"guide/install.md":       "install it",
+		"./guide/usage.txt":      "use it",
+		"__MACOSX/._README.md":   "resource fork",
+		"guide/.DS_Store":        "finder",
+		"../outside/escape.txt":  "nope",
+		"guide/nested/deep.html": "<p>deep</p>",
+	}
+	expected := map[string]string{
+		"README.md":              "# Docs",
+		"guide/install.md":       "install it",
+		"guide/usage.txt":        "use it",
+		"guide/nested/deep.html": "<p>deep</p>",
+	}
+
+	It("recognises archive names", func() {
+		Expect(IsArchive("docs.zip")).To(BeTrue())
+		Expect(IsArchive("docs.TAR.GZ")).To(BeTrue())
+		Expect(IsArchive("docs.tgz")).To(BeTrue())
+		Expect(IsArchive("docs.tar")).To(BeFalse())
+		Expect(IsArchive("notes.txt")).To(BeFalse())
+	})
+
+	It("names entries after the path of the file in the archive", func() {
+		Expect(ArchiveEntryName("README.md")).To(Equal("README.md"))
+		Expect(ArchiveEntryName("guide/install.md")).To(Equal("guide__install.md"))
+		Expect(ArchiveEntryName("guide/README.md")).ToNot(Equal(ArchiveEntryName("README.md")))
+
+		confusable := ArchiveEntryName("guide__install.md")
+		Expect(confusable).ToNot(Equal(ArchiveEntryName("guide/install.md")))
+		Expect(confusable).To(HaveSuffix(".md"))
+		Expect(len(ArchiveEntryName(strings.Repeat("deep/", 100) + "file.txt"))).To(BeNumerically("<=", 255))
+
+		Expect(ValidateMetadata(map[string]string{ArchivePathKey: "x"})).ToNot(Succeed())
+	})
+
+	It("walks the files of a zip archive", func() {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		_, err := zw.Create("guide/")
+		Expect(err).ToNot(HaveOccurred())
+		for name, content := range members {
+			w, err := zw.Create(name)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = w.Write([]byte(content))
+			Expect(err).ToNot(HaveOccurred())
+		}
+		Expect(zw.Close()).To(Succeed())
+
+		files, err := walkAll("docs.zip", buf.Bytes())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(files).To(Equal(expected))
+	})
+
+	It("walks the files of a tar.gz archive", func() {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gz)
+		Expect(tw.WriteHeader(&tar.Header{Name: "guide/", Typeflag: tar.TypeDir, Mode: 0755})).To(Succeed())
+		Expect(tw.WriteHeader(&tar.Header{Name: "link.md", Typeflag: tar.TypeSymlink, Linkname: "README.md"})).To(Succeed())
+		for name, content := range members {
+			Expect(tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))})).To(Succeed())
+			_, err := tw.Write([]byte(content))
+			Expect(err).ToNot(HaveOccurred())
+		}
+		Expect(tw.Close()).To(Succeed())
+		Expect(gz.Close()).To(Succeed())
+
+		files, err := walkAll("docs.tar.gz", buf.Bytes())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(files).To(Equal(expected))
+	})
+
+	It("rejects corrupt archives", func() {
+		_, err := walkAll("docs.zip", []byte("not a zip"))
+		Expect(err).To(HaveOccurred())
+		_, err = walkAll("docs.tgz", []byte("not gzip"))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -1,6 +1,7 @@
 package rag
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -8,6 +9,7 @@ import (
 
 	"github.com/mudler/localrecall/rag/engine"
 	"github.com/mudler/localrecall/rag/engine/localai"
+	"github.com/mudler/localrecall/rag/types"
 	"github.com/mudler/xlog"
 	"github.com/sashabaranov/go-openai"
 )
@@ -24,7 +26,7 @@ func NewPersistentChromeCollection(llmClient *openai.Client, collectionName, dbP
 	}
 
 	persistentKB, err := NewPersistentCollectionKB(
-		filepath.Join(dbPath, fmt.Sprintf("%s%s.json", collectionPrefix, collectionName)),
+		collectionStateFile(dbPath, collectionName),
 		filepath.Join(filePath, collectionName),
 		chromemDB,
 		maxChunkSize, chunkOverlap, llmClient, embeddingModel)
@@ -42,7 +44,7 @@ func NewPersistentLocalAICollection(llmClient *openai.Client, apiURL, apiKey, co
 	ragDB := engine.NewLocalAIRAGDB(laiStore, llmClient, embeddingModel)
 
 	persistentKB, err := NewPersistentCollectionKB(
-		filepath.Join(dbPath, fmt.Sprintf("%s%s.json", collectionPrefix, collectionName)),
+		collectionStateFile(dbPath, collectionName),
 		filepath.Join(filePath, collectionName),
 		ragDB,
 		maxChunkSize, chunkOverlap, llmClient, embeddingModel)
@@ -67,7 +69,7 @@ func NewPersistentPostgresCollection(llmClient *openai.Client, collectionName, d
 	}
 
 	persistentKB, err := NewPersistentCollectionKB(
-		filepath.Join(dbPath, fmt.Sprintf("%s%s.json", collectionPrefix, collectionName)),
+		collectionStateFile(dbPath, collectionName),
 		filepath.Join(filePath, collectionName),
 		postgresDB,
 		maxChunkSize, chunkOverlap, llmClient, embeddingModel)
@@ -97,3 +99,22 @@ func ListAllCollections(dbPath string) []string {
 
 	return collections
 }
+
+// collectionStateFile returns the path of the state file of a collection.
+func collectionStateFile(dbPath, collectionName string) string {
+	return filepath.Join(dbPath, fmt.Sprintf("%s%s.json", collectionPrefix, collectionName))
+}
+
+// LoadCollectionConfig reads the configuration stored in a collection's state
+// file. It returns nil when the collection does not exist yet or was created
+// without a configuration.
+func LoadCollectionConfig(dbPath, collectionName string) (*types.CollectionConfig, error) {
+	state, err := loadDB(collectionStateFile(dbPath, collectionName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return state.Config, nil
+}
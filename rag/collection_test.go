@@ -5,6 +5,8 @@ import (
 	"path/filepath"
 
 	. "github.com/mudler/localrecall/rag"
+	"github.com/mudler/localrecall/rag/engine"
+	"github.com/mudler/localrecall/rag/types"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -55,4 +57,77 @@ var _ = Describe("Collection", func() {
 			Expect(collections).To(BeNil())
 		})
 	})
+
+	Describe("Collection config", func() {
+		var tempDir string
+
+		BeforeEach(func() {
+			var err error
+			tempDir, err = os.MkdirTemp("", "collection_config_test_*")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(tempDir)
+		})
+
+		It("persists the config in the collection state", func() {
+			stateFile := filepath.Join(tempDir, "collection-notes.json")
+			kb, err := NewPersistentCollectionKB(stateFile, filepath.Join(tempDir, "assets"), engine.NewMockEngine(), 400, 0, nil, "")
+			Expect(err).ToNot(HaveOccurred())
+
+			config, err := LoadCollectionConfig(tempDir, "notes")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config).To(BeNil())
+
+			overlap := 50
+			Expect(kb.SetConfig(&types.CollectionConfig{ChunkSize: 1500, ChunkOverlap: &overlap, Engine: "postgres"})).To(Succeed())
+
+			config, err = LoadCollectionConfig(tempDir, "notes")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.ChunkSize).To(Equal(1500))
+			Expect(config.Overlap()).To(Equal(50))
+			Expect(config.Engine).To(Equal("postgres"))
+
+			reloaded, err := NewPersistentCollectionKB(stateFile, filepath.Join(tempDir, "assets"), engine.NewMockEngine(), 400, 0, nil, "")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reloaded.Config()).To(Equal(config))
+		})
+
+		It("returns no config for unknown collections", func() {
+			config, err := LoadCollectionConfig(tempDir, "missing")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config).To(BeNil())
+		})
+
+		It("merges, resolves and validates settings", func() {
+			zero, defaultOverlap := 0, 20
+			defaults := types.CollectionConfig{ChunkSize: 400, ChunkOverlap: &defaultOverlap, ChunkingUnit: types.ChunkingUnitCharacters, EmbeddingModel: "granite", Engine: "chromem"}
+
+			var stored *types.CollectionConfig
+			Expect(stored.Resolve(defaults)).To(Equal(defaults))
+
+			stored = stored.Merge(&types.CollectionConfig{ChunkSize: 2000, ChunkOverlap: &zero})
+			stored = stored.Merge(&types.CollectionConfig{EmbeddingModel: "bge"})
+			effective := stored.Resolve(defaults)
+			Expect(effective.ChunkSize).To(Equal(2000))
+			Expect(effective.Overlap()).To(Equal(0))
+			Expect(effective.EmbeddingModel).To(Equal("bge"))
+			Expect(effective.Engine).To(Equal("chromem"))
+			Expect(effective.Validate()).To(Succeed())
+
+			tooLarge := 2000
+			Expect((&types.CollectionConfig{ChunkSize: 100, ChunkOverlap: &tooLarge}).Validate()).ToNot(Succeed())
+			Expect((&types.CollectionConfig{ChunkingUnit: "bytes"}).Validate()).ToNot(Succeed())
+		})
+
+		It("replaces key columns only when the patch sets them", func() {
+			stored := (*types.CollectionConfig)(nil).Merge(&types.CollectionConfig{KeyColumns: []string{"sku"}})
+			Expect(stored.Merge(&types.CollectionConfig{ChunkSize: 100}).KeyColumns).To(Equal([]string{"sku"}))
+			Expect(stored.Merge(&types.CollectionConfig{KeyColumns: []string{}}).KeyColumns).To(BeEmpty())
+			Expect(stored.IsZero()).To(BeFalse())
+			Expect(types.CollectionConfig{}.IsZero()).To(BeTrue())
+			Expect((&types.CollectionConfig{KeyColumns: []string{" "}}).Validate()).ToNot(Succeed())
+		})
+	})
 })
@@ -0,0 +1,121 @@
+package rag
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/mudler/localrecall/rag/types"
+	"github.com/mudler/xlog"
+)
+
+const (
+	// DocumentIDKey is the metadata key holding the ID of a document entry.
+	DocumentIDKey = "document_id"
+	// documentType is the "type" metadata of document entries.
+	documentType = "document"
+)
+
+// ReservedMetadataKeys are set by the collection itself and cannot be
+// supplied by callers.
+var ReservedMetadataKeys = []string{"type", "source", "file_name", DocumentIDKey, ArchiveKey, ArchivePathKey}
+
+// ValidateMetadata rejects caller-supplied metadata that sets a reserved key.
+func ValidateMetadata(metadata map[string]string) error {
+	for _, k := range ReservedMetadataKeys {
+		if _, ok := metadata[k]; ok {
+			return fmt.Errorf("metadata key %q is reserved", k)
+		}
+	}
+	return nil
+}
+
+// StoreDocument stores raw text as an entry of its own, keyed
+// "uuid/<id>.txt". Storing a document under the ID of an existing document
+// replaces it. It returns the entry key.
+func (db *PersistentKB) StoreDocument(doc types.Document) (string, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	key, err := db.storeDocument(doc)
+	if err != nil {
+		return "", err
+	}
+	return key, db.save()
+}
+
+// storeDocument stores doc like StoreDocument without saving the collection
+// state. Callers hold the lock.
+func (db *PersistentKB) storeDocument(doc types.Document) (string, error) {
+	if db.dropped {
+		return "", errCollectionDropped
+	}
+	if strings.TrimSpace(doc.Content) == "" {
+		return "", errors.New("document content is empty")
+	}
+	id := doc.ID
+	if id == "" {
+		id = uuid.New().String()
+	}
+	if err := validateDocumentID(id); err != nil {
+		return "", err
+	}
+	fileName := id + ".txt"
+
+	oldKey, exists := db.findEntryKey(fileName)
+	if exists && db.metadata[oldKey]["type"] != documentType {
+		return "", fmt.Errorf("entry %s already exists and is not a document", fileName)
+	}
+
+	dir := uuid.New().String()
+	key := filepath.Join(dir, fileName)
+	if err := os.MkdirAll(filepath.Join(db.assetDir, dir), 0755); err != nil {
+		return "", fmt.Errorf("failed to create entry directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(db.assetDir, key), []byte(doc.Content), 0644); err != nil {
+		return "", fmt.Errorf("failed to write document: %w", err)
+	}
+
+	metadata := make(map[string]string, len(doc.Metadata)+2)
+	for k, v := range doc.Metadata {
+		metadata[k] = v
+	}
+	metadata["type"] = documentType
+	metadata[DocumentIDKey] = id
+	db.setEntryMetadata(key, metadata)
+
+	if _, err := db.store(map[string]string{}, key); err != nil {
+		db.setEntryMetadata(key, nil)
+		os.RemoveAll(filepath.Join(db.assetDir, dir))
+		return "", fmt.Errorf("failed to store document: %w", err)
+	}
+	xlog.Info("Stored document", "id", id, "entry", key)
+
+	// The document replaced is only removed once its new version is
+	// stored, so a failed store keeps it.
+	if exists {
+		xlog.Info("Removing replaced document", "id", id, "entry", oldKey)
+		repopulate := os.Getenv("LOCALRECALL_REPOPULATE_DELETE") == "true"
+		if err := db.deleteEntry(oldKey, repopulate); err != nil {
+			return "", fmt.Errorf("failed to remove the replaced version of document %s: %w", id, err)
+		}
+		if repopulate {
+			if err := db.repopulate(); err != nil {
+				return "", fmt.Errorf("failed to remove the replaced version of document %s: %w", id, err)
+			}
+		}
+	}
+
+	return key, nil
+}
+
+// validateDocumentID rejects IDs that cannot be used as a file name.
+func validateDocumentID(id string) error {
+	if id != filepath.Base(id) || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+		return fmt.Errorf("invalid document id: %q", id)
+	}
+	return nil
+}
@@ -0,0 +1,313 @@
+package rag
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mudler/localrecall/pkg/chunk"
+	"github.com/mudler/localrecall/pkg/extract"
+	"github.com/mudler/xlog"
+)
+
+// Chunk metadata keys holding the headers of the email a chunk was taken
+// from. Message IDs are stored without their angle brackets, so the
+// in_reply_to of a reply is the message_id of its parent and all messages of
+// a thread share a thread_id, the message_id of its first message.
+const (
+	FromKey      = "from"
+	ToKey        = "to"
+	CcKey        = "cc"
+	SubjectKey   = "subject"
+	DateKey      = "date"
+	MessageIDKey = "message_id"
+	InReplyToKey = "in_reply_to"
+	ThreadIDKey  = "thread_id"
+	// AttachmentKey is the chunk metadata key holding the name of the email
+	// attachment a chunk was taken from.
+	AttachmentKey = "attachment"
+	// MailboxKey is the metadata key holding the name of the mbox file a
+	// message entry was taken from.
+	MailboxKey = "mailbox"
+)
+
+// isMailboxFile reports whether the file is an mbox, whose messages are each
+// stored as an entry of their own.
+func isMailboxFile(path string) bool {
+	return strings.ToLower(filepath.Ext(path)) == ".mbox"
+}
+
+// emailMetadata returns the header metadata of an email.
+func emailMetadata(email extract.Email) map[string]string {
+	metadata := map[string]string{}
+	set := func(k, v string) {
+		if v != "" {
+			metadata[k] = v
+		}
+	}
+	set(FromKey, email.From)
+	set(ToKey, email.To)
+	set(CcKey, email.Cc)
+	set(SubjectKey, email.Subject)
+	if !email.Date.IsZero() {
+		metadata[DateKey] = email.Date.UTC().Format(time.RFC3339)
+	}
+	set(MessageIDKey, email.MessageID)
+	set(InReplyToKey, email.InReplyTo)
+	set(ThreadIDKey, email.ThreadID())
+	return metadata
+}
+
+// emailText returns the body of an email under its main headers, so that
+// searches for a sender or subject find the message.
+func emailText(email extract.Email) string {
+	var b strings.Builder
+	for _, h := range []struct{ name, value string }{
+		{"From", email.From},
+		{"To", email.To},
+		{"Cc", email.Cc},
+		{"Subject", email.Subject},
+	} {
+		if h.value != "" {
+			fmt.Fprintf(&b, "%s: %s\n", h.name, h.value)
+		}
+	}
+	if !email.Date.IsZero() {
+		fmt.Fprintf(&b, "Date: %s\n", email.Date.Format(time.RFC1123Z))
+	}
+	if b.Len() > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString(email.Text)
+	return b.String()
+}
+
+// walkAttachments calls fn with the name and a temporary copy of every
+// attachment of email in a supported format.
+func walkAttachments(email extract.Email, fn func(name, path string) error) error {
+	var dir string
+	defer func() {
+		if dir != "" {
+			os.RemoveAll(dir)
+		}
+	}()
+	for i, attachment := range email.Attachments {
+		if !isChunkableFile(attachment.Name) {
+			continue
+		}
+		if dir == "" {
+			var err error
+			if dir, err = os.MkdirTemp("", "localrecall-attachments-*"); err != nil {
+				return fmt.Errorf("failed to stage attachments: %w", err)
+			}
+		}
+		// Attachments may share a name.
+		path := filepath.Join(dir, fmt.Sprint(i), attachment.Name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to stage attachment %s: %w", attachment.Name, err)
+		}
+		if err := os.WriteFile(path, attachment.Data, 0644); err != nil {
+			return fmt.Errorf("failed to stage attachment %s: %w", attachment.Name, err)
+		}
+		if err := fn(attachment.Name, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractEmail returns the text of an email followed by the text of its
+// supported attachments. Attachments that cannot be extracted are skipped.
+func extractEmail(fpath string) (string, map[string]string, error) {
+	email, err := extract.EML(fpath)
+	if err != nil {
+		return "", nil, err
+	}
+	parts := []string{emailText(email)}
+	err = walkAttachments(email, func(name, path string) error {
+		text, err := fileToText(path)
+		if err != nil {
+			xlog.Warn("Skipping email attachment", "file", fpath, "attachment", name, "error", err)
+			return nil
+		}
+		parts = append(parts, fmt.Sprintf("Attachment: %s\n\n%s", name, text))
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return strings.Join(parts, "\n\n"), emailMetadata(email), nil
+}
+
+// chunkEmail splits the body of an email as flat text and every supported
+// attachment as a file of its own type, recording the attachment name. All
+// chunks record the headers of the email.
+func chunkEmail(fpath string, opts chunk.Options, keyColumns []string) ([]chunk.Chunk, error) {
+	email, err := extract.EML(fpath)
+	if err != nil {
+		return nil, err
+	}
+	headers := emailMetadata(email)
+
+	var chunks []chunk.Chunk
+	add := func(c chunk.Chunk) {
+		if c.Metadata == nil {
+			c.Metadata = make(map[string]string, len(headers))
+		}
+		for k, v := range headers {
+			c.Metadata[k] = v
+		}
+		chunks = append(chunks, c)
+	}
+	for _, c := range chunk.SplitParagraphIntoChunksWithOptions(emailText(email), opts) {
+		add(chunk.Chunk{Content: c})
+	}
+	err = walkAttachments(email, func(name, path string) error {
+		pieces, err := chunkFile(path, opts, keyColumns)
+		if err != nil {
+			xlog.Warn("Skipping email attachment", "file", fpath, "attachment", name, "error", err)
+			return nil
+		}
+		for _, c := range pieces {
+			if c.Metadata == nil {
+				c.Metadata = map[string]string{}
+			}
+			if _, ok := c.Metadata[AttachmentKey]; !ok {
+				c.Metadata[AttachmentKey] = name
+			}
+			// A forwarded message keeps its own headers.
+			for k, v := range headers {
+				if _, ok := c.Metadata[k]; !ok {
+					c.Metadata[k] = v
+				}
+			}
+			chunks = append(chunks, c)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	xlog.Info("Chunked email", "file", fpath, "message_id", email.MessageID, "attachments", len(email.Attachments), "max_chunk_size", opts.MaxSize, "chunk_count", len(chunks))
+	return chunks, nil
+}
+
+// messageFileName names the entry of a message of an mbox after its
+// Message-ID, so that importing the mailbox again replaces the message.
+// Messages without one are named after the mailbox and their position.
+func messageFileName(mailbox string, n int, messageID string) string {
+	if messageID != "" {
+		name := strings.Map(func(r rune) rune {
+			if r == '/' || r == '\\' || r <= ' ' || r == 0x7f {
+				return '_'
+			}
+			return r
+		}, messageID)
+		if len(name) > 200 {
+			name = name[:200]
+		}
+		if name != "." && name != ".." {
+			return name + ".eml"
+		}
+	}
+	return fmt.Sprintf("%s-%d.eml", strings.TrimSuffix(mailbox, filepath.Ext(mailbox)), n)
+}
+
+// ingestMailbox stores every message of an mbox file as an .eml entry of its
+// own, with metadata extending the upload metadata with the mailbox name.
+// Messages are stored under the lock one at a time, so searches and other
+// writes go on during a large import. progress, if set, is called as
+// messages are stored. On failure the messages stored so far are kept.
+func (db *PersistentKB) ingestMailbox(path string, metadata map[string]string, progress func(stored, total int)) error {
+	total := 0
+	if err := extract.MBOX(path, func([]byte) error { total++; return nil }); err != nil {
+		return err
+	}
+	if total == 0 {
+		return fmt.Errorf("no messages found in %s", filepath.Base(path))
+	}
+	if progress != nil {
+		progress(0, total)
+	}
+
+	mailbox := filepath.Base(path)
+	entryMetadata := make(map[string]string, len(metadata)+1)
+	for k, v := range metadata {
+		entryMetadata[k] = v
+	}
+	entryMetadata[MailboxKey] = mailbox
+
+	stored := 0
+	err := extract.MBOX(path, func(message []byte) error {
+		db.Lock()
+		err := db.storeMessage(mailbox, stored+1, message, entryMetadata)
+		if err == nil && (stored+1)%recordSaveInterval == 0 {
+			err = db.save()
+		}
+		if err != nil && !db.dropped && stored > 0 {
+			if serr := db.save(); serr != nil {
+				xlog.Error("Failed to save collection state", "error", serr)
+			}
+		}
+		db.Unlock()
+		if err != nil {
+			return fmt.Errorf("message %d: %w (%d earlier messages were stored)", stored+1, err, stored)
+		}
+		stored++
+		if progress != nil {
+			progress(stored, total)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	xlog.Info("Stored mailbox", "file", mailbox, "count", stored)
+
+	db.Lock()
+	defer db.Unlock()
+	if db.dropped {
+		return errCollectionDropped
+	}
+	return db.save()
+}
+
+// storeMessage stores the nth message of mailbox as an .eml entry, replacing
+// an entry with the same name. Callers hold the lock and save the state.
+func (db *PersistentKB) storeMessage(mailbox string, n int, message []byte, metadata map[string]string) error {
+	if db.dropped {
+		return errCollectionDropped
+	}
+	email, err := extract.ParseEmail(bytes.NewReader(message))
+	if err != nil {
+		return err
+	}
+	fileName := messageFileName(mailbox, n, email.MessageID)
+
+	if oldKey, exists := db.findEntryKey(fileName); exists {
+		xlog.Info("Replacing message", "message_id", email.MessageID, "entry", oldKey)
+		if err := db.removeFileEntry(oldKey); err != nil {
+			return fmt.Errorf("failed to replace message %s: %w", fileName, err)
+		}
+	}
+
+	dir := uuid.New().String()
+	key := filepath.Join(dir, fileName)
+	if err := os.MkdirAll(filepath.Join(db.assetDir, dir), 0755); err != nil {
+		return fmt.Errorf("failed to create entry directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(db.assetDir, key), message, 0644); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	db.setEntryMetadata(key, metadata)
+	if _, err := db.store(map[string]string{}, key); err != nil {
+		db.setEntryMetadata(key, nil)
+		os.RemoveAll(filepath.Join(db.assetDir, dir))
+		return fmt.Errorf("failed to store message: %w", err)
+	}
+	return nil
+}
@@ -10,9 +10,16 @@ type Engine interface {
 	StoreDocuments(s []string, metadata map[string]string) ([]engine.Result, error)
 	GetEmbeddingDimensions() (int, error)
 	Reset() error
+	// Drop removes the collection's storage from the engine for good. Unlike
+	// Reset it does not recreate it; the engine must not be used afterwards.
+	Drop() error
 	Search(s string, similarEntries int) ([]types.Result, error)
+	SearchWithFilter(s string, similarEntries int, filter *types.Filter) ([]types.Result, error)
 	Count() int
 	Delete(where map[string]string, whereDocuments map[string]string, ids ...string) error
 	GetByID(id string) (types.Result, error)
 	GetBySource(source string) ([]types.Result, error)
+	// UpdateMetadata sets and removes metadata keys on every chunk whose
+	// "source" metadata is source, without re-embedding them.
+	UpdateMetadata(source string, set map[string]string, remove []string) error
 }
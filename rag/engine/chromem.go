@@ -64,6 +64,13 @@ func (c *ChromemDB) Reset() error {
 	return nil
 }
 
+func (c *ChromemDB) Drop() error {
+	if err := c.db.DeleteCollection(c.collectionName); err != nil {
+		return fmt.Errorf("error deleting collection: %v", err)
+	}
+	return nil
+}
+
 func (c *ChromemDB) GetEmbeddingDimensions() (int, error) {
 	count := c.collection.Count()
 	if count == 0 {
@@ -193,8 +200,56 @@ func (c *ChromemDB) GetBySource(source string) ([]types.Result, error) {
 	return results, nil
 }
 
+// UpdateMetadata rewrites the chunks of source with their stored embeddings,
+// so nothing is re-embedded.
+func (c *ChromemDB) UpdateMetadata(source string, set map[string]string, remove []string) error {
+	ctx := context.Background()
+	chunks, err := c.GetBySource(source)
+	if err != nil {
+		return err
+	}
+	for _, chunk := range chunks {
+		doc, err := c.collection.GetByID(ctx, chunk.ID)
+		if err != nil {
+			return fmt.Errorf("error getting document %s: %v", chunk.ID, err)
+		}
+		for _, k := range remove {
+			delete(doc.Metadata, k)
+		}
+		for k, v := range set {
+			doc.Metadata[k] = v
+		}
+		if err := c.collection.AddDocument(ctx, doc); err != nil {
+			return fmt.Errorf("error updating document %s: %v", chunk.ID, err)
+		}
+	}
+	return nil
+}
+
 func (c *ChromemDB) Search(s string, similarEntries int) ([]types.Result, error) {
-	res, err := c.collection.Query(context.Background(), s, similarEntries, nil, nil)
+	return c.SearchWithFilter(s, similarEntries, nil)
+}
+
+// SearchWithFilter pushes the equality conditions of filter down to chromem's
+// where clause and post-filters the remaining conditions (in, prefix, ranges,
+// OR branches). chromem scores every document matching the where clause
+// anyway, so when post-filtering is needed we ask for all of them and trim
+// after filtering instead of risking fewer than similarEntries hits.
+func (c *ChromemDB) SearchWithFilter(s string, similarEntries int, filter *types.Filter) ([]types.Result, error) {
+	var where map[string]string
+	nResults := similarEntries
+	if !filter.IsEmpty() {
+		where = filter.EqualityConditions()
+		count := c.collection.Count()
+		if count == 0 {
+			return nil, nil
+		}
+		if !filter.IsEqualityOnly() || nResults > count {
+			nResults = count
+		}
+	}
+
+	res, err := c.collection.Query(context.Background(), s, nResults, where, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -202,6 +257,12 @@ func (c *ChromemDB) Search(s string, similarEntries int) ([]types.Result, error)
 	var results []types.Result
 
 	for _, r := range res {
+		if !filter.Match(r.Metadata) {
+			continue
+		}
+		if len(results) >= similarEntries {
+			break
+		}
 		results = append(results, types.Result{
 			ID:       r.ID,
 			Metadata: r.Metadata,
@@ -27,6 +27,12 @@ func (db *LocalAIRAGDB) Reset() error {
 	return fmt.Errorf("not implemented")
 }
 
+// Drop is a no-op: LocalAI stores are not persistent and hold no storage of
+// their own per collection.
+func (db *LocalAIRAGDB) Drop() error {
+	return nil
+}
+
 func (db *LocalAIRAGDB) Count() int {
 	return 0
 }
@@ -92,6 +98,18 @@ func (db *LocalAIRAGDB) GetBySource(source string) ([]types.Result, error) {
 	return nil, fmt.Errorf("not implemented")
 }
 
+func (db *LocalAIRAGDB) UpdateMetadata(source string, set map[string]string, remove []string) error {
+	return fmt.Errorf("not implemented")
+}
+
+// SearchWithFilter only supports an empty filter: LocalAI stores keep no metadata.
+func (db *LocalAIRAGDB) SearchWithFilter(s string, similarEntries int, filter *types.Filter) ([]types.Result, error) {
+	if !filter.IsEmpty() {
+		return nil, fmt.Errorf("metadata filters are not implemented for LocalAI stores")
+	}
+	return db.Search(s, similarEntries)
+}
+
 func (db *LocalAIRAGDB) Search(s string, similarEntries int) ([]types.Result, error) {
 	resp, err := db.openaiClient.CreateEmbeddings(context.TODO(),
 		openai.EmbeddingRequestStrings{
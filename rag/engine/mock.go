@@ -11,9 +11,10 @@ import (
 // MockEngine is a simple in-memory engine for testing. It requires no
 // external dependencies (no LocalAI, no embeddings).
 type MockEngine struct {
-	mu    sync.Mutex
-	docs  map[string]types.Result
-	index int
+	mu      sync.Mutex
+	docs    map[string]types.Result
+	index   int
+	dropped bool
 }
 
 func NewMockEngine() *MockEngine {
@@ -59,20 +60,30 @@ func (m *MockEngine) StoreDocuments(s []string, metadata map[string]string) ([]R
 }
 
 func (m *MockEngine) Search(s string, similarEntries int) ([]types.Result, error) {
+	return m.SearchWithFilter(s, similarEntries, nil)
+}
+
+// SearchWithFilter behaves like Search but post-filters documents by metadata.
+func (m *MockEngine) SearchWithFilter(s string, similarEntries int, filter *types.Filter) ([]types.Result, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	var results []types.Result
+	var candidates []types.Result
 	for _, doc := range m.docs {
+		if filter.Match(doc.Metadata) {
+			candidates = append(candidates, doc)
+		}
+	}
+
+	var results []types.Result
+	for _, doc := range candidates {
 		if strings.Contains(strings.ToLower(doc.Content), strings.ToLower(s)) {
 			results = append(results, doc)
 		}
 	}
 	// If no substring match, return all (useful for generic searches)
 	if len(results) == 0 {
-		for _, doc := range m.docs {
-			results = append(results, doc)
-		}
+		results = candidates
 	}
 	if len(results) > similarEntries {
 		results = results[:similarEntries]
@@ -135,6 +146,30 @@ func (m *MockEngine) GetBySource(source string) ([]types.Result, error) {
 	return results, nil
 }
 
+func (m *MockEngine) UpdateMetadata(source string, set map[string]string, remove []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, doc := range m.docs {
+		if doc.Metadata["source"] != source {
+			continue
+		}
+		metadata := make(map[string]string, len(doc.Metadata)+len(set))
+		for k, v := range doc.Metadata {
+			metadata[k] = v
+		}
+		for _, k := range remove {
+			delete(metadata, k)
+		}
+		for k, v := range set {
+			metadata[k] = v
+		}
+		doc.Metadata = metadata
+		m.docs[id] = doc
+	}
+	return nil
+}
+
 func (m *MockEngine) Count() int {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -151,6 +186,22 @@ func (m *MockEngine) Reset() error {
 	return nil
 }
 
+func (m *MockEngine) Drop() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.docs = make(map[string]types.Result)
+	m.dropped = true
+	return nil
+}
+
+// Dropped reports whether Drop was called.
+func (m *MockEngine) Dropped() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.dropped
+}
+
 func (m *MockEngine) GetEmbeddingDimensions() (int, error) {
 	return 384, nil
 }
@@ -0,0 +1,84 @@
+package engine_test
+
+import (
+	. "github.com/mudler/localrecall/rag/engine"
+	"github.com/mudler/localrecall/rag/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MockEngine SearchWithFilter", func() {
+	var eng *MockEngine
+
+	BeforeEach(func() {
+		eng = NewMockEngine()
+		_, err := eng.Store("install with docker", map[string]string{"source": "a/install.md", "tag": "docs", "page": "2", "created_at": "2024-01-10T00:00:00Z"})
+		Expect(err).ToNot(HaveOccurred())
+		_, err = eng.Store("install from source", map[string]string{"source": "b/build.md", "tag": "faq", "page": "10", "created_at": "2024-03-01T00:00:00Z"})
+		Expect(err).ToNot(HaveOccurred())
+		_, err = eng.Store("release notes", map[string]string{"source": "c/notes.txt", "tag": "news", "page": "x", "created_at": "2023-12-31T00:00:00Z"})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	sources := func(results []types.Result) []string {
+		var out []string
+		for _, r := range results {
+			out = append(out, r.Metadata["source"])
+		}
+		return out
+	}
+
+	It("returns everything for a nil filter", func() {
+		results, err := eng.SearchWithFilter("nothing matches", 10, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results).To(HaveLen(3))
+	})
+
+	It("restricts by equality", func() {
+		results, err := eng.SearchWithFilter("install", 10, &types.Filter{Field: "source", Value: "b/build.md"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sources(results)).To(ConsistOf("b/build.md"))
+	})
+
+	It("supports in and prefix", func() {
+		results, err := eng.SearchWithFilter("x", 10, &types.Filter{Field: "tag", Op: "in", Values: []string{"docs", "news"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sources(results)).To(ConsistOf("a/install.md", "c/notes.txt"))
+
+		results, err = eng.SearchWithFilter("x", 10, &types.Filter{Field: "source", Op: "prefix", Value: "a/"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sources(results)).To(ConsistOf("a/install.md"))
+	})
+
+	It("compares numeric ranges numerically and skips non-numeric values", func() {
+		results, err := eng.SearchWithFilter("x", 10, &types.Filter{Field: "page", Op: "gte", Value: "3"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sources(results)).To(ConsistOf("b/build.md"))
+	})
+
+	It("compares dates lexically", func() {
+		results, err := eng.SearchWithFilter("x", 10, &types.Filter{Field: "created_at", Op: "lt", Value: "2024-02-01"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sources(results)).To(ConsistOf("a/install.md", "c/notes.txt"))
+	})
+
+	It("combines conditions with and/or", func() {
+		f := &types.Filter{And: []*types.Filter{
+			{Field: "created_at", Op: "gte", Value: "2024-01-01"},
+			{Or: []*types.Filter{
+				{Field: "tag", Value: "faq"},
+				{Field: "page", Op: "lt", Value: "5"},
+			}},
+		}}
+		Expect(f.Validate()).To(Succeed())
+		results, err := eng.SearchWithFilter("x", 10, f)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sources(results)).To(ConsistOf("a/install.md", "b/build.md"))
+	})
+
+	It("rejects malformed filters", func() {
+		Expect((&types.Filter{Field: "tag", Op: "like"}).Validate()).ToNot(Succeed())
+		Expect((&types.Filter{Field: "tag", Op: "in"}).Validate()).ToNot(Succeed())
+		Expect((&types.Filter{And: []*types.Filter{{Field: "a"}}, Or: []*types.Filter{{Field: "b"}}}).Validate()).ToNot(Succeed())
+	})
+})
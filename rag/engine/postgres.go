@@ -540,6 +540,21 @@ func (p *PostgresDB) Reset() error {
 	return p.setupDatabase()
 }
 
+// Drop removes the collection table, together with its indexes, and its
+// collection_config row, then closes the connection pool.
+func (p *PostgresDB) Drop() error {
+	ctx := context.Background()
+
+	if _, err := p.pool.Exec(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s CASCADE", p.tableName)); err != nil {
+		return fmt.Errorf("failed to drop table: %w", err)
+	}
+	if _, err := p.pool.Exec(ctx, "DELETE FROM collection_config WHERE collection_name = $1", p.collectionName); err != nil {
+		return fmt.Errorf("failed to delete collection config: %w", err)
+	}
+	p.pool.Close()
+	return nil
+}
+
 func (p *PostgresDB) GetEmbeddingDimensions() (int, error) {
 	ctx := context.Background()
 
@@ -734,6 +749,48 @@ func (p *PostgresDB) GetByID(id string) (types.Result, error) {
 	return result, nil
 }
 
+// UpdateMetadata patches the metadata of the chunks of source in place and
+// refreshes the columns derived from it (title, category and the full-text
+// search vector).
+func (p *PostgresDB) UpdateMetadata(source string, set map[string]string, remove []string) error {
+	ctx := context.Background()
+
+	clean := make(map[string]string, len(set))
+	for k, v := range set {
+		clean[k] = strings.ReplaceAll(strings.ToValidUTF8(v, " "), "\x00", "")
+	}
+	setJSON, err := json.Marshal(clean)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	if remove == nil {
+		remove = []string{}
+	}
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, fmt.Sprintf(`
+		UPDATE %s SET metadata = (COALESCE(metadata, '{}'::jsonb) - $2::text[]) || $3::jsonb
+		WHERE metadata->>'source' = $1
+	`, p.tableName), source, remove, string(setJSON)); err != nil {
+		return fmt.Errorf("failed to update metadata: %w", err)
+	}
+	if _, err := tx.Exec(ctx, fmt.Sprintf(`
+		UPDATE %s SET
+			title = COALESCE(NULLIF(metadata->>'title', ''), metadata->>'source'),
+			category = metadata->>'category',
+			search_vector = to_tsvector('english', COALESCE(NULLIF(metadata->>'title', ''), metadata->>'source', '') || ' ' || content)
+		WHERE metadata->>'source' = $1
+	`, p.tableName), source); err != nil {
+		return fmt.Errorf("failed to update derived columns: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
 func (p *PostgresDB) GetBySource(source string) ([]types.Result, error) {
 	ctx := context.Background()
 
@@ -806,18 +863,28 @@ const (
 // multi-million-row collections (LocalAI issue #10186). $2/$4 weight each arm
 // (equal by default), so an arm can be biased without breaking the index path.
 func buildHybridSearchQuery(tableName string) string {
+	return buildFilteredHybridSearchQuery(tableName, "TRUE")
+}
+
+// buildFilteredHybridSearchQuery is buildHybridSearchQuery with an extra
+// metadata predicate (see buildFilterPredicate) applied inside both retrieval
+// arms, so each arm ranks only matching rows and the candidate pool is not
+// wasted on documents the filter would discard. Predicate parameters must
+// start at $6.
+func buildFilteredHybridSearchQuery(tableName, predicate string) string {
 	candidatePool := fmt.Sprintf("GREATEST($5 * %d, %d)", hybridCandidateMultiplier, hybridCandidateFloor)
 	return fmt.Sprintf(`
 		WITH bm25_results AS (
 			SELECT id, ROW_NUMBER() OVER (ORDER BY full_text <@> to_bm25query($1, 'idx_%[1]s_bm25')) AS rank
 			FROM %[1]s
+			WHERE %[4]s
 			ORDER BY full_text <@> to_bm25query($1, 'idx_%[1]s_bm25')
 			LIMIT %[2]s
 		),
 		vector_results AS (
 			SELECT id, ROW_NUMBER() OVER (ORDER BY embedding <=> $3::vector) AS rank
 			FROM %[1]s
-			WHERE embedding IS NOT NULL
+			WHERE embedding IS NOT NULL AND %[4]s
 			ORDER BY embedding <=> $3::vector
 			LIMIT %[2]s
 		),
@@ -838,10 +905,95 @@ func buildHybridSearchQuery(tableName string) string {
 		JOIN %[1]s d ON d.id = f.id
 		ORDER BY f.similarity DESC
 		LIMIT $5
-	`, tableName, candidatePool, rrfK)
+	`, tableName, candidatePool, rrfK, predicate)
+}
+
+// buildFilteredVectorSearchQuery is the vector-only search used when the
+// BM25 query fails, restricted by a metadata predicate. $1 is the query
+// embedding, $2 the limit; predicate parameters must start at $3.
+func buildFilteredVectorSearchQuery(tableName, predicate string) string {
+	return fmt.Sprintf(`
+		SELECT 
+			id::text,
+			COALESCE(title, '') as title,
+			content,
+			metadata,
+			(1 - (embedding <=> $1::vector)) as similarity
+		FROM %s
+		WHERE embedding IS NOT NULL AND %s
+		ORDER BY embedding <=> $1::vector
+		LIMIT $2
+	`, tableName, predicate)
+}
+
+// buildFilterPredicate translates a metadata filter into a boolean SQL
+// expression over the JSONB metadata column. Keys and values are always bound
+// as parameters, numbered from firstArg; the returned args must be appended
+// to the query arguments in order. An empty filter yields "TRUE".
+//
+// Range operators follow types.CompareFilterValues: numeric filter values
+// compare against metadata values that look like numbers (others never
+// match), and everything else compares bytewise (COLLATE "C"), which orders
+// ISO-8601 timestamps chronologically.
+func buildFilterPredicate(filter *types.Filter, firstArg int) (string, []interface{}) {
+	var args []interface{}
+	next := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", firstArg+len(args)-1)
+	}
+
+	var build func(f *types.Filter) string
+	build = func(f *types.Filter) string {
+		if f.IsEmpty() {
+			return "TRUE"
+		}
+		if f.IsLeaf() {
+			field := "metadata->>" + next(f.Field)
+			switch op := f.Operator(); op {
+			case types.FilterOpEq:
+				return fmt.Sprintf("%s = %s", field, next(f.Value))
+			case types.FilterOpIn:
+				return fmt.Sprintf("%s = ANY(%s::text[])", field, next(f.Values))
+			case types.FilterOpPrefix:
+				return fmt.Sprintf("starts_with(%s, %s)", field, next(f.Value))
+			default:
+				cmp := map[string]string{
+					types.FilterOpGt:  ">",
+					types.FilterOpGte: ">=",
+					types.FilterOpLt:  "<",
+					types.FilterOpLte: "<=",
+				}[op]
+				if cmp == "" {
+					return "FALSE"
+				}
+				if types.IsNumericFilterValue(f.Value) {
+					return fmt.Sprintf("(CASE WHEN %[1]s ~ %[2]s THEN (%[1]s)::numeric END) %[3]s %[4]s::numeric",
+						field, next(types.NumericFilterPattern), cmp, next(f.Value))
+				}
+				return fmt.Sprintf(`%s COLLATE "C" %s %s`, field, cmp, next(f.Value))
+			}
+		}
+		clauses, joiner := f.And, " AND "
+		if len(f.Or) > 0 {
+			clauses, joiner = f.Or, " OR "
+		}
+		parts := make([]string, 0, len(clauses))
+		for _, c := range clauses {
+			parts = append(parts, build(c))
+		}
+		return "(" + strings.Join(parts, joiner) + ")"
+	}
+
+	return build(filter), args
 }
 
 func (p *PostgresDB) Search(s string, similarEntries int) ([]types.Result, error) {
+	return p.SearchWithFilter(s, similarEntries, nil)
+}
+
+// SearchWithFilter runs the hybrid search restricted to documents whose
+// metadata matches filter.
+func (p *PostgresDB) SearchWithFilter(s string, similarEntries int, filter *types.Filter) ([]types.Result, error) {
 	ctx := context.Background()
 
 	// Get query embedding
@@ -849,28 +1001,27 @@ func (p *PostgresDB) Search(s string, similarEntries int) ([]types.Result, error
 	if err != nil {
 		return nil, fmt.Errorf("failed to get query embedding: %w", err)
 	}
-	queryEmbeddingStr := formatVector(queryEmbedding)
+	return p.searchByEmbedding(ctx, s, formatVector(queryEmbedding), similarEntries, filter)
+}
 
+// searchByEmbedding runs the filtered hybrid search for s with its embedding
+// already computed, falling back to a vector-only search when the BM25 query
+// fails.
+func (p *PostgresDB) searchByEmbedding(ctx context.Context, s, queryEmbeddingStr string, similarEntries int, filter *types.Filter) ([]types.Result, error) {
 	// Build hybrid search query (BM25 + vector similarity)
-	query := buildHybridSearchQuery(p.tableName)
+	predicate, filterArgs := buildFilterPredicate(filter, 6)
+	query := buildFilteredHybridSearchQuery(p.tableName, predicate)
 
-	rows, err := p.pool.Query(ctx, query, s, p.bm25Weight, queryEmbeddingStr, p.vectorWeight, similarEntries)
+	args := append([]interface{}{s, p.bm25Weight, queryEmbeddingStr, p.vectorWeight, similarEntries}, filterArgs...)
+	rows, err := p.pool.Query(ctx, query, args...)
 	if err != nil {
-		// If BM25 query fails, fallback to vector-only search
+		// If BM25 query fails, fallback to vector-only search. It binds fewer
+		// arguments, so the filter parameters are numbered again.
 		xlog.Warn("BM25 search failed, falling back to vector search", "error", err)
-		query = fmt.Sprintf(`
-			SELECT 
-				id::text,
-				COALESCE(title, '') as title,
-				content,
-				metadata,
-				(1 - (embedding <=> $1::vector)) as similarity
-			FROM %s
-			WHERE embedding IS NOT NULL
-			ORDER BY embedding <=> $1::vector
-			LIMIT $2
-		`, p.tableName)
-		rows, err = p.pool.Query(ctx, query, queryEmbeddingStr, similarEntries)
+		predicate, filterArgs = buildFilterPredicate(filter, 3)
+		query = buildFilteredVectorSearchQuery(p.tableName, predicate)
+		args = append([]interface{}{queryEmbeddingStr, similarEntries}, filterArgs...)
+		rows, err = p.pool.Query(ctx, query, args...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to execute search: %w", err)
 		}
@@ -0,0 +1,451 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mudler/localrecall/rag/types"
+	"github.com/mudler/xlog"
+)
+
+const (
+	// jobQueueSize bounds the number of jobs waiting for a worker.
+	jobQueueSize = 4096
+	// jobRetention is how long finished jobs are kept for status queries.
+	jobRetention = 24 * time.Hour
+	// jobPruneInterval is how often finished jobs past jobRetention are
+	// removed while the queue runs.
+	jobPruneInterval = time.Hour
+	// jobSaveInterval is how often the progress of a running job is saved.
+	jobSaveInterval = time.Second
+	// maxJobAttempts bounds how many times a job is run when its collection
+	// is replaced while it runs.
+	maxJobAttempts = 3
+)
+
+// ErrJobQueueFull is returned by Submit when too many jobs are waiting.
+var ErrJobQueueFull = errors.New("ingestion queue is full")
+
+// ErrJobNotRetryable is returned by Retry for jobs that did not fail.
+var ErrJobNotRetryable = errors.New("only failed jobs can be retried")
+
+// JobQueue ingests uploaded files in the background with a bounded number of
+// workers. Each job is kept in dir as <id>.json next to its staged file in
+// <id>/, so queued and interrupted jobs resume after a restart. The staged
+// file of a failed job is kept until the job is pruned, so it can be retried.
+type JobQueue struct {
+	dir     string
+	workers int
+	lookup  func(collection string) (*PersistentKB, bool)
+
+	mu      sync.Mutex
+	jobs    map[string]*types.Job
+	pending chan string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewJobQueue loads the jobs kept in dir. lookup resolves the collection of
+// a job when it runs. Jobs that were queued or running are queued again once
+// the queue is started.
+func NewJobQueue(dir string, workers int, lookup func(collection string) (*PersistentKB, bool)) (*JobQueue, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create jobs directory: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &JobQueue{
+		dir:     dir,
+		workers: workers,
+		lookup:  lookup,
+		jobs:    map[string]*types.Job{},
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read job %s: %w", f, err)
+		}
+		var job types.Job
+		if err := json.Unmarshal(data, &job); err != nil {
+			xlog.Error("Skipping unreadable job", "file", f, "error", err)
+			continue
+		}
+		if job.Done() && job.FinishedAt != nil && time.Since(*job.FinishedAt) > jobRetention {
+			q.removeJobFiles(job.ID)
+			continue
+		}
+		// An interrupted job reports the progress it had saved until it
+		// runs again.
+		if job.State == types.JobRunning {
+			job.State = types.JobQueued
+		}
+		q.jobs[job.ID] = &job
+	}
+
+	q.pending = make(chan string, max(jobQueueSize, len(q.jobs)))
+	for _, job := range q.sortedJobs("") {
+		if job.State == types.JobQueued {
+			q.pending <- job.ID
+		}
+	}
+	return q, nil
+}
+
+// Start starts the workers, and the removal of finished jobs once they are
+// older than the retention period.
+func (q *JobQueue) Start() {
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+	q.wg.Add(1)
+	go q.pruneEvery(jobPruneInterval)
+}
+
+func (q *JobQueue) pruneEvery(interval time.Duration) {
+	defer q.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-q.ctx.Done():
+			return
+		case <-ticker.C:
+			if n := q.Prune(jobRetention); n > 0 {
+				xlog.Info("Removed finished jobs", "count", n)
+			}
+		}
+	}
+}
+
+// Prune removes the jobs that finished more than olderThan ago, with their
+// files, and returns how many it removed. Queued and running jobs are kept.
+func (q *JobQueue) Prune(olderThan time.Duration) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	removed := 0
+	for id, job := range q.jobs {
+		if job.Done() && job.FinishedAt != nil && time.Since(*job.FinishedAt) > olderThan {
+			delete(q.jobs, id)
+			q.removeJobFiles(id)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Cancel removes the jobs of collection and their files, and returns how many
+// it removed. Queued jobs are never run; running jobs are dropped when their
+// worker is done with them, without being run again.
+func (q *JobQueue) Cancel(collection string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	removed := 0
+	for id, job := range q.jobs {
+		if job.Collection != collection {
+			continue
+		}
+		delete(q.jobs, id)
+		if job.State != types.JobRunning {
+			q.removeJobFiles(id)
+		}
+		removed++
+	}
+	return removed
+}
+
+// Stop stops the workers once their current job is done. Unfinished jobs
+// resume when a queue is started on the same directory again.
+func (q *JobQueue) Stop() {
+	q.cancel()
+	q.wg.Wait()
+}
+
+// Submit stages the content of r as fileName and queues a job storing it in
+// collection with the given entry metadata. The records of a JSON or JSON
+// Lines file are stored as documents following mapping, which may be nil;
+// mapping is ignored for other files. The messages of an mbox file are stored
+// as entries of their own.
+func (q *JobQueue) Submit(collection, fileName string, r io.Reader, metadata map[string]string, mapping *types.JSONMapping) (types.Job, error) {
+	fileName = filepath.Base(fileName)
+	if fileName == "." || fileName == string(filepath.Separator) {
+		return types.Job{}, fmt.Errorf("invalid file name")
+	}
+
+	id := uuid.New().String()
+	stagingDir := filepath.Join(q.dir, id)
+	if err := os.MkdirAll(stagingDir, 0755); err != nil {
+		return types.Job{}, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	f, err := os.Create(filepath.Join(stagingDir, fileName))
+	if err != nil {
+		os.RemoveAll(stagingDir)
+		return types.Job{}, fmt.Errorf("failed to stage file: %w", err)
+	}
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.RemoveAll(stagingDir)
+		return types.Job{}, fmt.Errorf("failed to stage file: %w", err)
+	}
+
+	job := &types.Job{
+		ID:         id,
+		Collection: collection,
+		FileName:   fileName,
+		Metadata:   metadata,
+		State:      types.JobQueued,
+		CreatedAt:  time.Now(),
+	}
+	switch {
+	case isRecordFile(fileName):
+		job.Mapping = mapping
+	case isMailboxFile(fileName):
+	default:
+		job.Key = filepath.Join(uuid.New().String(), fileName)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.save(job); err != nil {
+		os.RemoveAll(stagingDir)
+		return types.Job{}, err
+	}
+	select {
+	case q.pending <- id:
+	default:
+		q.removeJobFiles(id)
+		return types.Job{}, ErrJobQueueFull
+	}
+	q.jobs[id] = job
+	return *job, nil
+}
+
+// Retry queues a failed job again from its staged file.
+func (q *JobQueue) Retry(id string) (types.Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[id]
+	if !ok {
+		return types.Job{}, fmt.Errorf("job not found: %s", id)
+	}
+	if job.State != types.JobFailed {
+		return types.Job{}, ErrJobNotRetryable
+	}
+	if _, err := os.Stat(filepath.Join(q.dir, id, job.FileName)); err != nil {
+		return types.Job{}, fmt.Errorf("staged file of job %s is gone: %w", id, err)
+	}
+	previous := *job
+	job.State = types.JobQueued
+	job.Error = ""
+	job.ChunksStored, job.ChunksTotal = 0, 0
+	job.DocumentsStored, job.DocumentsTotal = 0, 0
+	job.StartedAt, job.FinishedAt = nil, nil
+	if err := q.save(job); err != nil {
+		*job = previous
+		return types.Job{}, err
+	}
+	select {
+	case q.pending <- id:
+	default:
+		*job = previous
+		if err := q.save(job); err != nil {
+			xlog.Error("Failed to save job", "job", id, "error", err)
+		}
+		return types.Job{}, ErrJobQueueFull
+	}
+	return *job, nil
+}
+
+// Get returns the job with the given ID.
+func (q *JobQueue) Get(id string) (types.Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[id]
+	if !ok {
+		return types.Job{}, false
+	}
+	return *job, true
+}
+
+// List returns the jobs of collection, or all jobs when collection is
+// empty, oldest first.
+func (q *JobQueue) List(collection string) []types.Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.sortedJobs(collection)
+}
+
+func (q *JobQueue) sortedJobs(collection string) []types.Job {
+	jobs := make([]types.Job, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		if collection == "" || job.Collection == collection {
+			jobs = append(jobs, *job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.Before(jobs[j].CreatedAt) })
+	return jobs
+}
+
+func (q *JobQueue) work() {
+	defer q.wg.Done()
+	for {
+		select {
+		case <-q.ctx.Done():
+			return
+		case id := <-q.pending:
+			q.run(id)
+		}
+	}
+}
+
+func (q *JobQueue) run(id string) {
+	q.mu.Lock()
+	job, ok := q.jobs[id]
+	if !ok || job.State != types.JobQueued {
+		q.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	job.State = types.JobRunning
+	job.StartedAt = &now
+	collection, key, metadata, mapping := job.Collection, job.Key, job.Metadata, job.Mapping
+	stagedPath := filepath.Join(q.dir, id, job.FileName)
+	q.mu.Unlock()
+
+	// saveProgress records the counts of the job, saving them at most once
+	// every jobSaveInterval.
+	var lastSave time.Time
+	saveProgress := func(update func()) {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		update()
+		if q.jobs[id] != job || time.Since(lastSave) < jobSaveInterval {
+			return
+		}
+		lastSave = time.Now()
+		if err := q.save(job); err != nil {
+			xlog.Error("Failed to save job", "job", id, "error", err)
+		}
+	}
+	documentProgress := func(stored, total int) {
+		saveProgress(func() { job.DocumentsStored, job.DocumentsTotal = stored, total })
+	}
+	chunkProgress := func(stored, total int) {
+		saveProgress(func() { job.ChunksStored, job.ChunksTotal = stored, total })
+	}
+
+	xlog.Info("Running ingestion job", "job", id, "collection", collection, "key", key)
+	var err error
+	for {
+		q.mu.Lock()
+		if q.jobs[id] != job {
+			// Cancelled meanwhile.
+			q.mu.Unlock()
+			break
+		}
+		job.Attempts++
+		if err := q.save(job); err != nil {
+			xlog.Error("Failed to save job", "job", id, "error", err)
+		}
+		q.mu.Unlock()
+		var kb *PersistentKB
+		kb, ok = q.lookup(collection)
+		if !ok {
+			err = fmt.Errorf("collection not found: %s", collection)
+			break
+		}
+		switch {
+		case isRecordFile(stagedPath):
+			err = kb.ingestRecords(stagedPath, mapping, metadata, documentProgress)
+		case isMailboxFile(stagedPath):
+			err = kb.ingestMailbox(stagedPath, metadata, documentProgress)
+		default:
+			err = kb.ingest(stagedPath, key, metadata, chunkProgress)
+		}
+		// A collection rebuilt with a new config closes its previous
+		// knowledge base: run the job again on the new one.
+		if !errors.Is(err, errCollectionDropped) || q.ctx.Err() != nil {
+			break
+		}
+		q.mu.Lock()
+		attempts := job.Attempts
+		q.mu.Unlock()
+		if attempts >= maxJobAttempts {
+			break
+		}
+		if current, ok := q.lookup(collection); !ok || current == kb {
+			break
+		}
+		xlog.Info("Collection replaced during ingestion, running job again", "job", id, "collection", collection)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.jobs[id] != job {
+		xlog.Info("Ingestion job cancelled", "job", id, "collection", collection)
+		q.removeJobFiles(id)
+		return
+	}
+	finished := time.Now()
+	job.FinishedAt = &finished
+	if err != nil {
+		xlog.Error("Ingestion job failed", "job", id, "collection", collection, "error", err)
+		job.State = types.JobFailed
+		job.Error = err.Error()
+	} else {
+		xlog.Info("Ingestion job succeeded", "job", id, "collection", collection, "key", key, "duration", finished.Sub(*job.StartedAt))
+		job.State = types.JobSucceeded
+	}
+	if err := q.save(job); err != nil {
+		xlog.Error("Failed to save job", "job", id, "error", err)
+	}
+	if err == nil {
+		os.RemoveAll(filepath.Join(q.dir, id))
+	}
+}
+
+// save writes job to disk. Callers hold q.mu.
+func (q *JobQueue) save(job *types.Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(q.dir, job.ID+".json.tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to save job: %w", err)
+	}
+	return os.Rename(tmp, filepath.Join(q.dir, job.ID+".json"))
+}
+
+func (q *JobQueue) removeJobFiles(id string) {
+	if strings.ContainsAny(id, `/\`) || id == "" {
+		return
+	}
+	os.RemoveAll(filepath.Join(q.dir, id))
+	os.Remove(filepath.Join(q.dir, id+".json"))
+}
@@ -0,0 +1,377 @@
+package rag_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/mudler/localrecall/rag"
+	"github.com/mudler/localrecall/rag/engine"
+	"github.com/mudler/localrecall/rag/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// gatedEngine is a MockEngine whose StoreDocuments waits for the gate to
+// open, to observe a collection while an ingestion is in flight.
+type gatedEngine struct {
+	*engine.MockEngine
+	entered chan struct{}
+	gate    chan struct{}
+}
+
+func (g *gatedEngine) StoreDocuments(s []string, metadata map[string]string) ([]engine.Result, error) {
+	select {
+	case g.entered <- struct{}{}:
+	default:
+	}
+	<-g.gate
+	return g.MockEngine.StoreDocuments(s, metadata)
+}
+
+var _ = Describe("JobQueue", func() {
+	var (
+		tempDir string
+		jobsDir string
+		kb      *PersistentKB
+		queue   *JobQueue
+	)
+
+	lookup := func(name string) (*PersistentKB, bool) {
+		if name != "docs" {
+			return nil, false
+		}
+		return kb, true
+	}
+
+	waitForJob := func(q *JobQueue, id string) types.Job {
+		var job types.Job
+		Eventually(func() bool {
+			job, _ = q.Get(id)
+			return job.Done()
+		}, 5*time.Second, 10*time.Millisecond).Should(BeTrue())
+		return job
+	}
+
+	BeforeEach(func() {
+		tempDir = GinkgoT().TempDir()
+		jobsDir = filepath.Join(tempDir, "jobs")
+		var err error
+		kb, err = newMockKB(filepath.Join(tempDir, "state.json"), filepath.Join(tempDir, "assets"), engine.NewMockEngine())
+		Expect(err).ToNot(HaveOccurred())
+		kb.SetChunkSize(20, 0)
+		queue = nil
+	})
+
+	AfterEach(func() {
+		if queue != nil {
+			queue.Stop()
+		}
+	})
+
+	It("ingests submitted files in the background", func() {
+		var err error
+		queue, err = NewJobQueue(jobsDir, 2, lookup)
+		Expect(err).ToNot(HaveOccurred())
+		queue.Start()
+
+		job, err := queue.Submit("docs", "notes.txt", strings.NewReader(strings.Repeat("some words to chunk ", 20)), map[string]string{"team": "docs"}, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(job.State).To(Equal(types.JobQueued))
+		Expect(job.Key).To(HaveSuffix("/notes.txt"))
+
+		job = waitForJob(queue, job.ID)
+		Expect(job.State).To(Equal(types.JobSucceeded), job.Error)
+		Expect(job.ChunksTotal).To(BeNumerically(">", 1))
+		Expect(job.ChunksStored).To(Equal(job.ChunksTotal))
+		Expect(job.Attempts).To(Equal(1))
+		Expect(job.StartedAt).ToNot(BeNil())
+		Expect(job.FinishedAt).ToNot(BeNil())
+
+		Expect(kb.ListDocuments()).To(ConsistOf(job.Key))
+		results, err := kb.GetEntryContent(job.Key)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results).To(HaveLen(job.ChunksTotal))
+		Expect(results[0].Metadata).To(HaveKeyWithValue("team", "docs"))
+
+		// The staged file is gone once the job is done.
+		Expect(filepath.Join(jobsDir, job.ID)).ToNot(BeADirectory())
+		Expect(queue.List("docs")).To(HaveLen(1))
+		Expect(queue.List("other")).To(BeEmpty())
+	})
+
+	It("stores every record of a JSON Lines file as a document", func() {
+		var err error
+		queue, err = NewJobQueue(jobsDir, 1, lookup)
+		Expect(err).ToNot(HaveOccurred())
+		queue.Start()
+
+		mapping := &types.JSONMapping{Content: "body", ID: "ticket.key", Metadata: []string{"priority", "labels"}}
+		tickets := `{"ticket": {"key": "T-1"}, "body": "Printer is jammed", "priority": "high", "labels": ["hw", "office"]}
+{"ticket": {"key": "T-2"}, "body": "VPN drops every hour", "priority": 2}
+`
+		job, err := queue.Submit("docs", "tickets.jsonl", strings.NewReader(tickets), map[string]string{"team": "it"}, mapping)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(job.Key).To(BeEmpty())
+		job = waitForJob(queue, job.ID)
+		Expect(job.State).To(Equal(types.JobSucceeded), job.Error)
+		Expect(job.DocumentsStored).To(Equal(2))
+		Expect(job.DocumentsTotal).To(Equal(2))
+
+		Expect(kb.ListDocuments()).To(HaveLen(2))
+		metadata, err := kb.GetEntryMetadata("T-1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(metadata).To(HaveKeyWithValue("document_id", "T-1"))
+		Expect(metadata).To(HaveKeyWithValue("team", "it"))
+		Expect(metadata).To(HaveKeyWithValue("priority", "high"))
+		Expect(metadata).To(HaveKeyWithValue("labels", "hw, office"))
+		results, err := kb.GetEntryContent("T-2")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results).ToNot(BeEmpty())
+		Expect(results[0].Content).To(ContainSubstring("VPN drops"))
+		Expect(results[0].Metadata).To(HaveKeyWithValue("priority", "2"))
+
+		// Uploading a record again replaces the document with its ID.
+		job, err = queue.Submit("docs", "update.json", strings.NewReader(`[{"ticket": {"key": "T-1"}, "body": "Printer fixed"}]`), nil, mapping)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(waitForJob(queue, job.ID).State).To(Equal(types.JobSucceeded))
+		Expect(kb.ListDocuments()).To(HaveLen(2))
+		content, _, err := kb.GetEntryFileContent("T-1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(content).To(Equal("Printer fixed"))
+
+		// A record without the mapped fields fails the job before any record is stored.
+		job, err = queue.Submit("docs", "broken.jsonl", strings.NewReader("{\"ticket\": {\"key\": \"T-3\"}, \"body\": \"ok\"}\n{\"body\": \"no key\"}\n"), nil, mapping)
+		Expect(err).ToNot(HaveOccurred())
+		job = waitForJob(queue, job.ID)
+		Expect(job.State).To(Equal(types.JobFailed))
+		Expect(job.Error).To(ContainSubstring(`record 2 has no "ticket.key" field`))
+		Expect(kb.ListDocuments()).To(HaveLen(2))
+	})
+
+	It("stores every message of an mbox file as an entry of its own", func() {
+		var err error
+		queue, err = NewJobQueue(jobsDir, 1, lookup)
+		Expect(err).ToNot(HaveOccurred())
+		queue.Start()
+
+		mbox := "From alice@example.com Mon Jan  2 15:04:05 2006\n" +
+			"From: Alice <alice@example.com>\nSubject: Printer\nMessage-ID: <root@example.com>\n\nThe printer is jammed.\n\n" +
+			"From bob@example.com Mon Jan  2 16:04:05 2006\n" +
+			"From: Bob <bob@example.com>\nSubject: Re: Printer\nDate: Mon, 02 Jan 2006 16:04:05 +0000\n" +
+			"Message-ID: <reply@example.com>\nIn-Reply-To: <root@example.com>\n\nFixed it.\n"
+		job, err := queue.Submit("docs", "support.mbox", strings.NewReader(mbox), map[string]string{"team": "it"}, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(job.Key).To(BeEmpty())
+		job = waitForJob(queue, job.ID)
+		Expect(job.State).To(Equal(types.JobSucceeded), job.Error)
+		Expect(job.DocumentsStored).To(Equal(2))
+		Expect(job.DocumentsTotal).To(Equal(2))
+
+		Expect(kb.EntryExists("root@example.com.eml")).To(BeTrue())
+		Expect(kb.EntryExists("reply@example.com.eml")).To(BeTrue())
+		metadata, err := kb.GetEntryMetadata("reply@example.com.eml")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(metadata).To(HaveKeyWithValue("team", "it"))
+		Expect(metadata).To(HaveKeyWithValue("mailbox", "support.mbox"))
+		results, err := kb.GetEntryContent("reply@example.com.eml")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results).ToNot(BeEmpty())
+		for _, r := range results {
+			Expect(r.Metadata).To(HaveKeyWithValue("from", "Bob <bob@example.com>"))
+			Expect(r.Metadata).To(HaveKeyWithValue("subject", "Re: Printer"))
+			Expect(r.Metadata).To(HaveKeyWithValue("date", "2006-01-02T16:04:05Z"))
+			Expect(r.Metadata).To(HaveKeyWithValue("message_id", "reply@example.com"))
+			Expect(r.Metadata).To(HaveKeyWithValue("in_reply_to", "root@example.com"))
+			Expect(r.Metadata).To(HaveKeyWithValue("thread_id", "root@example.com"))
+		}
+
+		// Importing the mailbox again replaces its messages.
+		job, err = queue.Submit("docs", "support.mbox", strings.NewReader(mbox), nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(waitForJob(queue, job.ID).State).To(Equal(types.JobSucceeded))
+		Expect(kb.ListDocuments()).To(HaveLen(2))
+	})
+
+	It("fails jobs whose collection is gone", func() {
+		var err error
+		queue, err = NewJobQueue(jobsDir, 1, lookup)
+		Expect(err).ToNot(HaveOccurred())
+		queue.Start()
+
+		job, err := queue.Submit("missing", "notes.txt", strings.NewReader("text"), nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+		job = waitForJob(queue, job.ID)
+		Expect(job.State).To(Equal(types.JobFailed))
+		Expect(job.Error).To(ContainSubstring("collection not found"))
+	})
+
+	It("keeps the staged file of failed jobs so they can be retried", func() {
+		available := false
+		var err error
+		queue, err = NewJobQueue(jobsDir, 1, func(name string) (*PersistentKB, bool) { return kb, available })
+		Expect(err).ToNot(HaveOccurred())
+		queue.Start()
+
+		job, err := queue.Submit("docs", "notes.txt", strings.NewReader("text"), nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(waitForJob(queue, job.ID).State).To(Equal(types.JobFailed))
+		Expect(filepath.Join(jobsDir, job.ID, "notes.txt")).To(BeAnExistingFile())
+
+		available = true
+		retried, err := queue.Retry(job.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(retried.State).To(Equal(types.JobQueued))
+		job = waitForJob(queue, job.ID)
+		Expect(job.State).To(Equal(types.JobSucceeded))
+		Expect(job.Error).To(BeEmpty())
+		Expect(job.Attempts).To(Equal(2))
+		Expect(kb.ListDocuments()).To(ConsistOf(job.Key))
+		Expect(filepath.Join(jobsDir, job.ID)).ToNot(BeADirectory())
+
+		_, err = queue.Retry(job.ID)
+		Expect(err).To(MatchError(ErrJobNotRetryable))
+	})
+
+	It("removes finished jobs past the retention period", func() {
+		var err error
+		queue, err = NewJobQueue(jobsDir, 1, lookup)
+		Expect(err).ToNot(HaveOccurred())
+		queue.Start()
+
+		job, err := queue.Submit("docs", "notes.txt", strings.NewReader("text"), nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(waitForJob(queue, job.ID).State).To(Equal(types.JobSucceeded))
+
+		Expect(queue.Prune(time.Hour)).To(Equal(0))
+		_, ok := queue.Get(job.ID)
+		Expect(ok).To(BeTrue())
+
+		time.Sleep(10 * time.Millisecond)
+		Expect(queue.Prune(time.Millisecond)).To(Equal(1))
+		_, ok = queue.Get(job.ID)
+		Expect(ok).To(BeFalse())
+		Expect(filepath.Join(jobsDir, job.ID+".json")).ToNot(BeAnExistingFile())
+		Expect(filepath.Join(jobsDir, job.ID)).ToNot(BeADirectory())
+		// The stored entry is not affected.
+		Expect(kb.ListDocuments()).To(ConsistOf(job.Key))
+	})
+
+	It("resumes interrupted jobs from the staged file after a restart", func() {
+		first, err := NewJobQueue(jobsDir, 1, lookup)
+		Expect(err).ToNot(HaveOccurred())
+		queued, err := first.Submit("docs", "queued.txt", strings.NewReader("queued text"), nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+		running, err := first.Submit("docs", "running.txt", strings.NewReader("running text"), nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		// Simulate a crash in the middle of the second job.
+		running.State = types.JobRunning
+		data, err := json.Marshal(running)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(jobsDir, running.ID+".json"), data, 0644)).To(Succeed())
+
+		queue, err = NewJobQueue(jobsDir, 1, lookup)
+		Expect(err).ToNot(HaveOccurred())
+		queue.Start()
+
+		Expect(waitForJob(queue, queued.ID).State).To(Equal(types.JobSucceeded))
+		Expect(waitForJob(queue, running.ID).State).To(Equal(types.JobSucceeded))
+		Expect(kb.ListDocuments()).To(ConsistOf(queued.Key, running.Key))
+	})
+
+	It("does not block searches while embedding", func() {
+		eng := &gatedEngine{MockEngine: engine.NewMockEngine(), entered: make(chan struct{}, 1), gate: make(chan struct{})}
+		var err error
+		kb, err = NewPersistentCollectionKB(filepath.Join(tempDir, "gated.json"), filepath.Join(tempDir, "gated"), eng, 1000, 0, nil, "")
+		Expect(err).ToNot(HaveOccurred())
+
+		queue, err = NewJobQueue(jobsDir, 1, lookup)
+		Expect(err).ToNot(HaveOccurred())
+		queue.Start()
+		job, err := queue.Submit("docs", "slow.txt", strings.NewReader("slow to embed"), nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(eng.entered).Should(Receive())
+		// The progress of the running job is saved.
+		data, err := os.ReadFile(filepath.Join(jobsDir, job.ID+".json"))
+		Expect(err).ToNot(HaveOccurred())
+		var saved types.Job
+		Expect(json.Unmarshal(data, &saved)).To(Succeed())
+		Expect(saved.State).To(Equal(types.JobRunning))
+		Expect(saved.ChunksTotal).To(Equal(1))
+
+		_, err = kb.Search("anything", 1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(kb.ListDocuments()).To(BeEmpty())
+		current, _ := queue.Get(job.ID)
+		Expect(current.State).To(Equal(types.JobRunning))
+
+		// Re-indexing meanwhile resets the engine; the job stores the entry
+		// again when it finishes, without duplicating chunks.
+		Expect(kb.Repopulate()).To(Succeed())
+		close(eng.gate)
+		Expect(waitForJob(queue, job.ID).State).To(Equal(types.JobSucceeded))
+		results, err := kb.GetEntryContent("slow.txt")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results).To(HaveLen(1))
+	})
+
+	It("runs a job again on the collection that replaced its own", func() {
+		eng := &gatedEngine{MockEngine: engine.NewMockEngine(), entered: make(chan struct{}, 1), gate: make(chan struct{})}
+		var err error
+		kb, err = NewPersistentCollectionKB(filepath.Join(tempDir, "gated.json"), filepath.Join(tempDir, "gated"), eng, 1000, 0, nil, "")
+		Expect(err).ToNot(HaveOccurred())
+
+		queue, err = NewJobQueue(jobsDir, 1, lookup)
+		Expect(err).ToNot(HaveOccurred())
+		queue.Start()
+		job, err := queue.Submit("docs", "moved.txt", strings.NewReader("moved to a new engine"), nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Eventually(eng.entered).Should(Receive())
+
+		// A config change rebuilds the collection and closes the old one.
+		previous := kb
+		kb, err = newMockKB(filepath.Join(tempDir, "rebuilt.json"), filepath.Join(tempDir, "rebuilt"), engine.NewMockEngine())
+		Expect(err).ToNot(HaveOccurred())
+		previous.Close()
+		close(eng.gate)
+
+		job = waitForJob(queue, job.ID)
+		Expect(job.State).To(Equal(types.JobSucceeded))
+		Expect(job.Attempts).To(Equal(2))
+		Expect(kb.ListDocuments()).To(ConsistOf(job.Key))
+	})
+
+	It("cancels the jobs of a deleted collection", func() {
+		eng := &gatedEngine{MockEngine: engine.NewMockEngine(), entered: make(chan struct{}, 1), gate: make(chan struct{})}
+		var err error
+		kb, err = NewPersistentCollectionKB(filepath.Join(tempDir, "gated.json"), filepath.Join(tempDir, "gated"), eng, 1000, 0, nil, "")
+		Expect(err).ToNot(HaveOccurred())
+
+		queue, err = NewJobQueue(jobsDir, 1, lookup)
+		Expect(err).ToNot(HaveOccurred())
+		queue.Start()
+		running, err := queue.Submit("docs", "running.txt", strings.NewReader("running text"), nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Eventually(eng.entered).Should(Receive())
+		queued, err := queue.Submit("docs", "queued.txt", strings.NewReader("queued text"), nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		// The collection is deleted, then created again under the same name.
+		Expect(kb.Drop()).To(Succeed())
+		Expect(queue.Cancel("docs")).To(Equal(2))
+		kb, err = newMockKB(filepath.Join(tempDir, "recreated.json"), filepath.Join(tempDir, "recreated"), engine.NewMockEngine())
+		Expect(err).ToNot(HaveOccurred())
+		close(eng.gate)
+
+		Eventually(func() []types.Job { return queue.List("") }, 5*time.Second, 10*time.Millisecond).Should(BeEmpty())
+		Consistently(func() []string { return kb.ListDocuments() }, 300*time.Millisecond, 10*time.Millisecond).Should(BeEmpty())
+		for _, id := range []string{running.ID, queued.ID} {
+			Eventually(filepath.Join(jobsDir, id)).ShouldNot(BeADirectory())
+			Expect(filepath.Join(jobsDir, id+".json")).ToNot(BeAnExistingFile())
+		}
+	})
+})
@@ -3,9 +3,11 @@ package rag
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"time"
 
@@ -16,8 +18,10 @@ import (
 	"github.com/google/uuid"
 	"github.com/klippa-app/go-pdfium"
 	"github.com/klippa-app/go-pdfium/requests"
+	"github.com/klippa-app/go-pdfium/responses"
 	"github.com/klippa-app/go-pdfium/webassembly"
 	"github.com/mudler/localrecall/pkg/chunk"
+	"github.com/mudler/localrecall/pkg/extract"
 	"github.com/mudler/localrecall/rag/engine"
 	"github.com/mudler/localrecall/rag/types"
 	"github.com/mudler/xlog"
@@ -26,9 +30,17 @@ import (
 
 // CollectionState represents the persistent state of a collection
 type CollectionState struct {
-	ExternalSources []*ExternalSource `json:"external_sources"`
+	ExternalSources []*ExternalSource       `json:"external_sources"`
+	Config          *types.CollectionConfig `json:"config,omitempty"`
+	// EntryMetadata holds the metadata of each entry, keyed by entry key, so
+	// that it is restored when the collection is repopulated.
+	EntryMetadata map[string]map[string]string `json:"entry_metadata,omitempty"`
 }
 
+// errCollectionDropped is returned when storing into a collection that was
+// deleted or closed, e.g. by a source update that was already running.
+var errCollectionDropped = errors.New("collection has been deleted")
+
 type PersistentKB struct {
 	Engine
 	sync.Mutex
@@ -36,7 +48,15 @@ type PersistentKB struct {
 	assetDir     string
 	maxChunkSize int
 	chunkOverlap int
+	tokenizer    chunk.Tokenizer
 	sources      []*ExternalSource
+	config       *types.CollectionConfig
+	metadata     map[string]map[string]string
+	dropped      bool
+
+	// generation changes whenever the engine is reset or re-indexed, so
+	// ingestion running without the lock can tell its chunks may be gone.
+	generation int
 }
 
 func loadDB(path string) (*CollectionState, error) {
@@ -103,6 +123,8 @@ func NewPersistentCollectionKB(stateFile, assetDir string, store Engine, maxChun
 		chunkOverlap: chunkOverlap,
 		assetDir:     assetDir,
 		sources:      state.ExternalSources,
+		config:       state.Config,
+		metadata:     state.EntryMetadata,
 	}
 
 	// Migrate flat files in assetDir (files not in UUID subdirectories) to UUID layout.
@@ -132,6 +154,43 @@ func NewPersistentCollectionKB(stateFile, assetDir string, store Engine, maxChun
 	return db, nil
 }
 
+// Config returns the configuration stored with the collection, or nil if it
+// was created without one.
+func (db *PersistentKB) Config() *types.CollectionConfig {
+	db.Lock()
+	defer db.Unlock()
+	if db.config == nil {
+		return nil
+	}
+	config := *db.config
+	return &config
+}
+
+// SetConfig stores config in the collection state. It does not re-chunk or
+// re-embed existing entries; see SetChunkSize and Repopulate.
+func (db *PersistentKB) SetConfig(config *types.CollectionConfig) error {
+	db.Lock()
+	defer db.Unlock()
+	db.config = config
+	return db.save()
+}
+
+// SetChunkSize changes the chunk size and overlap used for new entries.
+func (db *PersistentKB) SetChunkSize(maxChunkSize, chunkOverlap int) {
+	db.Lock()
+	defer db.Unlock()
+	db.maxChunkSize = maxChunkSize
+	db.chunkOverlap = chunkOverlap
+}
+
+// SetTokenizer makes chunk sizes and overlaps count tokens of t instead of
+// characters. A nil tokenizer restores character-based sizing.
+func (db *PersistentKB) SetTokenizer(t chunk.Tokenizer) {
+	db.Lock()
+	defer db.Unlock()
+	db.tokenizer = t
+}
+
 // listDocumentKeys scans assetDir for UUID subdirectories containing files
 // and returns the keys in "uuid/filename" format.
 func (db *PersistentKB) listDocumentKeys() []string {
@@ -179,6 +238,13 @@ func (db *PersistentKB) findEntryKey(entry string) (string, bool) {
 		}
 	}
 
+	// Documents can also be addressed by their ID
+	for _, k := range keys {
+		if id := db.metadata[k][DocumentIDKey]; id != "" && id == entry {
+			return k, true
+		}
+	}
+
 	return "", false
 }
 
@@ -189,11 +255,22 @@ func (db *PersistentKB) Search(s string, similarEntries int) ([]types.Result, er
 	return db.Engine.Search(s, similarEntries)
 }
 
+// SearchWithFilter searches the collection restricting results to chunks whose
+// metadata matches filter.
+func (db *PersistentKB) SearchWithFilter(s string, similarEntries int, filter *types.Filter) ([]types.Result, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	return db.Engine.SearchWithFilter(s, similarEntries, filter)
+}
+
 func (db *PersistentKB) Reset() error {
 	db.Lock()
 	os.RemoveAll(db.assetDir)
 	os.MkdirAll(db.assetDir, 0755)
 	db.sources = []*ExternalSource{}
+	db.metadata = nil
+	db.generation++
 	db.save()
 	db.Unlock()
 	if err := db.Engine.Reset(); err != nil {
@@ -203,9 +280,45 @@ func (db *PersistentKB) Reset() error {
 	return nil
 }
 
+// Drop deletes the collection for good: its engine storage, its asset
+// directory and its state file. Storing into a dropped collection fails.
+func (db *PersistentKB) Drop() error {
+	db.Lock()
+	defer db.Unlock()
+
+	if err := db.Engine.Drop(); err != nil {
+		return fmt.Errorf("failed to drop engine storage: %w", err)
+	}
+	db.dropped = true
+	db.generation++
+	db.sources = nil
+	if err := os.RemoveAll(db.assetDir); err != nil {
+		return fmt.Errorf("failed to remove assets: %w", err)
+	}
+	if err := os.Remove(db.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove state file: %w", err)
+	}
+	return nil
+}
+
+// Close detaches the collection from its storage without deleting it, for
+// when another PersistentKB takes the collection over. Like a dropped
+// collection, a closed one fails to store entries or save its state.
+func (db *PersistentKB) Close() {
+	db.Lock()
+	defer db.Unlock()
+	db.dropped = true
+	db.generation++
+}
+
 func (db *PersistentKB) save() error {
+	if db.dropped {
+		return errCollectionDropped
+	}
 	state := &CollectionState{
 		ExternalSources: db.sources,
+		Config:          db.config,
+		EntryMetadata:   db.metadata,
 	}
 	data, err := json.Marshal(state)
 	if err != nil {
@@ -224,6 +337,7 @@ func (db *PersistentKB) Count() int {
 
 // repopulate reinitializes the persistent knowledge base with the files that were added to it.
 func (db *PersistentKB) repopulate() error {
+	db.generation++
 	if err := db.Engine.Reset(); err != nil {
 		return fmt.Errorf("failed to reset engine: %w", err)
 	}
@@ -232,7 +346,7 @@ func (db *PersistentKB) repopulate() error {
 	// Only repopulate chunkable files
 	var chunkableKeys []string
 	for _, k := range keys {
-		if isChunkableFile(k) {
+		if db.isChunkableEntry(k) {
 			chunkableKeys = append(chunkableKeys, k)
 		}
 	}
@@ -330,6 +444,99 @@ func (db *PersistentKB) GetEntryFileContent(entry string) (content string, chunk
 	return content, chunkCount, nil
 }
 
+// ErrNotPaged is returned by GetEntryPages for entries that are not PDFs.
+var ErrNotPaged = errors.New("entry has no pages")
+
+// GetEntryPages returns the text of every page of a PDF entry, in order, and
+// the number of chunks the entry occupies.
+func (db *PersistentKB) GetEntryPages(entry string) (pages []string, chunkCount int, err error) {
+	db.Lock()
+	defer db.Unlock()
+
+	key, ok := db.findEntryKey(entry)
+	if !ok {
+		return nil, 0, fmt.Errorf("entry not found: %s", entry)
+	}
+	if strings.ToLower(filepath.Ext(key)) != ".pdf" {
+		return nil, 0, fmt.Errorf("%w: %s is not a PDF", ErrNotPaged, entry)
+	}
+
+	results, err := db.Engine.GetBySource(key)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get chunks for %s: %w", key, err)
+	}
+	pages, err = extractPDFPages(filepath.Join(db.assetDir, key))
+	if err != nil {
+		return nil, 0, err
+	}
+	return pages, len(results), nil
+}
+
+// GetEntryMetadata returns the metadata recorded for the given entry.
+func (db *PersistentKB) GetEntryMetadata(entry string) (map[string]string, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	key, ok := db.findEntryKey(entry)
+	if !ok {
+		return nil, fmt.Errorf("entry not found: %s", entry)
+	}
+	metadata := make(map[string]string, len(db.metadata[key]))
+	for k, v := range db.metadata[key] {
+		metadata[k] = v
+	}
+	return metadata, nil
+}
+
+// UpdateEntryMetadata sets and removes metadata keys of the given entry, both
+// in the collection state and on every chunk of the entry, and returns the
+// resulting metadata. Reserved keys cannot be changed.
+func (db *PersistentKB) UpdateEntryMetadata(entry string, set map[string]string, remove []string) (map[string]string, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	if db.dropped {
+		return nil, errCollectionDropped
+	}
+	key, ok := db.findEntryKey(entry)
+	if !ok {
+		return nil, fmt.Errorf("entry not found: %s", entry)
+	}
+	if err := ValidateMetadata(set); err != nil {
+		return nil, err
+	}
+	for _, k := range remove {
+		if slices.Contains(ReservedMetadataKeys, k) {
+			return nil, fmt.Errorf("metadata key %q is reserved", k)
+		}
+	}
+
+	if err := db.Engine.UpdateMetadata(key, set, remove); err != nil {
+		return nil, fmt.Errorf("failed to update metadata of %s: %w", key, err)
+	}
+
+	metadata := make(map[string]string, len(db.metadata[key])+len(set))
+	for k, v := range db.metadata[key] {
+		metadata[k] = v
+	}
+	for _, k := range remove {
+		delete(metadata, k)
+	}
+	for k, v := range set {
+		metadata[k] = v
+	}
+	db.setEntryMetadata(key, metadata)
+	if err := db.save(); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		result[k] = v
+	}
+	return result, nil
+}
+
 // Store stores an entry in the persistent knowledge base.
 func (db *PersistentKB) Store(entry string, metadata map[string]string) (string, error) {
 	db.Lock()
@@ -340,6 +547,9 @@ func (db *PersistentKB) Store(entry string, metadata map[string]string) (string,
 
 func (db *PersistentKB) storeFile(entry string, metadata map[string]string) (string, error) {
 	xlog.Info("Storing file", "entry", entry)
+	if db.dropped {
+		return "", errCollectionDropped
+	}
 	fileName := filepath.Base(entry)
 
 	// copy file to assetDir (if it's a file)
@@ -364,12 +574,15 @@ func (db *PersistentKB) storeFile(entry string, metadata map[string]string) (str
 	// via GetEntryFilePath(), but no semantic chunks are created.
 	if !isChunkableFile(fileName) {
 		xlog.Info("Storing as raw-only entry (not semantically indexed)", "entry", entry, "indexKey", indexKey)
+		db.setEntryMetadata(indexKey, metadata)
 		return indexKey, db.save()
 	}
 
+	db.setEntryMetadata(indexKey, metadata)
 	beforeCount := db.Engine.Count()
-	results, err := db.store(metadata, indexKey)
+	results, err := db.store(map[string]string{}, indexKey)
 	if err != nil {
+		db.setEntryMetadata(indexKey, nil)
 		return "", fmt.Errorf("failed to store file: %w", err)
 	}
 	afterCount := db.Engine.Count()
@@ -378,16 +591,123 @@ func (db *PersistentKB) storeFile(entry string, metadata map[string]string) (str
 	return indexKey, db.save()
 }
 
+// ingestBatchSize is the number of chunks ingest embeds and stores at once.
+const ingestBatchSize = 64
+
+// ingest stores the file at path under key, like Store, but chunks and embeds
+// it without holding the collection lock, so searches and other writes are not
+// blocked by a large file. progress, if set, is called as chunks are stored.
+// Chunks left under key by an interrupted earlier attempt are replaced.
+func (db *PersistentKB) ingest(path, key string, metadata map[string]string, progress func(stored, total int)) error {
+	db.Lock()
+	if db.dropped {
+		db.Unlock()
+		return errCollectionDropped
+	}
+	opts := db.chunkOptions()
+	keyColumns := db.keyColumns()
+	chunkMetadata := db.entryChunkMetadata(key, metadata)
+	generation := db.generation
+	db.Unlock()
+
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("file does not exist: %s", path)
+	}
+
+	discard := func() {
+		// Engines without deletes (LocalAI) cannot drop partial chunks.
+		if err := db.Engine.Delete(map[string]string{"source": key}, map[string]string{}); err != nil {
+			xlog.Debug("Could not delete chunks", "entry", key, "error", err)
+		}
+	}
+	discard()
+
+	chunkable := isChunkableFile(key)
+	if chunkable {
+		pieces, err := chunkFile(path, opts, keyColumns)
+		if err != nil {
+			return err
+		}
+		if len(pieces) == 0 {
+			return fmt.Errorf("no chunks generated for file: %s", key)
+		}
+		if progress != nil {
+			progress(0, len(pieces))
+		}
+		stored := 0
+		for _, group := range groupChunksByMetadata(pieces) {
+			groupMetadata := mergeChunkMetadata(chunkMetadata, group.metadata)
+			for start := 0; start < len(group.contents); start += ingestBatchSize {
+				end := min(start+ingestBatchSize, len(group.contents))
+				res, err := db.Engine.StoreDocuments(group.contents[start:end], groupMetadata)
+				if err != nil {
+					discard()
+					return fmt.Errorf("failed to store documents: %w", err)
+				}
+				stored += len(res)
+				if progress != nil {
+					progress(stored, len(pieces))
+				}
+			}
+		}
+		xlog.Info("Stored pieces", "indexKey", key, "chunk_count", stored)
+	}
+
+	db.Lock()
+	defer db.Unlock()
+
+	if db.dropped {
+		return errCollectionDropped
+	}
+	if err := copyFile(path, filepath.Join(db.assetDir, filepath.Dir(key))); err != nil {
+		discard()
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+	db.setEntryMetadata(key, metadata)
+	if chunkable && db.generation != generation {
+		// The engine was reset or re-indexed meanwhile: store the entry
+		// again, now under the lock.
+		xlog.Info("Collection changed during ingestion, storing again", "indexKey", key)
+		discard()
+		if _, err := db.store(map[string]string{}, key); err != nil {
+			db.setEntryMetadata(key, nil)
+			os.RemoveAll(filepath.Join(db.assetDir, filepath.Dir(key)))
+			return fmt.Errorf("failed to store file: %w", err)
+		}
+	}
+	return db.save()
+}
+
 func (db *PersistentKB) StoreOrReplace(entry string, metadata map[string]string) (string, error) {
 	xlog.Info("Storing or replacing entry", "entry", entry)
 	db.Lock()
 	defer db.Unlock()
 
-	fileName := filepath.Base(entry)
+	if db.dropped {
+		return "", errCollectionDropped
+	}
 
 	// Find the existing key by base filename (if any)
-	oldKey, hadExisting := db.findEntryKey(fileName)
-	if hadExisting {
+	oldKey, _ := db.findEntryKey(filepath.Base(entry))
+	indexKey, err := db.replaceEntry(entry, metadata, oldKey)
+	if err != nil {
+		return "", err
+	}
+
+	// Save state
+	if err := db.save(); err != nil {
+		return "", fmt.Errorf("failed to save state: %w", err)
+	}
+
+	return indexKey, nil
+}
+
+// replaceEntry stores the file at entry as a new entry, removing first the
+// chunks and files of oldKey, the entry it replaces, unless empty. It does
+// not save the state.
+func (db *PersistentKB) replaceEntry(entry string, metadata map[string]string, oldKey string) (string, error) {
+	fileName := filepath.Base(entry)
+	if oldKey != "" {
 		xlog.Info("Removing old chunks before storing new ones", "entry", oldKey)
 
 		// Delete old chunks by source metadata
@@ -417,19 +737,19 @@ func (db *PersistentKB) StoreOrReplace(entry string, metadata map[string]string)
 	}
 
 	// Store the new chunks
+	if oldKey != "" {
+		db.setEntryMetadata(oldKey, nil)
+	}
+	db.setEntryMetadata(indexKey, metadata)
 	beforeCount := db.Engine.Count()
-	results, err := db.store(metadata, indexKey)
+	results, err := db.store(map[string]string{}, indexKey)
 	if err != nil {
+		db.setEntryMetadata(indexKey, nil)
 		return "", fmt.Errorf("failed to store file: %w", err)
 	}
 	afterStoreCount := db.Engine.Count()
 	xlog.Info("Stored new chunks", "entry", indexKey, "new_chunk_count", len(results), "count_before", beforeCount, "count_after", afterStoreCount)
 
-	// Save state
-	if err := db.save(); err != nil {
-		return "", fmt.Errorf("failed to save state: %w", err)
-	}
-
 	return indexKey, nil
 }
 
@@ -439,30 +759,117 @@ func (db *PersistentKB) store(metadata map[string]string, indexKeys ...string) (
 
 	for _, key := range indexKeys {
 		e := filepath.Join(db.assetDir, key)
-		pieces, err := chunkFile(e, db.maxChunkSize, db.chunkOverlap)
+		pieces, err := chunkFile(e, db.chunkOptions(), db.keyColumns())
 		if err != nil {
 			return nil, err
 		}
-		metadata["type"] = "file"
-		metadata["source"] = key
-		metadata["file_name"] = filepath.Base(key)
+		metadata := db.entryChunkMetadata(key, metadata)
 		xlog.Info("Storing pieces", "pieces", len(pieces), "chunk_count", len(pieces), "indexKey", key, "metadata", metadata)
 		if len(pieces) == 0 {
 			return nil, fmt.Errorf("no chunks generated for file: %s", key)
 		}
-		res, err := db.Engine.StoreDocuments(pieces, metadata)
-		if err != nil {
-			return nil, fmt.Errorf("failed to store documents: %w", err)
+		// Engines take one metadata map per StoreDocuments call, so store runs
+		// of consecutive chunks sharing the same chunk metadata (e.g. the same
+		// Markdown section) together.
+		stored := 0
+		for _, group := range groupChunksByMetadata(pieces) {
+			groupMetadata := mergeChunkMetadata(metadata, group.metadata)
+			res, err := db.Engine.StoreDocuments(group.contents, groupMetadata)
+			if err != nil {
+				return nil, fmt.Errorf("failed to store documents: %w", err)
+			}
+			stored += len(res)
+			results = append(results, res...)
 		}
-		if len(res) != len(pieces) {
-			return nil, fmt.Errorf("stored %d chunks but expected %d for file: %s", len(res), len(pieces), key)
+		if stored != len(pieces) {
+			return nil, fmt.Errorf("stored %d chunks but expected %d for file: %s", stored, len(pieces), key)
 		}
-		results = append(results, res...)
 	}
 
 	return results, nil
 }
 
+// chunkGroup is a run of consecutive chunks sharing the same chunk metadata.
+type chunkGroup struct {
+	contents []string
+	metadata map[string]string
+}
+
+func groupChunksByMetadata(chunks []chunk.Chunk) []chunkGroup {
+	var groups []chunkGroup
+	for _, c := range chunks {
+		if n := len(groups); n > 0 && sameMetadata(groups[n-1].metadata, c.Metadata) {
+			groups[n-1].contents = append(groups[n-1].contents, c.Content)
+			continue
+		}
+		groups = append(groups, chunkGroup{contents: []string{c.Content}, metadata: c.Metadata})
+	}
+	return groups
+}
+
+func sameMetadata(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeChunkMetadata adds the metadata read from the file for a group of
+// chunks, such as its pages or the document title, to the metadata of their
+// entry. Metadata given with the entry wins, so a title supplied on upload
+// is not replaced by the one found in the file.
+func mergeChunkMetadata(entry, chunk map[string]string) map[string]string {
+	merged := make(map[string]string, len(entry)+len(chunk))
+	for k, v := range chunk {
+		merged[k] = v
+	}
+	for k, v := range entry {
+		merged[k] = v
+	}
+	return merged
+}
+
+// entryChunkMetadata returns the metadata shared by all chunks of an entry:
+// the caller's, overridden by the entry's stored metadata and the reserved
+// type/source/file_name keys.
+func (db *PersistentKB) entryChunkMetadata(key string, metadata map[string]string) map[string]string {
+	merged := make(map[string]string, len(metadata)+len(db.metadata[key])+3)
+	for k, v := range metadata {
+		merged[k] = v
+	}
+	for k, v := range db.metadata[key] {
+		merged[k] = v
+	}
+	if merged["type"] == "" {
+		merged["type"] = "file"
+	}
+	merged["source"] = key
+	merged["file_name"] = filepath.Base(key)
+	return merged
+}
+
+// setEntryMetadata records the metadata of an entry in the collection state;
+// nil or empty metadata removes the record. Callers save the state.
+func (db *PersistentKB) setEntryMetadata(key string, metadata map[string]string) {
+	if len(metadata) == 0 {
+		delete(db.metadata, key)
+		return
+	}
+	if db.metadata == nil {
+		db.metadata = map[string]map[string]string{}
+	}
+	stored := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		stored[k] = v
+	}
+	db.metadata[key] = stored
+}
+
 func (db *PersistentKB) RemoveEntry(entry string) error {
 	db.Lock()
 	defer db.Unlock()
@@ -480,36 +887,52 @@ func (db *PersistentKB) removeFileEntry(entry string) error {
 		return fmt.Errorf("entry not found: %s", entry)
 	}
 
-	if os.Getenv("LOCALRECALL_REPOPULATE_DELETE") != "true" {
-		e := filepath.Join(db.assetDir, key)
+	repopulate := os.Getenv("LOCALRECALL_REPOPULATE_DELETE") == "true"
+	if err := db.deleteEntry(key, repopulate); err != nil {
+		return err
+	}
+	if !repopulate {
+		return db.save()
+	}
 
-		// Get count before deletion for logging
-		beforeCount := db.Engine.Count()
-		xlog.Info("Deleting entry from engine", "entry", key, "total_count_before", beforeCount)
+	// TODO: this is suboptimal, but currently chromem does not support deleting single entities
+	return db.repopulate()
+}
 
-		if err := db.Engine.Delete(map[string]string{"source": key}, map[string]string{}); err != nil {
-			xlog.Error("Error deleting by source metadata", "error", err, "entry", key)
-			return err
-		}
+// deleteEntry removes the file and metadata of the entry with the given key
+// and, unless keepChunks is set for callers that repopulate the engine
+// afterwards, its chunks. It does not save the state.
+func (db *PersistentKB) deleteEntry(key string, keepChunks bool) error {
+	if keepChunks {
+		// Remove the file and its UUID subdir
+		os.RemoveAll(filepath.Join(db.assetDir, filepath.Dir(key)))
+		db.setEntryMetadata(key, nil)
+		return nil
+	}
 
-		afterCount := db.Engine.Count()
-		xlog.Info("Deleted entry", "entry", key, "count_before", beforeCount, "count_after", afterCount, "deleted_count", beforeCount-afterCount)
+	e := filepath.Join(db.assetDir, key)
 
-		xlog.Info("Removing entry from disk", "file", e)
-		os.Remove(e)
-		// Remove the UUID subdirectory
-		uuidDir := filepath.Dir(e)
-		if uuidDir != db.assetDir {
-			os.Remove(uuidDir)
-		}
-		return db.save()
-	}
+	// Get count before deletion for logging
+	beforeCount := db.Engine.Count()
+	xlog.Info("Deleting entry from engine", "entry", key, "total_count_before", beforeCount)
 
-	// Remove the file and its UUID subdir
-	os.RemoveAll(filepath.Join(db.assetDir, filepath.Dir(key)))
+	if err := db.Engine.Delete(map[string]string{"source": key}, map[string]string{}); err != nil {
+		xlog.Error("Error deleting by source metadata", "error", err, "entry", key)
+		return err
+	}
 
-	// TODO: this is suboptimal, but currently chromem does not support deleting single entities
-	return db.repopulate()
+	afterCount := db.Engine.Count()
+	xlog.Info("Deleted entry", "entry", key, "count_before", beforeCount, "count_after", afterCount, "deleted_count", beforeCount-afterCount)
+
+	xlog.Info("Removing entry from disk", "file", e)
+	os.Remove(e)
+	// Remove the UUID subdirectory
+	uuidDir := filepath.Dir(e)
+	if uuidDir != db.assetDir {
+		os.Remove(uuidDir)
+	}
+	db.setEntryMetadata(key, nil)
+	return nil
 }
 
 func copyFile(src, dst string) error {
@@ -538,7 +961,31 @@ func copyFile(src, dst string) error {
 // not appear in search results.
 func isChunkableFile(path string) bool {
 	switch strings.ToLower(filepath.Ext(path)) {
-	case ".pdf", ".txt", ".md":
+	case ".pdf", ".txt", ".md", ".docx", ".odt", ".rtf", ".html", ".htm", ".csv", ".tsv", ".xlsx", ".epub", ".eml", ".pptx", ".srt", ".vtt":
+		return true
+	}
+	return false
+}
+
+// isChunkableEntry reports whether the entry with the given key is chunked:
+// files in a format isChunkableFile supports and, for the files of Git
+// sources, source code and the other text formats of isPlainTextFile.
+// Callers hold the lock.
+func (db *PersistentKB) isChunkableEntry(key string) bool {
+	if isChunkableFile(key) {
+		return true
+	}
+	if db.metadata[key][RepositoryKey] == "" {
+		return false
+	}
+	return isPlainTextFile(key) || chunk.CodeLanguage(key) != ""
+}
+
+// isPlainTextFile reports whether the file is text read as is: plain text,
+// Markdown, and configuration and markup formats found in repositories.
+func isPlainTextFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".txt", ".md", ".json", ".yaml", ".yml", ".toml", ".ini", ".conf", ".xml", ".log", ".rst", ".tex", ".adoc", ".asciidoc", ".wiki":
 		return true
 	}
 	return false
@@ -590,17 +1037,74 @@ func initPDFiumPool() {
 	})
 }
 
-// extractPDFText reads fpath, opens it with go-pdfium's WebAssembly
-// backend, and concatenates per-page text. Runs the work on a goroutine
-// with a wall-clock timeout — PDFium is robust against adversarial
-// PDFs (it's the same parser Chrome ships) but the timeout protects
-// against degenerate cases. If the timeout fires the goroutine
-// continues until PDFium returns, then is GC'd; the caller does not
+// extractPDFPages returns the text of every page of the PDF at fpath.
+func extractPDFPages(fpath string) ([]string, error) {
+	doc, err := extractPDF(fpath)
+	if err != nil {
+		return nil, err
+	}
+	return doc.Pages, nil
+}
+
+// pdfDocument is the content of a PDF: the text of its pages, its document
+// information and its outline.
+type pdfDocument struct {
+	Pages   []string
+	Title   string
+	Author  string
+	Created time.Time
+	// Outline holds the bookmarks of the document in outline order.
+	Outline []pdfSection
+}
+
+// pdfSection is a bookmark of a PDF outline: the titles of the bookmark and
+// its ancestors and the page (1-based) it points to.
+type pdfSection struct {
+	Path []string
+	Page int
+}
+
+// metadata returns the document information recorded on every chunk of the
+// document.
+func (d *pdfDocument) metadata() map[string]string {
+	metadata := map[string]string{}
+	if d.Title != "" {
+		metadata[TitleKey] = d.Title
+	}
+	if d.Author != "" {
+		metadata[AuthorKey] = d.Author
+	}
+	if !d.Created.IsZero() {
+		metadata[CreatedKey] = d.Created.UTC().Format(time.RFC3339)
+	}
+	return metadata
+}
+
+// sectionAt returns the outline path of the section covering the given page:
+// the last bookmark pointing at or before it. Bookmarks pointing at the same
+// page resolve to the last one, usually the most nested.
+func (d *pdfDocument) sectionAt(page int) []string {
+	var path []string
+	best := 0
+	for _, section := range d.Outline {
+		if section.Page <= page && section.Page >= best {
+			path, best = section.Path, section.Page
+		}
+	}
+	return path
+}
+
+// extractPDF reads fpath, opens it with go-pdfium's WebAssembly backend, and
+// returns the text of every page, the document information and the outline.
+// Runs the work on a goroutine with a wall-clock timeout — PDFium is robust
+// against adversarial PDFs (it's the same parser Chrome ships) but the
+// timeout protects against degenerate cases. If the timeout fires the
+// goroutine continues until PDFium returns, then is GC'd; the caller does not
 // block.
-func extractPDFText(fpath string) (string, error) {
+func extractPDF(fpath string) (*pdfDocument, error) {
 	type result struct {
-		text string
-		err  error
+		doc *pdfDocument
+		err error
 	}
 	ch := make(chan result, 1)
 
@@ -637,7 +1141,7 @@ func extractPDFText(fpath string) (string, error) {
 			return
 		}
 
-		var sb strings.Builder
+		doc := &pdfDocument{Pages: make([]string, 0, count.PageCount)}
 		for i := 0; i < count.PageCount; i++ {
 			page, perr := instance.GetPageText(&requests.GetPageText{
 				Page: requests.Page{ByIndex: &requests.PageByIndex{
@@ -649,42 +1153,200 @@ func extractPDFText(fpath string) (string, error) {
 				ch <- result{err: fmt.Errorf("extracting page %d: %w", i+1, perr)}
 				return
 			}
-			sb.WriteString(page.Text)
-			if i+1 < count.PageCount {
-				sb.WriteString("\n\n")
+			doc.Pages = append(doc.Pages, page.Text)
+		}
+
+		// Document information and the outline are best effort: a PDF whose
+		// text could be read is still ingested without them.
+		tags := []string{"Title", "Author", "CreationDate"}
+		if meta, merr := instance.GetMetaData(&requests.GetMetaData{Document: open.Document, Tags: &tags}); merr == nil {
+			for _, tag := range meta.Tags {
+				switch tag.Tag {
+				case "Title":
+					doc.Title = tag.Value
+				case "Author":
+					doc.Author = tag.Value
+				case "CreationDate":
+					doc.Created, _ = parsePDFDate(tag.Value)
+				}
 			}
 		}
-		ch <- result{text: sb.String()}
+		if bookmarks, berr := instance.GetBookmarks(&requests.GetBookmarks{Document: open.Document}); berr == nil {
+			doc.Outline = flattenPDFOutline(nil, bookmarks.Bookmarks, nil)
+		}
+		ch <- result{doc: doc}
 	}()
 
 	timeout := pdfExtractTimeout()
 	select {
 	case r := <-ch:
 		if r.err != nil {
-			return "", r.err
+			return nil, r.err
 		}
 		// PDFium can emit invalid UTF-8 byte sequences from PDFs with
 		// custom CMaps; PostgreSQL rejects those and null bytes break
 		// downstream tooling. Sanitize before returning.
-		text := strings.ToValidUTF8(r.text, " ")
-		text = strings.ReplaceAll(text, "\x00", "")
-		return text, nil
+		doc := r.doc
+		for i, text := range doc.Pages {
+			doc.Pages[i] = sanitizePDFText(text)
+		}
+		doc.Title = singleLinePDFText(doc.Title)
+		doc.Author = singleLinePDFText(doc.Author)
+		for i := range doc.Outline {
+			for j, title := range doc.Outline[i].Path {
+				doc.Outline[i].Path[j] = singleLinePDFText(title)
+			}
+		}
+		return doc, nil
 	case <-time.After(timeout):
-		return "", fmt.Errorf("pdf extraction timed out after %s", timeout)
+		return nil, fmt.Errorf("pdf extraction timed out after %s", timeout)
+	}
+}
+
+func sanitizePDFText(text string) string {
+	return strings.ReplaceAll(strings.ToValidUTF8(text, " "), "\x00", "")
+}
+
+func singleLinePDFText(text string) string {
+	return strings.Join(strings.Fields(sanitizePDFText(text)), " ")
+}
+
+// flattenPDFOutline appends the bookmarks below parent to sections in
+// outline order. Bookmarks that point at no page of the document, such as
+// links to other files, are skipped, but their children are kept.
+func flattenPDFOutline(sections []pdfSection, bookmarks []responses.GetBookmarksBookmark, parent []string) []pdfSection {
+	for _, bookmark := range bookmarks {
+		path := append(append([]string(nil), parent...), bookmark.Title)
+		dest := bookmark.DestInfo
+		if dest == nil && bookmark.ActionInfo != nil {
+			dest = bookmark.ActionInfo.DestInfo
+		}
+		if dest != nil && dest.PageIndex >= 0 {
+			sections = append(sections, pdfSection{Path: path, Page: dest.PageIndex + 1})
+		}
+		sections = flattenPDFOutline(sections, bookmark.Children, path)
 	}
+	return sections
+}
+
+// parsePDFDate parses a PDF date string ("D:YYYYMMDDHHmmSSOHH'mm'"), whose
+// fields after the year and timezone are optional.
+func parsePDFDate(s string) (time.Time, bool) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "D:")
+	const layout = "20060102150405"
+	n := 0
+	for n < len(s) && n < len(layout) && s[n] >= '0' && s[n] <= '9' {
+		n++
+	}
+	if n < 4 || n%2 != 0 {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(layout[:n], s[:n])
+	if err != nil {
+		return time.Time{}, false
+	}
+	zone := strings.ReplaceAll(s[n:], "'", "")
+	if len(zone) >= 3 && (zone[0] == '+' || zone[0] == '-') {
+		hours, herr := strconv.Atoi(zone[1:3])
+		minutes := 0
+		if len(zone) >= 5 {
+			minutes, _ = strconv.Atoi(zone[3:5])
+		}
+		if herr == nil {
+			offset := hours*3600 + minutes*60
+			if zone[0] == '-' {
+				offset = -offset
+			}
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.FixedZone("", offset))
+		}
+	}
+	return t, true
 }
 
 // fileToText extracts the full text from a stored file (same logic as chunkFile but no splitting).
 // Used by GetEntryFileContent to return content without chunk overlap.
 func fileToText(fpath string) (string, error) {
+	text, _, err := extractFile(fpath)
+	return text, err
+}
+
+// extractFile extracts the full text of a stored file together with
+// document-level metadata (such as an HTML page title) that applies to all of
+// its chunks.
+func extractFile(fpath string) (string, map[string]string, error) {
 	if _, err := os.Stat(fpath); os.IsNotExist(err) {
-		return "", fmt.Errorf("file does not exist: %s", fpath)
+		return "", nil, fmt.Errorf("file does not exist: %s", fpath)
 	}
-	extension := filepath.Ext(fpath)
+	extension := strings.ToLower(filepath.Ext(fpath))
 	switch extension {
+	case ".html", ".htm":
+		page, err := extract.HTML(fpath)
+		if err != nil {
+			return "", nil, err
+		}
+		if page.Title == "" {
+			return page.Text, nil, nil
+		}
+		return page.Text, map[string]string{TitleKey: page.Title}, nil
+	case ".epub":
+		book, err := extract.EPUB(fpath)
+		if err != nil {
+			return "", nil, err
+		}
+		if book.Title == "" {
+			return book.Text(), nil, nil
+		}
+		return book.Text(), map[string]string{TitleKey: book.Title}, nil
+	case ".pptx":
+		deck, err := extract.PPTX(fpath)
+		if err != nil {
+			return "", nil, err
+		}
+		if deck.Title == "" {
+			return deck.Text(), nil, nil
+		}
+		return deck.Text(), map[string]string{TitleKey: deck.Title}, nil
 	case ".pdf":
-		return extractPDFText(fpath)
-	case ".txt", ".md":
+		doc, err := extractPDF(fpath)
+		if err != nil {
+			return "", nil, err
+		}
+		return strings.Join(doc.Pages, "\n\n"), doc.metadata(), nil
+	case ".eml":
+		return extractEmail(fpath)
+	case ".srt", ".vtt":
+		cues, err := readCues(fpath)
+		if err != nil {
+			return "", nil, err
+		}
+		return transcriptText(cues), nil, nil
+	default:
+		text, err := extractText(fpath, extension)
+		return text, nil, err
+	}
+}
+
+func extractText(fpath, extension string) (string, error) {
+	if isTableFile(fpath) {
+		tables, err := readTables(fpath)
+		if err != nil {
+			return "", err
+		}
+		sheets := make([]string, len(tables))
+		for i, table := range tables {
+			sheets[i] = table.Markdown()
+		}
+		return strings.Join(sheets, "\n"), nil
+	}
+	switch extension {
+	case ".docx":
+		return extract.DOCX(fpath)
+	case ".odt":
+		return extract.ODT(fpath)
+	case ".rtf":
+		return extract.RTF(fpath)
+	}
+	if isPlainTextFile(fpath) || chunk.CodeLanguage(fpath) != "" {
 		f, err := os.Open(fpath)
 		if err != nil {
 			return "", err
@@ -695,23 +1357,280 @@ func fileToText(fpath string) (string, error) {
 			return "", err
 		}
 		return string(content), nil
-	default:
-		return "", fmt.Errorf("unsupported file type: %s", extension)
 	}
+	return "", fmt.Errorf("unsupported file type: %s", extension)
+}
+
+// chunkOptions returns the chunking options for this collection.
+func (db *PersistentKB) chunkOptions() chunk.Options {
+	return chunk.Options{MaxSize: db.maxChunkSize, Overlap: db.chunkOverlap, SplitLongWords: true, Tokenizer: db.tokenizer}
+}
+
+// keyColumns returns the table columns copied into the metadata of row chunks.
+func (db *PersistentKB) keyColumns() []string {
+	if db.config == nil {
+		return nil
+	}
+	return db.config.KeyColumns
 }
 
-func chunkFile(fpath string, maxchunksize, chunkOverlap int) ([]string, error) {
-	content, err := fileToText(fpath)
+// TitleKey is the chunk metadata key holding the title of the document a
+// chunk was taken from, such as the title of an HTML page, a book or a
+// presentation.
+const TitleKey = "title"
+
+// AuthorKey and CreatedKey are the chunk metadata keys holding the author and
+// the creation date (RFC 3339, UTC) recorded in the document a chunk was
+// taken from.
+const (
+	AuthorKey  = "author"
+	CreatedKey = "created"
+)
+
+// ChapterKey is the chunk metadata key holding the chapter of a book a chunk
+// was taken from.
+const ChapterKey = "chapter"
+
+// SlideKey and SlideTitleKey are the chunk metadata keys holding the number
+// and the title of the presentation slide a chunk was taken from.
+const (
+	SlideKey      = "slide"
+	SlideTitleKey = "slide_title"
+)
+
+// SheetKey is the chunk metadata key holding the spreadsheet sheet a row
+// chunk was taken from.
+const SheetKey = "sheet"
+
+// chunkFile extracts the text of fpath and splits it into chunks. Markdown,
+// and office documents and HTML pages extracted as Markdown, are split along
+// their section structure and every chunk records its heading path; tables
+// are split into rows (see chunkTables), books into chapters (see
+// chunkBook), presentations into slides (see chunkDeck) and emails into
+// their body and attachments (see chunkEmail), subtitles and transcripts
+// into windows of cues (see chunkTranscript) and PDFs by page, along their
+// outline (see chunkPDF); source code is split along its declarations and
+// every chunk records its symbol and lines (see chunk.SplitCodeIntoChunks);
+// other formats are split as flat text. Document-level metadata is added to
+// every chunk.
+func chunkFile(fpath string, opts chunk.Options, keyColumns []string) ([]chunk.Chunk, error) {
+	if isTableFile(fpath) {
+		return chunkTables(fpath, opts, keyColumns)
+	}
+	switch strings.ToLower(filepath.Ext(fpath)) {
+	case ".pdf":
+		return chunkPDF(fpath, opts)
+	case ".epub":
+		return chunkBook(fpath, opts)
+	case ".pptx":
+		return chunkDeck(fpath, opts)
+	case ".eml":
+		return chunkEmail(fpath, opts, keyColumns)
+	case ".srt", ".vtt":
+		return chunkTranscript(fpath, opts)
+	}
+
+	content, docMetadata, err := extractFile(fpath)
 	if err != nil {
 		return nil, err
 	}
 
-	opts := chunk.Options{MaxSize: maxchunksize, Overlap: chunkOverlap, SplitLongWords: true}
-	chunks := chunk.SplitParagraphIntoChunksWithOptions(content, opts)
-	xlog.Info("Chunked file", "file", fpath, "content_length", len(content), "max_chunk_size", maxchunksize, "chunk_overlap", chunkOverlap, "chunk_count", len(chunks))
+	var chunks []chunk.Chunk
+	switch strings.ToLower(filepath.Ext(fpath)) {
+	case ".md", ".docx", ".odt", ".rtf", ".html", ".htm":
+		chunks = chunk.SplitMarkdownIntoChunks(content, opts)
+	}
+	if language := chunk.CodeLanguage(fpath); language != "" {
+		chunks = chunk.SplitCodeIntoChunks(content, language, opts)
+	}
+	if len(chunks) == 0 {
+		for _, c := range chunk.SplitParagraphIntoChunksWithOptions(content, opts) {
+			chunks = append(chunks, chunk.Chunk{Content: c})
+		}
+	}
+	if len(docMetadata) > 0 {
+		for i := range chunks {
+			if chunks[i].Metadata == nil {
+				chunks[i].Metadata = make(map[string]string, len(docMetadata))
+			}
+			for k, v := range docMetadata {
+				chunks[i].Metadata[k] = v
+			}
+		}
+	}
+	xlog.Info("Chunked file", "file", fpath, "content_length", len(content), "max_chunk_size", opts.MaxSize, "chunk_overlap", opts.Overlap, "tokens", opts.Tokenizer != nil, "chunk_count", len(chunks))
+	return chunks, nil
+}
+
+// chunkPDF splits the text of a PDF, recording the pages every chunk was
+// taken from, the document title, author and creation date, and the heading
+// path of the outline section the chunk starts in.
+func chunkPDF(fpath string, opts chunk.Options) ([]chunk.Chunk, error) {
+	doc, err := extractPDF(fpath)
+	if err != nil {
+		return nil, err
+	}
+	docMetadata := doc.metadata()
+	chunks := chunk.SplitPagesIntoChunks(doc.Pages, opts)
+	for i := range chunks {
+		for k, v := range docMetadata {
+			chunks[i].Metadata[k] = v
+		}
+		page, _ := strconv.Atoi(chunks[i].Metadata[chunk.PageStartKey])
+		if path := doc.sectionAt(page); len(path) > 0 {
+			chunks[i].Metadata[chunk.HeadingPathKey] = strings.Join(path, " > ")
+		}
+	}
+	xlog.Info("Chunked PDF", "file", fpath, "pages", len(doc.Pages), "outline", len(doc.Outline), "max_chunk_size", opts.MaxSize, "chunk_overlap", opts.Overlap, "tokens", opts.Tokenizer != nil, "chunk_count", len(chunks))
 	return chunks, nil
 }
 
+// chunkBook splits every chapter of an EPUB book along its section structure.
+// Chunks record the book title, their chapter and their heading path within
+// the chapter.
+func chunkBook(fpath string, opts chunk.Options) ([]chunk.Chunk, error) {
+	book, err := extract.EPUB(fpath)
+	if err != nil {
+		return nil, err
+	}
+
+	var chunks []chunk.Chunk
+	for _, chapter := range book.Chapters {
+		for _, c := range chunk.SplitMarkdownIntoChunks(chapter.Text, opts) {
+			if c.Metadata == nil {
+				c.Metadata = map[string]string{}
+			}
+			if book.Title != "" {
+				c.Metadata[TitleKey] = book.Title
+			}
+			if chapter.Title != "" {
+				c.Metadata[ChapterKey] = chapter.Title
+			}
+			chunks = append(chunks, c)
+		}
+	}
+	xlog.Info("Chunked book", "file", fpath, "title", book.Title, "chapters", len(book.Chapters), "max_chunk_size", opts.MaxSize, "chunk_overlap", opts.Overlap, "chunk_count", len(chunks))
+	return chunks, nil
+}
+
+// chunkDeck splits every slide of a PowerPoint presentation, with its speaker
+// notes, on its own. Chunks record the presentation title and the number and
+// title of their slide.
+func chunkDeck(fpath string, opts chunk.Options) ([]chunk.Chunk, error) {
+	deck, err := extract.PPTX(fpath)
+	if err != nil {
+		return nil, err
+	}
+
+	var chunks []chunk.Chunk
+	for _, slide := range deck.Slides {
+		for _, c := range chunk.SplitMarkdownIntoChunks(slide.Markdown(), opts) {
+			if c.Metadata == nil {
+				c.Metadata = map[string]string{}
+			}
+			if deck.Title != "" {
+				c.Metadata[TitleKey] = deck.Title
+			}
+			c.Metadata[SlideKey] = strconv.Itoa(slide.Number)
+			if slide.Title != "" {
+				c.Metadata[SlideTitleKey] = slide.Title
+			}
+			chunks = append(chunks, c)
+		}
+	}
+	xlog.Info("Chunked presentation", "file", fpath, "title", deck.Title, "slides", len(deck.Slides), "max_chunk_size", opts.MaxSize, "chunk_overlap", opts.Overlap, "chunk_count", len(chunks))
+	return chunks, nil
+}
+
+// readCues returns the cues of an SRT or WebVTT file.
+func readCues(fpath string) ([]chunk.Cue, error) {
+	read := extract.SRT
+	if strings.ToLower(filepath.Ext(fpath)) == ".vtt" {
+		read = extract.WebVTT
+	}
+	cues, err := read(fpath)
+	if err != nil {
+		return nil, err
+	}
+	converted := make([]chunk.Cue, len(cues))
+	for i, cue := range cues {
+		converted[i] = chunk.Cue{Start: cue.Start, End: cue.End, Text: cue.Text}
+	}
+	return converted, nil
+}
+
+// transcriptText returns the text of a subtitle or transcript file, one cue
+// per line prefixed with its start time ("[00:12:31] ...").
+func transcriptText(cues []chunk.Cue) string {
+	lines := make([]string, len(cues))
+	for i, cue := range cues {
+		lines[i] = "[" + chunk.FormatTimestamp(cue.Start) + "] " + cue.Text
+	}
+	return strings.Join(lines, "\n")
+}
+
+// chunkTranscript groups the cues of a subtitle or transcript file into
+// chunks recording the time range they cover.
+func chunkTranscript(fpath string, opts chunk.Options) ([]chunk.Chunk, error) {
+	cues, err := readCues(fpath)
+	if err != nil {
+		return nil, err
+	}
+	chunks := chunk.SplitCuesIntoChunks(cues, opts)
+	xlog.Info("Chunked transcript", "file", fpath, "cues", len(cues), "max_chunk_size", opts.MaxSize, "chunk_overlap", opts.Overlap, "tokens", opts.Tokenizer != nil, "chunk_count", len(chunks))
+	return chunks, nil
+}
+
+// chunkTables splits the sheets of a CSV, TSV or XLSX file into chunks of
+// whole rows under a repeated header. Key columns, other than reserved
+// metadata keys, are copied into the metadata of each row's chunk.
+func chunkTables(fpath string, opts chunk.Options, keyColumns []string) ([]chunk.Chunk, error) {
+	tables, err := readTables(fpath)
+	if err != nil {
+		return nil, err
+	}
+	keyColumns = slices.DeleteFunc(slices.Clone(keyColumns), func(column string) bool {
+		return slices.Contains(ReservedMetadataKeys, column)
+	})
+
+	var chunks []chunk.Chunk
+	rows := 0
+	for _, table := range tables {
+		rows += len(table.Rows)
+		for _, c := range chunk.SplitTableIntoChunks(table.Header, table.Rows, keyColumns, opts) {
+			if table.Name != "" {
+				c.Metadata[SheetKey] = table.Name
+			}
+			chunks = append(chunks, c)
+		}
+	}
+	xlog.Info("Chunked table file", "file", fpath, "sheets", len(tables), "rows", rows, "key_columns", keyColumns, "max_chunk_size", opts.MaxSize, "chunk_count", len(chunks))
+	return chunks, nil
+}
+
+// isTableFile reports whether fpath holds tabular data chunked by rows.
+func isTableFile(fpath string) bool {
+	switch strings.ToLower(filepath.Ext(fpath)) {
+	case ".csv", ".tsv", ".xlsx":
+		return true
+	}
+	return false
+}
+
+func readTables(fpath string) ([]extract.Table, error) {
+	switch strings.ToLower(filepath.Ext(fpath)) {
+	case ".csv":
+		table, err := extract.CSV(fpath, ',')
+		return []extract.Table{table}, err
+	case ".tsv":
+		table, err := extract.CSV(fpath, '\t')
+		return []extract.Table{table}, err
+	case ".xlsx":
+		return extract.XLSX(fpath)
+	}
+	return nil, fmt.Errorf("unsupported table file: %s", fpath)
+}
+
 // migrateToUUIDLayout migrates flat files in assetDir (files not in UUID
 // subdirectories) to UUID subdirectory layout. This is a one-time migration.
 func (db *PersistentKB) migrateToUUIDLayout() error {
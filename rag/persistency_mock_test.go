@@ -1,21 +1,130 @@
 package rag_test
 
 import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	. "github.com/mudler/localrecall/rag"
 	"github.com/mudler/localrecall/rag/engine"
+	"github.com/mudler/localrecall/rag/types"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
 
+// writePDF writes a PDF with one page per entry of pages, each holding its
+// text on a single line.
+func writePDF(path string, pages []string) {
+	writeOutlinedPDF(path, pages, "", nil)
+}
+
+// pdfBookmark is an outline entry pointing at a page (0-based) of a PDF
+// written by writeOutlinedPDF.
+type pdfBookmark struct {
+	title    string
+	page     int
+	children []pdfBookmark
+}
+
+// writeOutlinedPDF writes a PDF like writePDF with the given document
+// information dictionary entries (such as "/Title (Manual)") and outline.
+func writeOutlinedPDF(path string, pages []string, info string, outline []pdfBookmark) {
+	var objects []string
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+2*i)
+	}
+	infoNum, outlinesNum := 4+2*len(pages), 5+2*len(pages)
+	catalog := "<< /Type /Catalog /Pages 2 0 R >>"
+	if len(outline) > 0 {
+		catalog = fmt.Sprintf("<< /Type /Catalog /Pages 2 0 R /Outlines %d 0 R /PageMode /UseOutlines >>", outlinesNum)
+	}
+	objects = append(objects,
+		catalog,
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	)
+	for i, text := range pages {
+		stream := fmt.Sprintf("BT /F1 12 Tf 72 720 Td (%s) Tj ET", text)
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", 5+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(stream), stream),
+		)
+	}
+	objects = append(objects, fmt.Sprintf("<< %s >>", info))
+
+	if len(outline) > 0 {
+		bookmarks := map[int]string{}
+		next := outlinesNum + 1
+		var add func(items []pdfBookmark, parent int) (int, int)
+		add = func(items []pdfBookmark, parent int) (int, int) {
+			nums := make([]int, len(items))
+			for i := range items {
+				nums[i] = next
+				next++
+			}
+			for i, item := range items {
+				fields := fmt.Sprintf("/Title (%s) /Parent %d 0 R /Dest [%d 0 R /Fit]", item.title, parent, 4+2*item.page)
+				if i > 0 {
+					fields += fmt.Sprintf(" /Prev %d 0 R", nums[i-1])
+				}
+				if i < len(items)-1 {
+					fields += fmt.Sprintf(" /Next %d 0 R", nums[i+1])
+				}
+				if len(item.children) > 0 {
+					first, last := add(item.children, nums[i])
+					fields += fmt.Sprintf(" /First %d 0 R /Last %d 0 R /Count %d", first, last, len(item.children))
+				}
+				bookmarks[nums[i]] = "<< " + fields + " >>"
+			}
+			return nums[0], nums[len(nums)-1]
+		}
+		first, last := add(outline, outlinesNum)
+		objects = append(objects, fmt.Sprintf("<< /Type /Outlines /First %d 0 R /Last %d 0 R /Count %d >>", first, last, len(outline)))
+		for num := outlinesNum + 1; num < next; num++ {
+			objects = append(objects, bookmarks[num])
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, infoNum, xref)
+	Expect(os.WriteFile(path, []byte(b.String()), 0644)).To(Succeed())
+}
+
 // newMockKB creates a PersistentKB backed by a MockEngine.
 // It writes a minimal state file so the constructor skips the embedding check.
 func newMockKB(stateFile, assetDir string, eng *engine.MockEngine) (*PersistentKB, error) {
 	return NewPersistentCollectionKB(stateFile, assetDir, eng, 1000, 0, nil, "")
 }
 
+// failingEngine is a MockEngine whose StoreDocuments fails while fail is set.
+type failingEngine struct {
+	*engine.MockEngine
+	fail bool
+}
+
+func (f *failingEngine) StoreDocuments(s []string, metadata map[string]string) ([]engine.Result, error) {
+	if f.fail {
+		return nil, errors.New("embedding service unavailable")
+	}
+	return f.MockEngine.StoreDocuments(s, metadata)
+}
+
 var _ = Describe("PersistentKB with MockEngine", func() {
 	var (
 		tempDir   string
@@ -47,6 +156,23 @@ var _ = Describe("PersistentKB with MockEngine", func() {
 		return p
 	}
 
+	// Helper: create a temp zip-based file with the given members.
+	createZipFile := func(name string, members map[string]string) string {
+		p := filepath.Join(tempDir, name)
+		out, err := os.Create(p)
+		Expect(err).ToNot(HaveOccurred())
+		defer out.Close()
+		zw := zip.NewWriter(out)
+		for member, content := range members {
+			w, err := zw.Create(member)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = w.Write([]byte(content))
+			Expect(err).ToNot(HaveOccurred())
+		}
+		Expect(zw.Close()).To(Succeed())
+		return p
+	}
+
 	Describe("NewPersistentCollectionKB", func() {
 		It("creates a new KB and state file", func() {
 			kb, err := newMockKB(stateFile, assetDir, eng)
@@ -148,6 +274,396 @@ var _ = Describe("PersistentKB with MockEngine", func() {
 		})
 	})
 
+	Describe("Markdown entries", func() {
+		It("stores the heading path of each section as chunk metadata", func() {
+			kb, err := newMockKB(stateFile, assetDir, eng)
+			Expect(err).ToNot(HaveOccurred())
+
+			f := createTxtFile("guide.md", "# Install\n\n## Docker\n\nrun the image\n\n# Usage\n\nopen the UI\n")
+			_, err = kb.Store(f, map[string]string{})
+			Expect(err).ToNot(HaveOccurred())
+
+			results, err := kb.GetEntryContent("guide.md")
+			Expect(err).ToNot(HaveOccurred())
+			paths := map[string]string{}
+			for _, r := range results {
+				Expect(r.Metadata["file_name"]).To(Equal("guide.md"))
+				paths[r.Metadata["heading_path"]] = r.Content
+			}
+			Expect(paths).To(HaveLen(2))
+			Expect(paths["Install > Docker"]).To(ContainSubstring("run the image"))
+			Expect(paths["Usage"]).To(ContainSubstring("open the UI"))
+		})
+
+		It("splits office documents along their headings", func() {
+			kb, err := newMockKB(stateFile, assetDir, eng)
+			Expect(err).ToNot(HaveOccurred())
+
+			f := createTxtFile("guide.rtf", `{\rtf1\ansi{\stylesheet{\s1 heading 1;}}\pard\s1 Install\par\pard run the image\par\pard\s1 Usage\par\pard open the UI\par}`)
+			_, err = kb.Store(f, map[string]string{})
+			Expect(err).ToNot(HaveOccurred())
+
+			results, err := kb.GetEntryContent("guide.rtf")
+			Expect(err).ToNot(HaveOccurred())
+			paths := map[string]string{}
+			for _, r := range results {
+				paths[r.Metadata["heading_path"]] = r.Content
+			}
+			Expect(paths).To(HaveLen(2))
+			Expect(paths["Install"]).To(ContainSubstring("run the image"))
+			Expect(paths["Usage"]).To(ContainSubstring("open the UI"))
+		})
+
+		It("chunks tables by rows with key columns in metadata", func() {
+			kb, err := newMockKB(stateFile, assetDir, eng)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(kb.SetConfig(&types.CollectionConfig{KeyColumns: []string{"SKU", "source"}})).To(Succeed())
+
+			f := createTxtFile("catalog.csv", "SKU,Name,source\nA-1,Kettle,web\nA-2,Toaster,shop\n")
+			_, err = kb.Store(f, map[string]string{})
+			Expect(err).ToNot(HaveOccurred())
+
+			results, err := kb.GetEntryContent("catalog.csv")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results).To(HaveLen(2))
+			bySKU := map[string]string{}
+			for _, r := range results {
+				Expect(r.Content).To(HavePrefix("| SKU | Name | source |"))
+				Expect(r.Metadata["source"]).To(HaveSuffix("catalog.csv"))
+				bySKU[r.Metadata["SKU"]] = r.Metadata["row_start"]
+			}
+			Expect(bySKU).To(Equal(map[string]string{"A-1": "1", "A-2": "2"}))
+
+			content, _, err := kb.GetEntryFileContent("catalog.csv")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(content).To(ContainSubstring("| A-2 | Toaster | shop |"))
+		})
+
+		It("records the title of HTML pages on every chunk", func() {
+			kb, err := newMockKB(stateFile, assetDir, eng)
+			Expect(err).ToNot(HaveOccurred())
+
+			f := createTxtFile("page.html", `<html><head><title>Install guide</title><script>track()</script></head><body>
+				<nav><a href="/">Home</a></nav>
+				<main><h2>Docker</h2><p>run the image</p></main>
+			</body></html>`)
+			_, err = kb.Store(f, map[string]string{})
+			Expect(err).ToNot(HaveOccurred())
+
+			results, err := kb.GetEntryContent("page.html")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results).To(HaveLen(1))
+			Expect(results[0].Content).To(ContainSubstring("run the image"))
+			Expect(results[0].Content).ToNot(ContainSubstring("Home"))
+			Expect(results[0].Metadata).To(HaveKeyWithValue("title", "Install guide"))
+			Expect(results[0].Metadata).To(HaveKeyWithValue("heading_path", "Docker"))
+		})
+
+		It("records the book title and chapter of EPUB chunks", func() {
+			kb, err := newMockKB(stateFile, assetDir, eng)
+			Expect(err).ToNot(HaveOccurred())
+
+			f := createZipFile("book.epub", map[string]string{
+				"META-INF/container.xml": `<container><rootfiles><rootfile full-path="content.opf"/></rootfiles></container>`,
+				"content.opf": `<package><metadata><title>Field Guide</title></metadata>
+					<manifest><item id="c1" href="c1.xhtml" media-type="application/xhtml+xml"/></manifest>
+					<spine><itemref idref="c1"/></spine></package>`,
+				"c1.xhtml": `<html><body><h1>Birds</h1><h2>Owls</h2><p>Owls hunt at night.</p></body></html>`,
+			})
+
+			_, err = kb.Store(f, map[string]string{})
+			Expect(err).ToNot(HaveOccurred())
+
+			results, err := kb.GetEntryContent("book.epub")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results).To(HaveLen(1))
+			Expect(results[0].Content).To(ContainSubstring("Owls hunt at night."))
+			Expect(results[0].Metadata).To(HaveKeyWithValue("title", "Field Guide"))
+			Expect(results[0].Metadata).To(HaveKeyWithValue("chapter", "Birds"))
+			Expect(results[0].Metadata).To(HaveKeyWithValue("heading_path", "Birds > Owls"))
+		})
+
+		It("records the slide number and title of presentation chunks", func() {
+			kb, err := newMockKB(stateFile, assetDir, eng)
+			Expect(err).ToNot(HaveOccurred())
+
+			slide := func(title, body string) string {
+				return `<p:sld xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main" xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main"><p:cSld><p:spTree>
+					<p:sp><p:nvSpPr><p:nvPr><p:ph type="title"/></p:nvPr></p:nvSpPr><p:txBody><a:p><a:r><a:t>` + title + `</a:t></a:r></a:p></p:txBody></p:sp>
+					<p:sp><p:nvSpPr><p:nvPr><p:ph idx="1"/></p:nvPr></p:nvSpPr><p:txBody><a:p><a:r><a:t>` + body + `</a:t></a:r></a:p></p:txBody></p:sp>
+					</p:spTree></p:cSld></p:sld>`
+			}
+			f := createZipFile("deck.pptx", map[string]string{
+				"ppt/presentation.xml": `<p:presentation xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+					<p:sldIdLst><p:sldId r:id="rId1"/><p:sldId r:id="rId2"/></p:sldIdLst></p:presentation>`,
+				"ppt/_rels/presentation.xml.rels": `<Relationships><Relationship Id="rId1" Target="slides/slide1.xml"/><Relationship Id="rId2" Target="slides/slide2.xml"/></Relationships>`,
+				"ppt/slides/slide1.xml":           slide("Overview", "two services"),
+				"ppt/slides/slide2.xml":           slide("Storage", "postgres replicas"),
+			})
+			_, err = kb.Store(f, map[string]string{})
+			Expect(err).ToNot(HaveOccurred())
+
+			results, err := kb.GetEntryContent("deck.pptx")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results).To(HaveLen(2))
+			slides := map[string]string{}
+			for _, r := range results {
+				slides[r.Metadata["slide"]] = r.Metadata["slide_title"]
+				if r.Metadata["slide"] == "2" {
+					Expect(r.Content).To(ContainSubstring("postgres replicas"))
+				}
+			}
+			Expect(slides).To(Equal(map[string]string{"1": "Overview", "2": "Storage"}))
+		})
+	})
+
+	Describe("PDF entries", func() {
+		It("records the pages of every chunk and returns the text by page", func() {
+			kb, err := newMockKB(stateFile, assetDir, eng)
+			Expect(err).ToNot(HaveOccurred())
+			kb.SetChunkSize(40, 0)
+
+			f := filepath.Join(tempDir, "manual.pdf")
+			writePDF(f, []string{"Installing the server takes a minute", "", "Configure the collections next"})
+			_, err = kb.Store(f, map[string]string{})
+			Expect(err).ToNot(HaveOccurred())
+
+			results, err := kb.GetEntryContent("manual.pdf")
+			Expect(err).ToNot(HaveOccurred())
+			pages := map[string]string{}
+			for _, r := range results {
+				pages[r.Content] = r.Metadata["page_start"] + "-" + r.Metadata["page_end"]
+			}
+			Expect(pages).To(Equal(map[string]string{
+				"Installing the server takes a minute": "1-1",
+				"Configure the collections next":       "3-3",
+			}))
+
+			texts, chunkCount, err := kb.GetEntryPages("manual.pdf")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(chunkCount).To(Equal(2))
+			Expect(texts).To(HaveLen(3))
+			Expect(texts[0]).To(ContainSubstring("Installing the server"))
+			Expect(strings.TrimSpace(texts[1])).To(BeEmpty())
+			Expect(texts[2]).To(ContainSubstring("Configure the collections"))
+
+			_, _, err = kb.GetEntryPages("missing.pdf")
+			Expect(err).To(HaveOccurred())
+			txt := createTxtFile("notes.txt", "plain text")
+			_, err = kb.Store(txt, map[string]string{})
+			Expect(err).ToNot(HaveOccurred())
+			_, _, err = kb.GetEntryPages("notes.txt")
+			Expect(errors.Is(err, ErrNotPaged)).To(BeTrue())
+		})
+
+		It("records the document information and outline section of every chunk", func() {
+			kb, err := newMockKB(stateFile, assetDir, eng)
+			Expect(err).ToNot(HaveOccurred())
+			kb.SetChunkSize(40, 0)
+
+			f := filepath.Join(tempDir, "guide.pdf")
+			writeOutlinedPDF(f,
+				[]string{"Read this first before anything", "Installing the server takes a minute", "Configure the collections next"},
+				"/Title (Operator  guide) /Author (Jane Doe) /CreationDate (D:20240102030405+02'00')",
+				[]pdfBookmark{
+					{title: "Setup", page: 1, children: []pdfBookmark{
+						{title: "Install", page: 1},
+						{title: "Configure", page: 2},
+					}},
+				})
+			_, err = kb.Store(f, map[string]string{})
+			Expect(err).ToNot(HaveOccurred())
+
+			results, err := kb.GetEntryContent("guide.pdf")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results).To(HaveLen(3))
+			sections := map[string]string{}
+			for _, r := range results {
+				Expect(r.Metadata).To(HaveKeyWithValue("title", "Operator guide"))
+				Expect(r.Metadata).To(HaveKeyWithValue("author", "Jane Doe"))
+				Expect(r.Metadata).To(HaveKeyWithValue("created", "2024-01-02T01:04:05Z"))
+				sections[r.Content] = r.Metadata["heading_path"]
+			}
+			Expect(sections).To(Equal(map[string]string{
+				"Read this first before anything":      "",
+				"Installing the server takes a minute": "Setup > Install",
+				"Configure the collections next":       "Setup > Configure",
+			}))
+
+			content, _, err := kb.GetEntryFileContent("guide.pdf")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(content).To(ContainSubstring("Installing the server"))
+		})
+
+		It("keeps the metadata given on upload over the document information", func() {
+			kb, err := newMockKB(stateFile, assetDir, eng)
+			Expect(err).ToNot(HaveOccurred())
+
+			f := filepath.Join(tempDir, "runbook.pdf")
+			writeOutlinedPDF(f, []string{"Restart the server"}, "/Title (Draft) /Author (Jane Doe)", nil)
+			_, err = kb.Store(f, map[string]string{"title": "On-call runbook"})
+			Expect(err).ToNot(HaveOccurred())
+
+			for _, reindex := range []bool{false, true} {
+				if reindex {
+					Expect(kb.Repopulate()).To(Succeed())
+				}
+				results, err := kb.GetEntryContent("runbook.pdf")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(results).To(HaveLen(1))
+				Expect(results[0].Metadata).To(HaveKeyWithValue("title", "On-call runbook"))
+				Expect(results[0].Metadata).To(HaveKeyWithValue("author", "Jane Doe"))
+			}
+		})
+	})
+
+	Describe("Transcript entries", func() {
+		It("records the time range of every chunk", func() {
+			kb, err := newMockKB(stateFile, assetDir, eng)
+			Expect(err).ToNot(HaveOccurred())
+			kb.SetChunkSize(40, 0)
+
+			f := createTxtFile("recording.vtt", "WEBVTT\n\n"+
+				"00:00:01.000 --> 00:00:04.000\n<v Alice>Welcome everyone\n\n"+
+				"00:00:05.000 --> 00:00:09.000\n<v Bob>Thanks\n\n"+
+				"00:12:31.000 --> 00:12:40.000\n<v Alice>Search ships in March\n")
+			_, err = kb.Store(f, map[string]string{})
+			Expect(err).ToNot(HaveOccurred())
+
+			results, err := kb.GetEntryContent("recording.vtt")
+			Expect(err).ToNot(HaveOccurred())
+			ranges := map[string]string{}
+			for _, r := range results {
+				ranges[r.Content] = r.Metadata["time_start"] + "-" + r.Metadata["time_end"]
+			}
+			Expect(ranges).To(Equal(map[string]string{
+				"Alice: Welcome everyone\nBob: Thanks": "00:00:01-00:00:09",
+				"Alice: Search ships in March":         "00:12:31-00:12:40",
+			}))
+
+			content, _, err := kb.GetEntryFileContent("recording.vtt")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(content).To(Equal("[00:00:01] Alice: Welcome everyone\n[00:00:05] Bob: Thanks\n[00:12:31] Alice: Search ships in March"))
+		})
+	})
+
+	Describe("Email entries", func() {
+		It("records the headers of the message and chunks its attachments", func() {
+			kb, err := newMockKB(stateFile, assetDir, eng)
+			Expect(err).ToNot(HaveOccurred())
+
+			f := createTxtFile("report.eml", "From: Alice <alice@example.com>\r\n"+
+				"To: team@example.com\r\n"+
+				"Subject: Weekly report\r\n"+
+				"Message-ID: <report-1@example.com>\r\n"+
+				"Content-Type: multipart/mixed; boundary=b\r\n\r\n"+
+				"--b\r\nContent-Type: text/plain\r\n\r\nSee the attached notes.\r\n"+
+				"--b\r\nContent-Type: text/markdown\r\nContent-Disposition: attachment; filename=notes.md\r\n\r\n# Outages\n\nNone this week.\r\n"+
+				"--b\r\nContent-Type: image/png\r\nContent-Disposition: attachment; filename=chart.png\r\n\r\nPNG\r\n"+
+				"--b--\r\n")
+			_, err = kb.Store(f, map[string]string{})
+			Expect(err).ToNot(HaveOccurred())
+
+			results, err := kb.GetEntryContent("report.eml")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results).To(HaveLen(2))
+			byAttachment := map[string]string{}
+			for _, r := range results {
+				Expect(r.Metadata).To(HaveKeyWithValue("from", "Alice <alice@example.com>"))
+				Expect(r.Metadata).To(HaveKeyWithValue("subject", "Weekly report"))
+				Expect(r.Metadata).To(HaveKeyWithValue("message_id", "report-1@example.com"))
+				byAttachment[r.Metadata["attachment"]] = r.Content
+			}
+			Expect(byAttachment[""]).To(ContainSubstring("Subject: Weekly report"))
+			Expect(byAttachment[""]).To(ContainSubstring("See the attached notes."))
+			Expect(byAttachment["notes.md"]).To(ContainSubstring("None this week."))
+
+			content, _, err := kb.GetEntryFileContent("report.eml")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(content).To(ContainSubstring("See the attached notes."))
+			Expect(content).To(ContainSubstring("Attachment: notes.md"))
+		})
+	})
+
+	Describe("Entry metadata", func() {
+		It("merges upload metadata into every chunk and persists it", func() {
+			kb, err := newMockKB(stateFile, assetDir, eng)
+			Expect(err).ToNot(HaveOccurred())
+			kb.SetChunkSize(20, 0)
+
+			f := createTxtFile("report.txt", strings.Repeat("quarterly numbers look fine ", 10))
+			_, err = kb.Store(f, map[string]string{"team": "finance", "year": "2024"})
+			Expect(err).ToNot(HaveOccurred())
+
+			results, err := kb.GetEntryContent("report.txt")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(results)).To(BeNumerically(">", 1))
+			for _, r := range results {
+				Expect(r.Metadata).To(HaveKeyWithValue("team", "finance"))
+				Expect(r.Metadata).To(HaveKeyWithValue("year", "2024"))
+			}
+
+			kb2, err := newMockKB(stateFile, assetDir, eng)
+			Expect(err).ToNot(HaveOccurred())
+			md, err := kb2.GetEntryMetadata("report.txt")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(md).To(Equal(map[string]string{"team": "finance", "year": "2024"}))
+
+			Expect(kb2.Repopulate()).To(Succeed())
+			results, err = kb2.GetEntryContent("report.txt")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results[0].Metadata).To(HaveKeyWithValue("team", "finance"))
+		})
+
+		It("updates and removes keys across all chunks", func() {
+			kb, err := newMockKB(stateFile, assetDir, eng)
+			Expect(err).ToNot(HaveOccurred())
+			kb.SetChunkSize(20, 0)
+
+			f := createTxtFile("report.txt", strings.Repeat("quarterly numbers look fine ", 10))
+			_, err = kb.Store(f, map[string]string{"team": "finance", "year": "2024"})
+			Expect(err).ToNot(HaveOccurred())
+
+			md, err := kb.UpdateEntryMetadata("report.txt", map[string]string{"team": "sales", "status": "final"}, []string{"year"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(md).To(Equal(map[string]string{"team": "sales", "status": "final"}))
+
+			results, err := kb.GetEntryContent("report.txt")
+			Expect(err).ToNot(HaveOccurred())
+			for _, r := range results {
+				Expect(r.Metadata).To(HaveKeyWithValue("team", "sales"))
+				Expect(r.Metadata).To(HaveKeyWithValue("status", "final"))
+				Expect(r.Metadata).ToNot(HaveKey("year"))
+				Expect(r.Metadata).To(HaveKeyWithValue("file_name", "report.txt"))
+			}
+
+			// The update is recorded, so re-indexing keeps it.
+			Expect(kb.Repopulate()).To(Succeed())
+			results, err = kb.GetEntryContent("report.txt")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results[0].Metadata).To(HaveKeyWithValue("team", "sales"))
+			Expect(results[0].Metadata).ToNot(HaveKey("year"))
+		})
+
+		It("rejects reserved keys and unknown entries", func() {
+			kb, err := newMockKB(stateFile, assetDir, eng)
+			Expect(err).ToNot(HaveOccurred())
+
+			f := createTxtFile("report.txt", "some text")
+			_, err = kb.Store(f, map[string]string{})
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = kb.UpdateEntryMetadata("report.txt", map[string]string{"source": "x"}, nil)
+			Expect(err).To(MatchError(ContainSubstring("reserved")))
+			_, err = kb.UpdateEntryMetadata("report.txt", nil, []string{"file_name"})
+			Expect(err).To(MatchError(ContainSubstring("reserved")))
+			_, err = kb.UpdateEntryMetadata("missing.txt", map[string]string{"a": "b"}, nil)
+			Expect(err).To(MatchError(ContainSubstring("entry not found")))
+			_, err = kb.GetEntryMetadata("missing.txt")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
 	Describe("GetEntryFileContent", func() {
 		It("returns error for missing entry", func() {
 			kb, err := newMockKB(stateFile, assetDir, eng)
@@ -263,6 +779,147 @@ var _ = Describe("PersistentKB with MockEngine", func() {
 		})
 	})
 
+	Describe("Documents", func() {
+		It("stores raw text as an entry with its metadata", func() {
+			kb, err := newMockKB(stateFile, assetDir, eng)
+			Expect(err).ToNot(HaveOccurred())
+
+			key, err := kb.StoreDocument(types.Document{ID: "memory-1", Content: "the user prefers dark mode", Metadata: map[string]string{"agent": "helper"}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(key).To(HaveSuffix("/memory-1.txt"))
+			Expect(kb.EntryExists("memory-1")).To(BeTrue())
+
+			results, err := kb.GetEntryContent("memory-1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results).To(HaveLen(1))
+			Expect(results[0].Content).To(Equal("the user prefers dark mode"))
+			Expect(results[0].Metadata).To(HaveKeyWithValue("agent", "helper"))
+			Expect(results[0].Metadata).To(HaveKeyWithValue("type", "document"))
+			Expect(results[0].Metadata).To(HaveKeyWithValue(DocumentIDKey, "memory-1"))
+
+			// Metadata survives a reload and repopulation.
+			kb2, err := newMockKB(stateFile, assetDir, eng)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(kb2.Repopulate()).To(Succeed())
+			results, err = kb2.GetEntryContent("memory-1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results[0].Metadata).To(HaveKeyWithValue("agent", "helper"))
+		})
+
+		It("decodes numbers and booleans in JSON metadata as text", func() {
+			var doc types.Document
+			Expect(json.Unmarshal([]byte(`{"content":"x","metadata":{"page":3,"score":0.5,"draft":false,"agent":"helper"}}`), &doc)).To(Succeed())
+			Expect(doc.Metadata).To(Equal(types.Metadata{"page": "3", "score": "0.5", "draft": "false", "agent": "helper"}))
+
+			err := json.Unmarshal([]byte(`{"content":"x","metadata":{"tags":["a","b"]}}`), &doc)
+			Expect(err).To(MatchError(ContainSubstring(`metadata key "tags"`)))
+			err = json.Unmarshal([]byte(`{"content":"x","metadata":{"owner":null}}`), &doc)
+			Expect(err).To(MatchError(ContainSubstring(`metadata key "owner"`)))
+		})
+
+		It("generates IDs and replaces documents stored under the same ID", func() {
+			kb, err := newMockKB(stateFile, assetDir, eng)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = kb.StoreDocument(types.Document{Content: "anonymous note"})
+			Expect(err).ToNot(HaveOccurred())
+			_, err = kb.StoreDocument(types.Document{ID: "note", Content: "first version"})
+			Expect(err).ToNot(HaveOccurred())
+			_, err = kb.StoreDocument(types.Document{ID: "note", Content: "second version"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(kb.ListDocuments()).To(HaveLen(2))
+
+			content, _, err := kb.GetEntryFileContent("note")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(content).To(Equal("second version"))
+
+			Expect(kb.RemoveEntry("note")).To(Succeed())
+			Expect(kb.ListDocuments()).To(HaveLen(1))
+		})
+
+		It("keeps the document replaced when its new version fails to store", func() {
+			failing := &failingEngine{MockEngine: eng}
+			kb, err := NewPersistentCollectionKB(stateFile, assetDir, failing, 1000, 0, nil, "")
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = kb.StoreDocument(types.Document{ID: "note", Content: "first version"})
+			Expect(err).ToNot(HaveOccurred())
+			failing.fail = true
+			_, err = kb.StoreDocument(types.Document{ID: "note", Content: "second version"})
+			Expect(err).To(HaveOccurred())
+
+			Expect(kb.ListDocuments()).To(HaveLen(1))
+			content, _, err := kb.GetEntryFileContent("note")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(content).To(Equal("first version"))
+			results, err := kb.GetEntryContent("note")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results).To(HaveLen(1))
+			Expect(results[0].Content).To(ContainSubstring("first version"))
+		})
+
+		It("rejects empty content, bad IDs and clashes with uploaded files", func() {
+			kb, err := newMockKB(stateFile, assetDir, eng)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = kb.StoreDocument(types.Document{Content: "  "})
+			Expect(err).To(HaveOccurred())
+			_, err = kb.StoreDocument(types.Document{ID: "../escape", Content: "x"})
+			Expect(err).To(HaveOccurred())
+
+			_, err = kb.Store(createTxtFile("upload.txt", "uploaded"), map[string]string{})
+			Expect(err).ToNot(HaveOccurred())
+			_, err = kb.StoreDocument(types.Document{ID: "upload", Content: "x"})
+			Expect(err).To(HaveOccurred())
+
+			Expect(ValidateMetadata(map[string]string{"source": "x"})).ToNot(Succeed())
+			Expect(ValidateMetadata(map[string]string{"topic": "x"})).To(Succeed())
+		})
+	})
+
+	Describe("Drop", func() {
+		It("removes engine storage, assets and state", func() {
+			kb, err := newMockKB(stateFile, assetDir, eng)
+			Expect(err).ToNot(HaveOccurred())
+
+			f := createTxtFile("drop.txt", "to be dropped")
+			_, err = kb.Store(f, map[string]string{})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(kb.Drop()).To(Succeed())
+			Expect(eng.Dropped()).To(BeTrue())
+			Expect(eng.Count()).To(Equal(0))
+			Expect(assetDir).ToNot(BeADirectory())
+			Expect(stateFile).ToNot(BeAnExistingFile())
+
+			_, err = kb.Store(f, map[string]string{})
+			Expect(err).To(HaveOccurred())
+			Expect(assetDir).ToNot(BeADirectory())
+		})
+	})
+
+	Describe("Close", func() {
+		It("keeps the storage but refuses further writes", func() {
+			kb, err := newMockKB(stateFile, assetDir, eng)
+			Expect(err).ToNot(HaveOccurred())
+
+			f := createTxtFile("close.txt", "kept after close")
+			_, err = kb.Store(f, map[string]string{})
+			Expect(err).ToNot(HaveOccurred())
+			state, err := os.ReadFile(stateFile)
+			Expect(err).ToNot(HaveOccurred())
+
+			kb.Close()
+			Expect(eng.Dropped()).To(BeFalse())
+			Expect(kb.ListDocuments()).To(HaveLen(1))
+
+			_, err = kb.Store(createTxtFile("late.txt", "too late"), map[string]string{})
+			Expect(err).To(HaveOccurred())
+			Expect(kb.SetConfig(&types.CollectionConfig{ChunkSize: 10})).ToNot(Succeed())
+			Expect(os.ReadFile(stateFile)).To(Equal(state))
+		})
+	})
+
 	Describe("Repopulate", func() {
 		It("re-stores all entries in the engine", func() {
 			kb, err := newMockKB(stateFile, assetDir, eng)
@@ -347,5 +1004,24 @@ var _ = Describe("PersistentKB with MockEngine", func() {
 			Expect(err).ToNot(HaveOccurred())
 			Expect(p).To(BeAnExistingFile())
 		})
+
+		It("stores uploaded source code and configuration files without chunks", func() {
+			kb, err := newMockKB(stateFile, assetDir, eng)
+			Expect(err).ToNot(HaveOccurred())
+
+			for name, content := range map[string]string{
+				"main.go":     "package main\n\nfunc main() {}\n",
+				"config.yaml": "port: 8080\n",
+				"server.log":  "started\n",
+			} {
+				_, err = kb.Store(createTxtFile(name, content), map[string]string{})
+				Expect(err).ToNot(HaveOccurred())
+			}
+			Expect(kb.ListDocuments()).To(HaveLen(3))
+			Expect(kb.Count()).To(Equal(0))
+
+			Expect(kb.Repopulate()).To(Succeed())
+			Expect(kb.Count()).To(Equal(0))
+		})
 	})
 })
@@ -0,0 +1,151 @@
+package rag
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mudler/localrecall/pkg/extract"
+	"github.com/mudler/localrecall/rag/types"
+	"github.com/mudler/xlog"
+)
+
+// recordSaveInterval is how many records ingestRecords stores between saves
+// of the collection state.
+const recordSaveInterval = 100
+
+// isRecordFile reports whether the file holds JSON records, each stored as a
+// document of its own.
+func isRecordFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".jsonl", ".ndjson":
+		return true
+	}
+	return false
+}
+
+// ValidateJSONMapping rejects a mapping with empty field paths or metadata
+// fields that would set a reserved key.
+func ValidateJSONMapping(mapping types.JSONMapping) error {
+	fields := slices.Clone(mapping.Metadata)
+	for _, field := range []string{mapping.Content, mapping.ID} {
+		if field != "" {
+			fields = append(fields, field)
+		}
+	}
+	for _, field := range fields {
+		if slices.Contains(strings.Split(field, "."), "") {
+			return fmt.Errorf("invalid field path %q", field)
+		}
+	}
+	metadata := make(map[string]string, len(mapping.Metadata))
+	for _, field := range mapping.Metadata {
+		metadata[field] = ""
+	}
+	return ValidateMetadata(metadata)
+}
+
+// recordDocuments reads the records of a JSON or JSON Lines file and maps
+// them to documents. metadata is the upload metadata, which the mapped fields
+// of each record extend. Every record is checked before any is stored.
+func recordDocuments(path string, mapping *types.JSONMapping, metadata map[string]string) ([]types.Document, error) {
+	if mapping == nil {
+		mapping = &types.JSONMapping{}
+	}
+	records, err := extract.JSONRecords(path)
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make([]types.Document, 0, len(records))
+	for i, record := range records {
+		doc := types.Document{Metadata: make(map[string]string, len(metadata)+len(mapping.Metadata))}
+		for k, v := range metadata {
+			doc.Metadata[k] = v
+		}
+
+		content := record
+		if mapping.Content != "" {
+			value, ok := extract.JSONField(record, mapping.Content)
+			if !ok {
+				return nil, fmt.Errorf("record %d has no %q field", i+1, mapping.Content)
+			}
+			content = value
+		}
+		doc.Content = extract.JSONText(content, "\n\n")
+		if strings.TrimSpace(doc.Content) == "" {
+			return nil, fmt.Errorf("record %d has no content", i+1)
+		}
+
+		if mapping.ID != "" {
+			value, ok := extract.JSONField(record, mapping.ID)
+			switch value.(type) {
+			case map[string]any, []any:
+				ok = false
+			}
+			if !ok || extract.JSONText(value, "") == "" {
+				return nil, fmt.Errorf("record %d has no %q field", i+1, mapping.ID)
+			}
+			doc.ID = extract.JSONText(value, "")
+			if err := validateDocumentID(doc.ID); err != nil {
+				return nil, fmt.Errorf("record %d: %w", i+1, err)
+			}
+		}
+
+		for _, field := range mapping.Metadata {
+			if value, ok := extract.JSONField(record, field); ok {
+				if text := extract.JSONText(value, ", "); text != "" {
+					doc.Metadata[field] = text
+				}
+			}
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// ingestRecords stores every record of a JSON or JSON Lines file as a
+// document, following mapping. The lock is taken per record, so searches and
+// other writes go on during a large upload. progress, if set, is called as
+// documents are stored. On failure the documents stored so far are kept.
+func (db *PersistentKB) ingestRecords(path string, mapping *types.JSONMapping, metadata map[string]string, progress func(stored, total int)) error {
+	docs, err := recordDocuments(path, mapping, metadata)
+	if err != nil {
+		return err
+	}
+	if len(docs) == 0 {
+		return fmt.Errorf("no records found in %s", filepath.Base(path))
+	}
+	if progress != nil {
+		progress(0, len(docs))
+	}
+
+	for i, doc := range docs {
+		db.Lock()
+		_, err := db.storeDocument(doc)
+		if err == nil && (i+1)%recordSaveInterval == 0 {
+			err = db.save()
+		}
+		if err != nil && !db.dropped && i > 0 {
+			if serr := db.save(); serr != nil {
+				xlog.Error("Failed to save collection state", "error", serr)
+			}
+		}
+		db.Unlock()
+		if err != nil {
+			return fmt.Errorf("record %d: %w (%d earlier records were stored)", i+1, err, i)
+		}
+		if progress != nil {
+			progress(i+1, len(docs))
+		}
+	}
+	xlog.Info("Stored records", "file", filepath.Base(path), "count", len(docs))
+
+	db.Lock()
+	defer db.Unlock()
+	if db.dropped {
+		return errCollectionDropped
+	}
+	return db.save()
+}
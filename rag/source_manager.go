@@ -2,12 +2,18 @@ package rag
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"sync"
 	"time"
+	"unicode"
 
 	"github.com/mudler/localrecall/rag/sources"
 	"github.com/mudler/xlog"
@@ -18,8 +24,76 @@ type ExternalSource struct {
 	URL            string
 	UpdateInterval time.Duration
 	LastUpdate     time.Time
+	// LastCommit is the commit a Git source was last synced to, so the
+	// next update only re-indexes the files changed since.
+	LastCommit string
+
+	// Ref, Subdir, Include, Exclude and MaxFileSize narrow a Git source down
+	// to a branch, tag or commit and the files worth indexing; see
+	// sources.GitOptions.
+	Ref         string
+	Subdir      string
+	Include     []string
+	Exclude     []string
+	MaxFileSize int64
+
+	// Crawl makes a web source crawl the website from its URL, storing
+	// every page as an entry of its own, within the limits set by
+	// CrawlDepth, CrawlPages, CrawlPathPrefix and CrawlDelay; see
+	// sources.CrawlOptions.
+	Crawl           bool
+	CrawlDepth      int
+	CrawlPages      int
+	CrawlPathPrefix string
+	CrawlDelay      time.Duration
+
+	// Credentials holds the credentials of the source sealed with the key of
+	// the source manager, empty without credentials.
+	Credentials string
 }
 
+// SourceOptions configures how an external source is fetched.
+type SourceOptions struct {
+	// Git narrows a Git repository down to a ref and the files worth
+	// indexing.
+	Git sources.GitOptions
+	// Crawl, when set, crawls a website from the source URL instead of
+	// fetching the single page.
+	Crawl *sources.CrawlOptions
+	// Credentials authenticate the requests made to fetch the source.
+	Credentials *sources.Credentials
+}
+
+// gitOptions returns the Git options of a source.
+func (s *ExternalSource) gitOptions() sources.GitOptions {
+	return sources.GitOptions{
+		Ref:         s.Ref,
+		Subdir:      s.Subdir,
+		Include:     s.Include,
+		Exclude:     s.Exclude,
+		MaxFileSize: s.MaxFileSize,
+	}
+}
+
+// crawlOptions returns the crawl options of a source.
+func (s *ExternalSource) crawlOptions() sources.CrawlOptions {
+	return sources.CrawlOptions{
+		MaxDepth:   s.CrawlDepth,
+		MaxPages:   s.CrawlPages,
+		PathPrefix: s.CrawlPathPrefix,
+		Delay:      s.CrawlDelay,
+	}
+}
+
+// RepositoryKey, PathKey and CommitKey are the metadata keys holding the URL
+// of the Git repository an entry was taken from, the slash-separated path of
+// the file within the repository and the hash of the commit it was read at.
+const (
+	RepositoryKey = "repository"
+	PathKey       = "path"
+	CommitKey     = "commit"
+)
+
 // SourceManager manages external sources for collections
 type SourceManager struct {
 	sources     map[string][]*ExternalSource // collection name -> sources
@@ -28,6 +102,17 @@ type SourceManager struct {
 	ctx         context.Context
 	cancel      context.CancelFunc
 	config      *sources.Config
+	// cloneLocks serializes the syncs of each Git clone, keyed by directory.
+	cloneLocks sync.Map
+	// updating holds the sources being updated, keyed by entry prefix, with
+	// the update requested meanwhile, if any, to run once they are done.
+	updating map[string]*sourceUpdate
+}
+
+// sourceUpdate is an update of a source requested while another was running.
+type sourceUpdate struct {
+	source     *ExternalSource
+	collection *PersistentKB
 }
 
 // NewSourceManager creates a new source manager
@@ -36,17 +121,20 @@ func NewSourceManager(config *sources.Config) *SourceManager {
 	return &SourceManager{
 		sources:     make(map[string][]*ExternalSource),
 		collections: make(map[string]*PersistentKB),
+		updating:    make(map[string]*sourceUpdate),
 		ctx:         ctx,
 		cancel:      cancel,
 		config:      config,
 	}
 }
 
-// RegisterCollection registers a collection with the source manager
+// RegisterCollection registers a collection with the source manager.
+// Registering a name again replaces the previous collection and its sources.
 func (sm *SourceManager) RegisterCollection(name string, collection *PersistentKB) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 	sm.collections[name] = collection
+	delete(sm.sources, name)
 
 	// Load existing sources from the collection
 	sources := collection.GetExternalSources()
@@ -57,8 +145,62 @@ func (sm *SourceManager) RegisterCollection(name string, collection *PersistentK
 	}
 }
 
+// UnregisterCollection stops tracking a collection and its sources, and drops
+// the clones kept for its Git sources.
+func (sm *SourceManager) UnregisterCollection(name string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	for _, source := range sm.sources[name] {
+		sm.removeClone(name, source.URL)
+	}
+	delete(sm.collections, name)
+	delete(sm.sources, name)
+}
+
 // AddSource adds a new external source to a collection
 func (sm *SourceManager) AddSource(collectionName, url string, updateInterval time.Duration) error {
+	return sm.AddSourceWithOptions(collectionName, url, updateInterval, SourceOptions{})
+}
+
+// ErrInvalidSource is returned by AddSourceWithOptions for options a source
+// cannot take.
+var ErrInvalidSource = errors.New("invalid source")
+
+// AddSourceWithOptions adds a new external source to a collection. The Git
+// options only apply to Git repositories. Credentials are stored encrypted
+// with the key of the source manager.
+func (sm *SourceManager) AddSourceWithOptions(collectionName, url string, updateInterval time.Duration, opts SourceOptions) error {
+	git := opts.Git
+	if git.Ref != "" || git.Subdir != "" || len(git.Include) > 0 || len(git.Exclude) > 0 || git.MaxFileSize != 0 {
+		if !sources.IsGitRepository(url) {
+			return fmt.Errorf("%w: ref and file filters only apply to Git repositories", ErrInvalidSource)
+		}
+		if err := git.Validate(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSource, err)
+		}
+	}
+	if opts.Crawl != nil {
+		if sources.IsGitRepository(url) || sources.IsSitemap(url) || !(strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")) {
+			return fmt.Errorf("%w: only web pages can be crawled", ErrInvalidSource)
+		}
+		if err := opts.Crawl.Validate(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSource, err)
+		}
+	}
+	var sealed string
+	if opts.Credentials != nil {
+		if len(sm.config.CredentialsKey) == 0 {
+			return fmt.Errorf("%w: no key is configured to encrypt credentials", ErrInvalidSource)
+		}
+		if err := sources.CheckCredentialsURL(url); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSource, err)
+		}
+		var err error
+		if sealed, err = sources.SealCredentials(opts.Credentials, sm.config.CredentialsKey, url); err != nil {
+			return err
+		}
+	}
+
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
@@ -71,6 +213,19 @@ func (sm *SourceManager) AddSource(collectionName, url string, updateInterval ti
 		URL:            url,
 		UpdateInterval: updateInterval,
 		LastUpdate:     time.Now(),
+		Ref:            git.Ref,
+		Subdir:         git.Subdir,
+		Include:        git.Include,
+		Exclude:        git.Exclude,
+		MaxFileSize:    git.MaxFileSize,
+		Credentials:    sealed,
+	}
+	if opts.Crawl != nil {
+		source.Crawl = true
+		source.CrawlDepth = opts.Crawl.MaxDepth
+		source.CrawlPages = opts.Crawl.MaxPages
+		source.CrawlPathPrefix = opts.Crawl.PathPrefix
+		source.CrawlDelay = opts.Crawl.Delay
 	}
 
 	// Add the source to the collection's persistent storage
@@ -101,12 +256,11 @@ func (sm *SourceManager) RemoveSource(collectionName, url string) error {
 		return err
 	}
 
-	if err := collection.RemoveEntry(fmt.Sprintf("source-%s-%s.txt", collectionName, sanitizeURL(url))); err != nil {
-		// Ignore error if entry doesn't exist — content may never have been fetched
-		if !strings.Contains(err.Error(), "entry not found") {
-			return err
-		}
+	// Content may never have been fetched, leaving no entries to remove
+	if _, err := collection.removeEntriesMatching(sourceEntryMatcher(collectionName, url)); err != nil {
+		return err
 	}
+	sm.removeClone(collectionName, url)
 
 	// Remove from in-memory sources
 	sources := sm.sources[collectionName]
@@ -120,18 +274,62 @@ func (sm *SourceManager) RemoveSource(collectionName, url string) error {
 	return nil
 }
 
-// updateSource updates a single source
+// updateSource updates a single source. A source is updated once at a time:
+// an update requested while one is running is run after it.
 func (sm *SourceManager) updateSource(collectionName string, source *ExternalSource, collection *PersistentKB) {
+	key := sourceEntryPrefix(collectionName, source.URL)
+	sm.mu.Lock()
+	if _, running := sm.updating[key]; running {
+		sm.updating[key] = &sourceUpdate{source, collection}
+		sm.mu.Unlock()
+		xlog.Info("Source update already running, queued another", "url", source.URL)
+		return
+	}
+	sm.updating[key] = nil
+	sm.mu.Unlock()
+
+	for {
+		sm.mu.Lock()
+		collection.touchSource(source)
+		sm.mu.Unlock()
+
+		sm.fetchSource(collectionName, source, collection)
+
+		sm.mu.Lock()
+		next := sm.updating[key]
+		if next == nil {
+			delete(sm.updating, key)
+			sm.mu.Unlock()
+			return
+		}
+		sm.updating[key] = nil
+		sm.mu.Unlock()
+		source, collection = next.source, next.collection
+	}
+}
 
-	// update LastUpdate
-	source.LastUpdate = time.Now()
-
+// fetchSource fetches a source and stores its content in the collection.
+func (sm *SourceManager) fetchSource(collectionName string, source *ExternalSource, collection *PersistentKB) {
 	xlog.Info("Updating source", "url", source.URL)
-	content, err := sources.SourceRouter(source.URL, sm.config)
+	creds, err := sm.credentials(source)
+	if err != nil {
+		xlog.Error("Error reading source credentials", "url", source.URL, "error", err)
+		return
+	}
+	if sources.IsGitRepository(source.URL) {
+		sm.syncRepository(collectionName, source, creds, collection)
+		return
+	}
+	if source.Crawl {
+		sm.crawlSite(collectionName, source, creds, collection)
+		return
+	}
+	fetched, err := sources.FetchSource(source.URL, sm.config, creds)
 	if err != nil {
 		xlog.Error("Error updating source", err)
 		return
 	}
+	content := fetched.Text
 
 	xlog.Info("Fetched content", "url", source.URL, "content_length", len(content))
 	if len(content) == 0 {
@@ -142,8 +340,7 @@ func (sm *SourceManager) updateSource(collectionName string, source *ExternalSou
 	// Create a temporary file to store the content
 	// Use a consistent filename based on URL so StoreOrReplace can find existing entries
 	// But use a unique temp directory to avoid race conditions
-	sanitizedURL := sanitizeURL(source.URL)
-	fileName := fmt.Sprintf("source-%s-%s.txt", collectionName, sanitizedURL)
+	fileName := sourceEntryPrefix(collectionName, source.URL) + ".txt"
 
 	// Create a unique temp directory for this update to avoid race conditions
 	tmpDir := filepath.Join(os.TempDir(), fmt.Sprintf("source-update-%d", time.Now().UnixNano()))
@@ -163,14 +360,438 @@ func (sm *SourceManager) updateSource(collectionName string, source *ExternalSou
 
 	// Store the content in the collection
 	// StoreOrReplace will use filepath.Base to get fileName, which matches our consistent naming
-	if _, err := collection.StoreOrReplace(tmpFile, map[string]string{"url": source.URL}); err != nil {
+	metadata := map[string]string{"url": source.URL}
+	if fetched.Title != "" {
+		metadata[TitleKey] = fetched.Title
+	}
+	if _, err := collection.StoreOrReplace(tmpFile, metadata); err != nil {
 		xlog.Error("Error storing content in collection", "error", err)
 		return
 	}
+	// The entry stored under the name sources had before their entry
+	// prefixes were bounded is replaced by the one stored above.
+	if legacy := legacySourceEntryName(collectionName, source.URL); legacy != fileName {
+		if _, err := collection.removeEntriesMatching(func(name string) bool { return name == legacy }); err != nil {
+			xlog.Error("Error removing previous source entry", "url", source.URL, "entry", legacy, "error", err)
+		}
+	}
 
 	xlog.Info("Content stored in collection", "tmpFile", tmpFile, "fileName", fileName)
 }
 
+// syncRepository brings the clone kept for a Git source up to date and
+// re-indexes only the files changed since the commit the source was last
+// synced to, removing the entries of deleted files. The commit is recorded
+// once every changed file was stored, so failed files are retried on the
+// next update. Without a clone cache the repository is cloned afresh into a
+// temporary directory, and re-indexed whole when its commit changed.
+func (sm *SourceManager) syncRepository(collectionName string, source *ExternalSource, creds *sources.Credentials, collection *PersistentKB) {
+	prefix := sourceEntryPrefix(collectionName, source.URL)
+	dir := filepath.Join(sm.config.GitCacheDir, prefix)
+	if sm.config.GitCacheDir == "" {
+		tmpDir, err := os.MkdirTemp("", "git-repo-*")
+		if err != nil {
+			xlog.Error("Error creating temp directory", "error", err)
+			return
+		}
+		defer os.RemoveAll(tmpDir)
+		dir = tmpDir
+	}
+	unlock := sm.lockClone(dir)
+	defer unlock()
+
+	lastCommit := collection.sourceCommit(source.URL)
+	synced, err := sources.SyncGitRepository(source.URL, creds, dir, lastCommit, source.gitOptions())
+	if err != nil {
+		xlog.Error("Error syncing Git source", "url", source.URL, "error", err)
+		return
+	}
+	if synced.Commit == lastCommit {
+		xlog.Info("Git source is up to date", "url", source.URL, "commit", lastCommit)
+		return
+	}
+
+	stored, failed := storeSourceFiles(prefix, synced.Files, func(file sources.File) map[string]string {
+		return map[string]string{
+			"url":         source.URL,
+			RepositoryKey: source.URL,
+			PathKey:       file.Path,
+			CommitKey:     synced.Commit,
+		}
+	}, collection)
+	removed := map[string]bool{}
+	for _, path := range synced.Removed {
+		removed[sourceFileName(prefix, path)] = true
+	}
+	for _, file := range synced.Files {
+		// Files emptied are not stored, so their previous entry goes.
+		if name := sourceFileName(prefix, file.Path); !stored[name] {
+			removed[name] = true
+		}
+	}
+	isEntry := sourceEntryMatcher(collectionName, source.URL)
+	removedCount, err := collection.removeEntriesMatching(func(name string) bool {
+		if synced.Full {
+			return isEntry(name) && !stored[name]
+		}
+		return removed[name]
+	})
+	if err != nil {
+		xlog.Error("Error removing deleted source files", "url", source.URL, "error", err)
+		failed++
+	}
+	xlog.Info("Git source synced", "url", source.URL, "from", lastCommit, "to", synced.Commit, "full", synced.Full, "changed", len(synced.Files), "removed", removedCount, "failed", failed)
+	if failed > 0 {
+		return
+	}
+	if err := collection.setSourceCommit(source.URL, synced.Commit); err != nil {
+		xlog.Error("Error recording synced commit", "url", source.URL, "error", err)
+	}
+}
+
+// crawlSite crawls the website of a source and stores every page found as an
+// entry of its own, named after the path of the page, recording its URL and
+// title. Entries of pages no longer found are removed, except for pages that
+// could not be fetched this time.
+func (sm *SourceManager) crawlSite(collectionName string, source *ExternalSource, creds *sources.Credentials, collection *PersistentKB) {
+	crawled, err := sources.Crawl(sm.ctx, source.URL, source.crawlOptions(), creds)
+	if err != nil {
+		xlog.Error("Error crawling source", "url", source.URL, "error", err)
+		return
+	}
+
+	prefix := sourceEntryPrefix(collectionName, source.URL)
+	files := make([]sources.File, 0, len(crawled.Pages))
+	pages := map[string]sources.Page{}
+	for _, page := range crawled.Pages {
+		path := pageEntryPath(page.URL)
+		files = append(files, sources.File{Path: path, Content: []byte(page.Text)})
+		pages[path] = page
+	}
+	stored, failed := storeSourceFiles(prefix, files, func(file sources.File) map[string]string {
+		metadata := map[string]string{"url": pages[file.Path].URL}
+		if title := pages[file.Path].Title; title != "" {
+			metadata[TitleKey] = title
+		}
+		return metadata
+	}, collection)
+
+	unreachable := map[string]bool{}
+	for _, pageURL := range crawled.Failed {
+		unreachable[sourceFileName(prefix, pageEntryPath(pageURL))] = true
+	}
+	isEntry := sourceEntryMatcher(collectionName, source.URL)
+	removed, err := collection.removeEntriesMatching(func(name string) bool {
+		return isEntry(name) && !stored[name] && !unreachable[name]
+	})
+	if err != nil {
+		xlog.Error("Error removing stale pages", "url", source.URL, "error", err)
+	}
+	xlog.Info("Crawled source stored in collection", "url", source.URL, "pages", len(stored), "failed", failed+len(crawled.Failed), "removed", removed)
+}
+
+// pageEntryPath returns the path a crawled page is stored under: the path of
+// its URL, "index" for the root, with its query and a .md extension, as
+// pages are stored as Markdown.
+func pageEntryPath(pageURL string) string {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return "index.md"
+	}
+	p := strings.Trim(u.Path, "/")
+	if ext := path.Ext(p); ext == ".html" || ext == ".htm" {
+		p = strings.TrimSuffix(p, ext)
+	}
+	if p == "" {
+		p = "index"
+	}
+	if u.RawQuery != "" {
+		p += "_" + u.RawQuery
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '-' || r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, p) + ".md"
+}
+
+// credentials returns the credentials of a source. Git repositories without
+// credentials of their own fall back to GIT_PRIVATE_KEY.
+func (sm *SourceManager) credentials(source *ExternalSource) (*sources.Credentials, error) {
+	if source.Credentials != "" {
+		return sources.OpenCredentials(source.Credentials, sm.config.CredentialsKey, source.URL)
+	}
+	if sources.IsGitRepository(source.URL) && sm.config.GitPrivateKey != "" {
+		return &sources.Credentials{SSHKey: sm.config.GitPrivateKey}, nil
+	}
+	return nil, nil
+}
+
+// RedactedCredentials returns the credentials of a source with their secrets
+// redacted, or nil when the source has none. Credentials that cannot be
+// decrypted, such as after the key changed, are reported with every field
+// redacted.
+func (sm *SourceManager) RedactedCredentials(source *ExternalSource) *sources.Credentials {
+	if source.Credentials == "" {
+		return nil
+	}
+	creds, err := sources.OpenCredentials(source.Credentials, sm.config.CredentialsKey, source.URL)
+	if err != nil {
+		return &sources.Credentials{SSHKey: sources.Redacted, Token: sources.Redacted, Username: sources.Redacted, Password: sources.Redacted}
+	}
+	return creds.Redacted()
+}
+
+// lockClone locks the Git clone in dir and returns the function unlocking it.
+func (sm *SourceManager) lockClone(dir string) func() {
+	mu, _ := sm.cloneLocks.LoadOrStore(dir, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+// removeClone deletes the clone kept for a Git source, once any sync in
+// progress is done.
+func (sm *SourceManager) removeClone(collectionName, url string) {
+	if sm.config.GitCacheDir == "" || !sources.IsGitRepository(url) {
+		return
+	}
+	dir := filepath.Join(sm.config.GitCacheDir, sourceEntryPrefix(collectionName, url))
+	go func() {
+		unlock := sm.lockClone(dir)
+		defer unlock()
+		if err := os.RemoveAll(dir); err != nil {
+			xlog.Error("Error removing Git clone", "dir", dir, "error", err)
+		}
+	}()
+}
+
+// storeSourceFiles stores the non-empty files fetched from a source, such as
+// the files of a Git repository or the pages of a website, as entries of
+// their own with the metadata returned for each. It returns the names of the
+// entries of the files it stored or tried to store, and how many of them
+// failed. The existing entries are listed once and the state saved once, so
+// a sync does not rescan the collection for every file.
+func storeSourceFiles(prefix string, files []sources.File, metadata func(file sources.File) map[string]string, collection *PersistentKB) (map[string]bool, int) {
+	stored := map[string]bool{}
+	tmpDir, err := os.MkdirTemp("", "source-update-*")
+	if err != nil {
+		xlog.Error("Error creating temp directory", "error", err)
+		return stored, len(files)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	keys := collection.entryKeys()
+	failed := 0
+	for _, file := range files {
+		if strings.TrimSpace(string(file.Content)) == "" {
+			continue
+		}
+		fileName := sourceFileName(prefix, file.Path)
+		stored[fileName] = true
+
+		tmpFile := filepath.Join(tmpDir, fileName)
+		if err := os.WriteFile(tmpFile, file.Content, 0644); err != nil {
+			xlog.Error("Error creating temporary file", "error", err)
+			failed++
+			continue
+		}
+		err := collection.replaceSourceEntry(tmpFile, metadata(file), keys)
+		os.Remove(tmpFile)
+		if err != nil {
+			// A file that fails to ingest does not hold back the others.
+			xlog.Error("Error storing source file", "prefix", prefix, "path", file.Path, "error", err)
+			failed++
+		}
+	}
+	if err := collection.saveState(); err != nil {
+		xlog.Error("Error saving collection state", "prefix", prefix, "error", err)
+		failed = len(stored)
+	}
+	return stored, failed
+}
+
+// entryKeys returns the keys of the entries of the collection by name.
+func (db *PersistentKB) entryKeys() map[string]string {
+	db.Lock()
+	defer db.Unlock()
+
+	keys := map[string]string{}
+	for _, key := range db.listDocumentKeys() {
+		keys[filepath.Base(key)] = key
+	}
+	return keys
+}
+
+// replaceSourceEntry is StoreOrReplace for a batch of files: the entry it
+// replaces is looked up in keys, which it keeps up to date, and the state is
+// left for the caller to save.
+func (db *PersistentKB) replaceSourceEntry(tmpFile string, metadata map[string]string, keys map[string]string) error {
+	db.Lock()
+	defer db.Unlock()
+
+	if db.dropped {
+		return errCollectionDropped
+	}
+	name := filepath.Base(tmpFile)
+	oldKey := keys[name]
+	if oldKey != "" {
+		if _, err := os.Stat(filepath.Join(db.assetDir, oldKey)); err != nil {
+			// Replaced or removed since the keys were listed.
+			oldKey, _ = db.findEntryKey(name)
+		}
+	}
+	key, err := db.replaceEntry(tmpFile, metadata, oldKey)
+	if err != nil {
+		delete(keys, name)
+		return err
+	}
+	keys[name] = key
+	return nil
+}
+
+// saveState saves the state of the collection after a batch of changes.
+func (db *PersistentKB) saveState() error {
+	db.Lock()
+	defer db.Unlock()
+	return db.save()
+}
+
+// maxSourceNameLength bounds the sanitized URL in the entry prefix of a
+// source, so the prefix always leaves room for the names of its files.
+const maxSourceNameLength = 64
+
+// sourceEntryPrefix returns the name shared by the entries of a source: the
+// entry of a web source is this name with a .txt extension and the entries
+// of the files of a Git repository start with this name followed by "--".
+// A sanitized URL longer than maxSourceNameLength is shortened and suffixed
+// with a hash of the URL, so the prefix stays unique and of bounded length.
+func sourceEntryPrefix(collectionName, url string) string {
+	name := sanitizeURL(url)
+	if len(name) > maxSourceNameLength {
+		sum := sha256.Sum256([]byte(url))
+		name = strings.TrimRight(name[:maxSourceNameLength-17], "-") + "-" + hex.EncodeToString(sum[:8])
+	}
+	return fmt.Sprintf("source-%s-%s", collectionName, name)
+}
+
+// sourceFileName names the entry of a file of a source after the source and
+// the file's path encoded by encodeEntryPath. Sanitized URLs never hold
+// "--", so the entries of different sources never share a prefix.
+func sourceFileName(prefix, filePath string) string {
+	return prefix + "--" + encodeEntryPath(filePath, 255-len(prefix)-2)
+}
+
+// encodeEntryPath turns the slash-separated path of a file into an entry
+// name, with "/" replaced by "__", keeping the file's extension so the file
+// is chunked by type. Paths that this could confuse with others, such as
+// "a__b.go" and "a/b.go", get a hash of the path before the extension, and
+// paths encoded longer than maxLength are replaced by their hash.
+func encodeEntryPath(filePath string, maxLength int) string {
+	sum := sha256.Sum256([]byte(filePath))
+	hash := hex.EncodeToString(sum[:8])
+	ext := path.Ext(filePath)
+	name := strings.ReplaceAll(filePath, "/", "__")
+	if strings.Contains(filePath, "__") || strings.Contains(name, "___") {
+		name = strings.TrimSuffix(name, ext) + "-" + hash + ext
+	}
+	if len(name) > maxLength {
+		name = hash + ext
+	}
+	return name
+}
+
+// legacySourceEntryName returns the name the entry of a source had before
+// entry prefixes were bounded: the full sanitized URL with a .txt extension.
+// It differs from the current name only for long URLs.
+func legacySourceEntryName(collectionName, url string) string {
+	return fmt.Sprintf("source-%s-%s.txt", collectionName, sanitizeURL(url))
+}
+
+// sourceEntryMatcher returns a function reporting whether an entry name
+// belongs to the source with the given URL, including an entry left under
+// its legacy name.
+func sourceEntryMatcher(collectionName, url string) func(name string) bool {
+	prefix := sourceEntryPrefix(collectionName, url)
+	legacy := legacySourceEntryName(collectionName, url)
+	return func(name string) bool {
+		return name == prefix+".txt" || name == legacy || strings.HasPrefix(name, prefix+"--")
+	}
+}
+
+// removeEntriesMatching removes the entries whose name matches and returns
+// how many it removed. The state is saved once and, with
+// LOCALRECALL_REPOPULATE_DELETE, the engine repopulated once for all of them.
+func (db *PersistentKB) removeEntriesMatching(match func(name string) bool) (int, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	if db.dropped {
+		return 0, errCollectionDropped
+	}
+	repopulate := os.Getenv("LOCALRECALL_REPOPULATE_DELETE") == "true"
+	removed := 0
+	var err error
+	for _, key := range db.listDocumentKeys() {
+		if !match(filepath.Base(key)) {
+			continue
+		}
+		if err = db.deleteEntry(key, repopulate); err != nil {
+			break
+		}
+		removed++
+	}
+	if removed == 0 {
+		return 0, err
+	}
+	if serr := db.save(); err == nil {
+		err = serr
+	}
+	if repopulate {
+		if rerr := db.repopulate(); err == nil {
+			err = rerr
+		}
+	}
+	return removed, err
+}
+
+// touchSource records that a source of the collection starts updating.
+func (db *PersistentKB) touchSource(source *ExternalSource) {
+	db.Lock()
+	defer db.Unlock()
+	source.LastUpdate = time.Now()
+}
+
+// sourceCommit returns the commit the source with the given URL was last
+// synced to.
+func (db *PersistentKB) sourceCommit(url string) string {
+	db.Lock()
+	defer db.Unlock()
+	for _, source := range db.sources {
+		if source.URL == url {
+			return source.LastCommit
+		}
+	}
+	return ""
+}
+
+// setSourceCommit records the commit the source with the given URL was
+// synced to.
+func (db *PersistentKB) setSourceCommit(url, commit string) error {
+	db.Lock()
+	defer db.Unlock()
+	if db.dropped {
+		return errCollectionDropped
+	}
+	for _, source := range db.sources {
+		if source.URL == url {
+			source.LastCommit = commit
+			return db.save()
+		}
+	}
+	return fmt.Errorf("source %s not found", url)
+}
+
 // sanitizeURL converts a URL into a filesystem-safe string
 func sanitizeURL(url string) string {
 	// Replace common URL special characters with safe alternatives
@@ -222,6 +843,9 @@ func (sm *SourceManager) Start() {
 				for collectionName, sources := range sm.sources {
 					collection := sm.collections[collectionName]
 					for _, source := range sources {
+						if _, running := sm.updating[sourceEntryPrefix(collectionName, source.URL)]; running {
+							continue
+						}
 						if time.Since(source.LastUpdate) >= source.UpdateInterval {
 							go sm.updateSource(collectionName, source, collection)
 						}
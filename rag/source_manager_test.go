@@ -0,0 +1,451 @@
+package rag_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	. "github.com/mudler/localrecall/rag"
+	"github.com/mudler/localrecall/rag/engine"
+	"github.com/mudler/localrecall/rag/sources"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SourceManager with Git repositories", func() {
+	var (
+		tempDir string
+		repoDir string
+		repo    *git.Repository
+		kb      *PersistentKB
+		sm      *SourceManager
+	)
+
+	commit := func(files map[string]string, removed ...string) string {
+		tree, err := repo.Worktree()
+		Expect(err).ToNot(HaveOccurred())
+		for name, content := range files {
+			Expect(os.MkdirAll(filepath.Dir(filepath.Join(repoDir, name)), 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(repoDir, name), []byte(content), 0644)).To(Succeed())
+			_, err = tree.Add(name)
+			Expect(err).ToNot(HaveOccurred())
+		}
+		for _, name := range removed {
+			_, err = tree.Remove(name)
+			Expect(err).ToNot(HaveOccurred())
+		}
+		hash, err := tree.Commit("update", &git.CommitOptions{Author: &object.Signature{Name: "dev", Email: "dev@example.com", When: time.Now()}})
+		Expect(err).ToNot(HaveOccurred())
+		return hash.String()
+	}
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = os.MkdirTemp("", "source_manager_test_*")
+		Expect(err).ToNot(HaveOccurred())
+		repoDir = filepath.Join(tempDir, "project.git")
+		repo, err = git.PlainInit(repoDir, false)
+		Expect(err).ToNot(HaveOccurred())
+
+		kb, err = newMockKB(filepath.Join(tempDir, "state.json"), filepath.Join(tempDir, "assets"), engine.NewMockEngine())
+		Expect(err).ToNot(HaveOccurred())
+		sm = NewSourceManager(&sources.Config{})
+		sm.RegisterCollection("docs", kb)
+	})
+
+	AfterEach(func() {
+		sm.Stop()
+		os.RemoveAll(tempDir)
+	})
+
+	It("stores every file as an entry with its path, commit and symbols", func() {
+		hash := commit(map[string]string{
+			"README.md":          "# Project\n\nA server.",
+			"cmd/server/main.go": "package main\n\n// Run starts the server.\nfunc Run() {}\n\nfunc main() { Run() }\n",
+			"image.png":          "binary",
+		})
+		Expect(sm.AddSource("docs", repoDir, time.Hour)).To(Succeed())
+
+		Eventually(func() int { return len(kb.ListDocuments()) }, 30*time.Second, 100*time.Millisecond).Should(Equal(2))
+		var mainEntry string
+		for _, key := range kb.ListDocuments() {
+			if strings.HasSuffix(key, "--cmd__server__main.go") {
+				mainEntry = key
+			}
+		}
+		Expect(filepath.Base(mainEntry)).To(HavePrefix("source-docs-"))
+
+		results, err := kb.GetEntryContent(mainEntry)
+		Expect(err).ToNot(HaveOccurred())
+		symbols := map[string]string{}
+		for _, r := range results {
+			Expect(r.Metadata).To(HaveKeyWithValue("path", "cmd/server/main.go"))
+			Expect(r.Metadata).To(HaveKeyWithValue("repository", repoDir))
+			Expect(r.Metadata).To(HaveKeyWithValue("commit", hash))
+			Expect(r.Metadata).To(HaveKeyWithValue("language", "go"))
+			symbols[r.Metadata["symbol"]] = r.Metadata["line_start"] + "-" + r.Metadata["line_end"]
+		}
+		Expect(symbols).To(Equal(map[string]string{"": "1-1", "Run": "3-4", "main": "6-6"}))
+
+		// Re-indexing the collection chunks the source code again.
+		count := kb.Count()
+		Expect(kb.Repopulate()).To(Succeed())
+		Expect(kb.Count()).To(Equal(count))
+
+		// Files removed from the repository are removed from the collection.
+		commit(nil, "README.md")
+		sm.RegisterCollection("docs", kb)
+		Eventually(func() int { return len(kb.ListDocuments()) }, 30*time.Second, 100*time.Millisecond).Should(Equal(1))
+		Expect(kb.EntryExists(filepath.Base(mainEntry))).To(BeTrue())
+
+		Expect(sm.RemoveSource("docs", repoDir)).To(Succeed())
+		Expect(kb.ListDocuments()).To(BeEmpty())
+	})
+
+	It("keeps apart files with confusable paths from sources with long URLs", func() {
+		// A URL longer than a file name still gets entries of its own.
+		repoDir = filepath.Join(tempDir, strings.Repeat("a", 200), strings.Repeat("b", 200), "project.git")
+		var err error
+		repo, err = git.PlainInit(repoDir, false)
+		Expect(err).ToNot(HaveOccurred())
+		commit(map[string]string{
+			"a/b.go":   "package a\n\nfunc B() {}\n",
+			"a__b.go":  "package main\n\nfunc AB() {}\n",
+			"a_/_b.go": "package a_\n\nfunc B() {}\n",
+		})
+		Expect(sm.AddSource("docs", repoDir, time.Hour)).To(Succeed())
+
+		Eventually(func() int { return len(kb.ListDocuments()) }, 30*time.Second, 100*time.Millisecond).Should(Equal(3))
+		paths := map[string]bool{}
+		for _, key := range kb.ListDocuments() {
+			Expect(len(filepath.Base(key))).To(BeNumerically("<=", 255))
+			metadata, err := kb.GetEntryMetadata(key)
+			Expect(err).ToNot(HaveOccurred())
+			paths[metadata["path"]] = true
+		}
+		Expect(paths).To(Equal(map[string]bool{"a/b.go": true, "a__b.go": true, "a_/_b.go": true}))
+
+		Expect(sm.RemoveSource("docs", repoDir)).To(Succeed())
+		Expect(kb.ListDocuments()).To(BeEmpty())
+	})
+
+	It("re-indexes only the files changed since the last synced commit", func() {
+		sm.Stop()
+		sm = NewSourceManager(&sources.Config{GitCacheDir: filepath.Join(tempDir, "git")})
+		sm.RegisterCollection("docs", kb)
+
+		first := commit(map[string]string{
+			"README.md": "# Project",
+			"main.go":   "package main\n\nfunc main() {}\n",
+			"util.go":   "package main\n\nfunc util() {}\n",
+		})
+		Expect(sm.AddSource("docs", repoDir, time.Hour)).To(Succeed())
+		lastCommit := func() string { return kb.GetExternalSources()[0].LastCommit }
+		Eventually(lastCommit, 30*time.Second, 100*time.Millisecond).Should(Equal(first))
+		Expect(kb.ListDocuments()).To(HaveLen(3))
+
+		keys := func() map[string]string {
+			byPath := map[string]string{}
+			for _, key := range kb.ListDocuments() {
+				metadata, err := kb.GetEntryMetadata(key)
+				Expect(err).ToNot(HaveOccurred())
+				byPath[metadata["path"]] = key
+			}
+			return byPath
+		}
+		before := keys()
+
+		second := commit(map[string]string{"main.go": "package main\n\nfunc main() { util() }\n"}, "util.go")
+		sm.RegisterCollection("docs", kb)
+		Eventually(lastCommit, 30*time.Second, 100*time.Millisecond).Should(Equal(second))
+
+		after := keys()
+		Expect(after).To(HaveLen(2))
+		Expect(after).ToNot(HaveKey("util.go"))
+		// The unchanged file keeps its entry, the modified one is stored anew.
+		Expect(after["README.md"]).To(Equal(before["README.md"]))
+		Expect(after["main.go"]).ToNot(Equal(before["main.go"]))
+		metadata, err := kb.GetEntryMetadata(after["main.go"])
+		Expect(err).ToNot(HaveOccurred())
+		Expect(metadata).To(HaveKeyWithValue("commit", second))
+		metadata, err = kb.GetEntryMetadata(after["README.md"])
+		Expect(err).ToNot(HaveOccurred())
+		Expect(metadata).To(HaveKeyWithValue("commit", first))
+
+		// The clone goes with its source.
+		Expect(sm.RemoveSource("docs", repoDir)).To(Succeed())
+		Eventually(func() ([]os.DirEntry, error) { return os.ReadDir(filepath.Join(tempDir, "git")) }, 5*time.Second, 100*time.Millisecond).Should(BeEmpty())
+	})
+
+	It("indexes only the files selected by the source options", func() {
+		commit(map[string]string{
+			"README.md":        "# Project",
+			"docs/guide.md":    "# Guide",
+			"docs/api.gen.md":  "# Generated",
+			"docs/vendor/x.md": "# Vendored",
+		})
+		opts := SourceOptions{Git: sources.GitOptions{Subdir: "docs", Exclude: []string{"*.gen.md", "docs/vendor/**"}}}
+		Expect(sm.AddSourceWithOptions("docs", repoDir, time.Hour, opts)).To(Succeed())
+		Eventually(func() string { return kb.GetExternalSources()[0].LastCommit }, 30*time.Second, 100*time.Millisecond).ShouldNot(BeEmpty())
+
+		Expect(kb.ListDocuments()).To(HaveLen(1))
+		metadata, err := kb.GetEntryMetadata(kb.ListDocuments()[0])
+		Expect(err).ToNot(HaveOccurred())
+		Expect(metadata).To(HaveKeyWithValue("path", "docs/guide.md"))
+		Expect(kb.GetExternalSources()[0].Subdir).To(Equal("docs"))
+
+		err = sm.AddSourceWithOptions("docs", "https://example.com", time.Hour, SourceOptions{Git: sources.GitOptions{Ref: "main"}})
+		Expect(err).To(MatchError(ErrInvalidSource))
+		err = sm.AddSourceWithOptions("docs", repoDir, time.Hour, SourceOptions{Git: sources.GitOptions{Include: []string{"[a-"}}})
+		Expect(err).To(MatchError(ErrInvalidSource))
+	})
+})
+
+// resetCountingEngine counts the resets of the engine, each of which means
+// the collection was repopulated.
+type resetCountingEngine struct {
+	*engine.MockEngine
+	mu     sync.Mutex
+	resets int
+}
+
+func (e *resetCountingEngine) Reset() error {
+	e.mu.Lock()
+	e.resets++
+	e.mu.Unlock()
+	return e.MockEngine.Reset()
+}
+
+func (e *resetCountingEngine) Resets() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.resets
+}
+
+var _ = Describe("SourceManager syncing many files", func() {
+	It("repopulates the collection once per sync when deletes repopulate", func() {
+		GinkgoT().Setenv("LOCALRECALL_REPOPULATE_DELETE", "true")
+		tempDir := GinkgoT().TempDir()
+		repoDir := filepath.Join(tempDir, "project.git")
+		repo, err := git.PlainInit(repoDir, false)
+		Expect(err).ToNot(HaveOccurred())
+		tree, err := repo.Worktree()
+		Expect(err).ToNot(HaveOccurred())
+		commit := func(write bool, names ...string) string {
+			for _, name := range names {
+				if write {
+					Expect(os.WriteFile(filepath.Join(repoDir, name), []byte("# "+name), 0644)).To(Succeed())
+					_, err = tree.Add(name)
+				} else {
+					_, err = tree.Remove(name)
+				}
+				Expect(err).ToNot(HaveOccurred())
+			}
+			hash, err := tree.Commit("update", &git.CommitOptions{Author: &object.Signature{Name: "dev", Email: "dev@example.com", When: time.Now()}})
+			Expect(err).ToNot(HaveOccurred())
+			return hash.String()
+		}
+
+		eng := &resetCountingEngine{MockEngine: engine.NewMockEngine()}
+		kb, err := NewPersistentCollectionKB(filepath.Join(tempDir, "state.json"), filepath.Join(tempDir, "assets"), eng, 1000, 0, nil, "")
+		Expect(err).ToNot(HaveOccurred())
+		sm := NewSourceManager(&sources.Config{GitCacheDir: filepath.Join(tempDir, "git")})
+		defer sm.Stop()
+		sm.RegisterCollection("docs", kb)
+
+		first := commit(true, "a.md", "b.md", "c.md", "d.md", "e.md")
+		Expect(sm.AddSource("docs", repoDir, time.Hour)).To(Succeed())
+		lastCommit := func() string { return kb.GetExternalSources()[0].LastCommit }
+		Eventually(lastCommit, 30*time.Second, 100*time.Millisecond).Should(Equal(first))
+		Expect(kb.ListDocuments()).To(HaveLen(5))
+		resets := eng.Resets()
+
+		second := commit(false, "a.md", "b.md", "c.md")
+		sm.RegisterCollection("docs", kb)
+		Eventually(lastCommit, 30*time.Second, 100*time.Millisecond).Should(Equal(second))
+		Expect(kb.ListDocuments()).To(HaveLen(2))
+		Expect(eng.Resets()).To(Equal(resets + 1))
+		Expect(kb.Count()).To(Equal(2))
+	})
+})
+
+var _ = Describe("SourceManager with credentials", func() {
+	It("fetches with the source credentials and stores them encrypted", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if user, password, ok := r.BasicAuth(); !ok || user != "bob" || password != "hunter2" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`<html><body><article><p>Internal handbook</p></article></body></html>`))
+		}))
+		defer server.Close()
+
+		tempDir := GinkgoT().TempDir()
+		statePath := filepath.Join(tempDir, "state.json")
+		kb, err := newMockKB(statePath, filepath.Join(tempDir, "assets"), engine.NewMockEngine())
+		Expect(err).ToNot(HaveOccurred())
+
+		unkeyed := NewSourceManager(&sources.Config{})
+		unkeyed.RegisterCollection("docs", kb)
+		creds := &sources.Credentials{Username: "bob", Password: "hunter2"}
+		err = unkeyed.AddSourceWithOptions("docs", server.URL, time.Hour, SourceOptions{Credentials: creds})
+		Expect(err).To(MatchError(ErrInvalidSource))
+
+		sm := NewSourceManager(&sources.Config{CredentialsKey: sources.CredentialsKey("server secret")})
+		defer sm.Stop()
+		sm.RegisterCollection("docs", kb)
+		err = sm.AddSourceWithOptions("docs", "http://docs.example.com/", time.Hour, SourceOptions{Credentials: creds})
+		Expect(err).To(MatchError(ErrInvalidSource))
+		Expect(kb.GetExternalSources()).To(BeEmpty())
+		Expect(sm.AddSourceWithOptions("docs", server.URL, time.Hour, SourceOptions{Credentials: creds})).To(Succeed())
+		Eventually(func() []string { return kb.ListDocuments() }, 30*time.Second, 100*time.Millisecond).Should(HaveLen(1))
+		content, _, err := kb.GetEntryFileContent(kb.ListDocuments()[0])
+		Expect(err).ToNot(HaveOccurred())
+		Expect(content).To(ContainSubstring("Internal handbook"))
+
+		state, err := os.ReadFile(statePath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(state)).ToNot(ContainSubstring("hunter2"))
+		Expect(sm.RedactedCredentials(kb.GetExternalSources()[0])).To(Equal(&sources.Credentials{Username: sources.Redacted, Password: sources.Redacted}))
+	})
+})
+
+var _ = Describe("SourceManager crawling websites", func() {
+	It("stores every page as an entry and drops the pages no longer linked", func() {
+		links := `<a href="/guide">Guide</a> <a href="/faq?lang=en">FAQ</a>`
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/robots.txt" {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintf(w, `<html><head><title>Page %s</title></head><body><nav>%s</nav><main><p>About %s</p></main></body></html>`, r.URL.Path, links, r.URL.Path)
+		}))
+		defer server.Close()
+
+		tempDir := GinkgoT().TempDir()
+		kb, err := newMockKB(filepath.Join(tempDir, "state.json"), filepath.Join(tempDir, "assets"), engine.NewMockEngine())
+		Expect(err).ToNot(HaveOccurred())
+		sm := NewSourceManager(&sources.Config{})
+		defer sm.Stop()
+		sm.RegisterCollection("site", kb)
+
+		Expect(sm.AddSourceWithOptions("site", server.URL+"/sitemap.xml", time.Hour, SourceOptions{Crawl: &sources.CrawlOptions{}})).To(MatchError(ErrInvalidSource))
+		Expect(sm.AddSourceWithOptions("site", server.URL, time.Hour, SourceOptions{Crawl: &sources.CrawlOptions{MaxDepth: 1}})).To(Succeed())
+		Eventually(func() []string { return kb.ListDocuments() }, 30*time.Second, 100*time.Millisecond).Should(HaveLen(3))
+
+		pages := map[string]string{}
+		for _, key := range kb.ListDocuments() {
+			metadata, err := kb.GetEntryMetadata(key)
+			Expect(err).ToNot(HaveOccurred())
+			pages[key[strings.LastIndex(key, "--")+2:]] = metadata["url"] + " " + metadata["title"]
+		}
+		Expect(pages).To(Equal(map[string]string{
+			"index.md":       server.URL + "/ Page /",
+			"guide.md":       server.URL + "/guide Page /guide",
+			"faq_lang_en.md": server.URL + "/faq?lang=en Page /faq",
+		}))
+
+		links = `<a href="/guide">Guide</a>`
+		sm.RegisterCollection("site", kb)
+		Eventually(func() []string { return kb.ListDocuments() }, 30*time.Second, 100*time.Millisecond).Should(HaveLen(2))
+	})
+})
+
+var _ = Describe("SourceManager updates", func() {
+	It("runs one update of a source at a time, queueing the next", func() {
+		var (
+			mu                  sync.Mutex
+			inFlight, maxFlight int
+			requests            int
+		)
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			requests++
+			inFlight++
+			maxFlight = max(maxFlight, inFlight)
+			mu.Unlock()
+			<-release
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html><body><main><p>A slow page</p></main></body></html>`)
+		}))
+		defer server.Close()
+		var once sync.Once
+		unblock := func() { once.Do(func() { close(release) }) }
+		defer unblock()
+		count := func() int {
+			mu.Lock()
+			defer mu.Unlock()
+			return requests
+		}
+
+		tempDir := GinkgoT().TempDir()
+		kb, err := newMockKB(filepath.Join(tempDir, "state.json"), filepath.Join(tempDir, "assets"), engine.NewMockEngine())
+		Expect(err).ToNot(HaveOccurred())
+		sm := NewSourceManager(&sources.Config{})
+		defer sm.Stop()
+		sm.RegisterCollection("site", kb)
+
+		Expect(sm.AddSource("site", server.URL+"/page", time.Hour)).To(Succeed())
+		Eventually(count, 5*time.Second, 10*time.Millisecond).Should(Equal(1))
+		sm.RegisterCollection("site", kb)
+		sm.RegisterCollection("site", kb)
+		Consistently(count, 300*time.Millisecond, 10*time.Millisecond).Should(Equal(1))
+
+		unblock()
+		Eventually(count, 5*time.Second, 10*time.Millisecond).Should(Equal(2))
+		Consistently(count, 300*time.Millisecond, 10*time.Millisecond).Should(Equal(2))
+		mu.Lock()
+		defer mu.Unlock()
+		Expect(maxFlight).To(Equal(1))
+	})
+})
+
+var _ = Describe("SourceManager with sources stored by earlier versions", func() {
+	It("replaces and removes the entry stored under the full URL of a long web source", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html><body><main><p>Current handbook</p></main></body></html>`)
+		}))
+		defer server.Close()
+
+		url := server.URL + "/" + strings.Repeat("handbook/", 12) + "index"
+		legacy := "source-docs-" + strings.NewReplacer("://", "-", ":", "-", ".", "-", "/", "-").Replace(url) + ".txt"
+		tempDir := GinkgoT().TempDir()
+		kb, err := newMockKB(filepath.Join(tempDir, "state.json"), filepath.Join(tempDir, "assets"), engine.NewMockEngine())
+		Expect(err).ToNot(HaveOccurred())
+		seed := func() {
+			file := filepath.Join(tempDir, legacy)
+			Expect(os.WriteFile(file, []byte("Outdated handbook"), 0644)).To(Succeed())
+			_, err := kb.Store(file, map[string]string{"url": url})
+			Expect(err).ToNot(HaveOccurred())
+		}
+		seed()
+		Expect(kb.EntryExists(legacy)).To(BeTrue())
+
+		sm := NewSourceManager(&sources.Config{})
+		defer sm.Stop()
+		sm.RegisterCollection("docs", kb)
+		Expect(sm.AddSource("docs", url, time.Hour)).To(Succeed())
+		Eventually(func() bool { return kb.EntryExists(legacy) }, 30*time.Second, 100*time.Millisecond).Should(BeFalse())
+		Expect(kb.ListDocuments()).To(HaveLen(1))
+		content, _, err := kb.GetEntryFileContent(kb.ListDocuments()[0])
+		Expect(err).ToNot(HaveOccurred())
+		Expect(content).To(ContainSubstring("Current handbook"))
+
+		seed()
+		Expect(sm.RemoveSource("docs", url)).To(Succeed())
+		Expect(kb.ListDocuments()).To(BeEmpty())
+	})
+})
@@ -2,4 +2,11 @@ package sources
 
 type Config struct {
 	GitPrivateKey string
+	// GitCacheDir holds a clone of every Git source, so updates only fetch
+	// and re-index what changed. Without it every update clones the
+	// repository afresh and re-indexes all of it when it changed.
+	GitCacheDir string
+	// CredentialsKey is the AES-256 key the credentials of sources are
+	// encrypted with at rest, as derived by the CredentialsKey function.
+	CredentialsKey []byte
 }
@@ -0,0 +1,362 @@
+package sources
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mudler/localrecall/pkg/extract"
+	"github.com/mudler/xlog"
+)
+
+// DefaultCrawlDepth and DefaultCrawlPages bound crawls that do not set
+// CrawlOptions.MaxDepth and CrawlOptions.MaxPages.
+const (
+	DefaultCrawlDepth = 3
+	DefaultCrawlPages = 100
+)
+
+// MaxCrawlDelay is the longest time waited between two requests of a crawl.
+// Websites whose robots.txt asks for a longer Crawl-delay are not crawled.
+const MaxCrawlDelay = 30 * time.Second
+
+// CrawlOptions configures the crawl of a website.
+type CrawlOptions struct {
+	// MaxDepth is how many links away from the start page the crawl goes,
+	// DefaultCrawlDepth when zero.
+	MaxDepth int
+	// MaxPages is how many pages the crawl fetches at most,
+	// DefaultCrawlPages when zero.
+	MaxPages int
+	// PathPrefix restricts the crawl to the URLs whose path is under it:
+	// "/docs" covers "/docs" and "/docs/setup" but not "/docsearch". The
+	// crawl never leaves the host of the start page.
+	PathPrefix string
+	// Delay is the time waited between two requests, raised to the
+	// Crawl-delay of robots.txt. It cannot exceed MaxCrawlDelay.
+	Delay time.Duration
+}
+
+// Validate checks that the limits are not negative, that the delay does not
+// exceed MaxCrawlDelay and that the path prefix is an absolute path.
+func (o CrawlOptions) Validate() error {
+	if o.MaxDepth < 0 || o.MaxPages < 0 || o.Delay < 0 {
+		return errors.New("crawl depth, pages and delay cannot be negative")
+	}
+	if o.Delay > MaxCrawlDelay {
+		return fmt.Errorf("crawl delay cannot exceed %s", MaxCrawlDelay)
+	}
+	if o.PathPrefix != "" && !strings.HasPrefix(o.PathPrefix, "/") {
+		return fmt.Errorf("crawl path prefix %q must start with /", o.PathPrefix)
+	}
+	return nil
+}
+
+// Page is a page found by a crawl.
+type Page struct {
+	// URL is the canonical URL of the page.
+	URL   string
+	Title string
+	// Text is the main content of the page as Markdown.
+	Text string
+}
+
+// CrawlResult is what a crawl found: the pages with content, and the URLs
+// that could not be fetched.
+type CrawlResult struct {
+	Pages  []Page
+	Failed []string
+}
+
+// Crawl fetches the page at startURL and the pages it links to, breadth
+// first, up to the depth and page budget of opts. It stays on the host of
+// the start page and under the path prefix, skips what robots.txt disallows
+// for LocalRecall and waits between requests, failing when robots.txt asks
+// to wait longer than MaxCrawlDelay. A page is kept once, under
+// its canonical URL: URLs are normalized, and pages naming an already
+// crawled page as canonical are skipped. Cancelling ctx stops the crawl,
+// which then returns the pages found so far with the error of ctx.
+func Crawl(ctx context.Context, startURL string, opts CrawlOptions, creds *Credentials) (CrawlResult, error) {
+	start, err := url.Parse(startURL)
+	if err != nil {
+		return CrawlResult{}, err
+	}
+	if start.Scheme != "http" && start.Scheme != "https" {
+		return CrawlResult{}, fmt.Errorf("cannot crawl %s: not an HTTP URL", startURL)
+	}
+	normalizeURL(start)
+	maxDepth, maxPages := opts.MaxDepth, opts.MaxPages
+	if maxDepth == 0 {
+		maxDepth = DefaultCrawlDepth
+	}
+	if maxPages == 0 {
+		maxPages = DefaultCrawlPages
+	}
+
+	robots := fetchRobots(start, creds)
+	if robots.delay > MaxCrawlDelay {
+		return CrawlResult{}, fmt.Errorf("cannot crawl %s: robots.txt asks for a crawl delay of %s, more than %s", startURL, robots.delay, MaxCrawlDelay)
+	}
+	delay := max(opts.Delay, robots.delay)
+	inScope := func(u *url.URL) bool {
+		return u.Host == start.Host && underPathPrefix(u.Path, opts.PathPrefix) && robots.allowed(u.RequestURI())
+	}
+	if !inScope(start) {
+		return CrawlResult{}, fmt.Errorf("cannot crawl %s: outside of the path prefix or disallowed by robots.txt", startURL)
+	}
+
+	type queued struct {
+		u     *url.URL
+		depth int
+	}
+	queue := []queued{{start, 0}}
+	seen := map[string]bool{start.String(): true}
+	var result CrawlResult
+	for fetched := 0; len(queue) > 0 && fetched < maxPages; fetched++ {
+		next := queue[0]
+		queue = queue[1:]
+		if fetched > 0 {
+			if err := waitDelay(ctx, delay); err != nil {
+				return result, err
+			}
+		}
+
+		page, final, err := fetchHTMLPage(next.u.String(), creds)
+		if err != nil {
+			xlog.Warn("Error crawling page", "url", next.u.String(), "error", err)
+			result.Failed = append(result.Failed, next.u.String())
+			continue
+		}
+		// Redirects may lead to a page already crawled or off the site.
+		normalizeURL(final)
+		if final.String() != next.u.String() {
+			if seen[final.String()] || !inScope(final) {
+				continue
+			}
+			seen[final.String()] = true
+		}
+
+		pageURL := final
+		if canonical, err := final.Parse(page.Canonical); page.Canonical != "" && err == nil && canonical.Host == final.Host {
+			normalizeURL(canonical)
+			if canonical.String() != final.String() && seen[canonical.String()] {
+				continue
+			}
+			seen[canonical.String()] = true
+			pageURL = canonical
+		}
+		if strings.TrimSpace(page.Text) != "" {
+			result.Pages = append(result.Pages, Page{URL: pageURL.String(), Title: page.Title, Text: page.Text})
+		}
+
+		if next.depth >= maxDepth {
+			continue
+		}
+		for _, href := range page.Links {
+			link, err := final.Parse(href)
+			if err != nil || (link.Scheme != "http" && link.Scheme != "https") {
+				continue
+			}
+			normalizeURL(link)
+			if seen[link.String()] || !inScope(link) || !isPageURL(link) {
+				continue
+			}
+			seen[link.String()] = true
+			queue = append(queue, queued{link, next.depth + 1})
+		}
+	}
+	xlog.Info("Crawled website", "url", startURL, "pages", len(result.Pages), "failed", len(result.Failed), "unvisited", len(queue))
+	return result, nil
+}
+
+// waitDelay waits for delay, or until ctx is cancelled.
+func waitDelay(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// underPathPrefix reports whether a URL path is the path prefix or below it,
+// matching whole path segments only.
+func underPathPrefix(p, prefix string) bool {
+	dir := strings.TrimSuffix(prefix, "/")
+	return dir == "" || p == dir || strings.HasPrefix(p, dir+"/")
+}
+
+// fetchHTMLPage fetches an HTML page and returns it with the URL it was
+// served from after redirects.
+func fetchHTMLPage(rawURL string, creds *Credentials) (extract.HTMLPage, *url.URL, error) {
+	resp, body, err := getWeb(rawURL, creds)
+	if err != nil {
+		return extract.HTMLPage{}, nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return extract.HTMLPage{}, nil, fmt.Errorf("HTTP error: %d %s", resp.StatusCode, resp.Status)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	if !strings.Contains(contentType, "html") {
+		return extract.HTMLPage{}, nil, fmt.Errorf("not an HTML page: %s", contentType)
+	}
+	page, err := extract.ParseHTML(bytes.NewReader(body), contentType)
+	if err != nil {
+		return extract.HTMLPage{}, nil, err
+	}
+	return page, resp.Request.URL, nil
+}
+
+// normalizeURL drops the fragment, the default port and the case of the
+// scheme and host of a URL, so the same page is always written the same.
+func normalizeURL(u *url.URL) {
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if port := u.Port(); (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		u.Host = u.Hostname()
+	}
+	u.Fragment, u.RawFragment = "", ""
+	if u.Path == "" {
+		u.Path, u.RawPath = "/", ""
+	}
+}
+
+// nonPageExtensions are the extensions of links not worth crawling, such as
+// images and archives.
+var nonPageExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true,
+	".webp": true, ".ico": true, ".css": true, ".js": true, ".pdf": true,
+	".zip": true, ".gz": true, ".tar": true, ".mp3": true, ".mp4": true,
+	".woff": true, ".woff2": true, ".xml": true, ".json": true,
+}
+
+func isPageURL(u *url.URL) bool {
+	return !nonPageExtensions[strings.ToLower(path.Ext(u.Path))]
+}
+
+// robotsRules are the rules of a robots.txt that apply to LocalRecall.
+type robotsRules struct {
+	allow, disallow []robotsPattern
+	delay           time.Duration
+}
+
+// robotsPattern is a robots.txt path pattern, compiled once when parsed.
+type robotsPattern struct {
+	length int
+	expr   *regexp.Regexp
+}
+
+// newRobotsPattern compiles a robots.txt path pattern, where "*" matches
+// any characters and a trailing "$" anchors the end of the path.
+func newRobotsPattern(pattern string) robotsPattern {
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(strings.TrimSuffix(pattern, "$")), `\*`, ".*")
+	if strings.HasSuffix(pattern, "$") {
+		expr += "$"
+	}
+	return robotsPattern{length: len(pattern), expr: regexp.MustCompile(expr)}
+}
+
+// disallowAll are the rules applied when robots.txt cannot be read.
+var disallowAll = robotsRules{disallow: []robotsPattern{newRobotsPattern("/")}}
+
+// allowed reports whether a path, with its query, may be crawled: the
+// longest matching rule wins, and Allow wins ties.
+func (r robotsRules) allowed(requestURI string) bool {
+	longest := func(patterns []robotsPattern) int {
+		n := -1
+		for _, pattern := range patterns {
+			if pattern.length > n && pattern.expr.MatchString(requestURI) {
+				n = pattern.length
+			}
+		}
+		return n
+	}
+	return longest(r.allow) >= longest(r.disallow)
+}
+
+// fetchRobots reads the robots.txt of the host of u. A robots.txt answered
+// with a client error, such as 404, allows everything. One that cannot be
+// fetched, because of a network or server error, disallows everything, as
+// the website may have rules it could not serve.
+func fetchRobots(u *url.URL, creds *Credentials) robotsRules {
+	robotsURL := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+	resp, body, err := getWeb(robotsURL.String(), creds)
+	switch {
+	case err != nil:
+		xlog.Warn("Error fetching robots.txt, not crawling", "url", robotsURL.String(), "error", err)
+		return disallowAll
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return robotsRules{}
+	case resp.StatusCode != http.StatusOK:
+		xlog.Warn("Error fetching robots.txt, not crawling", "url", robotsURL.String(), "status", resp.Status)
+		return disallowAll
+	}
+	return parseRobots(string(body))
+}
+
+// parseRobots returns the rules of the group of a robots.txt naming
+// LocalRecall, or else of the "*" group.
+func parseRobots(text string) robotsRules {
+	groups := map[string]*robotsRules{}
+	var current []*robotsRules
+	inAgents := false
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		field, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		field, value = strings.ToLower(strings.TrimSpace(field)), strings.TrimSpace(value)
+		if field == "user-agent" {
+			if !inAgents {
+				current = nil
+			}
+			inAgents = true
+			agent := strings.ToLower(value)
+			if groups[agent] == nil {
+				groups[agent] = &robotsRules{}
+			}
+			current = append(current, groups[agent])
+			continue
+		}
+		inAgents = false
+		for _, rules := range current {
+			switch field {
+			case "allow":
+				if value != "" {
+					rules.allow = append(rules.allow, newRobotsPattern(value))
+				}
+			case "disallow":
+				if value != "" {
+					rules.disallow = append(rules.disallow, newRobotsPattern(value))
+				}
+			case "crawl-delay":
+				if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+					rules.delay = time.Duration(seconds * float64(time.Second))
+				}
+			}
+		}
+	}
+	if rules, ok := groups["localrecall"]; ok {
+		return *rules
+	}
+	if rules, ok := groups["*"]; ok {
+		return *rules
+	}
+	return robotsRules{}
+}
@@ -0,0 +1,183 @@
+package sources_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"time"
+
+	. "github.com/mudler/localrecall/rag/sources"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Crawl", func() {
+	var (
+		server       *httptest.Server
+		requested    []string
+		robots       string
+		robotsStatus int
+	)
+
+	pages := map[string]string{
+		"/":              `<a href="/docs/">Docs</a> <a href="/old">Old docs</a> <a href="/blog/">Blog</a> <a href="https://elsewhere.example/">Out</a>`,
+		"/docs/":         `<a href="intro#top">Intro</a> <a href="/docs/setup">Setup</a> <a href="/docs/logo.png">Logo</a> <a href="/private/keys">Keys</a>`,
+		"/docs/intro":    `<a href="/docs/deep">Deep</a>`,
+		"/docs/setup":    `<a href="/docs/intro">Intro</a>`,
+		"/docs/deep":     `<a href="/docs/deeper">Deeper</a>`,
+		"/docs/deeper":   ``,
+		"/docs/print":    `<a href="/docs/setup">Setup</a>`,
+		"/blog/":         `<a href="/docs/print">Print</a>`,
+		"/private/keys":  ``,
+		"/docs/logo.png": ``,
+	}
+
+	BeforeEach(func() {
+		requested = nil
+		robots = "User-agent: *\nDisallow: /private/\n"
+		robotsStatus = http.StatusOK
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requested = append(requested, r.URL.RequestURI())
+			if r.URL.Path == "/robots.txt" {
+				w.WriteHeader(robotsStatus)
+				w.Write([]byte(robots))
+				return
+			}
+			if r.URL.Path == "/huge" {
+				w.Header().Set("Content-Type", "text/html")
+				w.Write([]byte("<html><body><p>" + strings.Repeat("x", MaxPageSize) + "</p></body></html>"))
+				return
+			}
+			if r.URL.Path == "/old" {
+				http.Redirect(w, r, "/docs/", http.StatusMovedPermanently)
+				return
+			}
+			links, ok := pages[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			head := ""
+			if r.URL.Path == "/docs/print" {
+				head = `<link rel="canonical" href="/docs/setup">`
+			}
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintf(w, `<html><head><title>%s</title>%s</head><body><nav>%s</nav><main><p>Content of %s</p></main></body></html>`, r.URL.Path, head, links, r.URL.Path)
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	urls := func(result CrawlResult) []string {
+		var out []string
+		for _, page := range result.Pages {
+			out = append(out, strings.TrimPrefix(page.URL, server.URL))
+		}
+		sort.Strings(out)
+		return out
+	}
+
+	It("follows links on the same host up to the depth, once per canonical URL", func() {
+		result, err := Crawl(context.Background(), server.URL, CrawlOptions{MaxDepth: 3}, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(urls(result)).To(Equal([]string{"/", "/blog/", "/docs/", "/docs/deep", "/docs/intro", "/docs/setup"}))
+		Expect(result.Failed).To(BeEmpty())
+		Expect(requested).ToNot(ContainElement("/private/keys"))
+		Expect(requested).ToNot(ContainElement("/docs/logo.png"))
+		// The redirect to a crawled page is fetched but not kept twice.
+		Expect(requested).To(ContainElement("/old"))
+
+		for _, page := range result.Pages {
+			if page.URL == server.URL+"/docs/intro" {
+				Expect(page.Title).To(Equal("/docs/intro"))
+				Expect(page.Text).To(ContainSubstring("Content of /docs/intro"))
+			}
+		}
+	})
+
+	It("stays under the path prefix and within the page budget", func() {
+		result, err := Crawl(context.Background(), server.URL+"/docs/", CrawlOptions{PathPrefix: "/docs/", MaxPages: 3}, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(urls(result)).To(Equal([]string{"/docs/", "/docs/intro", "/docs/setup"}))
+
+		_, err = Crawl(context.Background(), server.URL+"/blog/", CrawlOptions{PathPrefix: "/docs/"}, nil)
+		Expect(err).To(HaveOccurred())
+
+		// The prefix matches whole path segments.
+		result, err = Crawl(context.Background(), server.URL+"/docs/", CrawlOptions{PathPrefix: "/docs", MaxPages: 1}, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(urls(result)).To(Equal([]string{"/docs/"}))
+		_, err = Crawl(context.Background(), server.URL+"/docsearch", CrawlOptions{PathPrefix: "/docs"}, nil)
+		Expect(err).To(MatchError(ContainSubstring("outside of the path prefix")))
+	})
+
+	It("honours the rules and crawl delay of robots.txt for LocalRecall", func() {
+		robots = "User-agent: *\nDisallow: /docs/\n\nUser-agent: LocalRecall\nAllow: /docs/\nDisallow: /docs/*tro$\nCrawl-delay: 0.05\n"
+		started := time.Now()
+		result, err := Crawl(context.Background(), server.URL+"/docs/", CrawlOptions{MaxDepth: 1}, nil)
+		Expect(err).ToNot(HaveOccurred())
+		// The LocalRecall group replaces the "*" one.
+		Expect(urls(result)).To(Equal([]string{"/docs/", "/docs/setup", "/private/keys"}))
+		Expect(time.Since(started)).To(BeNumerically(">=", 50*time.Millisecond))
+	})
+
+	It("allows everything when robots.txt is missing", func() {
+		robotsStatus = http.StatusNotFound
+		result, err := Crawl(context.Background(), server.URL+"/private/keys", CrawlOptions{}, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.Failed).To(BeEmpty())
+	})
+
+	It("does not crawl websites whose robots.txt cannot be fetched", func() {
+		robotsStatus = http.StatusServiceUnavailable
+		_, err := Crawl(context.Background(), server.URL, CrawlOptions{}, nil)
+		Expect(err).To(MatchError(ContainSubstring("disallowed by robots.txt")))
+		Expect(requested).To(Equal([]string{"/robots.txt"}))
+
+		closed := httptest.NewServer(http.NotFoundHandler())
+		closed.Close()
+		_, err = Crawl(context.Background(), closed.URL, CrawlOptions{}, nil)
+		Expect(err).To(MatchError(ContainSubstring("disallowed by robots.txt")))
+	})
+
+	It("stops waiting between requests when cancelled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(100*time.Millisecond, cancel)
+		started := time.Now()
+		result, err := Crawl(ctx, server.URL+"/docs/", CrawlOptions{Delay: MaxCrawlDelay}, nil)
+		Expect(err).To(MatchError(context.Canceled))
+		Expect(time.Since(started)).To(BeNumerically("<", 5*time.Second))
+		Expect(urls(result)).To(Equal([]string{"/docs/"}))
+	})
+
+	It("does not crawl websites asking for a crawl delay above the maximum", func() {
+		robots = "User-agent: *\nCrawl-delay: 3600\n"
+		started := time.Now()
+		_, err := Crawl(context.Background(), server.URL, CrawlOptions{}, nil)
+		Expect(err).To(MatchError(ContainSubstring("crawl delay")))
+		Expect(time.Since(started)).To(BeNumerically("<", 5*time.Second))
+		Expect(requested).To(Equal([]string{"/robots.txt"}))
+	})
+
+	It("fails to fetch pages larger than the maximum page size", func() {
+		_, err := FetchWebPage(server.URL + "/huge")
+		Expect(err).To(MatchError(ContainSubstring("larger than")))
+
+		result, err := Crawl(context.Background(), server.URL+"/huge", CrawlOptions{}, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.Pages).To(BeEmpty())
+		Expect(result.Failed).To(Equal([]string{server.URL + "/huge"}))
+	})
+
+	It("rejects invalid options", func() {
+		Expect(CrawlOptions{MaxDepth: 2, PathPrefix: "/docs"}.Validate()).To(Succeed())
+		Expect(CrawlOptions{MaxPages: -1}.Validate()).ToNot(Succeed())
+		Expect(CrawlOptions{PathPrefix: "docs"}.Validate()).ToNot(Succeed())
+		Expect(CrawlOptions{Delay: MaxCrawlDelay + time.Second}.Validate()).ToNot(Succeed())
+	})
+})
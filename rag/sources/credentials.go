@@ -0,0 +1,209 @@
+package sources
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Redacted replaces secret values in redacted credentials.
+const Redacted = "[REDACTED]"
+
+// Credentials authenticate the requests made to fetch a source.
+type Credentials struct {
+	// SSHKey is a base64-encoded SSH private key for Git repositories cloned
+	// over SSH, like GIT_PRIVATE_KEY.
+	SSHKey string `json:"ssh_key,omitempty"`
+	// Token is an access token. Git repositories cloned over HTTPS get it as
+	// the password of Username, "x-access-token" by default; web pages get
+	// it as a bearer token.
+	Token string `json:"token,omitempty"`
+	// Username and Password authenticate with HTTP basic auth.
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// Headers and Cookies are sent with every web page request.
+	Headers map[string]string `json:"headers,omitempty"`
+	Cookies map[string]string `json:"cookies,omitempty"`
+}
+
+// Redacted returns a copy of the credentials with every secret and the
+// username replaced by Redacted, keeping the names of headers and cookies.
+func (c *Credentials) Redacted() *Credentials {
+	if c == nil {
+		return nil
+	}
+	redact := func(s string) string {
+		if s == "" {
+			return ""
+		}
+		return Redacted
+	}
+	redacted := &Credentials{
+		SSHKey:   redact(c.SSHKey),
+		Token:    redact(c.Token),
+		Username: redact(c.Username),
+		Password: redact(c.Password),
+	}
+	for name := range c.Headers {
+		if redacted.Headers == nil {
+			redacted.Headers = map[string]string{}
+		}
+		redacted.Headers[name] = Redacted
+	}
+	for name := range c.Cookies {
+		if redacted.Cookies == nil {
+			redacted.Cookies = map[string]string{}
+		}
+		redacted.Cookies[name] = Redacted
+	}
+	return redacted
+}
+
+// apply authenticates a web request.
+func (c *Credentials) apply(req *http.Request) {
+	if c == nil {
+		return
+	}
+	for name, value := range c.Headers {
+		req.Header.Set(name, value)
+	}
+	for name, value := range c.Cookies {
+		req.AddCookie(&http.Cookie{Name: name, Value: value})
+	}
+	switch {
+	case c.Token != "":
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	case c.Username != "" || c.Password != "":
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+}
+
+// strip removes what apply added to a request, for a redirect leaving the
+// host of the source.
+func (c *Credentials) strip(req *http.Request) {
+	if c == nil {
+		return
+	}
+	for name := range c.Headers {
+		req.Header.Del(name)
+	}
+	req.Header.Del("Cookie")
+	req.Header.Del("Authorization")
+}
+
+// forURL returns the credentials of a source at sourceURL to use for a
+// request to target: none when target is on another host, so a sitemap
+// cannot send them elsewhere.
+func (c *Credentials) forURL(sourceURL, target string) *Credentials {
+	from, err := url.Parse(sourceURL)
+	if err != nil {
+		return nil
+	}
+	to, err := url.Parse(target)
+	if err != nil || to.Host != from.Host {
+		return nil
+	}
+	return c
+}
+
+// CheckCredentialsURL returns an error when the credentials of a source at
+// sourceURL would be sent in the clear: over plain HTTP to a host other than
+// a loopback address, or over the unauthenticated Git protocol. HTTPS and SSH,
+// including the user@host:path form of Git repositories, are accepted.
+func CheckCredentialsURL(sourceURL string) error {
+	u, err := url.Parse(sourceURL)
+	if err != nil || u.Scheme == "" {
+		if IsGitRepository(sourceURL) && strings.Contains(sourceURL, ":") {
+			return nil
+		}
+		return fmt.Errorf("credentials need an HTTPS or SSH URL, not %q", sourceURL)
+	}
+	if !secureURL(u) {
+		return fmt.Errorf("credentials would be sent in the clear to %s: use an HTTPS or SSH URL", u.Redacted())
+	}
+	return nil
+}
+
+// secureURL reports whether requests to u are encrypted, or stay on the
+// local machine.
+func secureURL(u *url.URL) bool {
+	switch strings.ToLower(u.Scheme) {
+	case "https", "ssh":
+		return true
+	case "http":
+		host := u.Hostname()
+		if strings.EqualFold(host, "localhost") {
+			return true
+		}
+		ip := net.ParseIP(host)
+		return ip != nil && ip.IsLoopback()
+	}
+	return false
+}
+
+// CredentialsKey derives the key credentials are sealed with from a secret.
+func CredentialsKey(secret string) []byte {
+	key := sha256.Sum256([]byte(secret))
+	return key[:]
+}
+
+// SealCredentials encrypts the credentials of the source at sourceURL with
+// AES-256-GCM, returning them base64-encoded. The URL is authenticated with
+// them, so they cannot be opened for another source.
+func SealCredentials(c *Credentials, key []byte, sourceURL string) (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := credentialsCipher(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, data, []byte(sourceURL))), nil
+}
+
+// OpenCredentials decrypts credentials sealed by SealCredentials for the
+// source at sourceURL.
+func OpenCredentials(sealed string, key []byte, sourceURL string) (*Credentials, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := credentialsCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("sealed credentials are too short")
+	}
+	data, err = gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], []byte(sourceURL))
+	if err != nil {
+		return nil, errors.New("cannot decrypt credentials: wrong key or source, or corrupted data")
+	}
+	c := &Credentials{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func credentialsCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,131 @@
+package sources_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/mudler/localrecall/rag/sources"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Credentials", func() {
+	creds := &Credentials{
+		Token:   "s3cr3t-token",
+		Headers: map[string]string{"X-Api-Key": "k3y"},
+		Cookies: map[string]string{"session": "c00kie"},
+	}
+
+	It("seals credentials so only the same key opens them, for the same source", func() {
+		key := CredentialsKey("server secret")
+		sealed, err := SealCredentials(creds, key, "https://docs.example.com/")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sealed).ToNot(ContainSubstring("s3cr3t"))
+
+		opened, err := OpenCredentials(sealed, key, "https://docs.example.com/")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(opened).To(Equal(creds))
+
+		_, err = OpenCredentials(sealed, CredentialsKey("another secret"), "https://docs.example.com/")
+		Expect(err).To(HaveOccurred())
+		_, err = OpenCredentials(sealed, key, "https://evil.example.com/")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("only accepts URLs that do not send credentials in the clear", func() {
+		for _, u := range []string{
+			"https://docs.example.com/",
+			"https://github.com/org/private.git",
+			"ssh://git@github.com/org/private.git",
+			"git@github.com:org/private.git",
+			"http://localhost:8080/",
+			"http://127.0.0.1/sitemap.xml",
+			"http://[::1]/",
+		} {
+			Expect(CheckCredentialsURL(u)).To(Succeed(), u)
+		}
+		for _, u := range []string{
+			"http://docs.example.com/",
+			"http://10.0.0.1/",
+			"git://github.com/org/private.git",
+			"docs.example.com",
+		} {
+			Expect(CheckCredentialsURL(u)).ToNot(Succeed(), u)
+		}
+	})
+
+	It("redacts every secret", func() {
+		redacted := (&Credentials{Username: "bob", Password: "pw", SSHKey: "key", Headers: creds.Headers}).Redacted()
+		Expect(redacted).To(Equal(&Credentials{
+			Username: Redacted,
+			Password: Redacted,
+			SSHKey:   Redacted,
+			Headers:  map[string]string{"X-Api-Key": Redacted},
+		}))
+		Expect(creds.Headers).To(HaveKeyWithValue("X-Api-Key", "k3y"))
+	})
+
+	It("authenticates web pages and sitemaps on their own host only", func() {
+		var otherHeaders http.Header
+		other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			otherHeaders = r.Header.Clone()
+			w.Write([]byte(`<html><body><article><p>Elsewhere</p></article></body></html>`))
+		}))
+		defer other.Close()
+
+		var server *httptest.Server
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cookie, err := r.Cookie("session")
+			if r.Header.Get("Authorization") != "Bearer s3cr3t-token" || r.Header.Get("X-Api-Key") != "k3y" || err != nil || cookie.Value != "c00kie" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if strings.HasSuffix(r.URL.Path, "sitemap.xml") {
+				fmt.Fprintf(w, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><url><loc>%s/page</loc></url><url><loc>%s/page</loc></url></urlset>`, server.URL, other.URL)
+				return
+			}
+			w.Write([]byte(`<html><body><article><p>Members only</p></article></body></html>`))
+		}))
+		defer server.Close()
+
+		_, err := SourceRouter(server.URL+"/page", &Config{})
+		Expect(err).To(HaveOccurred())
+
+		content, err := FetchSource(server.URL+"/page", &Config{}, creds)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(content.Text).To(ContainSubstring("Members only"))
+
+		content, err = FetchSource(server.URL+"/sitemap.xml", &Config{}, creds)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(content.Text).To(ContainSubstring("Members only"))
+		Expect(content.Text).To(ContainSubstring("Elsewhere"))
+		Expect(otherHeaders.Get("Authorization")).To(BeEmpty())
+		Expect(otherHeaders.Get("X-Api-Key")).To(BeEmpty())
+		Expect(otherHeaders.Get("Cookie")).To(BeEmpty())
+	})
+
+	It("drops the credentials on redirects to another host", func() {
+		var otherHeaders http.Header
+		other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			otherHeaders = r.Header.Clone()
+			w.Write([]byte(`<html><body><article><p>Moved elsewhere</p></article></body></html>`))
+		}))
+		defer other.Close()
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.Header.Get("X-Api-Key")).To(Equal("k3y"))
+			http.Redirect(w, r, other.URL+"/page", http.StatusFound)
+		}))
+		defer server.Close()
+
+		content, err := FetchSource(server.URL+"/page", &Config{}, creds)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(content.Text).To(ContainSubstring("Moved elsewhere"))
+		Expect(otherHeaders.Get("User-Agent")).To(ContainSubstring("LocalRecall"))
+		Expect(otherHeaders.Get("Authorization")).To(BeEmpty())
+		Expect(otherHeaders.Get("X-Api-Key")).To(BeEmpty())
+		Expect(otherHeaders.Get("Cookie")).To(BeEmpty())
+	})
+})
@@ -2,16 +2,145 @@ package sources
 
 import (
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
 )
 
-// GetGitRepositoryContent fetches and processes content from a Git repository
+// GitSync is the outcome of syncing a Git repository: the commit it was
+// synced to and the text files that changed since the previous sync.
+type GitSync struct {
+	Commit string
+	// Files holds the text files added or modified since the previous
+	// commit, or every text file when Full is set.
+	Files []File
+	// Removed holds the paths of the text files removed since the previous
+	// commit.
+	Removed []string
+	// Full is set when the previous commit was unknown, so Files lists the
+	// whole repository and files missing from it should be dropped.
+	Full bool
+}
+
+// GitOptions narrows a Git source down to a ref and the files worth
+// indexing.
+type GitOptions struct {
+	// Ref is the branch, tag or commit to index, the default branch when
+	// empty.
+	Ref string
+	// Subdir restricts the source to the files under a directory of the
+	// repository.
+	Subdir string
+	// Include and Exclude are glob patterns matched against the paths of the
+	// files in the repository. A pattern without a slash matches the file
+	// name in any directory, and "**" matches any number of directories.
+	// When Include is set only the files it matches are indexed; files
+	// matched by Exclude are never indexed.
+	Include []string
+	Exclude []string
+	// MaxFileSize skips the files larger than it, in bytes, when positive.
+	MaxFileSize int64
+}
+
+// Validate checks that the subdirectory stays within the repository and that
+// the glob patterns are well formed.
+func (o GitOptions) Validate() error {
+	if o.Subdir != "" {
+		subdir := path.Clean(strings.Trim(o.Subdir, "/"))
+		if subdir == ".." || strings.HasPrefix(subdir, "../") {
+			return fmt.Errorf("subdirectory %q is outside the repository", o.Subdir)
+		}
+	}
+	for _, pattern := range append(append([]string{}, o.Include...), o.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+			return fmt.Errorf("invalid glob pattern %q", pattern)
+		}
+	}
+	if o.MaxFileSize < 0 {
+		return fmt.Errorf("invalid maximum file size %d", o.MaxFileSize)
+	}
+	return nil
+}
+
+// selects reports whether the file at a path of a Git tree is indexed: a
+// regular text file, not a symbolic link or submodule, that passes the
+// filters.
+func (o GitOptions) selects(name string, f *object.File) bool {
+	if f.Mode != filemode.Regular && f.Mode != filemode.Executable || !isTextFile(name) {
+		return false
+	}
+	if o.MaxFileSize > 0 && f.Size > o.MaxFileSize {
+		return false
+	}
+	if subdir := path.Clean(strings.Trim(o.Subdir, "/")); subdir != "." && !strings.HasPrefix(name, subdir+"/") {
+		return false
+	}
+	for _, pattern := range o.Exclude {
+		if matchGlob(pattern, name) {
+			return false
+		}
+	}
+	if len(o.Include) == 0 {
+		return true
+	}
+	for _, pattern := range o.Include {
+		if matchGlob(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches a slash-separated path against a glob pattern. A pattern
+// without a slash matches the last element of the path; otherwise the
+// pattern matches the whole path, a "**" element standing for any number of
+// directories.
+func matchGlob(pattern, name string) bool {
+	pattern = strings.TrimPrefix(pattern, "/")
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(name))
+		return ok
+	}
+	return matchElements(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchElements(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchElements(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// GetGitRepositoryContent clones the default branch of a Git repository and
+// returns its text files concatenated, each after a line naming its path.
 func GetGitRepositoryContent(url string, privateKey string) (string, error) {
 	// Create a temporary directory for cloning
 	tempDir, err := os.MkdirTemp("", "git-repo-*")
@@ -20,66 +149,244 @@ func GetGitRepositoryContent(url string, privateKey string) (string, error) {
 	}
 	defer os.RemoveAll(tempDir)
 
-	// Configure clone options
-	cloneOptions := &git.CloneOptions{
-		URL:           url,
-		Depth:         1,
-		SingleBranch:  true,
-		ReferenceName: plumbing.HEAD,
+	var creds *Credentials
+	if privateKey != "" {
+		creds = &Credentials{SSHKey: privateKey}
 	}
+	sync, err := SyncGitRepository(url, creds, tempDir, "", GitOptions{})
+	if err != nil {
+		return "", err
+	}
+	return gitRepositoryText(sync.Files), nil
+}
 
-	// If private key is provided, use SSH authentication
-	if privateKey != "" {
-		// Decode base64 private key
-		keyBytes, err := base64.StdEncoding.DecodeString(privateKey)
-		if err != nil {
-			return "", err
+// gitRepositoryText concatenates the files of a repository, each after a
+// line naming its path.
+func gitRepositoryText(files []File) string {
+	var content strings.Builder
+	for _, file := range files {
+		content.WriteString("\n--- File: " + file.Path + " ---\n")
+		content.Write(file.Content)
+		content.WriteString("\n")
+	}
+	return content.String()
+}
+
+// SyncGitRepository brings the bare clone of a Git repository kept in dir up
+// to date with the ref selected by opts, the remote default branch unless
+// set, creating the clone on first use. It returns the files selected by
+// opts that changed since lastCommit by diffing the trees of the two
+// commits. Without a lastCommit, or when it is not in the clone, every
+// selected file is returned.
+func SyncGitRepository(url string, creds *Credentials, dir, lastCommit string, opts GitOptions) (GitSync, error) {
+	auth, err := gitAuth(url, creds)
+	if err != nil {
+		return GitSync{}, err
+	}
+	// A commit never changes, so a clone already synced to it is up to date.
+	if plumbing.IsHash(opts.Ref) && lastCommit == opts.Ref {
+		if _, err := os.Stat(dir); err == nil {
+			return GitSync{Commit: lastCommit}, nil
 		}
+	}
 
-		// Create SSH auth method from the decoded key
-		auth, err := ssh.NewPublicKeys("git", keyBytes, "")
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		// No usable clone yet: start from scratch.
+		if err := os.RemoveAll(dir); err != nil {
+			return GitSync{}, err
+		}
+		if repo, err = git.PlainInit(dir, true); err == nil {
+			_, err = repo.CreateRemote(&config.RemoteConfig{Name: git.DefaultRemoteName, URLs: []string{url}})
+		}
 		if err != nil {
-			return "", err
+			os.RemoveAll(dir)
+			return GitSync{}, err
 		}
-		cloneOptions.Auth = auth
 	}
 
-	// Clone the repository
-	_, err = git.PlainClone(tempDir, false, cloneOptions)
+	hash, err := fetchGitRef(repo, auth, opts.Ref)
 	if err != nil {
-		return "", err
+		return GitSync{}, err
+	}
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return GitSync{}, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return GitSync{}, err
+	}
+	sync := GitSync{Commit: commit.Hash.String()}
+	if lastCommit == sync.Commit {
+		return sync, nil
 	}
 
-	// Walk through the repository and collect content
-	var content strings.Builder
-	err = filepath.Walk(tempDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+	var previous *object.Tree
+	if lastCommit != "" {
+		if old, err := repo.CommitObject(plumbing.NewHash(lastCommit)); err == nil {
+			previous, _ = old.Tree()
 		}
+	}
+	if previous == nil {
+		sync.Full = true
+		err = tree.Files().ForEach(func(f *object.File) error {
+			if !opts.selects(f.Name, f) {
+				return nil
+			}
+			content, err := readBlob(f)
+			if err != nil {
+				return err
+			}
+			sync.Files = append(sync.Files, File{Path: f.Name, Content: content})
+			return nil
+		})
+		return sync, err
+	}
 
-		// Skip .git directory and binary files
-		if info.IsDir() && info.Name() == ".git" {
-			return filepath.SkipDir
+	changes, err := object.DiffTree(previous, tree)
+	if err != nil {
+		return GitSync{}, err
+	}
+	for _, change := range changes {
+		// The files of a change are named after their base name only.
+		from, to, err := change.Files()
+		if err != nil {
+			return GitSync{}, err
 		}
-
-		// Only process text files
-		if !info.IsDir() && isTextFile(path) {
-			fileContent, err := os.ReadFile(path)
+		kept := to != nil && opts.selects(change.To.Name, to)
+		if from != nil && opts.selects(change.From.Name, from) && (!kept || change.To.Name != change.From.Name) {
+			sync.Removed = append(sync.Removed, change.From.Name)
+		}
+		if kept {
+			content, err := readBlob(to)
 			if err != nil {
-				return err
+				return GitSync{}, err
+			}
+			sync.Files = append(sync.Files, File{Path: change.To.Name, Content: content})
+		}
+	}
+	return sync, nil
+}
+
+// fetchGitRef fetches the tip of ref from the remote of a clone and returns
+// the commit it points to. The ref is looked up among the remote branches
+// and tags; a full or abbreviated commit hash that is neither is fetched with
+// the history of every branch and tag to be found.
+func fetchGitRef(repo *git.Repository, auth transport.AuthMethod, ref string) (plumbing.Hash, error) {
+	remote, err := repo.Remote(git.DefaultRemoteName)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	refs, err := remote.List(&git.ListOptions{Auth: auth})
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	var name plumbing.ReferenceName
+	for _, candidate := range refs {
+		switch {
+		case ref == "" && candidate.Name() == plumbing.HEAD:
+			// The default branch, when the remote tells which one it is.
+			if candidate.Type() == plumbing.SymbolicReference {
+				name = candidate.Target()
 			}
-			content.WriteString("\n--- File: " + strings.TrimPrefix(path, tempDir+"/") + " ---\n")
-			content.Write(fileContent)
-			content.WriteString("\n")
+		case ref != "" && (candidate.Name().String() == ref || candidate.Name() == plumbing.NewBranchReferenceName(ref)):
+			name = candidate.Name()
+		case ref != "" && name == "" && candidate.Name() == plumbing.NewTagReferenceName(ref):
+			name = candidate.Name()
 		}
-		return nil
+	}
+
+	var specs []config.RefSpec
+	depth := 1
+	switch {
+	case name != "":
+		specs = []config.RefSpec{config.RefSpec("+" + name + ":" + name)}
+	case ref == "":
+		name = plumbing.NewRemoteHEADReferenceName(git.DefaultRemoteName)
+		specs = []config.RefSpec{config.RefSpec("+" + plumbing.HEAD + ":" + name)}
+	case isAbbreviatedHash(ref):
+		// Servers rarely serve commits by hash, so fetch the history that
+		// leads to it instead.
+		specs, depth = []config.RefSpec{"+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*"}, 0
+	default:
+		return plumbing.ZeroHash, fmt.Errorf("ref %q not found in %s", ref, remote.Config().URLs[0])
+	}
+	err = repo.Fetch(&git.FetchOptions{
+		RemoteName: git.DefaultRemoteName,
+		RefSpecs:   specs,
+		Auth:       auth,
+		Depth:      depth,
+		Force:      true,
+		Tags:       git.NoTags,
 	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return plumbing.ZeroHash, err
+	}
 
+	revision := plumbing.Revision(name)
+	if depth == 0 {
+		revision = plumbing.Revision(ref)
+	}
+	hash, err := repo.ResolveRevision(revision)
 	if err != nil {
-		return "", err
+		return plumbing.ZeroHash, fmt.Errorf("resolving %s: %w", revision, err)
+	}
+	return *hash, nil
+}
+
+// isAbbreviatedHash reports whether ref could be a full or abbreviated commit
+// hash.
+func isAbbreviatedHash(ref string) bool {
+	if len(ref) < 4 || len(ref) > 40 {
+		return false
 	}
+	_, err := hex.DecodeString(ref + strings.Repeat("0", len(ref)%2))
+	return err == nil
+}
 
-	return content.String(), nil
+// gitAuth returns the authentication for a repository: a token or basic
+// auth for repositories cloned over HTTP(S), the SSH key otherwise, or nil
+// without credentials.
+func gitAuth(url string, creds *Credentials) (transport.AuthMethod, error) {
+	if creds == nil {
+		return nil, nil
+	}
+	if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
+		switch {
+		case creds.Token != "":
+			username := creds.Username
+			if username == "" {
+				// Any username goes with a token on GitHub and GitLab.
+				username = "x-access-token"
+			}
+			return &githttp.BasicAuth{Username: username, Password: creds.Token}, nil
+		case creds.Username != "" || creds.Password != "":
+			return &githttp.BasicAuth{Username: creds.Username, Password: creds.Password}, nil
+		}
+		return nil, nil
+	}
+	if creds.SSHKey == "" {
+		return nil, nil
+	}
+	// Decode base64 private key
+	keyBytes, err := base64.StdEncoding.DecodeString(creds.SSHKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create SSH auth method from the decoded key
+	return ssh.NewPublicKeys("git", keyBytes, "")
+}
+
+func readBlob(f *object.File) ([]byte, error) {
+	r, err := f.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
 }
 
 // isTextFile checks if a file is likely to be a text file
@@ -88,9 +395,11 @@ func isTextFile(path string) bool {
 	// List of common text file extensions
 	textExtensions := map[string]bool{
 		".txt": true, ".md": true, ".go": true, ".py": true, ".js": true,
-		".ts": true, ".html": true, ".css": true, ".json": true, ".yaml": true,
+		".jsx": true, ".mjs": true, ".cjs": true, ".ts": true, ".tsx": true,
+		".html": true, ".css": true, ".json": true, ".yaml": true,
 		".yml": true, ".xml": true, ".sh": true, ".bash": true, ".c": true,
-		".cpp": true, ".h": true, ".hpp": true, ".java": true, ".rb": true,
+		".cc": true, ".cpp": true, ".h": true, ".hpp": true, ".cs": true,
+		".java": true, ".rb": true,
 		".php": true, ".rs": true, ".swift": true, ".kt": true, ".scala": true,
 		".sql": true, ".proto": true, ".toml": true, ".ini": true, ".conf": true,
 		".log": true, ".csv": true, ".tsv": true, ".rst": true, ".tex": true,
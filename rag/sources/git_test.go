@@ -0,0 +1,131 @@
+package sources_test
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	. "github.com/mudler/localrecall/rag/sources"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SyncGitRepository", func() {
+	var (
+		repoDir string
+		repo    *git.Repository
+		tree    *git.Worktree
+	)
+
+	signature := &object.Signature{Name: "dev", Email: "dev@example.com", When: time.Now()}
+	commit := func(files map[string]string) plumbing.Hash {
+		for name, content := range files {
+			Expect(os.MkdirAll(filepath.Dir(filepath.Join(repoDir, name)), 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(repoDir, name), []byte(content), 0644)).To(Succeed())
+			_, err := tree.Add(name)
+			Expect(err).ToNot(HaveOccurred())
+		}
+		hash, err := tree.Commit("update", &git.CommitOptions{Author: signature})
+		Expect(err).ToNot(HaveOccurred())
+		return hash
+	}
+	paths := func(sync GitSync) []string {
+		var out []string
+		for _, f := range sync.Files {
+			out = append(out, f.Path)
+		}
+		sort.Strings(out)
+		return out
+	}
+
+	BeforeEach(func() {
+		var err error
+		repoDir = filepath.Join(GinkgoT().TempDir(), "project")
+		repo, err = git.PlainInit(repoDir, false)
+		Expect(err).ToNot(HaveOccurred())
+		tree, err = repo.Worktree()
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("syncs the default branch, a branch, a tag or a commit", func() {
+		first := commit(map[string]string{"README.md": "v1", "main.go": "package main"})
+		_, err := repo.CreateTag("v1.0", first, &git.CreateTagOptions{Tagger: signature, Message: "v1.0"})
+		Expect(err).ToNot(HaveOccurred())
+		second := commit(map[string]string{"README.md": "v2", "logo.png": "binary"})
+		Expect(tree.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("release"), Hash: first, Create: true})).To(Succeed())
+		release := commit(map[string]string{"CHANGELOG.md": "fixes"})
+		Expect(tree.Checkout(&git.CheckoutOptions{Branch: plumbing.Master})).To(Succeed())
+
+		for ref, expected := range map[string]plumbing.Hash{
+			"":                   second,
+			"release":            release,
+			"refs/heads/release": release,
+			"v1.0":               first,
+			first.String():       first,
+			first.String()[:8]:   first,
+			"refs/tags/v1.0":     first,
+			"refs/heads/master":  second,
+		} {
+			sync, err := SyncGitRepository(repoDir, nil, filepath.Join(GinkgoT().TempDir(), "clone"), "", GitOptions{Ref: ref})
+			Expect(err).ToNot(HaveOccurred(), ref)
+			Expect(sync.Commit).To(Equal(expected.String()), ref)
+			Expect(sync.Full).To(BeTrue())
+		}
+
+		_, err = SyncGitRepository(repoDir, nil, filepath.Join(GinkgoT().TempDir(), "clone"), "", GitOptions{Ref: "missing"})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("returns only the files under the subdirectory that pass the filters", func() {
+		commit(map[string]string{
+			"README.md":              "root",
+			"docs/guide.md":          "guide",
+			"docs/api/index.md":      "api",
+			"docs/api/gen.pb.go":     "package api",
+			"docs/vendor/lib/doc.md": "vendored",
+			"docs/huge.txt":          strings.Repeat("x", 100),
+		})
+		dir := filepath.Join(GinkgoT().TempDir(), "clone")
+		opts := GitOptions{Subdir: "/docs/", Exclude: []string{"**/vendor/**", "*.pb.go"}, MaxFileSize: 50}
+		sync, err := SyncGitRepository(repoDir, nil, dir, "", opts)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(paths(sync)).To(Equal([]string{"docs/api/index.md", "docs/guide.md"}))
+
+		opts.Exclude = []string{"docs/vendor/**"}
+		opts.Include = []string{"**/api/*"}
+		sync, err = SyncGitRepository(repoDir, nil, dir, "", opts)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(paths(sync)).To(Equal([]string{"docs/api/gen.pb.go", "docs/api/index.md"}))
+
+		// A file growing past the size limit leaves the source.
+		previous := sync.Commit
+		commit(map[string]string{"docs/api/index.md": strings.Repeat("y", 100), "docs/api/new.md": "new"})
+		sync, err = SyncGitRepository(repoDir, nil, dir, previous, opts)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sync.Full).To(BeFalse())
+		Expect(paths(sync)).To(Equal([]string{"docs/api/new.md"}))
+		Expect(sync.Removed).To(Equal([]string{"docs/api/index.md"}))
+	})
+
+	It("returns the text files of the repository concatenated", func() {
+		commit(map[string]string{"README.md": "# Project", "cmd/main.go": "package main", "logo.png": "binary"})
+
+		content, err := GetGitRepositoryContent(repoDir, "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(content).To(ContainSubstring("\n--- File: README.md ---\n# Project\n"))
+		Expect(content).To(ContainSubstring("\n--- File: cmd/main.go ---\npackage main\n"))
+		Expect(content).ToNot(ContainSubstring("logo.png"))
+	})
+
+	It("rejects invalid filters", func() {
+		Expect(GitOptions{Subdir: "docs", Include: []string{"*.md", "**/*.go"}}.Validate()).To(Succeed())
+		Expect(GitOptions{Subdir: "../etc"}.Validate()).ToNot(Succeed())
+		Expect(GitOptions{Include: []string{"[a-"}}.Validate()).ToNot(Succeed())
+		Expect(GitOptions{MaxFileSize: -1}.Validate()).ToNot(Succeed())
+	})
+})
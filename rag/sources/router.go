@@ -1,31 +1,89 @@
 package sources
 
 import (
+	"os"
 	"strings"
 
 	"github.com/mudler/xlog"
 )
 
+// Content is what a source fetched.
+type Content struct {
+	Text string
+	// Title is the page title for web pages, empty otherwise.
+	Title string
+	// Files holds the files of a Git repository, which are stored as entries
+	// of their own, and Commit the hash of the commit they were read at.
+	Files  []File
+	Commit string
+}
+
+// File is a file fetched by a source, with its slash-separated path relative
+// to the root of the source.
+type File struct {
+	Path    string
+	Content []byte
+}
+
+// IsGitRepository reports whether a source URL is a Git repository.
+func IsGitRepository(url string) bool {
+	return strings.HasSuffix(url, ".git")
+}
+
+// IsSitemap reports whether a source URL is a sitemap.
+func IsSitemap(url string) bool {
+	return strings.HasSuffix(url, "sitemap.xml")
+}
+
+// SourceRouter fetches a source and returns its text. The files of a Git
+// repository are concatenated, each after a line naming its path.
 func SourceRouter(url string, config *Config) (string, error) {
+	content, err := FetchSource(url, config, nil)
+	if err != nil {
+		return "", err
+	}
+	if content.Files != nil {
+		return gitRepositoryText(content.Files), nil
+	}
+	return content.Text, nil
+}
+
+// FetchSource fetches a source, authenticating with the given credentials,
+// keeping the files of a Git repository apart and the title of a web page.
+// Git repositories fall back to the GIT_PRIVATE_KEY of the config without
+// credentials.
+func FetchSource(url string, config *Config, creds *Credentials) (Content, error) {
 	xlog.Info("Downloading content from", "url", url)
 
 	switch {
-	case strings.HasSuffix(url, ".git"):
-		content, err := GetGitRepositoryContent(url, config.GitPrivateKey)
+	case IsGitRepository(url):
+		if creds == nil && config.GitPrivateKey != "" {
+			creds = &Credentials{SSHKey: config.GitPrivateKey}
+		}
+		tempDir, err := os.MkdirTemp("", "git-repo-*")
 		if err != nil {
-			return "", err
+			return Content{}, err
 		}
-		xlog.Info("Downloaded content from Git repository", "url", url)
-		return content, nil
-	case strings.HasSuffix(url, "sitemap.xml"):
-		content, err := GetWebSitemapContent(url)
+		defer os.RemoveAll(tempDir)
+		sync, err := SyncGitRepository(url, creds, tempDir, "", GitOptions{})
 		if err != nil {
-			return "", err
+			return Content{}, err
+		}
+		xlog.Info("Downloaded content from Git repository", "url", url, "commit", sync.Commit, "files", len(sync.Files))
+		return Content{Files: sync.Files, Commit: sync.Commit}, nil
+	case IsSitemap(url):
+		content, err := getWebSitemapContent(url, creds)
+		if err != nil {
+			return Content{}, err
 		}
 		xlog.Info("Downloaded all content from sitemap", "url", url, "length", len(content))
-		return strings.Join(content, "\n"), nil
+		return Content{Text: strings.Join(content, "\n")}, nil
 	default:
 		// Default to web page
-		return GetWebPage(url)
+		page, err := fetchWebPage(url, creds)
+		if err != nil {
+			return Content{}, err
+		}
+		return Content{Text: page.Text, Title: page.Title}, nil
 	}
 }
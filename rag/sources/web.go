@@ -1,65 +1,130 @@
 package sources
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"time"
 
+	"github.com/mudler/localrecall/pkg/extract"
 	"github.com/mudler/xlog"
 	sitemap "github.com/oxffaa/gopher-parse-sitemap"
-	"jaytaylor.com/html2text"
 )
 
-func GetWebPage(url string) (string, error) {
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
+// userAgent identifies LocalRecall to the websites it fetches.
+const userAgent = "LocalRecall/1.0 (https://github.com/mudler/localrecall)"
 
-	req, err := http.NewRequest("GET", url, nil)
+// MaxPageSize is the largest body read from a web page, sitemap or
+// robots.txt. Larger responses fail to fetch.
+const MaxPageSize = 10 << 20
+
+// GetWebPage fetches url and returns its main content as Markdown.
+func GetWebPage(url string) (string, error) {
+	page, err := FetchWebPage(url)
 	if err != nil {
 		return "", err
 	}
+	return page.Text, nil
+}
 
-	// Set User-Agent to avoid being blocked by websites like Wikipedia
-	req.Header.Set("User-Agent", "LocalRecall/1.0 (https://github.com/mudler/localrecall)")
+// FetchWebPage fetches url and extracts its title and main content with the
+// same extractor used for uploaded HTML files, dropping navigation, footers
+// and other boilerplate.
+func FetchWebPage(url string) (extract.HTMLPage, error) {
+	return fetchWebPage(url, nil)
+}
 
-	resp, err := client.Do(req)
+func fetchWebPage(url string, creds *Credentials) (extract.HTMLPage, error) {
+	resp, body, err := getWeb(url, creds)
 	if err != nil {
-		return "", err
+		return extract.HTMLPage{}, err
 	}
-	defer resp.Body.Close()
-
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("HTTP error: %d %s", resp.StatusCode, resp.Status)
+		return extract.HTMLPage{}, fmt.Errorf("HTTP error: %d %s", resp.StatusCode, resp.Status)
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	page, err := extract.ParseHTML(bytes.NewReader(body), resp.Header.Get("Content-Type"))
 	if err != nil {
-		return "", err
+		return extract.HTMLPage{}, fmt.Errorf("failed to convert HTML to text: %w", err)
+	}
+
+	if len(page.Text) < 100 {
+		// Very short content might indicate an error page or blocking
+		xlog.Warn("Very short content extracted from URL", "url", url, "length", len(page.Text), "html_length", len(body))
 	}
 
-	text, err := html2text.FromString(string(body), html2text.Options{PrettyTables: true})
+	return page, nil
+}
+
+// getWeb fetches url authenticated with creds and returns the response,
+// whatever its status, with its body read, up to MaxPageSize.
+func getWeb(url string, creds *Credentials) (*http.Response, []byte, error) {
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+		// The credentials are only sent to the host of url, and never in the
+		// clear: Go keeps custom headers on redirects to other hosts and from
+		// HTTPS to HTTP.
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			if req.URL.Host != via[0].URL.Host || (secureURL(via[0].URL) && !secureURL(req.URL)) {
+				creds.strip(req)
+			}
+			return nil
+		},
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to convert HTML to text: %w", err)
+		return nil, nil, err
 	}
 
-	if len(text) < 100 {
-		// Very short content might indicate an error page or blocking
-		xlog.Warn("Very short content extracted from URL", "url", url, "length", len(text), "html_length", len(body))
+	// Set User-Agent to avoid being blocked by websites like Wikipedia
+	req.Header.Set("User-Agent", userAgent)
+	creds.apply(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
 	}
+	defer resp.Body.Close()
 
-	return text, nil
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxPageSize+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(body) > MaxPageSize {
+		return nil, nil, fmt.Errorf("response from %s is larger than %d bytes", url, MaxPageSize)
+	}
+	return resp, body, nil
 }
 
 func GetWebSitemapContent(url string) (res []string, err error) {
-	err = sitemap.ParseFromSite(url, func(e sitemap.Entry) error {
+	return getWebSitemapContent(url, nil)
+}
+
+// getWebSitemapContent fetches the pages listed by a sitemap, which is read
+// up to MaxPageSize like pages. The credentials are only sent to the host of
+// the sitemap.
+func getWebSitemapContent(url string, creds *Credentials) (res []string, err error) {
+	consume := func(e sitemap.Entry) error {
 		xlog.Info("Sitemap page: " + e.GetLocation())
-		content, err := GetWebPage(e.GetLocation())
+		page, err := fetchWebPage(e.GetLocation(), creds.forURL(url, e.GetLocation()))
 		if err == nil {
-			res = append(res, content)
+			res = append(res, page.Text)
 		}
 		return nil
-	})
+	}
+	resp, body, err := getWeb(url, creds)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP error: %d %s", resp.StatusCode, resp.Status)
+	}
+	err = sitemap.Parse(bytes.NewReader(body), consume)
 	return
 }
@@ -1,6 +1,10 @@
 package sources_test
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
 	. "github.com/mudler/localrecall/rag/sources"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -19,6 +23,25 @@ var _ = Describe("Web Sources", func() {
 		})
 	})
 
+	Describe("FetchWebPage", func() {
+		It("extracts the title and main content of the page", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				w.Write([]byte(`<html><head><title>Release notes</title></head><body>
+					<nav><a href="/">Home</a> <a href="/blog">Blog</a></nav>
+					<article><h1>Release notes</h1><p>Uploads now accept HTML pages.</p></article>
+					<footer>All rights reserved</footer>
+				</body></html>`))
+			}))
+			defer server.Close()
+
+			page, err := FetchWebPage(server.URL)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(page.Title).To(Equal("Release notes"))
+			Expect(page.Text).To(Equal("# Release notes\n\nUploads now accept HTML pages.\n"))
+		})
+	})
+
 	Describe("GetWebSitemapContent", func() {
 		It("should handle invalid sitemap URLs", func() {
 			_, err := GetWebSitemapContent("not-a-valid-url")
@@ -29,5 +52,17 @@ var _ = Describe("Web Sources", func() {
 			_, err := GetWebSitemapContent("http://localhost:99999/sitemap.xml")
 			Expect(err).To(HaveOccurred())
 		})
+
+		It("fails to read sitemaps larger than the maximum page size", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`))
+				w.Write([]byte(strings.Repeat("<!-- padding -->", MaxPageSize/16+1)))
+				w.Write([]byte(`</urlset>`))
+			}))
+			defer server.Close()
+
+			_, err := GetWebSitemapContent(server.URL + "/sitemap.xml")
+			Expect(err).To(MatchError(ContainSubstring("larger than")))
+		})
 	})
 })
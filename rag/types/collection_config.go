@@ -0,0 +1,109 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Chunking units accepted by CollectionConfig.ChunkingUnit.
+const (
+	ChunkingUnitCharacters = "characters"
+	ChunkingUnitTokens     = "tokens"
+)
+
+// CollectionConfig holds the per-collection overrides of the server-wide
+// chunking, embedding and engine settings. It is persisted in the
+// collection's state file; empty fields fall back to the server defaults.
+type CollectionConfig struct {
+	ChunkSize      int    `json:"chunk_size,omitempty"`
+	ChunkOverlap   *int   `json:"chunk_overlap,omitempty"`
+	ChunkingUnit   string `json:"chunking_unit,omitempty"`
+	EmbeddingModel string `json:"embedding_model,omitempty"`
+	Engine         string `json:"engine,omitempty"`
+	// KeyColumns names the columns of CSV, TSV and XLSX entries whose values
+	// are copied into the metadata of each row's chunk.
+	KeyColumns []string `json:"key_columns,omitempty"`
+}
+
+// Validate checks the values that are set in c.
+func (c *CollectionConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.ChunkSize < 0 {
+		return errors.New("chunk_size must be positive")
+	}
+	if c.ChunkOverlap != nil {
+		if *c.ChunkOverlap < 0 {
+			return errors.New("chunk_overlap must not be negative")
+		}
+		if c.ChunkSize > 0 && *c.ChunkOverlap >= c.ChunkSize {
+			return errors.New("chunk_overlap must be smaller than chunk_size")
+		}
+	}
+	switch c.ChunkingUnit {
+	case "", ChunkingUnitCharacters, ChunkingUnitTokens:
+	default:
+		return fmt.Errorf("chunking_unit must be %q or %q", ChunkingUnitCharacters, ChunkingUnitTokens)
+	}
+	for _, column := range c.KeyColumns {
+		if strings.TrimSpace(column) == "" {
+			return errors.New("key_columns must not contain empty names")
+		}
+	}
+	return nil
+}
+
+// Merge returns a copy of c with the fields set in patch overriding its own.
+func (c *CollectionConfig) Merge(patch *CollectionConfig) *CollectionConfig {
+	merged := &CollectionConfig{}
+	if c != nil {
+		*merged = *c
+	}
+	if patch == nil {
+		return merged
+	}
+	if patch.ChunkSize != 0 {
+		merged.ChunkSize = patch.ChunkSize
+	}
+	if patch.ChunkOverlap != nil {
+		overlap := *patch.ChunkOverlap
+		merged.ChunkOverlap = &overlap
+	}
+	if patch.ChunkingUnit != "" {
+		merged.ChunkingUnit = patch.ChunkingUnit
+	}
+	if patch.EmbeddingModel != "" {
+		merged.EmbeddingModel = patch.EmbeddingModel
+	}
+	if patch.Engine != "" {
+		merged.Engine = patch.Engine
+	}
+	// An empty list clears the key columns; a missing one keeps them.
+	if patch.KeyColumns != nil {
+		merged.KeyColumns = slices.Clone(patch.KeyColumns)
+	}
+	return merged
+}
+
+// Resolve returns the effective configuration: the fields set in c, and
+// defaults for the rest.
+func (c *CollectionConfig) Resolve(defaults CollectionConfig) CollectionConfig {
+	return *defaults.Merge(c)
+}
+
+// IsZero reports whether no field of c is set.
+func (c CollectionConfig) IsZero() bool {
+	return reflect.ValueOf(c).IsZero()
+}
+
+// Overlap returns the chunk overlap, or 0 when it is not set.
+func (c CollectionConfig) Overlap() int {
+	if c.ChunkOverlap == nil {
+		return 0
+	}
+	return *c.ChunkOverlap
+}
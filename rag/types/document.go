@@ -0,0 +1,55 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Document is a piece of raw text stored as an entry of its own, without an
+// uploaded file. When ID is empty one is generated.
+type Document struct {
+	ID       string   `json:"id,omitempty"`
+	Content  string   `json:"content"`
+	Metadata Metadata `json:"metadata,omitempty"`
+}
+
+// Metadata is entry metadata. Metadata values are strings, but numbers and
+// booleans are accepted from JSON and kept as their JSON text, so
+// {"page": 3, "draft": false} is stored as "3" and "false".
+type Metadata map[string]string
+
+// UnmarshalJSON decodes a JSON object of strings, numbers and booleans,
+// naming a key holding any other value in its error.
+func (m *Metadata) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("metadata must be a JSON object: %w", err)
+	}
+	if raw == nil {
+		*m = nil
+		return nil
+	}
+	metadata := make(Metadata, len(raw))
+	for key, value := range raw {
+		decoder := json.NewDecoder(bytes.NewReader(value))
+		decoder.UseNumber()
+		var v any
+		if err := decoder.Decode(&v); err != nil {
+			return fmt.Errorf("metadata key %q: %w", key, err)
+		}
+		switch v := v.(type) {
+		case string:
+			metadata[key] = v
+		case json.Number:
+			metadata[key] = v.String()
+		case bool:
+			metadata[key] = strconv.FormatBool(v)
+		default:
+			return fmt.Errorf("metadata key %q must be a string, a number or a boolean", key)
+		}
+	}
+	*m = metadata
+	return nil
+}
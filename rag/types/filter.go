@@ -0,0 +1,250 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Filter operators supported by leaf conditions.
+const (
+	FilterOpEq     = "eq"
+	FilterOpIn     = "in"
+	FilterOpPrefix = "prefix"
+	FilterOpGt     = "gt"
+	FilterOpGte    = "gte"
+	FilterOpLt     = "lt"
+	FilterOpLte    = "lte"
+)
+
+// Filter is a metadata filter expression used to restrict search results.
+//
+// A Filter is either a boolean node (And/Or holding sub-filters) or a leaf
+// condition on a single metadata Field. Leaf conditions compare the
+// metadata value with Value (or Values for "in"). Range operators compare
+// numerically when Value is a number and lexically otherwise, which is the
+// correct ordering for RFC3339/ISO-8601 dates such as "created_at".
+//
+//	{"and": [
+//	  {"field": "file_name", "op": "prefix", "value": "install"},
+//	  {"or": [
+//	    {"field": "tag", "op": "in", "values": ["docs", "faq"]},
+//	    {"field": "created_at", "op": "gte", "value": "2024-01-01"}
+//	  ]}
+//	]}
+type Filter struct {
+	And []*Filter `json:"and,omitempty"`
+	Or  []*Filter `json:"or,omitempty"`
+
+	Field  string   `json:"field,omitempty"`
+	Op     string   `json:"op,omitempty"`
+	Value  string   `json:"value,omitempty"`
+	Values []string `json:"values,omitempty"`
+}
+
+// IsEmpty reports whether the filter places no restriction on results.
+func (f *Filter) IsEmpty() bool {
+	return f == nil || (len(f.And) == 0 && len(f.Or) == 0 && f.Field == "")
+}
+
+// IsLeaf reports whether the filter is a single field condition.
+func (f *Filter) IsLeaf() bool {
+	return f != nil && f.Field != ""
+}
+
+// Validate checks that the filter is well formed.
+func (f *Filter) Validate() error {
+	if f.IsEmpty() {
+		return nil
+	}
+	if f.IsLeaf() {
+		if len(f.And) > 0 || len(f.Or) > 0 {
+			return fmt.Errorf("filter on field %q cannot also have and/or clauses", f.Field)
+		}
+		switch f.Operator() {
+		case FilterOpEq, FilterOpPrefix, FilterOpGt, FilterOpGte, FilterOpLt, FilterOpLte:
+			return nil
+		case FilterOpIn:
+			if len(f.Values) == 0 {
+				return fmt.Errorf("filter on field %q: operator \"in\" requires values", f.Field)
+			}
+			return nil
+		default:
+			return fmt.Errorf("filter on field %q: unknown operator %q", f.Field, f.Op)
+		}
+	}
+	if len(f.And) > 0 && len(f.Or) > 0 {
+		return fmt.Errorf("filter cannot have both and and or clauses at the same level")
+	}
+	for _, clauses := range [][]*Filter{f.And, f.Or} {
+		for _, sub := range clauses {
+			if sub == nil {
+				return fmt.Errorf("filter contains an empty clause")
+			}
+			if err := sub.Validate(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Operator returns the normalized leaf operator ("eq" when unset).
+func (f *Filter) Operator() string {
+	if f.Op == "" {
+		return FilterOpEq
+	}
+	return strings.ToLower(f.Op)
+}
+
+// Match reports whether metadata satisfies the filter.
+func (f *Filter) Match(metadata map[string]string) bool {
+	if f.IsEmpty() {
+		return true
+	}
+	if f.IsLeaf() {
+		v, ok := metadata[f.Field]
+		if !ok {
+			return false
+		}
+		switch f.Operator() {
+		case FilterOpEq:
+			return v == f.Value
+		case FilterOpIn:
+			for _, want := range f.Values {
+				if v == want {
+					return true
+				}
+			}
+			return false
+		case FilterOpPrefix:
+			return strings.HasPrefix(v, f.Value)
+		}
+		cmp, ok := CompareFilterValues(v, f.Value)
+		if !ok {
+			return false
+		}
+		switch f.Operator() {
+		case FilterOpGt:
+			return cmp > 0
+		case FilterOpGte:
+			return cmp >= 0
+		case FilterOpLt:
+			return cmp < 0
+		case FilterOpLte:
+			return cmp <= 0
+		}
+		return false
+	}
+	for _, sub := range f.And {
+		if !sub.Match(metadata) {
+			return false
+		}
+	}
+	if len(f.Or) == 0 {
+		return true
+	}
+	for _, sub := range f.Or {
+		if sub.Match(metadata) {
+			return true
+		}
+	}
+	return false
+}
+
+// EqualityConditions returns the field=value pairs that every matching
+// document must satisfy, i.e. the "eq" leaves reachable through AND nodes
+// only. Engines with a native equality-only where clause (chromem) push these
+// down and post-filter the remainder with Match.
+func (f *Filter) EqualityConditions() map[string]string {
+	where := map[string]string{}
+	f.collectEquality(where)
+	return where
+}
+
+func (f *Filter) collectEquality(where map[string]string) {
+	if f.IsEmpty() {
+		return
+	}
+	if f.IsLeaf() {
+		if f.Operator() == FilterOpEq {
+			if existing, ok := where[f.Field]; ok && existing != f.Value {
+				// Contradictory conditions: leave it to Match to reject everything.
+				return
+			}
+			where[f.Field] = f.Value
+		}
+		return
+	}
+	if len(f.Or) > 0 {
+		return
+	}
+	for _, sub := range f.And {
+		sub.collectEquality(where)
+	}
+}
+
+// IsEqualityOnly reports whether the filter is fully expressed by
+// EqualityConditions, so no post-filtering is needed.
+func (f *Filter) IsEqualityOnly() bool {
+	if f.IsEmpty() {
+		return true
+	}
+	if f.IsLeaf() {
+		return f.Operator() == FilterOpEq
+	}
+	if len(f.Or) > 0 {
+		return false
+	}
+	seen := map[string]string{}
+	for _, sub := range f.And {
+		if !sub.IsEqualityOnly() {
+			return false
+		}
+		for k, v := range sub.EqualityConditions() {
+			if existing, ok := seen[k]; ok && existing != v {
+				return false
+			}
+			seen[k] = v
+		}
+	}
+	return true
+}
+
+// numericFilterValue matches the values range filters compare as numbers.
+// It is deliberately stricter than strconv.ParseFloat so that SQL engines can
+// apply the exact same test with a regular expression (see
+// NumericFilterPattern).
+var numericFilterValue = regexp.MustCompile(NumericFilterPattern)
+
+// NumericFilterPattern is the regular expression a value must match to be
+// compared numerically by range filters.
+const NumericFilterPattern = `^-?[0-9]+(\.[0-9]+)?$`
+
+// IsNumericFilterValue reports whether a range filter value is compared numerically.
+func IsNumericFilterValue(v string) bool {
+	return numericFilterValue.MatchString(v)
+}
+
+// CompareFilterValues orders a metadata value against a range filter value.
+// When the filter value is numeric the comparison is numeric and a
+// non-numeric metadata value does not match (ok is false); otherwise values
+// are compared lexically, which orders RFC3339/ISO-8601 timestamps correctly.
+func CompareFilterValues(value, want string) (cmp int, ok bool) {
+	if !IsNumericFilterValue(want) {
+		return strings.Compare(value, want), true
+	}
+	if !IsNumericFilterValue(value) {
+		return 0, false
+	}
+	vf, _ := strconv.ParseFloat(value, 64)
+	wf, _ := strconv.ParseFloat(want, 64)
+	switch {
+	case vf < wf:
+		return -1, true
+	case vf > wf:
+		return 1, true
+	}
+	return 0, true
+}
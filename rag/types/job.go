@@ -0,0 +1,42 @@
+package types
+
+import "time"
+
+// JobState is the state of an ingestion job.
+type JobState string
+
+const (
+	JobQueued    JobState = "queued"
+	JobRunning   JobState = "running"
+	JobSucceeded JobState = "succeeded"
+	JobFailed    JobState = "failed"
+)
+
+// Job is the asynchronous ingestion of an uploaded file into a collection.
+// Key is the entry key the file is stored under once the job succeeds. The
+// records of JSON and JSON Lines files are stored as documents of their own
+// instead, following Mapping, and the messages of mbox files as entries of
+// their own; Key is then empty and the Documents counts report progress.
+type Job struct {
+	ID              string            `json:"id"`
+	Collection      string            `json:"collection"`
+	FileName        string            `json:"file_name"`
+	Key             string            `json:"key"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+	Mapping         *JSONMapping      `json:"mapping,omitempty"`
+	State           JobState          `json:"state"`
+	Error           string            `json:"error,omitempty"`
+	ChunksTotal     int               `json:"chunks_total"`
+	ChunksStored    int               `json:"chunks_stored"`
+	DocumentsTotal  int               `json:"documents_total,omitempty"`
+	DocumentsStored int               `json:"documents_stored,omitempty"`
+	Attempts        int               `json:"attempts"`
+	CreatedAt       time.Time         `json:"created_at"`
+	StartedAt       *time.Time        `json:"started_at,omitempty"`
+	FinishedAt      *time.Time        `json:"finished_at,omitempty"`
+}
+
+// Done reports whether the job has finished, successfully or not.
+func (j Job) Done() bool {
+	return j.State == JobSucceeded || j.State == JobFailed
+}
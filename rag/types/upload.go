@@ -0,0 +1,28 @@
+package types
+
+// UploadResult reports what became of one uploaded file, or of one file
+// expanded from an uploaded archive. Error is set when the file could not be
+// queued; otherwise JobID tracks its ingestion.
+type UploadResult struct {
+	FileName string `json:"filename"`
+	Archive  string `json:"archive,omitempty"`
+	Path     string `json:"path,omitempty"`
+	JobID    string `json:"job_id,omitempty"`
+	Key      string `json:"key,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// JSONMapping tells how the records of an uploaded JSON or JSON Lines file
+// become documents. Fields are dot-separated paths of object keys, such as
+// "fields.summary". Without a Content field the whole record is the content;
+// without an ID field every record gets a generated ID.
+type JSONMapping struct {
+	// Content is the field holding the text of the document.
+	Content string `json:"content,omitempty"`
+	// Metadata lists the fields copied into the metadata of every chunk,
+	// keyed by their path.
+	Metadata []string `json:"metadata,omitempty"`
+	// ID is the field holding the stable ID of the document. Uploading a
+	// record with the ID of a stored document replaces it.
+	ID string `json:"id,omitempty"`
+}
@@ -1,25 +1,66 @@
 package main
 
 import (
+	"bytes"
 	"crypto/subtle"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/mudler/localrecall/rag"
+	"github.com/mudler/localrecall/rag/sources"
+	"github.com/mudler/localrecall/rag/types"
 	"github.com/mudler/xlog"
 	"github.com/sashabaranov/go-openai"
 )
 
 type collectionList map[string]*rag.PersistentKB
 
-var collections = collectionList{}
+var (
+	collections = collectionList{}
+	// collectionsMu guards collections and busyCollections. Handlers
+	// adding, replacing or removing a collection hold it, but never while
+	// an engine is built or re-indexed.
+	collectionsMu sync.Mutex
+	// busyCollections holds the collections being rebuilt by a config
+	// update or rehydrated from a placeholder. The channel is closed when
+	// the work is done.
+	busyCollections = map[string]chan struct{}{}
+)
+
+// markBusy marks name as being rebuilt and returns the function ending the
+// rebuild. It reports false if the collection is busy already. The caller
+// holds collectionsMu.
+func markBusy(name string) (func(), bool) {
+	if _, busy := busyCollections[name]; busy {
+		return nil, false
+	}
+	done := make(chan struct{})
+	busyCollections[name] = done
+	return func() {
+		collectionsMu.Lock()
+		delete(busyCollections, name)
+		collectionsMu.Unlock()
+		close(done)
+	}, true
+}
+
+// busyResponse answers a request that would change a collection while it
+// is being rebuilt.
+func busyResponse(c echo.Context, name string) error {
+	return c.JSON(http.StatusConflict, errorResponse(ErrCodeConflict, "Collection busy", fmt.Sprintf("Collection '%s' is being rebuilt, retry later", name)))
+}
 
 // lookupCollection returns the cached collection KB for name. If the cache
 // holds a placeholder (nil entry — engine init failed at startup, e.g. the
@@ -84,14 +125,32 @@ func errorResponse(code string, message string, details string) APIResponse {
 // os.Exit on any error, which crash-looped the server during transient
 // embedding outages and crashed the whole process on a single bad
 // runtime request.
+//
+// The engine type, embedding model and chunking settings passed in are the
+// server defaults; any of them set in the collection's stored config
+// override them.
 func newVectorEngine(
 	vectorEngineType string,
 	llmClient *openai.Client,
 	apiURL, apiKey, collectionName, dbPath, embeddingModel string, maxChunkSize, chunkOverlap int) (*rag.PersistentKB, error) {
-	var (
-		kb  *rag.PersistentKB
-		err error
-	)
+	config, err := rag.LoadCollectionConfig(dbPath, collectionName)
+	if err != nil {
+		return nil, fmt.Errorf("loading config of collection %q: %w", collectionName, err)
+	}
+	settings := config.Resolve(types.CollectionConfig{
+		ChunkSize:      maxChunkSize,
+		ChunkOverlap:   &chunkOverlap,
+		ChunkingUnit:   defaultChunkingUnit(),
+		EmbeddingModel: embeddingModel,
+		Engine:         vectorEngineType,
+	})
+	vectorEngineType, embeddingModel = settings.Engine, settings.EmbeddingModel
+	maxChunkSize, chunkOverlap = settings.ChunkSize, settings.Overlap()
+	if settings.ChunkingUnit == types.ChunkingUnitTokens && chunkTokenizer == nil {
+		return nil, fmt.Errorf("collection %q chunks by tokens but TOKENIZER_VOCAB_FILE is not set", collectionName)
+	}
+
+	var kb *rag.PersistentKB
 	switch vectorEngineType {
 	case "chromem":
 		xlog.Info("Chromem collection", "collectionName", collectionName, "dbPath", dbPath)
@@ -112,11 +171,54 @@ func newVectorEngine(
 	if err != nil {
 		return nil, fmt.Errorf("creating %s collection %q: %w", vectorEngineType, collectionName, err)
 	}
+	if settings.ChunkingUnit == types.ChunkingUnitTokens {
+		kb.SetTokenizer(chunkTokenizer)
+	}
 	return kb, nil
 }
 
+// defaultChunkingUnit returns the server-wide chunking unit.
+func defaultChunkingUnit() string {
+	if chunkingUnit == "" {
+		return types.ChunkingUnitCharacters
+	}
+	return chunkingUnit
+}
+
+// applyChunking makes a collection chunk new entries as config says.
+func applyChunking(collection *rag.PersistentKB, config types.CollectionConfig) {
+	collection.SetChunkSize(config.ChunkSize, config.Overlap())
+	if config.ChunkingUnit == types.ChunkingUnitTokens {
+		collection.SetTokenizer(chunkTokenizer)
+	} else {
+		collection.SetTokenizer(nil)
+	}
+}
+
+// validateCollectionConfig checks a collection config resolved against the
+// server defaults.
+func validateCollectionConfig(config types.CollectionConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	switch config.Engine {
+	case "chromem", "localai", "postgres":
+	default:
+		return fmt.Errorf("unknown vector engine: %q", config.Engine)
+	}
+	if config.ChunkingUnit == types.ChunkingUnitTokens && chunkTokenizer == nil {
+		return fmt.Errorf("chunking by tokens requires TOKENIZER_VOCAB_FILE to be set")
+	}
+	for _, column := range config.KeyColumns {
+		if slices.Contains(rag.ReservedMetadataKeys, column) {
+			return fmt.Errorf("key column %q is a reserved metadata key", column)
+		}
+	}
+	return nil
+}
+
 // API routes for managing collections
-func registerAPIRoutes(e *echo.Echo, openAIClient *openai.Client, maxChunkingSize, chunkOverlap int, apiKeys []string) {
+func registerAPIRoutes(e *echo.Echo, openAIClient *openai.Client, maxChunkingSize, chunkOverlap, ingestionWorkers int, apiKeys []string) {
 
 	// Load all on-disk collections. Init failures (e.g. embedding service
 	// briefly unreachable) no longer crash the server: register a nil
@@ -135,26 +237,64 @@ func registerAPIRoutes(e *echo.Echo, openAIClient *openai.Client, maxChunkingSiz
 	}
 
 	lookupCollection = func(name string) (*rag.PersistentKB, bool) {
-		kb, exists := collections[name]
-		if !exists {
-			return nil, false
-		}
-		if kb != nil {
+		for {
+			collectionsMu.Lock()
+			kb, exists := collections[name]
+			if !exists {
+				collectionsMu.Unlock()
+				return nil, false
+			}
+			if kb != nil {
+				collectionsMu.Unlock()
+				return kb, true
+			}
+			// Placeholder: collection is known on disk but its engine
+			// wrapper failed to construct earlier. Try again now, unless
+			// another request already is, in which case wait for it.
+			if done, busy := busyCollections[name]; busy {
+				collectionsMu.Unlock()
+				<-done
+				continue
+			}
+			finish, _ := markBusy(name)
+			collectionsMu.Unlock()
+
+			// The engine is built without holding collectionsMu: it may
+			// call the embedding service or the database.
+			kb, err := newVectorEngine(vectorEngine, openAIClient, openAIBaseURL, openAIKey, name, collectionDBPath, embeddingModel, maxChunkingSize, chunkOverlap)
+			if err != nil {
+				finish()
+				xlog.Error("Failed to rehydrate collection on demand",
+					"collection", name, "engine", vectorEngine, "error", err)
+				return nil, false
+			}
+			collectionsMu.Lock()
+			current, exists := collections[name]
+			if !exists || current != nil {
+				// Deleted or replaced while the engine was built.
+				collectionsMu.Unlock()
+				finish()
+				kb.Close()
+				return current, current != nil
+			}
+			collections[name] = kb
+			sourceManager.RegisterCollection(name, kb)
+			collectionsMu.Unlock()
+			finish()
 			return kb, true
 		}
-		// Placeholder: collection is known on disk but its engine wrapper
-		// failed to construct earlier. Try again now.
-		kb, err := newVectorEngine(vectorEngine, openAIClient, openAIBaseURL, openAIKey, name, collectionDBPath, embeddingModel, maxChunkingSize, chunkOverlap)
-		if err != nil {
-			xlog.Error("Failed to rehydrate collection on demand",
-				"collection", name, "engine", vectorEngine, "error", err)
-			return nil, false
-		}
-		collections[name] = kb
-		sourceManager.RegisterCollection(name, kb)
-		return kb, true
 	}
 
+	// Uploads are ingested by background workers; jobs left unfinished by a
+	// previous run resume from their staged files.
+	jobs, err := rag.NewJobQueue(jobsDir, ingestionWorkers, func(name string) (*rag.PersistentKB, bool) {
+		return lookupCollection(name)
+	})
+	if err != nil {
+		e.Logger.Fatal("Failed to load ingestion jobs: ", err)
+	}
+	jobs.Start()
+
 	if len(apiKeys) > 0 {
 		e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
 			return func(c echo.Context) error {
@@ -175,17 +315,26 @@ func registerAPIRoutes(e *echo.Echo, openAIClient *openai.Client, maxChunkingSiz
 	}
 
 	e.POST("/api/collections", createCollection(collections, openAIClient, embeddingModel, maxChunkingSize, chunkOverlap))
-	e.POST("/api/collections/:name/upload", uploadFile(collections, fileAssets))
+	e.POST("/api/collections/:name/upload", uploadFile(collections, jobs))
+	e.POST("/api/collections/:name/documents", storeDocuments(collections))
 	e.GET("/api/collections", listCollections)
+	e.GET("/api/collections/:name/config", getCollectionConfig(collections, embeddingModel, maxChunkingSize, chunkOverlap))
+	e.PATCH("/api/collections/:name/config", updateCollectionConfig(collections, openAIClient, embeddingModel, maxChunkingSize, chunkOverlap))
 	e.GET("/api/collections/:name/entries", listFiles(collections))
 	e.GET("/api/collections/:name/entries/:entry", getEntryContent(collections))
 	e.GET("/api/collections/:name/entries/:entry/raw", getEntryRawFile(collections))
+	e.GET("/api/collections/:name/entries/:entry/metadata", getEntryMetadata(collections))
+	e.PATCH("/api/collections/:name/entries/:entry/metadata", updateEntryMetadata(collections))
 	e.POST("/api/collections/:name/search", search(collections))
 	e.POST("/api/collections/:name/reset", reset(collections))
+	e.DELETE("/api/collections/:name", deleteCollection(collections, jobs))
 	e.DELETE("/api/collections/:name/entry/delete", deleteEntryFromCollection(collections))
 	e.POST("/api/collections/:name/sources", registerExternalSource(collections))
 	e.DELETE("/api/collections/:name/sources", removeExternalSource(collections))
 	e.GET("/api/collections/:name/sources", listSources(collections))
+	e.GET("/api/collections/:name/jobs", listJobs(jobs))
+	e.GET("/api/jobs/:id", getJob(jobs))
+	e.POST("/api/jobs/:id/retry", retryJob(jobs))
 }
 
 // createCollection handles creating a new collection
@@ -193,6 +342,7 @@ func createCollection(collections collectionList, client *openai.Client, embeddi
 	return func(c echo.Context) error {
 		type request struct {
 			Name string `json:"name"`
+			types.CollectionConfig
 		}
 
 		r := new(request)
@@ -200,29 +350,203 @@ func createCollection(collections collectionList, client *openai.Client, embeddi
 			return c.JSON(http.StatusBadRequest, errorResponse(ErrCodeInvalidRequest, "Invalid request", err.Error()))
 		}
 
+		var config *types.CollectionConfig
+		if !r.CollectionConfig.IsZero() {
+			config = &r.CollectionConfig
+		}
+		defaults := defaultCollectionConfig(embeddingModel, maxChunkingSize, chunkOverlap)
+		settings := config.Resolve(defaults)
+		if err := validateCollectionConfig(settings); err != nil {
+			return c.JSON(http.StatusBadRequest, errorResponse(ErrCodeInvalidRequest, "Invalid collection config", err.Error()))
+		}
+
+		collectionsMu.Lock()
+		finish, ok := markBusy(r.Name)
+		collectionsMu.Unlock()
+		if !ok {
+			return busyResponse(c, r.Name)
+		}
+		defer finish()
+
 		// If the engine can't construct the collection right now (transient
 		// embedding/DB outage, misconfiguration, …), surface that as 502 so
 		// the caller can retry. Returning success and storing a nil entry
 		// would leave the caller with a permanently-broken collection.
-		collection, err := newVectorEngine(vectorEngine, client, openAIBaseURL, openAIKey, r.Name, collectionDBPath, embeddingModel, maxChunkingSize, chunkOverlap)
+		collection, err := newVectorEngine(settings.Engine, client, openAIBaseURL, openAIKey, r.Name, collectionDBPath, settings.EmbeddingModel, settings.ChunkSize, settings.Overlap())
 		if err != nil {
 			xlog.Error("Failed to create collection",
-				"collection", r.Name, "engine", vectorEngine, "error", err)
+				"collection", r.Name, "engine", settings.Engine, "error", err)
 			return c.JSON(http.StatusBadGateway, errorResponse(ErrCodeInternalError, "Vector backend unavailable", err.Error()))
 		}
+		// The config is only stored below, so newVectorEngine applied the
+		// server's chunking unit.
+		if settings.ChunkingUnit == types.ChunkingUnitTokens {
+			collection.SetTokenizer(chunkTokenizer)
+		} else {
+			collection.SetTokenizer(nil)
+		}
+		if config != nil {
+			if err := collection.SetConfig(config); err != nil {
+				return c.JSON(http.StatusInternalServerError, errorResponse(ErrCodeInternalError, "Failed to save collection config", err.Error()))
+			}
+		}
+		collectionsMu.Lock()
 		collections[r.Name] = collection
+		collectionsMu.Unlock()
 
 		// Register the new collection with the source manager
 		sourceManager.RegisterCollection(r.Name, collection)
 
 		response := successResponse("Collection created successfully", map[string]interface{}{
 			"name":       r.Name,
+			"config":     settings,
 			"created_at": time.Now().Format(time.RFC3339),
 		})
 		return c.JSON(http.StatusCreated, response)
 	}
 }
 
+// defaultCollectionConfig returns the server-wide collection settings.
+func defaultCollectionConfig(embeddingModel string, maxChunkingSize, chunkOverlap int) types.CollectionConfig {
+	return types.CollectionConfig{
+		ChunkSize:      maxChunkingSize,
+		ChunkOverlap:   &chunkOverlap,
+		ChunkingUnit:   defaultChunkingUnit(),
+		EmbeddingModel: embeddingModel,
+		Engine:         vectorEngine,
+	}
+}
+
+// getCollectionConfig returns the settings stored with a collection and the
+// effective settings after applying the server defaults.
+func getCollectionConfig(collections collectionList, embeddingModel string, maxChunkingSize, chunkOverlap int) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		name := c.Param("name")
+		collection, exists := lookupCollection(name)
+		if !exists {
+			return c.JSON(http.StatusNotFound, errorResponse(ErrCodeNotFound, "Collection not found", fmt.Sprintf("Collection '%s' does not exist", name)))
+		}
+
+		config := collection.Config()
+		response := successResponse("Collection config retrieved successfully", map[string]interface{}{
+			"collection": name,
+			"config":     config,
+			"effective":  config.Resolve(defaultCollectionConfig(embeddingModel, maxChunkingSize, chunkOverlap)),
+		})
+		return c.JSON(http.StatusOK, response)
+	}
+}
+
+// updateCollectionConfig merges the settings in the request into the
+// collection's config. Changing the engine or embedding model rebuilds the
+// collection on the new backend; changing the chunking or the key columns
+// re-chunks it. Either
+// way existing entries are re-indexed from their stored files.
+func updateCollectionConfig(collections collectionList, client *openai.Client, embeddingModel string, maxChunkingSize, chunkOverlap int) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		name := c.Param("name")
+		collection, exists := lookupCollection(name)
+		if !exists {
+			return c.JSON(http.StatusNotFound, errorResponse(ErrCodeNotFound, "Collection not found", fmt.Sprintf("Collection '%s' does not exist", name)))
+		}
+
+		patch := new(types.CollectionConfig)
+		if err := c.Bind(patch); err != nil {
+			return c.JSON(http.StatusBadRequest, errorResponse(ErrCodeInvalidRequest, "Invalid request", err.Error()))
+		}
+
+		// Config updates mark the collection busy so only one rebuild runs
+		// at a time, and fail if the collection was replaced or deleted
+		// since it was looked up. collectionsMu is only held for these
+		// checks and for the swap below: re-indexing can take minutes and
+		// must not block requests on other collections.
+		collectionsMu.Lock()
+		if collections[name] != collection {
+			collectionsMu.Unlock()
+			return c.JSON(http.StatusConflict, errorResponse(ErrCodeConflict, "Collection changed", fmt.Sprintf("Collection '%s' was changed concurrently, retry the update", name)))
+		}
+		finish, ok := markBusy(name)
+		collectionsMu.Unlock()
+		if !ok {
+			return busyResponse(c, name)
+		}
+		defer finish()
+
+		defaults := defaultCollectionConfig(embeddingModel, maxChunkingSize, chunkOverlap)
+		previous := collection.Config()
+		config := previous.Merge(patch)
+		before, after := previous.Resolve(defaults), config.Resolve(defaults)
+		if err := validateCollectionConfig(after); err != nil {
+			return c.JSON(http.StatusBadRequest, errorResponse(ErrCodeInvalidRequest, "Invalid collection config", err.Error()))
+		}
+
+		if err := collection.SetConfig(config); err != nil {
+			return c.JSON(http.StatusInternalServerError, errorResponse(ErrCodeInternalError, "Failed to save collection config", err.Error()))
+		}
+
+		reindexed := true
+		switch {
+		case before.Engine != after.Engine || before.EmbeddingModel != after.EmbeddingModel:
+			// newVectorEngine picks up the config saved above.
+			kb, err := newVectorEngine(vectorEngine, client, openAIBaseURL, openAIKey, name, collectionDBPath, embeddingModel, maxChunkingSize, chunkOverlap)
+			if err != nil {
+				collection.SetConfig(previous)
+				xlog.Error("Failed to rebuild collection", "collection", name, "engine", after.Engine, "error", err)
+				return c.JSON(http.StatusBadGateway, errorResponse(ErrCodeInternalError, "Vector backend unavailable", err.Error()))
+			}
+			if err := kb.Repopulate(); err != nil {
+				// Go back to the previous engine. With the same engine the
+				// failed re-index cleared its storage, so index it again
+				// with the previous model.
+				kb.Close()
+				collection.SetConfig(previous)
+				if before.Engine != after.Engine {
+					if derr := kb.Engine.Drop(); derr != nil {
+						xlog.Warn("Failed to drop new engine storage", "collection", name, "engine", after.Engine, "error", derr)
+					}
+				} else if rerr := collection.Repopulate(); rerr != nil {
+					xlog.Error("Failed to restore collection index", "collection", name, "error", rerr)
+				}
+				return c.JSON(http.StatusInternalServerError, errorResponse(ErrCodeInternalError, "Failed to re-index collection", err.Error()))
+			}
+			// The old knowledge base may still be held by running ingestion
+			// or source updates; closing it makes their writes fail instead
+			// of overwriting the state of the new one.
+			collection.Close()
+			if before.Engine != after.Engine {
+				// Drop the index left behind on the previous engine.
+				if err := collection.Engine.Drop(); err != nil {
+					xlog.Warn("Failed to drop previous engine storage", "collection", name, "engine", before.Engine, "error", err)
+				}
+			}
+			collectionsMu.Lock()
+			collections[name] = kb
+			sourceManager.RegisterCollection(name, kb)
+			collectionsMu.Unlock()
+		case before.ChunkSize != after.ChunkSize || before.Overlap() != after.Overlap() || before.ChunkingUnit != after.ChunkingUnit || !slices.Equal(before.KeyColumns, after.KeyColumns):
+			applyChunking(collection, after)
+			if err := collection.Repopulate(); err != nil {
+				collection.SetConfig(previous)
+				applyChunking(collection, before)
+				if rerr := collection.Repopulate(); rerr != nil {
+					xlog.Error("Failed to restore collection index", "collection", name, "error", rerr)
+				}
+				return c.JSON(http.StatusInternalServerError, errorResponse(ErrCodeInternalError, "Failed to re-index collection", err.Error()))
+			}
+		default:
+			reindexed = false
+		}
+
+		response := successResponse("Collection config updated successfully", map[string]interface{}{
+			"collection": name,
+			"config":     config,
+			"effective":  after,
+			"reindexed":  reindexed,
+		})
+		return c.JSON(http.StatusOK, response)
+	}
+}
+
 func deleteEntryFromCollection(collections collectionList) func(c echo.Context) error {
 	return func(c echo.Context) error {
 		name := c.Param("name")
@@ -262,11 +586,25 @@ func reset(collections collectionList) func(c echo.Context) error {
 			return c.JSON(http.StatusNotFound, errorResponse(ErrCodeNotFound, "Collection not found", fmt.Sprintf("Collection '%s' does not exist", name)))
 		}
 
+		collectionsMu.Lock()
+		if collections[name] != collection {
+			collectionsMu.Unlock()
+			return c.JSON(http.StatusConflict, errorResponse(ErrCodeConflict, "Collection changed", fmt.Sprintf("Collection '%s' was changed concurrently, retry", name)))
+		}
+		finish, ok := markBusy(name)
+		collectionsMu.Unlock()
+		if !ok {
+			return busyResponse(c, name)
+		}
+		defer finish()
+
 		if err := collection.Reset(); err != nil {
 			return c.JSON(http.StatusInternalServerError, errorResponse(ErrCodeInternalError, "Failed to reset collection", err.Error()))
 		}
 
+		collectionsMu.Lock()
 		delete(collections, name)
+		collectionsMu.Unlock()
 
 		response := successResponse("Collection reset successfully", map[string]interface{}{
 			"collection": name,
@@ -276,6 +614,56 @@ func reset(collections collectionList) func(c echo.Context) error {
 	}
 }
 
+// deleteCollection removes a collection for good: its engine storage, its
+// files and state, its registration with the source manager and its
+// ingestion jobs.
+func deleteCollection(collections collectionList, jobs *rag.JobQueue) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		name := c.Param("name")
+		collection, exists := lookupCollection(name)
+		if !exists {
+			collectionsMu.Lock()
+			_, known := collections[name]
+			collectionsMu.Unlock()
+			if known {
+				return c.JSON(http.StatusBadGateway, errorResponse(ErrCodeInternalError, "Vector backend unavailable", fmt.Sprintf("Collection '%s' could not be loaded", name)))
+			}
+			return c.JSON(http.StatusNotFound, errorResponse(ErrCodeNotFound, "Collection not found", fmt.Sprintf("Collection '%s' does not exist", name)))
+		}
+
+		collectionsMu.Lock()
+		if collections[name] != collection {
+			collectionsMu.Unlock()
+			return c.JSON(http.StatusConflict, errorResponse(ErrCodeConflict, "Collection changed", fmt.Sprintf("Collection '%s' was changed concurrently, retry", name)))
+		}
+		finish, ok := markBusy(name)
+		collectionsMu.Unlock()
+		if !ok {
+			return busyResponse(c, name)
+		}
+		defer finish()
+
+		sourceManager.UnregisterCollection(name)
+		if err := collection.Drop(); err != nil {
+			sourceManager.RegisterCollection(name, collection)
+			return c.JSON(http.StatusInternalServerError, errorResponse(ErrCodeInternalError, "Failed to delete collection", err.Error()))
+		}
+		collectionsMu.Lock()
+		delete(collections, name)
+		collectionsMu.Unlock()
+		// The collection stays busy until the handler returns, so it cannot
+		// be created again before its jobs are gone.
+		cancelled := jobs.Cancel(name)
+
+		response := successResponse("Collection deleted successfully", map[string]interface{}{
+			"collection":     name,
+			"cancelled_jobs": cancelled,
+			"deleted_at":     time.Now().Format(time.RFC3339),
+		})
+		return c.JSON(http.StatusOK, response)
+	}
+}
+
 func search(collections collectionList) func(c echo.Context) error {
 	return func(c echo.Context) error {
 		name := c.Param("name")
@@ -285,8 +673,9 @@ func search(collections collectionList) func(c echo.Context) error {
 		}
 
 		type request struct {
-			Query      string `json:"query"`
-			MaxResults int    `json:"max_results"`
+			Query      string        `json:"query"`
+			MaxResults int           `json:"max_results"`
+			Filter     *types.Filter `json:"filter,omitempty"`
 		}
 
 		r := new(request)
@@ -294,6 +683,10 @@ func search(collections collectionList) func(c echo.Context) error {
 			return c.JSON(http.StatusBadRequest, errorResponse(ErrCodeInvalidRequest, "Invalid request", err.Error()))
 		}
 
+		if err := r.Filter.Validate(); err != nil {
+			return c.JSON(http.StatusBadRequest, errorResponse(ErrCodeInvalidRequest, "Invalid filter", err.Error()))
+		}
+
 		if r.MaxResults == 0 {
 			if len(collection.ListDocuments()) >= 5 {
 				r.MaxResults = 5
@@ -302,7 +695,15 @@ func search(collections collectionList) func(c echo.Context) error {
 			}
 		}
 
-		results, err := collection.Search(r.Query, r.MaxResults)
+		var (
+			results []types.Result
+			err     error
+		)
+		if r.Filter.IsEmpty() {
+			results, err = collection.Search(r.Query, r.MaxResults)
+		} else {
+			results, err = collection.SearchWithFilter(r.Query, r.MaxResults, r.Filter)
+		}
 		if err != nil {
 			return c.JSON(http.StatusInternalServerError, errorResponse(ErrCodeInternalError, "Failed to search collection", err.Error()))
 		}
@@ -310,6 +711,7 @@ func search(collections collectionList) func(c echo.Context) error {
 		response := successResponse("Search completed successfully", map[string]interface{}{
 			"query":       r.Query,
 			"max_results": r.MaxResults,
+			"filter":      r.Filter,
 			"results":     results,
 			"count":       len(results),
 		})
@@ -342,6 +744,7 @@ func listFiles(collections collectionList) func(c echo.Context) error {
 }
 
 // getEntryContent returns the full content of the stored file (no chunk overlap) and the number of chunks it occupies.
+// With ?pages=true the text of a PDF is returned page by page instead.
 func getEntryContent(collections collectionList) func(c echo.Context) error {
 	return func(c echo.Context) error {
 		name := c.Param("name")
@@ -356,6 +759,10 @@ func getEntryContent(collections collectionList) func(c echo.Context) error {
 			entry = entryParam
 		}
 
+		if c.QueryParam("pages") == "true" {
+			return getEntryPages(c, collection, name, entry)
+		}
+
 		content, chunkCount, err := collection.GetEntryFileContent(entry)
 		if err != nil {
 			if strings.Contains(err.Error(), "entry not found") {
@@ -377,6 +784,127 @@ func getEntryContent(collections collectionList) func(c echo.Context) error {
 	}
 }
 
+// getEntryPages returns the text of every page of a PDF entry. Page numbers
+// match the page_start/page_end metadata of its chunks.
+func getEntryPages(c echo.Context, collection *rag.PersistentKB, name, entry string) error {
+	type page struct {
+		Page    int    `json:"page"`
+		Content string `json:"content"`
+	}
+
+	texts, chunkCount, err := collection.GetEntryPages(entry)
+	if err != nil {
+		if strings.Contains(err.Error(), "entry not found") {
+			return c.JSON(http.StatusNotFound, errorResponse(ErrCodeNotFound, "Entry not found", fmt.Sprintf("Entry '%s' does not exist in collection '%s'", entry, name)))
+		}
+		if errors.Is(err, rag.ErrNotPaged) {
+			return c.JSON(http.StatusBadRequest, errorResponse(ErrCodeInvalidRequest, "Entry has no pages", err.Error()))
+		}
+		return c.JSON(http.StatusInternalServerError, errorResponse(ErrCodeInternalError, "Failed to get entry pages", err.Error()))
+	}
+
+	pages := make([]page, len(texts))
+	for i, text := range texts {
+		pages[i] = page{Page: i + 1, Content: text}
+	}
+	response := successResponse("Entry pages retrieved successfully", map[string]interface{}{
+		"collection":  name,
+		"entry":       entry,
+		"pages":       pages,
+		"page_count":  len(pages),
+		"chunk_count": chunkCount,
+	})
+	return c.JSON(http.StatusOK, response)
+}
+
+// getEntryMetadata returns the metadata recorded for an entry.
+func getEntryMetadata(collections collectionList) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		name := c.Param("name")
+		collection, exists := lookupCollection(name)
+		if !exists {
+			return c.JSON(http.StatusNotFound, errorResponse(ErrCodeNotFound, "Collection not found", fmt.Sprintf("Collection '%s' does not exist", name)))
+		}
+
+		entryParam := c.Param("entry")
+		entry, err := url.PathUnescape(entryParam)
+		if err != nil {
+			entry = entryParam
+		}
+
+		metadata, err := collection.GetEntryMetadata(entry)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, errorResponse(ErrCodeNotFound, "Entry not found", fmt.Sprintf("Entry '%s' does not exist in collection '%s'", entry, name)))
+		}
+
+		response := successResponse("Entry metadata retrieved successfully", map[string]interface{}{
+			"collection": name,
+			"entry":      entry,
+			"metadata":   metadata,
+		})
+		return c.JSON(http.StatusOK, response)
+	}
+}
+
+// updateEntryMetadata sets metadata keys of an entry across all its chunks;
+// keys given a null value are removed.
+func updateEntryMetadata(collections collectionList) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		name := c.Param("name")
+		collection, exists := lookupCollection(name)
+		if !exists {
+			return c.JSON(http.StatusNotFound, errorResponse(ErrCodeNotFound, "Collection not found", fmt.Sprintf("Collection '%s' does not exist", name)))
+		}
+
+		entryParam := c.Param("entry")
+		entry, err := url.PathUnescape(entryParam)
+		if err != nil {
+			entry = entryParam
+		}
+
+		var request struct {
+			Metadata map[string]*string `json:"metadata"`
+		}
+		if err := c.Bind(&request); err != nil {
+			return c.JSON(http.StatusBadRequest, errorResponse(ErrCodeInvalidRequest, "Invalid request", err.Error()))
+		}
+		if len(request.Metadata) == 0 {
+			return c.JSON(http.StatusBadRequest, errorResponse(ErrCodeInvalidRequest, "Invalid request", "metadata is required"))
+		}
+
+		set := map[string]string{}
+		var remove []string
+		for k, v := range request.Metadata {
+			if v == nil {
+				remove = append(remove, k)
+			} else {
+				set[k] = *v
+			}
+		}
+
+		metadata, err := collection.UpdateEntryMetadata(entry, set, remove)
+		if err != nil {
+			if strings.Contains(err.Error(), "entry not found") {
+				return c.JSON(http.StatusNotFound, errorResponse(ErrCodeNotFound, "Entry not found", fmt.Sprintf("Entry '%s' does not exist in collection '%s'", entry, name)))
+			}
+			if strings.Contains(err.Error(), "is reserved") {
+				return c.JSON(http.StatusBadRequest, errorResponse(ErrCodeInvalidRequest, "Invalid metadata", err.Error()))
+			}
+			if strings.Contains(err.Error(), "not implemented") {
+				return c.JSON(http.StatusNotImplemented, errorResponse(ErrCodeInternalError, "Not supported", err.Error()))
+			}
+			return c.JSON(http.StatusInternalServerError, errorResponse(ErrCodeInternalError, "Failed to update entry metadata", err.Error()))
+		}
+
+		response := successResponse("Entry metadata updated successfully", map[string]interface{}{
+			"collection": name,
+			"entry":      entry,
+			"metadata":   metadata,
+		})
+		return c.JSON(http.StatusOK, response)
+	}
+}
+
 // getEntryRawFile returns the original uploaded binary file.
 func getEntryRawFile(collections collectionList) func(c echo.Context) error {
 	return func(c echo.Context) error {
@@ -401,68 +929,283 @@ func getEntryRawFile(collections collectionList) func(c echo.Context) error {
 	}
 }
 
-// uploadFile handles uploading files to a collection
-func uploadFile(collections collectionList, fileAssets string) func(c echo.Context) error {
+// uploadFile stages uploaded files and queues a job ingesting each into a
+// collection. Any number of "file" fields may be sent; .zip and .tar.gz
+// archives are expanded into one entry per contained file, and every record of
+// a JSON or JSON Lines file becomes a document following the optional
+// "mapping" field. Results are reported per file, and job progress by
+// GET /api/jobs/:id.
+func uploadFile(collections collectionList, jobs *rag.JobQueue) func(c echo.Context) error {
 	return func(c echo.Context) error {
 		name := c.Param("name")
-		collection, exists := lookupCollection(name)
+		_, exists := lookupCollection(name)
 		if !exists {
 			xlog.Error("Collection not found")
 			return c.JSON(http.StatusNotFound, errorResponse(ErrCodeNotFound, "Collection not found", fmt.Sprintf("Collection '%s' does not exist", name)))
 		}
 
-		file, err := c.FormFile("file")
+		form, err := c.MultipartForm()
 		if err != nil {
 			xlog.Error("Failed to read file", err)
 			return c.JSON(http.StatusBadRequest, errorResponse(ErrCodeInvalidRequest, "Failed to read file", err.Error()))
 		}
+		files := append(form.File["file"], form.File["files"]...)
+		if len(files) == 0 {
+			return c.JSON(http.StatusBadRequest, errorResponse(ErrCodeInvalidRequest, "Failed to read file", "no file given"))
+		}
 
-		f, err := file.Open()
+		metadata, err := parseMetadataField(c.FormValue("metadata"))
 		if err != nil {
-			xlog.Error("Failed to open file", err)
-			return c.JSON(http.StatusBadRequest, errorResponse(ErrCodeInvalidRequest, "Failed to open file", err.Error()))
+			return c.JSON(http.StatusBadRequest, errorResponse(ErrCodeInvalidRequest, "Invalid metadata", err.Error()))
 		}
-		defer f.Close()
-
-		// Write to a temp file; collection.Store() will copy it into the
-		// correct UUID subdirectory under the collection's asset dir.
-		tmpFile, err := os.CreateTemp("", "localrecall-upload-*-"+file.Filename)
+		mapping, err := parseMappingField(c.FormValue("mapping"))
 		if err != nil {
-			xlog.Error("Failed to create temp file", err)
-			return c.JSON(http.StatusInternalServerError, errorResponse(ErrCodeInternalError, "Failed to create temp file", err.Error()))
+			return c.JSON(http.StatusBadRequest, errorResponse(ErrCodeInvalidRequest, "Invalid mapping", err.Error()))
 		}
-		tmpPath := tmpFile.Name()
-		defer os.Remove(tmpPath)
 
-		_, err = io.Copy(tmpFile, f)
-		tmpFile.Close()
-		if err != nil {
-			xlog.Error("Failed to copy file", err)
-			return c.JSON(http.StatusInternalServerError, errorResponse(ErrCodeInternalError, "Failed to copy file", err.Error()))
+		now := time.Now().Format(time.RFC3339)
+		if _, ok := metadata["created_at"]; !ok {
+			metadata["created_at"] = now
 		}
 
-		// Rename the temp file so its base name matches the original filename,
-		// since collection.Store uses filepath.Base to derive the index key.
-		uploadPath := filepath.Join(filepath.Dir(tmpPath), file.Filename)
-		if err := os.Rename(tmpPath, uploadPath); err != nil {
-			xlog.Error("Failed to rename temp file", err)
-			return c.JSON(http.StatusInternalServerError, errorResponse(ErrCodeInternalError, "Failed to rename temp file", err.Error()))
+		var results []types.UploadResult
+		var firstJob *types.Job
+		for _, file := range files {
+			for _, result := range submitUpload(jobs, name, file, metadata, mapping) {
+				if result.Error == "" && firstJob == nil {
+					if job, ok := jobs.Get(result.JobID); ok {
+						firstJob = &job
+					}
+				}
+				results = append(results, result)
+			}
 		}
-		defer os.Remove(uploadPath)
 
-		now := time.Now().Format(time.RFC3339)
+		queued := 0
+		var failures []string
+		for _, r := range results {
+			if r.Error != "" {
+				failures = append(failures, fmt.Sprintf("%s: %s", r.FileName, r.Error))
+			} else {
+				queued++
+			}
+		}
+		if queued == 0 {
+			status := http.StatusBadRequest
+			if strings.Contains(failures[0], rag.ErrJobQueueFull.Error()) {
+				status = http.StatusServiceUnavailable
+			}
+			return c.JSON(status, errorResponse(ErrCodeInvalidRequest, "Failed to queue files", strings.Join(failures, "; ")))
+		}
+
+		data := map[string]interface{}{
+			"collection": name,
+			"files":      results,
+			"count":      len(results),
+			"queued":     queued,
+			"failed":     len(results) - queued,
+			"created_at": now,
+			"metadata":   metadata,
+		}
+		// A single plain file keeps the flat response of earlier versions.
+		if len(results) == 1 && results[0].Archive == "" {
+			data["filename"] = results[0].FileName
+			data["key"] = results[0].Key
+			data["job_id"] = results[0].JobID
+			data["job"] = firstJob
+		}
+		return c.JSON(http.StatusAccepted, successResponse("Files queued for ingestion", data))
+	}
+}
+
+// submitUpload queues one uploaded file, or every file of an uploaded
+// archive, and reports the outcome of each.
+func submitUpload(jobs *rag.JobQueue, collection string, file *multipart.FileHeader, metadata map[string]string, mapping *types.JSONMapping) []types.UploadResult {
+	f, err := file.Open()
+	if err != nil {
+		return []types.UploadResult{{FileName: file.Filename, Error: err.Error()}}
+	}
+	defer f.Close()
+
+	if !rag.IsArchive(file.Filename) {
+		return []types.UploadResult{submitFile(jobs, collection, file.Filename, f, metadata, mapping)}
+	}
+
+	var results []types.UploadResult
+	err = rag.WalkArchive(file.Filename, f, file.Size, func(relPath string, content io.Reader) error {
+		fileMetadata := make(map[string]string, len(metadata)+2)
+		for k, v := range metadata {
+			fileMetadata[k] = v
+		}
+		fileMetadata[rag.ArchiveKey] = file.Filename
+		fileMetadata[rag.ArchivePathKey] = relPath
+
+		result := submitFile(jobs, collection, rag.ArchiveEntryName(relPath), content, fileMetadata, mapping)
+		result.Archive = file.Filename
+		result.Path = relPath
+		results = append(results, result)
+		return nil
+	})
+	if err != nil {
+		// Files queued before the archive turned out to be broken are kept.
+		results = append(results, types.UploadResult{FileName: file.Filename, Archive: file.Filename, Error: err.Error()})
+	}
+	return results
+}
+
+func submitFile(jobs *rag.JobQueue, collection, fileName string, content io.Reader, metadata map[string]string, mapping *types.JSONMapping) types.UploadResult {
+	job, err := jobs.Submit(collection, fileName, content, metadata, mapping)
+	if err != nil {
+		xlog.Error("Failed to queue file", "file", fileName, "error", err)
+		return types.UploadResult{FileName: fileName, Error: err.Error()}
+	}
+	return types.UploadResult{FileName: fileName, JobID: job.ID, Key: job.Key}
+}
+
+// getJob reports the state, chunk counts, error and timing of an ingestion job.
+func getJob(jobs *rag.JobQueue) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		id := c.Param("id")
+		job, exists := jobs.Get(id)
+		if !exists {
+			return c.JSON(http.StatusNotFound, errorResponse(ErrCodeNotFound, "Job not found", fmt.Sprintf("Job '%s' does not exist", id)))
+		}
+		return c.JSON(http.StatusOK, successResponse("Job retrieved successfully", job))
+	}
+}
+
+// retryJob queues a failed ingestion job again from its staged file.
+func retryJob(jobs *rag.JobQueue) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		id := c.Param("id")
+		if _, exists := jobs.Get(id); !exists {
+			return c.JSON(http.StatusNotFound, errorResponse(ErrCodeNotFound, "Job not found", fmt.Sprintf("Job '%s' does not exist", id)))
+		}
+		job, err := jobs.Retry(id)
+		switch {
+		case errors.Is(err, rag.ErrJobNotRetryable):
+			return c.JSON(http.StatusConflict, errorResponse(ErrCodeConflict, "Job cannot be retried", err.Error()))
+		case errors.Is(err, rag.ErrJobQueueFull):
+			return c.JSON(http.StatusServiceUnavailable, errorResponse(ErrCodeInternalError, "Ingestion queue is full", err.Error()))
+		case err != nil:
+			return c.JSON(http.StatusInternalServerError, errorResponse(ErrCodeInternalError, "Failed to retry job", err.Error()))
+		}
+		return c.JSON(http.StatusAccepted, successResponse("Job queued again", job))
+	}
+}
+
+// listJobs returns the ingestion jobs of a collection, oldest first.
+func listJobs(jobs *rag.JobQueue) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		name := c.Param("name")
+		list := jobs.List(name)
+		response := successResponse("Jobs retrieved successfully", map[string]interface{}{
+			"collection": name,
+			"jobs":       list,
+			"count":      len(list),
+		})
+		return c.JSON(http.StatusOK, response)
+	}
+}
+
+// parseMetadataField decodes the "metadata" form field of an upload: a JSON
+// object of string, number or boolean values, merged into every chunk of the
+// entry.
+func parseMetadataField(field string) (map[string]string, error) {
+	metadata := types.Metadata{}
+	if strings.TrimSpace(field) == "" {
+		return metadata, nil
+	}
+	if err := json.Unmarshal([]byte(field), &metadata); err != nil {
+		return nil, err
+	}
+	if metadata == nil {
+		metadata = types.Metadata{}
+	}
+	if err := rag.ValidateMetadata(metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// parseMappingField decodes the "mapping" form field of an upload, telling
+// how the records of JSON and JSON Lines files become documents.
+func parseMappingField(field string) (*types.JSONMapping, error) {
+	if strings.TrimSpace(field) == "" {
+		return nil, nil
+	}
+	var mapping types.JSONMapping
+	d := json.NewDecoder(strings.NewReader(field))
+	d.DisallowUnknownFields()
+	if err := d.Decode(&mapping); err != nil {
+		return nil, fmt.Errorf("mapping must be a JSON object with content, metadata and id fields: %w", err)
+	}
+	if err := rag.ValidateJSONMapping(mapping); err != nil {
+		return nil, err
+	}
+	return &mapping, nil
+}
+
+// storeDocuments stores one document ({content, metadata, id?}) or an array
+// of them as entries of their own, without a file upload. Documents are
+// stored in order; the first failure aborts the request.
+func storeDocuments(collections collectionList) func(c echo.Context) error {
+	return func(c echo.Context) error {
+		name := c.Param("name")
+		collection, exists := lookupCollection(name)
+		if !exists {
+			return c.JSON(http.StatusNotFound, errorResponse(ErrCodeNotFound, "Collection not found", fmt.Sprintf("Collection '%s' does not exist", name)))
+		}
 
-		// Save the file to disk
-		key, err := collection.Store(uploadPath, map[string]string{"created_at": now})
+		body, err := io.ReadAll(c.Request().Body)
 		if err != nil {
-			xlog.Error("Failed to store file", err)
-			return c.JSON(http.StatusInternalServerError, errorResponse(ErrCodeInternalError, "Failed to store file", err.Error()))
+			return c.JSON(http.StatusBadRequest, errorResponse(ErrCodeInvalidRequest, "Failed to read request body", err.Error()))
+		}
+		var docs []types.Document
+		if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+			err = json.Unmarshal(trimmed, &docs)
+		} else {
+			docs = make([]types.Document, 1)
+			err = json.Unmarshal(trimmed, &docs[0])
+		}
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, errorResponse(ErrCodeInvalidRequest, "Invalid request", err.Error()))
+		}
+		if len(docs) == 0 {
+			return c.JSON(http.StatusBadRequest, errorResponse(ErrCodeInvalidRequest, "Invalid request", "no documents given"))
 		}
 
-		response := successResponse("File uploaded successfully", map[string]interface{}{
-			"filename":   file.Filename,
+		now := time.Now().Format(time.RFC3339)
+		for i := range docs {
+			if strings.TrimSpace(docs[i].Content) == "" {
+				return c.JSON(http.StatusBadRequest, errorResponse(ErrCodeInvalidRequest, "Invalid document", fmt.Sprintf("document %d has no content", i)))
+			}
+			if err := rag.ValidateMetadata(docs[i].Metadata); err != nil {
+				return c.JSON(http.StatusBadRequest, errorResponse(ErrCodeInvalidRequest, "Invalid document", fmt.Sprintf("document %d: %v", i, err)))
+			}
+			if docs[i].Metadata == nil {
+				docs[i].Metadata = map[string]string{}
+			}
+			if _, ok := docs[i].Metadata["created_at"]; !ok {
+				docs[i].Metadata["created_at"] = now
+			}
+		}
+
+		stored := make([]map[string]string, 0, len(docs))
+		for i, doc := range docs {
+			key, err := collection.StoreDocument(doc)
+			if err != nil {
+				xlog.Error("Failed to store document", "collection", name, "index", i, "error", err)
+				return c.JSON(http.StatusInternalServerError, errorResponse(ErrCodeInternalError, "Failed to store document",
+					fmt.Sprintf("document %d: %v (%d earlier documents were stored)", i, err, len(stored))))
+			}
+			stored = append(stored, map[string]string{"id": strings.TrimSuffix(filepath.Base(key), ".txt"), "key": key})
+		}
+
+		response := successResponse("Documents stored successfully", map[string]interface{}{
 			"collection": name,
-			"key":        key,
+			"documents":  stored,
+			"count":      len(stored),
 			"created_at": now,
 		})
 		return c.JSON(http.StatusOK, response)
@@ -491,6 +1234,21 @@ func registerExternalSource(collections collectionList) func(c echo.Context) err
 		type request struct {
 			URL            string `json:"url"`
 			UpdateInterval int    `json:"update_interval"` // in minutes
+			// Git repositories only
+			Ref         string   `json:"ref"`
+			Subdir      string   `json:"subdir"`
+			Include     []string `json:"include"`
+			Exclude     []string `json:"exclude"`
+			MaxFileSize int64    `json:"max_file_size"` // in bytes
+			// Web pages only: crawl the website from the URL
+			Crawl *struct {
+				MaxDepth   int     `json:"max_depth"`
+				MaxPages   int     `json:"max_pages"`
+				PathPrefix string  `json:"path_prefix"`
+				Delay      float64 `json:"delay"` // in seconds
+			} `json:"crawl"`
+			// Stored encrypted and never returned
+			Credentials *sources.Credentials `json:"credentials"`
 		}
 
 		r := new(request)
@@ -506,7 +1264,28 @@ func registerExternalSource(collections collectionList) func(c echo.Context) err
 		sourceManager.RegisterCollection(name, collection)
 
 		// Add the source to the manager
-		if err := sourceManager.AddSource(name, r.URL, time.Duration(r.UpdateInterval)*time.Minute); err != nil {
+		opts := rag.SourceOptions{
+			Git: sources.GitOptions{
+				Ref:         r.Ref,
+				Subdir:      r.Subdir,
+				Include:     r.Include,
+				Exclude:     r.Exclude,
+				MaxFileSize: r.MaxFileSize,
+			},
+			Credentials: r.Credentials,
+		}
+		if r.Crawl != nil {
+			opts.Crawl = &sources.CrawlOptions{
+				MaxDepth:   r.Crawl.MaxDepth,
+				MaxPages:   r.Crawl.MaxPages,
+				PathPrefix: r.Crawl.PathPrefix,
+				Delay:      time.Duration(r.Crawl.Delay * float64(time.Second)),
+			}
+		}
+		if err := sourceManager.AddSourceWithOptions(name, r.URL, time.Duration(r.UpdateInterval)*time.Minute, opts); err != nil {
+			if errors.Is(err, rag.ErrInvalidSource) {
+				return c.JSON(http.StatusBadRequest, errorResponse(ErrCodeInvalidRequest, "Invalid source", err.Error()))
+			}
 			return c.JSON(http.StatusInternalServerError, errorResponse(ErrCodeInternalError, "Failed to register source", err.Error()))
 		}
 
@@ -560,11 +1339,41 @@ func listSources(collections collectionList) func(c echo.Context) error {
 		// Convert sources to a more frontend-friendly format
 		sourcesList := []map[string]interface{}{}
 		for _, source := range sources {
-			sourcesList = append(sourcesList, map[string]interface{}{
+			item := map[string]interface{}{
 				"url":             source.URL,
 				"update_interval": int(source.UpdateInterval.Minutes()),
 				"last_update":     source.LastUpdate.Format(time.RFC3339),
-			})
+			}
+			if source.LastCommit != "" {
+				item["last_commit"] = source.LastCommit
+			}
+			if source.Ref != "" {
+				item["ref"] = source.Ref
+			}
+			if source.Subdir != "" {
+				item["subdir"] = source.Subdir
+			}
+			if len(source.Include) > 0 {
+				item["include"] = source.Include
+			}
+			if len(source.Exclude) > 0 {
+				item["exclude"] = source.Exclude
+			}
+			if source.MaxFileSize > 0 {
+				item["max_file_size"] = source.MaxFileSize
+			}
+			if source.Crawl {
+				item["crawl"] = map[string]interface{}{
+					"max_depth":   source.CrawlDepth,
+					"max_pages":   source.CrawlPages,
+					"path_prefix": source.CrawlPathPrefix,
+					"delay":       source.CrawlDelay.Seconds(),
+				}
+			}
+			if creds := sourceManager.RedactedCredentials(source); creds != nil {
+				item["credentials"] = creds
+			}
+			sourcesList = append(sourcesList, item)
 		}
 
 		response := successResponse("Sources retrieved successfully", map[string]interface{}{
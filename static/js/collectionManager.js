@@ -331,7 +331,9 @@ function uploadPage() {
       }
 
       const formData = new FormData();
-      formData.append('file', fileInput.files[0]);
+      for (const file of fileInput.files) {
+        formData.append('file', file);
+      }
       
       this.loading.upload = true;
       
@@ -341,9 +343,16 @@ function uploadPage() {
       })
         .then(response => handleAPIResponse(response))
         .then(data => {
-          this.showToast('success', data.message || 'File uploaded successfully');
+          const files = data.data?.files || [];
+          const failed = files.filter(f => f.error);
+          if (failed.length > 0) {
+            this.showToast('warning', `${failed.length} of ${files.length} files could not be queued: ` + failed.map(f => f.filename).join(', '));
+          } else {
+            this.showToast('info', data.message || 'Files queued for ingestion');
+          }
           fileInput.value = '';
           this.fileName = '';
+          files.filter(f => f.job_id).forEach(f => this.watchJob(f.job_id, f.path || f.filename));
         })
         .catch(error => {
           console.error('Error uploading file:', error);
@@ -354,6 +363,25 @@ function uploadPage() {
         });
     },
     
+    // watchJob polls an ingestion job and reports its outcome.
+    watchJob(jobId, fileName) {
+      fetch(`/api/jobs/${jobId}`)
+        .then(response => handleAPIResponse(response))
+        .then(data => {
+          const job = data.data;
+          if (job.state === 'succeeded') {
+            this.showToast('success', `${fileName} ingested (${job.chunks_stored} chunks)`);
+          } else if (job.state === 'failed') {
+            this.showToast('error', `Failed to ingest ${fileName}: ${job.error}`);
+          } else {
+            setTimeout(() => this.watchJob(jobId, fileName), 1000);
+          }
+        })
+        .catch(error => {
+          console.error('Error fetching job:', error);
+        });
+    },
+    
     showToast(type, message) {
       
       const router = getRouter();
@@ -1,13 +1,16 @@
 package e2e_test
 
 import (
+	"archive/zip"
 	"context"
 	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 
 	"github.com/mudler/localrecall/pkg/client"
+	"github.com/mudler/localrecall/rag/types"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/sashabaranov/go-openai"
@@ -94,6 +97,29 @@ var _ = Describe("API", func() {
 		Expect(collections).To(ContainElement(TestCollection))
 	})
 
+	It("should delete collections", func() {
+		err := localRecall.CreateCollection(TestCollection)
+		Expect(err).ToNot(HaveOccurred())
+
+		tempContent(story1, localRecall)
+
+		Expect(localRecall.DeleteCollection(TestCollection)).To(Succeed())
+
+		collections, err := localRecall.ListCollections()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(collections).ToNot(ContainElement(TestCollection))
+
+		_, err = localRecall.Search(TestCollection, "heist", 1)
+		Expect(err).To(HaveOccurred())
+		Expect(localRecall.DeleteCollection(TestCollection)).ToNot(Succeed())
+
+		// A collection created again under the same name starts empty.
+		Expect(localRecall.CreateCollection(TestCollection)).To(Succeed())
+		entries, err := localRecall.ListEntries(TestCollection)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entries).To(BeEmpty())
+	})
+
 	It("should search between documents", func() {
 		err := localRecall.CreateCollection(TestCollection)
 		Expect(err).ToNot(HaveOccurred())
@@ -161,6 +187,188 @@ var _ = Describe("API", func() {
 		}
 		Expect(fullContent).To(ContainSubstring("Bertie"))
 	})
+
+	It("should store raw documents", func() {
+		err := localRecall.CreateCollection(TestCollection)
+		Expect(err).ToNot(HaveOccurred())
+
+		keys, err := localRecall.StoreDocuments(TestCollection,
+			types.Document{ID: "pigeons", Content: story1, Metadata: map[string]string{"kind": "story"}},
+			types.Document{Content: story2},
+		)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(keys).To(HaveLen(2))
+		Expect(keys[0]).To(HaveSuffix("/pigeons.txt"))
+
+		expectContent(TestCollection, "heist", "the Great Pigeon Heist", localRecall)
+
+		chunks, err := localRecall.GetEntryContent(TestCollection, "pigeons")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(chunks).ToNot(BeEmpty())
+
+		entries, err := localRecall.DeleteEntry(TestCollection, "pigeons")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+	})
+
+	It("should ingest uploads as background jobs", func() {
+		err := localRecall.CreateCollection(TestCollection)
+		Expect(err).ToNot(HaveOccurred())
+
+		dir, err := os.MkdirTemp("", "temp-content")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "pigeons.txt")
+		Expect(os.WriteFile(path, []byte(story1), 0644)).To(Succeed())
+
+		job, err := localRecall.SubmitFile(TestCollection, path, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(job.ID).ToNot(BeEmpty())
+		Expect(job.Key).To(HaveSuffix("/pigeons.txt"))
+
+		cancelled, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = localRecall.WaitForJob(cancelled, job.ID)
+		Expect(err).To(MatchError(context.Canceled))
+
+		job, err = localRecall.WaitForJob(context.Background(), job.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(job.State).To(Equal(types.JobSucceeded))
+		Expect(job.ChunksStored).To(Equal(job.ChunksTotal))
+		Expect(job.FinishedAt).ToNot(BeNil())
+
+		expectContent(TestCollection, "heist", "the Great Pigeon Heist", localRecall)
+
+		_, err = localRecall.GetJob("does-not-exist")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should upload several files and archives at once", func() {
+		err := localRecall.CreateCollection(TestCollection)
+		Expect(err).ToNot(HaveOccurred())
+
+		dir, err := os.MkdirTemp("", "temp-content")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		plain := filepath.Join(dir, "pigeons.txt")
+		Expect(os.WriteFile(plain, []byte(story1), 0644)).To(Succeed())
+
+		archive := filepath.Join(dir, "stories.zip")
+		f, err := os.Create(archive)
+		Expect(err).ToNot(HaveOccurred())
+		zw := zip.NewWriter(f)
+		w, err := zw.Create("garden/spider.txt")
+		Expect(err).ToNot(HaveOccurred())
+		_, err = w.Write([]byte(story2))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(zw.Close()).To(Succeed())
+		Expect(f.Close()).To(Succeed())
+
+		results, err := localRecall.SubmitFiles(TestCollection, nil, plain, archive)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results).To(HaveLen(2))
+		Expect(results[1].Archive).To(Equal("stories.zip"))
+		Expect(results[1].Path).To(Equal("garden/spider.txt"))
+		for _, r := range results {
+			Expect(r.Error).To(BeEmpty())
+			_, err := localRecall.WaitForJob(context.Background(), r.JobID)
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		md, err := localRecall.GetEntryMetadata(TestCollection, "garden__spider.txt")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(md).To(HaveKeyWithValue("archive_path", "garden/spider.txt"))
+		expectContent(TestCollection, "spider", "Edgar", localRecall)
+	})
+
+	It("should store the records of JSON Lines uploads as documents", func() {
+		err := localRecall.CreateCollection(TestCollection)
+		Expect(err).ToNot(HaveOccurred())
+
+		dir, err := os.MkdirTemp("", "temp-content")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		var lines []byte
+		for _, record := range []map[string]any{
+			{"slug": "pigeons", "story": map[string]string{"text": story1}, "genre": "heist"},
+			{"slug": "spider", "story": map[string]string{"text": story2}, "genre": "fable"},
+		} {
+			line, err := json.Marshal(record)
+			Expect(err).ToNot(HaveOccurred())
+			lines = append(append(lines, line...), '\n')
+		}
+		path := filepath.Join(dir, "stories.jsonl")
+		Expect(os.WriteFile(path, lines, 0644)).To(Succeed())
+
+		job, err := localRecall.StoreRecords(TestCollection, path, types.JSONMapping{Content: "story.text", ID: "slug", Metadata: []string{"genre"}}, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(job.DocumentsStored).To(Equal(2))
+
+		md, err := localRecall.GetEntryMetadata(TestCollection, "spider")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(md).To(HaveKeyWithValue("genre", "fable"))
+		expectContent(TestCollection, "heist", "the Great Pigeon Heist", localRecall)
+	})
+
+	It("should store and update entry metadata", func() {
+		err := localRecall.CreateCollection(TestCollection)
+		Expect(err).ToNot(HaveOccurred())
+
+		dir, err := os.MkdirTemp("", "temp-content")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "pigeons.txt")
+		Expect(os.WriteFile(path, []byte(story1), 0644)).To(Succeed())
+
+		_, err = localRecall.StoreWithMetadata(TestCollection, path, map[string]string{"author": "bertie", "year": "2024"})
+		Expect(err).ToNot(HaveOccurred())
+
+		md, err := localRecall.GetEntryMetadata(TestCollection, "pigeons.txt")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(md).To(HaveKeyWithValue("author", "bertie"))
+		Expect(md).To(HaveKey("created_at"))
+
+		md, err = localRecall.UpdateEntryMetadata(TestCollection, "pigeons.txt", map[string]string{"author": "gertrude"}, "year")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(md).To(HaveKeyWithValue("author", "gertrude"))
+		Expect(md).ToNot(HaveKey("year"))
+
+		docs, err := localRecall.Search(TestCollection, "heist", 1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(docs).ToNot(BeEmpty())
+		Expect(docs[0].Metadata).To(HaveKeyWithValue("author", "gertrude"))
+
+		_, err = localRecall.UpdateEntryMetadata(TestCollection, "pigeons.txt", map[string]string{"source": "elsewhere"})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should store and update collection config", func() {
+		overlap := 10
+		err := localRecall.CreateCollectionWithConfig(TestCollection, &types.CollectionConfig{ChunkSize: 300, ChunkOverlap: &overlap})
+		Expect(err).ToNot(HaveOccurred())
+
+		tempContent(story1, localRecall)
+
+		stored, effective, err := localRecall.GetCollectionConfig(TestCollection)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stored).ToNot(BeNil())
+		Expect(stored.ChunkSize).To(Equal(300))
+		Expect(effective.ChunkSize).To(Equal(300))
+		Expect(effective.Overlap()).To(Equal(10))
+		Expect(effective.Engine).ToNot(BeEmpty())
+
+		stored, effective, err = localRecall.UpdateCollectionConfig(TestCollection, types.CollectionConfig{ChunkSize: 1000})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stored.ChunkSize).To(Equal(1000))
+		Expect(effective.Overlap()).To(Equal(10))
+
+		_, _, err = localRecall.UpdateCollectionConfig(TestCollection, types.CollectionConfig{Engine: "nope"})
+		Expect(err).To(HaveOccurred())
+
+		expectContent(TestCollection, "heist", "the Great Pigeon Heist", localRecall)
+	})
 })
 
 func tempContent(content string, localRecall *client.Client) string {
@@ -72,6 +72,14 @@ var _ = Describe("SourceManager", func() {
 			Expect(sources[0].URL).To(Equal("https://example.com"))
 		})
 
+		It("should unregister a collection", func() {
+			sourceManager.RegisterCollection(TestCollection, kb)
+			sourceManager.UnregisterCollection(TestCollection)
+
+			err := sourceManager.AddSource(TestCollection, "https://example.com", DefaultUpdateInterval)
+			Expect(err).To(HaveOccurred())
+		})
+
 		It("should load existing sources when registering a collection", func() {
 			// Add a source to the collection first
 			source := rag.ExternalSource{